@@ -16,28 +16,82 @@ type Bookmark struct {
 	PostID     pgtype.Int4
 }
 
+type CalendarToken struct {
+	UserID       int32
+	Token        pgtype.UUID
+	CreationDate pgtype.Timestamptz
+}
+
 type Category struct {
 	CategoryID  int32
 	Name        string
 	Description pgtype.Text
 }
 
+type CategoryFollow struct {
+	UserID       int32
+	CategoryID   int32
+	CreationDate pgtype.Timestamptz
+}
+
 type Comment struct {
+	CommentID       int32
+	Content         string
+	ContentHtml     pgtype.Text
+	CreationDate    pgtype.Timestamptz
+	PostID          pgtype.Int4
+	UserID          pgtype.Int4
+	Score           int32
+	ParentCommentID pgtype.Int4
+	RootCommentID   pgtype.Int4
+	Depth           int32
+	IsDeleted       bool
+}
+
+type CommentVote struct {
 	CommentID    int32
-	Content      string
+	UserID       int32
+	Value        int16
 	CreationDate pgtype.Timestamptz
-	PostID       pgtype.Int4
-	UserID       pgtype.Int4
+}
+
+type EmailPreference struct {
+	UserID           int32
+	DigestFrequency  string
+	UnsubscribeToken pgtype.UUID
+	LastDigestDate   pgtype.Timestamptz
 }
 
 type Event struct {
-	EventID       int32
-	Title         string
-	Description   pgtype.Text
-	EventDate     pgtype.Timestamptz
-	MeetingPoint  string
-	RouteID       pgtype.Int4
-	CreatorUserID pgtype.Int4
+	EventID        int32
+	Title          string
+	Description    pgtype.Text
+	EventDate      pgtype.Timestamptz
+	MeetingPoint   string
+	RouteID        pgtype.Int4
+	CreatorUserID  pgtype.Int4
+	Capacity       pgtype.Int4
+	RecurrenceRule pgtype.Text
+	TimeZone       string
+}
+
+type EventOccurrence struct {
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+	IsCancelled    bool
+	Title          pgtype.Text
+	Description    pgtype.Text
+	EventDate      pgtype.Timestamptz
+	MeetingPoint   pgtype.Text
+}
+
+type EventReminder struct {
+	ReminderID     int32
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+	UserID         int32
+	ReminderType   string
+	SentDate       pgtype.Timestamptz
 }
 
 type ForumModerationLog struct {
@@ -52,23 +106,58 @@ type ForumModerationLog struct {
 }
 
 type Notification struct {
-	NotificationID int32
-	UserID         pgtype.Int4
-	Content        string
-	CreationDate   pgtype.Timestamptz
-	IsRead         pgtype.Bool
+	NotificationID   int32
+	UserID           pgtype.Int4
+	Content          string
+	CreationDate     pgtype.Timestamptz
+	IsRead           pgtype.Bool
+	NotificationType string
+	ActorUserID      pgtype.Int4
+	PostID           pgtype.Int4
+	CommentID        pgtype.Int4
+	MessageID        pgtype.Int4
+	EventID          pgtype.Int4
 }
 
 type Post struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+}
+
+type PostRevision struct {
+	RevisionID      int32
 	PostID          int32
+	RevisionNumber  int32
 	Title           string
 	Content         string
-	CreationDate    pgtype.Timestamptz
-	UserID          pgtype.Int4
-	IsSticky        pgtype.Bool
-	IsLocked        pgtype.Bool
 	PostCategoryID  pgtype.Int4
 	AdditionalNotes pgtype.Text
+	EditorUserID    pgtype.Int4
+	CreationDate    pgtype.Timestamptz
+}
+
+type PostVote struct {
+	PostID       int32
+	UserID       int32
+	Value        int16
+	CreationDate pgtype.Timestamptz
+}
+
+type PostWatch struct {
+	UserID       int32
+	PostID       int32
+	CreationDate pgtype.Timestamptz
 }
 
 type PrivateMessage struct {
@@ -78,6 +167,31 @@ type PrivateMessage struct {
 	ReceiverUserID pgtype.Int4
 	SentDate       pgtype.Timestamptz
 	IsRead         pgtype.Bool
+	DeliveredDate  pgtype.Timestamptz
+}
+
+type Report struct {
+	ReportID         int32
+	TargetType       string
+	TargetID         int32
+	TargetUserID     pgtype.Int4
+	Status           string
+	SubmissionCount  int32
+	CreationDate     pgtype.Timestamptz
+	LastReportedDate pgtype.Timestamptz
+	ReviewerUserID   pgtype.Int4
+	ResolutionAction pgtype.Text
+	ResolutionNote   pgtype.Text
+	ResolutionDate   pgtype.Timestamptz
+}
+
+type ReportSubmission struct {
+	SubmissionID   int32
+	ReportID       int32
+	ReporterUserID pgtype.Int4
+	ReasonCategory string
+	Note           pgtype.Text
+	CreationDate   pgtype.Timestamptz
 }
 
 type Role struct {
@@ -97,25 +211,44 @@ type Route struct {
 	UserID        pgtype.Int4
 }
 
+type RoutePoint struct {
+	RouteID    int32
+	PointIndex int32
+	Latitude   float64
+	Longitude  float64
+	Elevation  pgtype.Float8
+}
+
 type Rsvp struct {
-	RsvpID     int32
-	EventID    pgtype.Int4
-	UserID     pgtype.Int4
-	RsvpStatus string
-	RsvpDate   pgtype.Timestamptz
+	RsvpID         int32
+	EventID        pgtype.Int4
+	UserID         pgtype.Int4
+	RsvpStatus     string
+	RsvpDate       pgtype.Timestamptz
+	OccurrenceDate pgtype.Timestamptz
+}
+
+type StreamTicket struct {
+	Ticket     pgtype.UUID
+	SessionID  pgtype.UUID
+	ExpiryDate pgtype.Timestamptz
 }
 
 type User struct {
-	UserID           int32
-	Username         string
-	Email            string
-	PasswordHash     string
-	RegistrationDate pgtype.Timestamptz
-	ProfilePicture   pgtype.Text
-	Biography        pgtype.Text
-	LastLoginDate    pgtype.Timestamptz
-	IsActive         pgtype.Bool
-	RoleID           pgtype.Int4
+	UserID               int32
+	Username             string
+	Email                string
+	PasswordHash         string
+	RegistrationDate     pgtype.Timestamptz
+	ProfilePicture       pgtype.Text
+	Biography            pgtype.Text
+	LastLoginDate        pgtype.Timestamptz
+	IsActive             pgtype.Bool
+	SuspendedUntil       pgtype.Timestamptz
+	SuspensionReason     pgtype.Text
+	EmailVerifiedAt      pgtype.Timestamptz
+	VerificationSentDate pgtype.Timestamptz
+	RoleID               pgtype.Int4
 }
 
 type UserSession struct {
@@ -13,15 +13,207 @@ import (
 )
 
 const activateUser = `-- name: ActivateUser :exec
-UPDATE users SET is_active = TRUE WHERE user_id = $1
+UPDATE users SET is_active = TRUE, suspended_until = NULL, suspension_reason = NULL WHERE user_id = $1
 `
 
-// Activate a user
+// Activate a user, lifting any suspension or ban
 func (q *Queries) ActivateUser(ctx context.Context, userID int32) error {
 	_, err := q.db.Exec(ctx, activateUser, userID)
 	return err
 }
 
+const addCommentScore = `-- name: AddCommentScore :one
+UPDATE comments SET score = score + $1::int WHERE comment_id = $2 RETURNING score
+`
+
+type AddCommentScoreParams struct {
+	Delta     int32
+	CommentID int32
+}
+
+// Add to a comment's score, returning the new score
+func (q *Queries) AddCommentScore(ctx context.Context, arg AddCommentScoreParams) (int32, error) {
+	row := q.db.QueryRow(ctx, addCommentScore, arg.Delta, arg.CommentID)
+	var score int32
+	err := row.Scan(&score)
+	return score, err
+}
+
+const addPostScore = `-- name: AddPostScore :one
+UPDATE posts SET score = score + $1::int WHERE post_id = $2 RETURNING score
+`
+
+type AddPostScoreParams struct {
+	Delta  int32
+	PostID int32
+}
+
+// Add to a post's score, returning the new score
+func (q *Queries) AddPostScore(ctx context.Context, arg AddPostScoreParams) (int32, error) {
+	row := q.db.QueryRow(ctx, addPostScore, arg.Delta, arg.PostID)
+	var score int32
+	err := row.Scan(&score)
+	return score, err
+}
+
+const claimDigest = `-- name: ClaimDigest :one
+INSERT INTO email_preferences (user_id, unsubscribe_token, last_digest_date)
+VALUES ($1::int, $2::uuid, $3::timestamptz)
+ON CONFLICT (user_id) DO UPDATE SET last_digest_date = EXCLUDED.last_digest_date
+WHERE email_preferences.last_digest_date IS NOT DISTINCT FROM $4::timestamptz
+RETURNING user_id, digest_frequency, unsubscribe_token, last_digest_date
+`
+
+type ClaimDigestParams struct {
+	UserID             int32
+	UnsubscribeToken   pgtype.UUID
+	Now                pgtype.Timestamptz
+	PreviousDigestDate pgtype.Timestamptz
+}
+
+// Record that a user's digest is being sent, unless another server instance already did since it was found due.
+// Returns no rows when the digest was claimed elsewhere.
+func (q *Queries) ClaimDigest(ctx context.Context, arg ClaimDigestParams) (EmailPreference, error) {
+	row := q.db.QueryRow(ctx, claimDigest,
+		arg.UserID,
+		arg.UnsubscribeToken,
+		arg.Now,
+		arg.PreviousDigestDate,
+	)
+	var i EmailPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.DigestFrequency,
+		&i.UnsubscribeToken,
+		&i.LastDigestDate,
+	)
+	return i, err
+}
+
+const claimVerificationEmail = `-- name: ClaimVerificationEmail :execrows
+
+UPDATE users SET verification_sent_date = $1::timestamptz
+WHERE user_id = $2::int AND email_verified_at IS NULL
+AND (verification_sent_date IS NULL OR verification_sent_date <= $3::timestamptz)
+`
+
+type ClaimVerificationEmailParams struct {
+	Now            pgtype.Timestamptz
+	UserID         int32
+	ThrottleBefore pgtype.Timestamptz
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Record that a verification email is being sent to an unverified user, unless one was sent since throttle_before
+func (q *Queries) ClaimVerificationEmail(ctx context.Context, arg ClaimVerificationEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimVerificationEmail, arg.Now, arg.UserID, arg.ThrottleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countCommentReplies = `-- name: CountCommentReplies :one
+SELECT COUNT(*) FROM comments WHERE parent_comment_id = $1
+`
+
+// Count the direct replies to a comment
+func (q *Queries) CountCommentReplies(ctx context.Context, parentCommentID pgtype.Int4) (int64, error) {
+	row := q.db.QueryRow(ctx, countCommentReplies, parentCommentID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRSVPsByEventIDAndStatus = `-- name: CountRSVPsByEventIDAndStatus :one
+SELECT COUNT(*) FROM rsvps WHERE event_id = $1 AND rsvp_status = $2 AND occurrence_date IS NOT DISTINCT FROM $3::timestamptz
+`
+
+type CountRSVPsByEventIDAndStatusParams struct {
+	EventID        pgtype.Int4
+	RsvpStatus     string
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Count the RSVPs for a specific event occurrence with a specific status
+func (q *Queries) CountRSVPsByEventIDAndStatus(ctx context.Context, arg CountRSVPsByEventIDAndStatusParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRSVPsByEventIDAndStatus, arg.EventID, arg.RsvpStatus, arg.OccurrenceDate)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRSVPsByEventIDGroupedByStatus = `-- name: CountRSVPsByEventIDGroupedByStatus :many
+SELECT rsvp_status, COUNT(*) FROM rsvps WHERE event_id = $1 AND occurrence_date IS NOT DISTINCT FROM $2::timestamptz GROUP BY rsvp_status
+`
+
+type CountRSVPsByEventIDGroupedByStatusParams struct {
+	EventID        pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+}
+
+type CountRSVPsByEventIDGroupedByStatusRow struct {
+	RsvpStatus string
+	Count      int64
+}
+
+// Count the RSVPs for a specific event occurrence by status
+func (q *Queries) CountRSVPsByEventIDGroupedByStatus(ctx context.Context, arg CountRSVPsByEventIDGroupedByStatusParams) ([]CountRSVPsByEventIDGroupedByStatusRow, error) {
+	rows, err := q.db.Query(ctx, countRSVPsByEventIDGroupedByStatus, arg.EventID, arg.OccurrenceDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRSVPsByEventIDGroupedByStatusRow
+	for rows.Next() {
+		var i CountRSVPsByEventIDGroupedByStatusRow
+		if err := rows.Scan(&i.RsvpStatus, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countRoutePointsByRouteID = `-- name: CountRoutePointsByRouteID :one
+SELECT COUNT(*) FROM route_points WHERE route_id = $1
+`
+
+// Count the track points of a route
+func (q *Queries) CountRoutePointsByRouteID(ctx context.Context, routeID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countRoutePointsByRouteID, routeID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnreadNotificationsByUserId = `-- name: CountUnreadNotificationsByUserId :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND is_read = FALSE
+`
+
+// Count the unread notifications of a specific user
+func (q *Queries) CountUnreadNotificationsByUserId(ctx context.Context, userID pgtype.Int4) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnreadNotificationsByUserId, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnreadPrivateMessages = `-- name: CountUnreadPrivateMessages :one
+SELECT COUNT(*) FROM private_messages WHERE receiver_user_id = $1 AND is_read = FALSE
+`
+
+// Count the messages a user has received and not read
+func (q *Queries) CountUnreadPrivateMessages(ctx context.Context, receiverUserID pgtype.Int4) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnreadPrivateMessages, receiverUserID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createBookmark = `-- name: CreateBookmark :exec
 
 INSERT INTO bookmarks (user_id, post_id) VALUES ($1, $2)
@@ -58,54 +250,143 @@ func (q *Queries) CreateCategory(ctx context.Context, arg CreateCategoryParams)
 }
 
 const createComment = `-- name: CreateComment :one
-INSERT INTO comments (content, post_id, user_id) VALUES ($1, $2, $3) RETURNING comment_id, content, creation_date, post_id, user_id
+INSERT INTO comments (content, content_html, post_id, user_id, parent_comment_id, root_comment_id, depth)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted
 `
 
 type CreateCommentParams struct {
-	Content string
-	PostID  pgtype.Int4
-	UserID  pgtype.Int4
+	Content         string
+	ContentHtml     pgtype.Text
+	PostID          pgtype.Int4
+	UserID          pgtype.Int4
+	ParentCommentID pgtype.Int4
+	RootCommentID   pgtype.Int4
+	Depth           int32
 }
 
 // Create a new comment
 func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (Comment, error) {
-	row := q.db.QueryRow(ctx, createComment, arg.Content, arg.PostID, arg.UserID)
+	row := q.db.QueryRow(ctx, createComment,
+		arg.Content,
+		arg.ContentHtml,
+		arg.PostID,
+		arg.UserID,
+		arg.ParentCommentID,
+		arg.RootCommentID,
+		arg.Depth,
+	)
 	var i Comment
 	err := row.Scan(
 		&i.CommentID,
 		&i.Content,
+		&i.ContentHtml,
 		&i.CreationDate,
 		&i.PostID,
 		&i.UserID,
+		&i.Score,
+		&i.ParentCommentID,
+		&i.RootCommentID,
+		&i.Depth,
+		&i.IsDeleted,
 	)
 	return i, err
 }
 
-const createEvent = `-- name: CreateEvent :exec
-INSERT INTO events (title, description, event_date, meeting_point, route_id, creator_user_id)
-VALUES ($1, $2, $3, $4, $5, $6)
+const createEmailPreferences = `-- name: CreateEmailPreferences :one
+INSERT INTO email_preferences (user_id, unsubscribe_token) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING user_id, digest_frequency, unsubscribe_token, last_digest_date
+`
+
+type CreateEmailPreferencesParams struct {
+	UserID           int32
+	UnsubscribeToken pgtype.UUID
+}
+
+// Create a user's email preferences with the defaults, returning the existing ones if they already have them
+func (q *Queries) CreateEmailPreferences(ctx context.Context, arg CreateEmailPreferencesParams) (EmailPreference, error) {
+	row := q.db.QueryRow(ctx, createEmailPreferences, arg.UserID, arg.UnsubscribeToken)
+	var i EmailPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.DigestFrequency,
+		&i.UnsubscribeToken,
+		&i.LastDigestDate,
+	)
+	return i, err
+}
+
+const createEvent = `-- name: CreateEvent :one
+INSERT INTO events (title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone
 `
 
 type CreateEventParams struct {
-	Title         string
-	Description   pgtype.Text
-	EventDate     pgtype.Timestamptz
-	MeetingPoint  string
-	RouteID       pgtype.Int4
-	CreatorUserID pgtype.Int4
+	Title          string
+	Description    pgtype.Text
+	EventDate      pgtype.Timestamptz
+	MeetingPoint   string
+	RouteID        pgtype.Int4
+	CreatorUserID  pgtype.Int4
+	Capacity       pgtype.Int4
+	RecurrenceRule pgtype.Text
+	TimeZone       string
 }
 
 // Create a new event
-func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) error {
-	_, err := q.db.Exec(ctx, createEvent,
+func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (Event, error) {
+	row := q.db.QueryRow(ctx, createEvent,
 		arg.Title,
 		arg.Description,
 		arg.EventDate,
 		arg.MeetingPoint,
 		arg.RouteID,
 		arg.CreatorUserID,
+		arg.Capacity,
+		arg.RecurrenceRule,
+		arg.TimeZone,
 	)
-	return err
+	var i Event
+	err := row.Scan(
+		&i.EventID,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+		&i.RouteID,
+		&i.CreatorUserID,
+		&i.Capacity,
+		&i.RecurrenceRule,
+		&i.TimeZone,
+	)
+	return i, err
+}
+
+const createEventReminder = `-- name: CreateEventReminder :execrows
+INSERT INTO event_reminders (event_id, occurrence_date, user_id, reminder_type) VALUES ($1, $2, $3, $4)
+ON CONFLICT (event_id, occurrence_date, user_id, reminder_type) DO NOTHING
+`
+
+type CreateEventReminderParams struct {
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+	UserID         int32
+	ReminderType   string
+}
+
+// Record that a reminder is being sent, affecting no rows if it has already been sent
+func (q *Queries) CreateEventReminder(ctx context.Context, arg CreateEventReminderParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createEventReminder,
+		arg.EventID,
+		arg.OccurrenceDate,
+		arg.UserID,
+		arg.ReminderType,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const createLog = `-- name: CreateLog :exec
@@ -136,15 +417,62 @@ func (q *Queries) CreateLog(ctx context.Context, arg CreateLogParams) error {
 	return err
 }
 
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications (user_id, content, notification_type, actor_user_id, post_id, comment_id, message_id, event_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING notification_id, user_id, content, creation_date, is_read, notification_type, actor_user_id, post_id, comment_id, message_id, event_id
+`
+
+type CreateNotificationParams struct {
+	UserID           pgtype.Int4
+	Content          string
+	NotificationType string
+	ActorUserID      pgtype.Int4
+	PostID           pgtype.Int4
+	CommentID        pgtype.Int4
+	MessageID        pgtype.Int4
+	EventID          pgtype.Int4
+}
+
+// Create a new notification
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
+	row := q.db.QueryRow(ctx, createNotification,
+		arg.UserID,
+		arg.Content,
+		arg.NotificationType,
+		arg.ActorUserID,
+		arg.PostID,
+		arg.CommentID,
+		arg.MessageID,
+		arg.EventID,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.NotificationID,
+		&i.UserID,
+		&i.Content,
+		&i.CreationDate,
+		&i.IsRead,
+		&i.NotificationType,
+		&i.ActorUserID,
+		&i.PostID,
+		&i.CommentID,
+		&i.MessageID,
+		&i.EventID,
+	)
+	return i, err
+}
+
 const createPost = `-- name: CreatePost :exec
 
-INSERT INTO posts (title, content, user_id, post_category_id, additional_notes)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO posts (title, content, content_html, user_id, post_category_id, additional_notes)
+VALUES ($1, $2, $3, $4, $5, $6)
 `
 
 type CreatePostParams struct {
 	Title           string
 	Content         string
+	ContentHtml     pgtype.Text
 	UserID          pgtype.Int4
 	PostCategoryID  pgtype.Int4
 	AdditionalNotes pgtype.Text
@@ -155,6 +483,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
 	_, err := q.db.Exec(ctx, createPost,
 		arg.Title,
 		arg.Content,
+		arg.ContentHtml,
 		arg.UserID,
 		arg.PostCategoryID,
 		arg.AdditionalNotes,
@@ -162,10 +491,55 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
 	return err
 }
 
-const createPrivateMessage = `-- name: CreatePrivateMessage :exec
+const createPostRevision = `-- name: CreatePostRevision :one
+INSERT INTO post_revisions (post_id, revision_number, title, content, post_category_id, additional_notes, editor_user_id, creation_date)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING revision_id, post_id, revision_number, title, content, post_category_id, additional_notes, editor_user_id, creation_date
+`
+
+type CreatePostRevisionParams struct {
+	PostID          int32
+	RevisionNumber  int32
+	Title           string
+	Content         string
+	PostCategoryID  pgtype.Int4
+	AdditionalNotes pgtype.Text
+	EditorUserID    pgtype.Int4
+	CreationDate    pgtype.Timestamptz
+}
+
+// Record a version of a post
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) (PostRevision, error) {
+	row := q.db.QueryRow(ctx, createPostRevision,
+		arg.PostID,
+		arg.RevisionNumber,
+		arg.Title,
+		arg.Content,
+		arg.PostCategoryID,
+		arg.AdditionalNotes,
+		arg.EditorUserID,
+		arg.CreationDate,
+	)
+	var i PostRevision
+	err := row.Scan(
+		&i.RevisionID,
+		&i.PostID,
+		&i.RevisionNumber,
+		&i.Title,
+		&i.Content,
+		&i.PostCategoryID,
+		&i.AdditionalNotes,
+		&i.EditorUserID,
+		&i.CreationDate,
+	)
+	return i, err
+}
+
+const createPrivateMessage = `-- name: CreatePrivateMessage :one
 
 INSERT INTO private_messages (content, sender_user_id, receiver_user_id)
 VALUES ($1, $2, $3)
+RETURNING message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date
 `
 
 type CreatePrivateMessageParams struct {
@@ -176,24 +550,70 @@ type CreatePrivateMessageParams struct {
 
 // ----------------------------------------------------------------------------------------------------------------------
 // Create a new private message
-func (q *Queries) CreatePrivateMessage(ctx context.Context, arg CreatePrivateMessageParams) error {
-	_, err := q.db.Exec(ctx, createPrivateMessage, arg.Content, arg.SenderUserID, arg.ReceiverUserID)
-	return err
+func (q *Queries) CreatePrivateMessage(ctx context.Context, arg CreatePrivateMessageParams) (PrivateMessage, error) {
+	row := q.db.QueryRow(ctx, createPrivateMessage, arg.Content, arg.SenderUserID, arg.ReceiverUserID)
+	var i PrivateMessage
+	err := row.Scan(
+		&i.MessageID,
+		&i.Content,
+		&i.SenderUserID,
+		&i.ReceiverUserID,
+		&i.SentDate,
+		&i.IsRead,
+		&i.DeliveredDate,
+	)
+	return i, err
 }
 
-const createRSVP = `-- name: CreateRSVP :exec
-INSERT INTO rsvps (event_id, user_id) VALUES ($1, $2)
+const createRSVP = `-- name: CreateRSVP :one
+INSERT INTO rsvps (event_id, user_id, rsvp_status) VALUES ($1, $2, $3) RETURNING rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date
 `
 
 type CreateRSVPParams struct {
-	EventID pgtype.Int4
-	UserID  pgtype.Int4
+	EventID    pgtype.Int4
+	UserID     pgtype.Int4
+	RsvpStatus string
 }
 
 // Create a new RSVP
-func (q *Queries) CreateRSVP(ctx context.Context, arg CreateRSVPParams) error {
-	_, err := q.db.Exec(ctx, createRSVP, arg.EventID, arg.UserID)
-	return err
+func (q *Queries) CreateRSVP(ctx context.Context, arg CreateRSVPParams) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, createRSVP, arg.EventID, arg.UserID, arg.RsvpStatus)
+	var i Rsvp
+	err := row.Scan(
+		&i.RsvpID,
+		&i.EventID,
+		&i.UserID,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
+	)
+	return i, err
+}
+
+const createReportSubmission = `-- name: CreateReportSubmission :execrows
+INSERT INTO report_submissions (report_id, reporter_user_id, reason_category, note) VALUES ($1, $2, $3, $4)
+ON CONFLICT (report_id, reporter_user_id) DO NOTHING
+`
+
+type CreateReportSubmissionParams struct {
+	ReportID       int32
+	ReporterUserID pgtype.Int4
+	ReasonCategory string
+	Note           pgtype.Text
+}
+
+// Add a user's report to a report, doing nothing if they have already reported it
+func (q *Queries) CreateReportSubmission(ctx context.Context, arg CreateReportSubmissionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createReportSubmission,
+		arg.ReportID,
+		arg.ReporterUserID,
+		arg.ReasonCategory,
+		arg.Note,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const createRole = `-- name: CreateRole :exec
@@ -208,9 +628,9 @@ func (q *Queries) CreateRole(ctx context.Context, roleName string) error {
 	return err
 }
 
-const createRoute = `-- name: CreateRoute :exec
+const createRoute = `-- name: CreateRoute :one
 INSERT INTO routes (name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id
 `
 
 type CreateRouteParams struct {
@@ -225,8 +645,8 @@ type CreateRouteParams struct {
 }
 
 // Create a new route
-func (q *Queries) CreateRoute(ctx context.Context, arg CreateRouteParams) error {
-	_, err := q.db.Exec(ctx, createRoute,
+func (q *Queries) CreateRoute(ctx context.Context, arg CreateRouteParams) (Route, error) {
+	row := q.db.QueryRow(ctx, createRoute,
 		arg.Name,
 		arg.Description,
 		arg.StartLocation,
@@ -236,12 +656,70 @@ func (q *Queries) CreateRoute(ctx context.Context, arg CreateRouteParams) error
 		arg.RouteMapLink,
 		arg.UserID,
 	)
+	var i Route
+	err := row.Scan(
+		&i.RouteID,
+		&i.Name,
+		&i.Description,
+		&i.StartLocation,
+		&i.EndLocation,
+		&i.Distance,
+		&i.ElevationGain,
+		&i.RouteMapLink,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const createRoutePoints = `-- name: CreateRoutePoints :exec
+
+INSERT INTO route_points (route_id, point_index, latitude, longitude, elevation)
+SELECT $1::int, p.point_index, p.latitude, p.longitude, NULLIF(p.elevation, 'NaN')
+FROM unnest($2::int[], $3::float8[], $4::float8[], $5::float8[])
+AS p(point_index, latitude, longitude, elevation)
+`
+
+type CreateRoutePointsParams struct {
+	RouteID      int32
+	PointIndexes []int32
+	Latitudes    []float64
+	Longitudes   []float64
+	Elevations   []float64
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Bulk insert the track points of a route, NaN elevations are stored as NULL
+func (q *Queries) CreateRoutePoints(ctx context.Context, arg CreateRoutePointsParams) error {
+	_, err := q.db.Exec(ctx, createRoutePoints,
+		arg.RouteID,
+		arg.PointIndexes,
+		arg.Latitudes,
+		arg.Longitudes,
+		arg.Elevations,
+	)
+	return err
+}
+
+const createStreamTicket = `-- name: CreateStreamTicket :exec
+INSERT INTO stream_tickets (ticket, session_id, expiry_date) VALUES ($1, $2, $3)
+`
+
+type CreateStreamTicketParams struct {
+	Ticket     pgtype.UUID
+	SessionID  pgtype.UUID
+	ExpiryDate pgtype.Timestamptz
+}
+
+// Create a single-use ticket opening a stream for a session
+func (q *Queries) CreateStreamTicket(ctx context.Context, arg CreateStreamTicketParams) error {
+	_, err := q.db.Exec(ctx, createStreamTicket, arg.Ticket, arg.SessionID, arg.ExpiryDate)
 	return err
 }
 
-const createUser = `-- name: CreateUser :exec
+const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, email, password_hash, profile_picture, biography, role_id)
 VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING user_id
 `
 
 type CreateUserParams struct {
@@ -253,9 +731,9 @@ type CreateUserParams struct {
 	RoleID         pgtype.Int4
 }
 
-// Create a new user
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
-	_, err := q.db.Exec(ctx, createUser,
+// Create a new user, whose email address is unverified
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int32, error) {
+	row := q.db.QueryRow(ctx, createUser,
 		arg.Username,
 		arg.Email,
 		arg.PasswordHash,
@@ -263,7 +741,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
 		arg.Biography,
 		arg.RoleID,
 	)
-	return err
+	var userID int32
+	err := row.Scan(&userID)
+	return userID, err
 }
 
 const createUserSession = `-- name: CreateUserSession :one
@@ -365,22 +845,112 @@ func (q *Queries) DeleteCommentByCommentIdAndUserId(ctx context.Context, arg Del
 	return err
 }
 
-const deleteEvent = `-- name: DeleteEvent :exec
-DELETE FROM events WHERE event_id = $1
+const deleteCommentVote = `-- name: DeleteCommentVote :exec
+DELETE FROM comment_votes WHERE comment_id = $1 AND user_id = $2
 `
 
-// Delete a specific event
-func (q *Queries) DeleteEvent(ctx context.Context, eventID int32) error {
-	_, err := q.db.Exec(ctx, deleteEvent, eventID)
+type DeleteCommentVoteParams struct {
+	CommentID int32
+	UserID    int32
+}
+
+// Remove a user's vote on a comment
+func (q *Queries) DeleteCommentVote(ctx context.Context, arg DeleteCommentVoteParams) error {
+	_, err := q.db.Exec(ctx, deleteCommentVote, arg.CommentID, arg.UserID)
+	return err
+}
+
+const deleteEvent = `-- name: DeleteEvent :exec
+DELETE FROM events WHERE event_id = $1
+`
+
+// Delete a specific event
+func (q *Queries) DeleteEvent(ctx context.Context, eventID int32) error {
+	_, err := q.db.Exec(ctx, deleteEvent, eventID)
+	return err
+}
+
+const deleteEventByEventIdAndUserId = `-- name: DeleteEventByEventIdAndUserId :execrows
+DELETE FROM events WHERE event_id = $1 AND creator_user_id = $2
+`
+
+type DeleteEventByEventIdAndUserIdParams struct {
+	EventID       int32
+	CreatorUserID pgtype.Int4
+}
+
+// Delete a specific event by its ID and the ID of the user who created it
+func (q *Queries) DeleteEventByEventIdAndUserId(ctx context.Context, arg DeleteEventByEventIdAndUserIdParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteEventByEventIdAndUserId, arg.EventID, arg.CreatorUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteEventOccurrence = `-- name: DeleteEventOccurrence :execrows
+DELETE FROM event_occurrences WHERE event_id = $1 AND occurrence_date = $2
+`
+
+type DeleteEventOccurrenceParams struct {
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Restore a single occurrence of a recurring event to the series
+func (q *Queries) DeleteEventOccurrence(ctx context.Context, arg DeleteEventOccurrenceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteEventOccurrence, arg.EventID, arg.OccurrenceDate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredStreamTickets = `-- name: DeleteExpiredStreamTickets :exec
+DELETE FROM stream_tickets WHERE expiry_date <= $1::timestamptz
+`
+
+// Delete the stream tickets that expired without being used
+func (q *Queries) DeleteExpiredStreamTickets(ctx context.Context, now pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredStreamTickets, now)
+	return err
+}
+
+const deleteNotification = `-- name: DeleteNotification :exec
+DELETE FROM notifications WHERE notification_id = $1
+`
+
+// Delete a notification by its ID
+func (q *Queries) DeleteNotification(ctx context.Context, notificationID int32) error {
+	_, err := q.db.Exec(ctx, deleteNotification, notificationID)
 	return err
 }
 
-const deleteLog = `-- name: DeleteLog :exec
-DELETE FROM forum_moderation_log WHERE log_id = $1
+const deleteNotificationByNotificationIdAndUserId = `-- name: DeleteNotificationByNotificationIdAndUserId :execrows
+DELETE FROM notifications WHERE notification_id = $1 AND user_id = $2
+`
+
+type DeleteNotificationByNotificationIdAndUserIdParams struct {
+	NotificationID int32
+	UserID         pgtype.Int4
+}
+
+// Delete a notification of a specific user
+func (q *Queries) DeleteNotificationByNotificationIdAndUserId(ctx context.Context, arg DeleteNotificationByNotificationIdAndUserIdParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteNotificationByNotificationIdAndUserId, arg.NotificationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteNotificationsByUserId = `-- name: DeleteNotificationsByUserId :exec
+DELETE FROM notifications WHERE user_id = $1
 `
 
-func (q *Queries) DeleteLog(ctx context.Context, logID int32) error {
-	_, err := q.db.Exec(ctx, deleteLog, logID)
+// Delete all notifications for a specific user
+func (q *Queries) DeleteNotificationsByUserId(ctx context.Context, userID pgtype.Int4) error {
+	_, err := q.db.Exec(ctx, deleteNotificationsByUserId, userID)
 	return err
 }
 
@@ -407,6 +977,21 @@ func (q *Queries) DeletePostByPostIdAndUserId(ctx context.Context, arg DeletePos
 	return err
 }
 
+const deletePostVote = `-- name: DeletePostVote :exec
+DELETE FROM post_votes WHERE post_id = $1 AND user_id = $2
+`
+
+type DeletePostVoteParams struct {
+	PostID int32
+	UserID int32
+}
+
+// Remove a user's vote on a post
+func (q *Queries) DeletePostVote(ctx context.Context, arg DeletePostVoteParams) error {
+	_, err := q.db.Exec(ctx, deletePostVote, arg.PostID, arg.UserID)
+	return err
+}
+
 const deletePrivateMessage = `-- name: DeletePrivateMessage :exec
 DELETE FROM private_messages WHERE message_id = $1
 `
@@ -462,6 +1047,25 @@ func (q *Queries) DeleteRSVP(ctx context.Context, rsvpID int32) error {
 	return err
 }
 
+const deleteRSVPByEventIDAndUserID = `-- name: DeleteRSVPByEventIDAndUserID :execrows
+DELETE FROM rsvps WHERE event_id = $1 AND user_id = $2 AND occurrence_date IS NOT DISTINCT FROM $3::timestamptz
+`
+
+type DeleteRSVPByEventIDAndUserIDParams struct {
+	EventID        pgtype.Int4
+	UserID         pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Delete the RSVP of a specific user to a specific event occurrence
+func (q *Queries) DeleteRSVPByEventIDAndUserID(ctx context.Context, arg DeleteRSVPByEventIDAndUserIDParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRSVPByEventIDAndUserID, arg.EventID, arg.UserID, arg.OccurrenceDate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteRole = `-- name: DeleteRole :exec
 DELETE FROM roles WHERE role_id = $1
 `
@@ -482,6 +1086,34 @@ func (q *Queries) DeleteRoute(ctx context.Context, routeID int32) error {
 	return err
 }
 
+const deleteRouteByRouteIdAndUserId = `-- name: DeleteRouteByRouteIdAndUserId :execrows
+DELETE FROM routes WHERE route_id = $1 AND user_id = $2
+`
+
+type DeleteRouteByRouteIdAndUserIdParams struct {
+	RouteID int32
+	UserID  pgtype.Int4
+}
+
+// Delete a specific route by its ID and the ID of the user who created it
+func (q *Queries) DeleteRouteByRouteIdAndUserId(ctx context.Context, arg DeleteRouteByRouteIdAndUserIdParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRouteByRouteIdAndUserId, arg.RouteID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteRoutePointsByRouteID = `-- name: DeleteRoutePointsByRouteID :exec
+DELETE FROM route_points WHERE route_id = $1
+`
+
+// Delete the track points of a route
+func (q *Queries) DeleteRoutePointsByRouteID(ctx context.Context, routeID int32) error {
+	_, err := q.db.Exec(ctx, deleteRoutePointsByRouteID, routeID)
+	return err
+}
+
 const deleteUser = `-- name: DeleteUser :exec
 DELETE FROM users WHERE user_id = $1
 `
@@ -502,6 +1134,34 @@ func (q *Queries) DeleteUserSession(ctx context.Context, sessionID pgtype.UUID)
 	return err
 }
 
+const deleteUserSessionsByUserId = `-- name: DeleteUserSessionsByUserId :exec
+DELETE FROM user_sessions WHERE user_id = $1
+`
+
+// Delete all sessions of a user, logging them out everywhere
+func (q *Queries) DeleteUserSessionsByUserId(ctx context.Context, userID pgtype.Int4) error {
+	_, err := q.db.Exec(ctx, deleteUserSessionsByUserId, userID)
+	return err
+}
+
+const followCategory = `-- name: FollowCategory :exec
+
+INSERT INTO category_follows (user_id, category_id) VALUES ($1, $2)
+ON CONFLICT (user_id, category_id) DO NOTHING
+`
+
+type FollowCategoryParams struct {
+	UserID     int32
+	CategoryID int32
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Follow a category, doing nothing if the user already follows it
+func (q *Queries) FollowCategory(ctx context.Context, arg FollowCategoryParams) error {
+	_, err := q.db.Exec(ctx, followCategory, arg.UserID, arg.CategoryID)
+	return err
+}
+
 const getAllCategories = `-- name: GetAllCategories :many
 SELECT category_id, name, description FROM categories
 `
@@ -528,7 +1188,7 @@ func (q *Queries) GetAllCategories(ctx context.Context) ([]Category, error) {
 }
 
 const getAllComments = `-- name: GetAllComments :many
-SELECT comment_id, content, creation_date, post_id, user_id FROM comments ORDER BY creation_date DESC
+SELECT comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted FROM comments ORDER BY creation_date DESC
 `
 
 // Get all comments, ordered by creation date
@@ -544,9 +1204,15 @@ func (q *Queries) GetAllComments(ctx context.Context) ([]Comment, error) {
 		if err := rows.Scan(
 			&i.CommentID,
 			&i.Content,
+			&i.ContentHtml,
 			&i.CreationDate,
 			&i.PostID,
 			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
 		); err != nil {
 			return nil, err
 		}
@@ -559,18 +1225,74 @@ func (q *Queries) GetAllComments(ctx context.Context) ([]Comment, error) {
 }
 
 const getAllLogs = `-- name: GetAllLogs :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log
+SELECT forum_moderation_log.log_id, forum_moderation_log.action, forum_moderation_log.action_date, forum_moderation_log.moderator_user_id, forum_moderation_log.affected_user_id, forum_moderation_log.post_id, forum_moderation_log.comment_id, forum_moderation_log.reason, moderators.username AS moderator_username, affected_users.username AS affected_username
+FROM forum_moderation_log
+LEFT JOIN users AS moderators ON forum_moderation_log.moderator_user_id = moderators.user_id
+LEFT JOIN users AS affected_users ON forum_moderation_log.affected_user_id = affected_users.user_id
+WHERE ($1::text IS NULL OR forum_moderation_log.action = $1::text)
+AND ($2::int IS NULL OR forum_moderation_log.moderator_user_id = $2::int)
+AND ($3::int IS NULL OR forum_moderation_log.affected_user_id = $3::int)
+AND ($4::int IS NULL OR forum_moderation_log.post_id = $4::int)
+AND ($5::int IS NULL OR forum_moderation_log.comment_id = $5::int)
+AND ($6::text IS NULL OR forum_moderation_log.reason ILIKE '%' || $6::text || '%')
+AND ($7::timestamptz IS NULL OR forum_moderation_log.action_date >= $7::timestamptz)
+AND ($8::timestamptz IS NULL OR forum_moderation_log.action_date < $8::timestamptz)
+AND ($9::timestamptz IS NULL OR (forum_moderation_log.action_date, forum_moderation_log.log_id) < ($9::timestamptz, $10::int))
+ORDER BY forum_moderation_log.action_date DESC, forum_moderation_log.log_id DESC
+LIMIT $11
 `
 
-func (q *Queries) GetAllLogs(ctx context.Context) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getAllLogs)
+type GetAllLogsParams struct {
+	Action          pgtype.Text
+	ModeratorUserID pgtype.Int4
+	AffectedUserID  pgtype.Int4
+	PostID          pgtype.Int4
+	CommentID       pgtype.Int4
+	Reason          pgtype.Text
+	FromDate        pgtype.Timestamptz
+	ToDate          pgtype.Timestamptz
+	CursorDate      pgtype.Timestamptz
+	CursorID        pgtype.Int4
+	PageLimit       int32
+}
+
+type GetAllLogsRow struct {
+	LogID             int32
+	Action            string
+	ActionDate        pgtype.Timestamptz
+	ModeratorUserID   pgtype.Int4
+	AffectedUserID    pgtype.Int4
+	PostID            pgtype.Int4
+	CommentID         pgtype.Int4
+	Reason            pgtype.Text
+	ModeratorUsername pgtype.Text
+	AffectedUsername  pgtype.Text
+}
+
+// Get a page of moderation logs matching all of the given filters, newest first, starting after the cursor.
+// Each filter is skipped when NULL, so this is the only query reading the log. The log is append-only, so there are
+// no queries to update or delete entries.
+func (q *Queries) GetAllLogs(ctx context.Context, arg GetAllLogsParams) ([]GetAllLogsRow, error) {
+	rows, err := q.db.Query(ctx, getAllLogs,
+		arg.Action,
+		arg.ModeratorUserID,
+		arg.AffectedUserID,
+		arg.PostID,
+		arg.CommentID,
+		arg.Reason,
+		arg.FromDate,
+		arg.ToDate,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []GetAllLogsRow
 	for rows.Next() {
-		var i ForumModerationLog
+		var i GetAllLogsRow
 		if err := rows.Scan(
 			&i.LogID,
 			&i.Action,
@@ -580,6 +1302,8 @@ func (q *Queries) GetAllLogs(ctx context.Context) ([]ForumModerationLog, error)
 			&i.PostID,
 			&i.CommentID,
 			&i.Reason,
+			&i.ModeratorUsername,
+			&i.AffectedUsername,
 		); err != nil {
 			return nil, err
 		}
@@ -591,45 +1315,298 @@ func (q *Queries) GetAllLogs(ctx context.Context) ([]ForumModerationLog, error)
 	return items, nil
 }
 
-const getAllPosts = `-- name: GetAllPosts :many
-SELECT posts.post_id, posts.title, posts.content, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, users.username 
-FROM posts 
-INNER JOIN users ON posts.user_id = users.user_id 
-ORDER BY posts.creation_date DESC
+const getAllPostsActive = `-- name: GetAllPostsActive :many
+SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE ($1::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < ($2::boolean, $3::timestamptz, $1::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT $4
 `
 
-type GetAllPostsRow struct {
-	PostID          int32
-	Title           string
-	Content         string
-	CreationDate    pgtype.Timestamptz
-	UserID          pgtype.Int4
-	IsSticky        pgtype.Bool
-	IsLocked        pgtype.Bool
-	PostCategoryID  pgtype.Int4
-	AdditionalNotes pgtype.Text
-	Username        string
+type GetAllPostsActiveParams struct {
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorDate   pgtype.Timestamptz
+	PageLimit    int32
+}
+
+type GetAllPostsActiveRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	Username         string
+}
+
+// Get a page of posts, sticky posts first and then latest comment first, starting after the cursor
+func (q *Queries) GetAllPostsActive(ctx context.Context, arg GetAllPostsActiveParams) ([]GetAllPostsActiveRow, error) {
+	rows, err := q.db.Query(ctx, getAllPostsActive,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllPostsActiveRow
+	for rows.Next() {
+		var i GetAllPostsActiveRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllPostsHot = `-- name: GetAllPostsHot :many
+SELECT ranked.post_id, ranked.title, ranked.content, ranked.content_html, ranked.creation_date, ranked.user_id, ranked.is_sticky, ranked.is_locked, ranked.post_category_id, ranked.additional_notes, ranked.edited_at, ranked.score, ranked.last_activity_date, ranked.sort_key, users.username
+FROM (
+  SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+) AS ranked
+INNER JOIN users ON ranked.user_id = users.user_id
+WHERE ($1::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < ($2::boolean, $1::float8, $3::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT $4
+`
+
+type GetAllPostsHotParams struct {
+	CursorKey    pgtype.Float8
+	CursorSticky pgtype.Bool
+	CursorID     pgtype.Int4
+	PageLimit    int32
+}
+
+type GetAllPostsHotRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	SortKey          float64
+	Username         string
+}
+
+// Get a page of posts, sticky posts first and then by score decayed by age, starting after the cursor.
+// The key changes with time, so it cannot be indexed. It is computed once in a subquery and shared by the cursor
+// condition and the ordering.
+func (q *Queries) GetAllPostsHot(ctx context.Context, arg GetAllPostsHotParams) ([]GetAllPostsHotRow, error) {
+	rows, err := q.db.Query(ctx, getAllPostsHot,
+		arg.CursorKey,
+		arg.CursorSticky,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllPostsHotRow
+	for rows.Next() {
+		var i GetAllPostsHotRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+			&i.SortKey,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllPostsNew = `-- name: GetAllPostsNew :many
+SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE ($1::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < ($2::boolean, $3::timestamptz, $1::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT $4
+`
+
+type GetAllPostsNewParams struct {
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorDate   pgtype.Timestamptz
+	PageLimit    int32
+}
+
+type GetAllPostsNewRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	Username         string
+}
+
+// Get a page of posts, sticky posts first and then newest first, starting after the cursor.
+// The new, top and active sorts each have an index on the sticky flag, their key and the post ID, see schema.sql.
+func (q *Queries) GetAllPostsNew(ctx context.Context, arg GetAllPostsNewParams) ([]GetAllPostsNewRow, error) {
+	rows, err := q.db.Query(ctx, getAllPostsNew,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllPostsNewRow
+	for rows.Next() {
+		var i GetAllPostsNewRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllPostsTop = `-- name: GetAllPostsTop :many
+SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE ($1::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < ($2::boolean, $3::int, $1::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT $4
+`
+
+type GetAllPostsTopParams struct {
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorScore  pgtype.Int4
+	PageLimit    int32
+}
+
+type GetAllPostsTopRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	Username         string
 }
 
-func (q *Queries) GetAllPosts(ctx context.Context) ([]GetAllPostsRow, error) {
-	rows, err := q.db.Query(ctx, getAllPosts)
+// Get a page of posts, sticky posts first and then highest score first, starting after the cursor
+func (q *Queries) GetAllPostsTop(ctx context.Context, arg GetAllPostsTopParams) ([]GetAllPostsTopRow, error) {
+	rows, err := q.db.Query(ctx, getAllPostsTop,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorScore,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetAllPostsRow
+	var items []GetAllPostsTopRow
 	for rows.Next() {
-		var i GetAllPostsRow
+		var i GetAllPostsTopRow
 		if err := rows.Scan(
 			&i.PostID,
 			&i.Title,
 			&i.Content,
+			&i.ContentHtml,
 			&i.CreationDate,
 			&i.UserID,
 			&i.IsSticky,
 			&i.IsLocked,
 			&i.PostCategoryID,
 			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 			&i.Username,
 		); err != nil {
 			return nil, err
@@ -643,7 +1620,7 @@ func (q *Queries) GetAllPosts(ctx context.Context) ([]GetAllPostsRow, error) {
 }
 
 const getAllPrivateMessages = `-- name: GetAllPrivateMessages :many
-SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read FROM private_messages
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages
 `
 
 // Get all private messages
@@ -663,6 +1640,7 @@ func (q *Queries) GetAllPrivateMessages(ctx context.Context) ([]PrivateMessage,
 			&i.ReceiverUserID,
 			&i.SentDate,
 			&i.IsRead,
+			&i.DeliveredDate,
 		); err != nil {
 			return nil, err
 		}
@@ -676,8 +1654,16 @@ func (q *Queries) GetAllPrivateMessages(ctx context.Context) ([]PrivateMessage,
 
 const getAllUsers = `-- name: GetAllUsers :many
 SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users
+WHERE $1::int IS NULL OR user_id > $1::int
+ORDER BY user_id
+LIMIT $2
 `
 
+type GetAllUsersParams struct {
+	CursorID  pgtype.Int4
+	PageLimit int32
+}
+
 type GetAllUsersRow struct {
 	UserID           int32
 	Username         string
@@ -690,9 +1676,9 @@ type GetAllUsersRow struct {
 	RoleID           pgtype.Int4
 }
 
-// Get all users, no password_hash
-func (q *Queries) GetAllUsers(ctx context.Context) ([]GetAllUsersRow, error) {
-	rows, err := q.db.Query(ctx, getAllUsers)
+// Get a page of users ordered by user_id, starting after the cursor, no password_hash
+func (q *Queries) GetAllUsers(ctx context.Context, arg GetAllUsersParams) ([]GetAllUsersRow, error) {
+	rows, err := q.db.Query(ctx, getAllUsers, arg.CursorID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -783,6 +1769,32 @@ func (q *Queries) GetBookmarksByUser(ctx context.Context, userID pgtype.Int4) ([
 	return items, nil
 }
 
+const getCalendarTokenByToken = `-- name: GetCalendarTokenByToken :one
+SELECT user_id, token, creation_date FROM calendar_tokens WHERE token = $1
+`
+
+// Get a calendar feed token, used to find the user whose feed is requested
+func (q *Queries) GetCalendarTokenByToken(ctx context.Context, token pgtype.UUID) (CalendarToken, error) {
+	row := q.db.QueryRow(ctx, getCalendarTokenByToken, token)
+	var i CalendarToken
+	err := row.Scan(&i.UserID, &i.Token, &i.CreationDate)
+	return i, err
+}
+
+const getCalendarTokenByUserID = `-- name: GetCalendarTokenByUserID :one
+
+SELECT user_id, token, creation_date FROM calendar_tokens WHERE user_id = $1
+`
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Get the private calendar feed token of a user
+func (q *Queries) GetCalendarTokenByUserID(ctx context.Context, userID int32) (CalendarToken, error) {
+	row := q.db.QueryRow(ctx, getCalendarTokenByUserID, userID)
+	var i CalendarToken
+	err := row.Scan(&i.UserID, &i.Token, &i.CreationDate)
+	return i, err
+}
+
 const getCategory = `-- name: GetCategory :one
 SELECT category_id, name, description FROM categories WHERE category_id = $1
 `
@@ -797,7 +1809,7 @@ func (q *Queries) GetCategory(ctx context.Context, categoryID int32) (Category,
 
 const getComment = `-- name: GetComment :one
 
-SELECT comment_id, content, creation_date, post_id, user_id FROM comments WHERE comment_id = $1
+SELECT comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted FROM comments WHERE comment_id = $1
 `
 
 // ----------------------------------------------------------------------------------------------------------------------
@@ -808,47 +1820,128 @@ func (q *Queries) GetComment(ctx context.Context, commentID int32) (Comment, err
 	err := row.Scan(
 		&i.CommentID,
 		&i.Content,
+		&i.ContentHtml,
 		&i.CreationDate,
 		&i.PostID,
 		&i.UserID,
+		&i.Score,
+		&i.ParentCommentID,
+		&i.RootCommentID,
+		&i.Depth,
+		&i.IsDeleted,
 	)
 	return i, err
 }
 
-const getCommentsByPost = `-- name: GetCommentsByPost :many
-SELECT comments.comment_id, comments.content, comments.creation_date, comments.post_id, comments.user_id, users.username 
-FROM comments 
-INNER JOIN users ON comments.user_id = users.user_id 
-WHERE post_id = $1 
-ORDER BY comments.creation_date DESC
+const getCommentForUpdate = `-- name: GetCommentForUpdate :one
+SELECT comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted FROM comments WHERE comment_id = $1 FOR UPDATE
 `
 
-type GetCommentsByPostRow struct {
-	CommentID    int32
-	Content      string
-	CreationDate pgtype.Timestamptz
-	PostID       pgtype.Int4
-	UserID       pgtype.Int4
-	Username     string
+// Get a comment by its ID, locking it until the end of the transaction
+func (q *Queries) GetCommentForUpdate(ctx context.Context, commentID int32) (Comment, error) {
+	row := q.db.QueryRow(ctx, getCommentForUpdate, commentID)
+	var i Comment
+	err := row.Scan(
+		&i.CommentID,
+		&i.Content,
+		&i.ContentHtml,
+		&i.CreationDate,
+		&i.PostID,
+		&i.UserID,
+		&i.Score,
+		&i.ParentCommentID,
+		&i.RootCommentID,
+		&i.Depth,
+		&i.IsDeleted,
+	)
+	return i, err
 }
 
-// Get all comments for a specific post, ordered by creation date
-func (q *Queries) GetCommentsByPost(ctx context.Context, postID pgtype.Int4) ([]GetCommentsByPostRow, error) {
-	rows, err := q.db.Query(ctx, getCommentsByPost, postID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetCommentsByPostRow
-	for rows.Next() {
-		var i GetCommentsByPostRow
-		if err := rows.Scan(
-			&i.CommentID,
-			&i.Content,
+const getCommentVote = `-- name: GetCommentVote :one
+SELECT comment_id, user_id, value, creation_date FROM comment_votes WHERE comment_id = $1 AND user_id = $2
+`
+
+type GetCommentVoteParams struct {
+	CommentID int32
+	UserID    int32
+}
+
+// Get a user's vote on a comment
+func (q *Queries) GetCommentVote(ctx context.Context, arg GetCommentVoteParams) (CommentVote, error) {
+	row := q.db.QueryRow(ctx, getCommentVote, arg.CommentID, arg.UserID)
+	var i CommentVote
+	err := row.Scan(
+		&i.CommentID,
+		&i.UserID,
+		&i.Value,
+		&i.CreationDate,
+	)
+	return i, err
+}
+
+const getCommentsByPost = `-- name: GetCommentsByPost :many
+SELECT comments.comment_id, comments.content, comments.content_html, comments.creation_date, comments.post_id, comments.user_id, comments.score, comments.parent_comment_id, comments.root_comment_id, comments.depth, comments.is_deleted, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
+FROM comments 
+LEFT JOIN users ON comments.user_id = users.user_id 
+WHERE comments.post_id = $1 
+AND ($2::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < ($2::timestamptz, $3::int))
+ORDER BY comments.creation_date DESC, comments.comment_id DESC
+LIMIT $4
+`
+
+type GetCommentsByPostParams struct {
+	PostID     pgtype.Int4
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type GetCommentsByPostRow struct {
+	CommentID       int32
+	Content         string
+	ContentHtml     pgtype.Text
+	CreationDate    pgtype.Timestamptz
+	PostID          pgtype.Int4
+	UserID          pgtype.Int4
+	Score           int32
+	ParentCommentID pgtype.Int4
+	RootCommentID   pgtype.Int4
+	Depth           int32
+	IsDeleted       bool
+	Username        pgtype.Text
+	ReplyCount      int32
+}
+
+// Get a page of comments for a specific post, newest first, starting after the cursor, with their number of direct replies
+func (q *Queries) GetCommentsByPost(ctx context.Context, arg GetCommentsByPostParams) ([]GetCommentsByPostRow, error) {
+	rows, err := q.db.Query(ctx, getCommentsByPost,
+		arg.PostID,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCommentsByPostRow
+	for rows.Next() {
+		var i GetCommentsByPostRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.Content,
+			&i.ContentHtml,
 			&i.CreationDate,
 			&i.PostID,
 			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
 			&i.Username,
+			&i.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
@@ -860,26 +1953,55 @@ func (q *Queries) GetCommentsByPost(ctx context.Context, postID pgtype.Int4) ([]
 	return items, nil
 }
 
-const getCommentsByUser = `-- name: GetCommentsByUser :many
-SELECT comment_id, content, creation_date, post_id, user_id FROM comments WHERE user_id = $1 ORDER BY creation_date DESC
+const getCommentsByRootIDs = `-- name: GetCommentsByRootIDs :many
+SELECT comments.comment_id, comments.content, comments.content_html, comments.creation_date, comments.post_id, comments.user_id, comments.score, comments.parent_comment_id, comments.root_comment_id, comments.depth, comments.is_deleted, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
+FROM comments
+LEFT JOIN users ON comments.user_id = users.user_id
+WHERE comments.root_comment_id = ANY($1::int[])
+ORDER BY comments.creation_date, comments.comment_id
 `
 
-// Get all comments made by a specific user, ordered by creation date
-func (q *Queries) GetCommentsByUser(ctx context.Context, userID pgtype.Int4) ([]Comment, error) {
-	rows, err := q.db.Query(ctx, getCommentsByUser, userID)
+type GetCommentsByRootIDsRow struct {
+	CommentID       int32
+	Content         string
+	ContentHtml     pgtype.Text
+	CreationDate    pgtype.Timestamptz
+	PostID          pgtype.Int4
+	UserID          pgtype.Int4
+	Score           int32
+	ParentCommentID pgtype.Int4
+	RootCommentID   pgtype.Int4
+	Depth           int32
+	IsDeleted       bool
+	Username        pgtype.Text
+	ReplyCount      int32
+}
+
+// Get all replies in the threads of the given top level comments, oldest first, with their number of direct replies
+func (q *Queries) GetCommentsByRootIDs(ctx context.Context, rootIds []int32) ([]GetCommentsByRootIDsRow, error) {
+	rows, err := q.db.Query(ctx, getCommentsByRootIDs, rootIds)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Comment
+	var items []GetCommentsByRootIDsRow
 	for rows.Next() {
-		var i Comment
+		var i GetCommentsByRootIDsRow
 		if err := rows.Scan(
 			&i.CommentID,
 			&i.Content,
+			&i.ContentHtml,
 			&i.CreationDate,
 			&i.PostID,
 			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
+			&i.Username,
+			&i.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
@@ -891,51 +2013,78 @@ func (q *Queries) GetCommentsByUser(ctx context.Context, userID pgtype.Int4) ([]
 	return items, nil
 }
 
-const getEventByID = `-- name: GetEventByID :one
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE event_id = $1
+const getCommentsByUser = `-- name: GetCommentsByUser :many
+SELECT comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted FROM comments WHERE user_id = $1
+AND ($2::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < ($2::timestamptz, $3::int))
+ORDER BY creation_date DESC, comment_id DESC
+LIMIT $4
 `
 
-// Get a specific event by its ID
-func (q *Queries) GetEventByID(ctx context.Context, eventID int32) (Event, error) {
-	row := q.db.QueryRow(ctx, getEventByID, eventID)
-	var i Event
-	err := row.Scan(
-		&i.EventID,
-		&i.Title,
-		&i.Description,
-		&i.EventDate,
-		&i.MeetingPoint,
-		&i.RouteID,
-		&i.CreatorUserID,
-	)
-	return i, err
+type GetCommentsByUserParams struct {
+	UserID     pgtype.Int4
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
 }
 
-const getEvents = `-- name: GetEvents :many
+// Get a page of comments made by a specific user, newest first, starting after the cursor
+func (q *Queries) GetCommentsByUser(ctx context.Context, arg GetCommentsByUserParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, getCommentsByUser,
+		arg.UserID,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.PostID,
+			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events ORDER BY event_date
+const getCommentsWithoutContentHTML = `-- name: GetCommentsWithoutContentHTML :many
+SELECT comment_id, content FROM comments WHERE content_html IS NULL ORDER BY comment_id LIMIT $1
 `
 
-// ----------------------------------------------------------------------------------------------------------------------
-// Get all events, ordered by event date
-func (q *Queries) GetEvents(ctx context.Context) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEvents)
+type GetCommentsWithoutContentHTMLRow struct {
+	CommentID int32
+	Content   string
+}
+
+// Get the content of comments whose Markdown has not been rendered yet
+func (q *Queries) GetCommentsWithoutContentHTML(ctx context.Context, limit int32) ([]GetCommentsWithoutContentHTMLRow, error) {
+	rows, err := q.db.Query(ctx, getCommentsWithoutContentHTML, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Event
+	var items []GetCommentsWithoutContentHTMLRow
 	for rows.Next() {
-		var i Event
-		if err := rows.Scan(
-			&i.EventID,
-			&i.Title,
-			&i.Description,
-			&i.EventDate,
-			&i.MeetingPoint,
-			&i.RouteID,
-			&i.CreatorUserID,
-		); err != nil {
+		var i GetCommentsWithoutContentHTMLRow
+		if err := rows.Scan(&i.CommentID, &i.Content); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -946,28 +2095,45 @@ func (q *Queries) GetEvents(ctx context.Context) ([]Event, error) {
 	return items, nil
 }
 
-const getEventsByDate = `-- name: GetEventsByDate :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events ORDER BY event_date
+const getConversationMessages = `-- name: GetConversationMessages :many
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages
+WHERE ((sender_user_id = $1::int AND receiver_user_id = $2::int)
+  OR (sender_user_id = $2::int AND receiver_user_id = $1::int))
+AND ($3::int IS NULL OR message_id < $3::int)
+ORDER BY message_id DESC
+LIMIT $4
 `
 
-// Get all events, ordered by event date
-func (q *Queries) GetEventsByDate(ctx context.Context) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEventsByDate)
+type GetConversationMessagesParams struct {
+	UserID        int32
+	PartnerUserID int32
+	CursorID      pgtype.Int4
+	PageLimit     int32
+}
+
+// Get a page of the messages between two users, newest first, starting after the cursor
+func (q *Queries) GetConversationMessages(ctx context.Context, arg GetConversationMessagesParams) ([]PrivateMessage, error) {
+	rows, err := q.db.Query(ctx, getConversationMessages,
+		arg.UserID,
+		arg.PartnerUserID,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Event
+	var items []PrivateMessage
 	for rows.Next() {
-		var i Event
+		var i PrivateMessage
 		if err := rows.Scan(
-			&i.EventID,
-			&i.Title,
-			&i.Description,
-			&i.EventDate,
-			&i.MeetingPoint,
-			&i.RouteID,
-			&i.CreatorUserID,
+			&i.MessageID,
+			&i.Content,
+			&i.SenderUserID,
+			&i.ReceiverUserID,
+			&i.SentDate,
+			&i.IsRead,
+			&i.DeliveredDate,
 		); err != nil {
 			return nil, err
 		}
@@ -979,33 +2145,66 @@ func (q *Queries) GetEventsByDate(ctx context.Context) ([]Event, error) {
 	return items, nil
 }
 
-const getEventsByDateRange = `-- name: GetEventsByDateRange :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE event_date >= $1 AND event_date <= $2 ORDER BY event_date
+const getConversations = `-- name: GetConversations :many
+SELECT private_messages.message_id, private_messages.content, private_messages.sender_user_id, private_messages.receiver_user_id, private_messages.sent_date, private_messages.is_read, private_messages.delivered_date, partners.user_id AS partner_user_id, partners.username AS partner_username,
+(SELECT COUNT(*) FROM private_messages AS unread
+  WHERE unread.receiver_user_id = $1::int
+  AND unread.sender_user_id IS NOT DISTINCT FROM partners.user_id
+  AND unread.is_read = FALSE)::int AS unread_count
+FROM private_messages
+LEFT JOIN users AS partners ON partners.user_id = CASE WHEN private_messages.sender_user_id = $1::int THEN private_messages.receiver_user_id ELSE private_messages.sender_user_id END
+WHERE private_messages.message_id IN (
+  SELECT MAX(latest.message_id) FROM private_messages AS latest
+  WHERE latest.sender_user_id = $1::int OR latest.receiver_user_id = $1::int
+  GROUP BY LEAST(COALESCE(latest.sender_user_id, 0), COALESCE(latest.receiver_user_id, 0)), GREATEST(COALESCE(latest.sender_user_id, 0), COALESCE(latest.receiver_user_id, 0))
+)
+AND ($2::int IS NULL OR private_messages.message_id < $2::int)
+ORDER BY private_messages.message_id DESC
+LIMIT $3
 `
 
-type GetEventsByDateRangeParams struct {
-	EventDate   pgtype.Timestamptz
-	EventDate_2 pgtype.Timestamptz
+type GetConversationsParams struct {
+	UserID    int32
+	CursorID  pgtype.Int4
+	PageLimit int32
 }
 
-// Get all events within a date range
-func (q *Queries) GetEventsByDateRange(ctx context.Context, arg GetEventsByDateRangeParams) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEventsByDateRange, arg.EventDate, arg.EventDate_2)
+type GetConversationsRow struct {
+	MessageID       int32
+	Content         string
+	SenderUserID    pgtype.Int4
+	ReceiverUserID  pgtype.Int4
+	SentDate        pgtype.Timestamptz
+	IsRead          pgtype.Bool
+	DeliveredDate   pgtype.Timestamptz
+	PartnerUserID   pgtype.Int4
+	PartnerUsername pgtype.Text
+	UnreadCount     int32
+}
+
+// Get a page of a user's conversations, with the latest message of each, the other user and the number of messages
+// they have sent that the user has not read, most recently active first, starting after the cursor.
+// A conversation is every message between the same two users, in either direction.
+func (q *Queries) GetConversations(ctx context.Context, arg GetConversationsParams) ([]GetConversationsRow, error) {
+	rows, err := q.db.Query(ctx, getConversations, arg.UserID, arg.CursorID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Event
+	var items []GetConversationsRow
 	for rows.Next() {
-		var i Event
+		var i GetConversationsRow
 		if err := rows.Scan(
-			&i.EventID,
-			&i.Title,
-			&i.Description,
-			&i.EventDate,
-			&i.MeetingPoint,
-			&i.RouteID,
-			&i.CreatorUserID,
+			&i.MessageID,
+			&i.Content,
+			&i.SenderUserID,
+			&i.ReceiverUserID,
+			&i.SentDate,
+			&i.IsRead,
+			&i.DeliveredDate,
+			&i.PartnerUserID,
+			&i.PartnerUsername,
+			&i.UnreadCount,
 		); err != nil {
 			return nil, err
 		}
@@ -1017,34 +2216,134 @@ func (q *Queries) GetEventsByDateRange(ctx context.Context, arg GetEventsByDateR
 	return items, nil
 }
 
-const getEventsByDateRangeAndRouteID = `-- name: GetEventsByDateRangeAndRouteID :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE event_date >= $1 AND event_date <= $2 AND route_id = $3 ORDER BY event_date
+const getEmailPreferencesByToken = `-- name: GetEmailPreferencesByToken :one
+SELECT user_id, digest_frequency, unsubscribe_token, last_digest_date FROM email_preferences WHERE unsubscribe_token = $1
 `
 
-type GetEventsByDateRangeAndRouteIDParams struct {
-	EventDate   pgtype.Timestamptz
-	EventDate_2 pgtype.Timestamptz
-	RouteID     pgtype.Int4
+// Get email preferences by unsubscribe token, used to find the user who follows an unsubscribe link
+func (q *Queries) GetEmailPreferencesByToken(ctx context.Context, unsubscribeToken pgtype.UUID) (EmailPreference, error) {
+	row := q.db.QueryRow(ctx, getEmailPreferencesByToken, unsubscribeToken)
+	var i EmailPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.DigestFrequency,
+		&i.UnsubscribeToken,
+		&i.LastDigestDate,
+	)
+	return i, err
+}
+
+const getEmailPreferencesByUserID = `-- name: GetEmailPreferencesByUserID :one
+
+SELECT user_id, digest_frequency, unsubscribe_token, last_digest_date FROM email_preferences WHERE user_id = $1
+`
+
+// ----------------------------------------------------------------------------------------------------------------------
+func (q *Queries) GetEmailPreferencesByUserID(ctx context.Context, userID int32) (EmailPreference, error) {
+	row := q.db.QueryRow(ctx, getEmailPreferencesByUserID, userID)
+	var i EmailPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.DigestFrequency,
+		&i.UnsubscribeToken,
+		&i.LastDigestDate,
+	)
+	return i, err
+}
+
+const getEventByID = `-- name: GetEventByID :one
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE event_id = $1
+`
+
+// Get a specific event by its ID
+func (q *Queries) GetEventByID(ctx context.Context, eventID int32) (Event, error) {
+	row := q.db.QueryRow(ctx, getEventByID, eventID)
+	var i Event
+	err := row.Scan(
+		&i.EventID,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+		&i.RouteID,
+		&i.CreatorUserID,
+		&i.Capacity,
+		&i.RecurrenceRule,
+		&i.TimeZone,
+	)
+	return i, err
+}
+
+const getEventByIDForUpdate = `-- name: GetEventByIDForUpdate :one
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE event_id = $1 FOR UPDATE
+`
+
+// Get a specific event by its ID and lock it until the end of the transaction
+func (q *Queries) GetEventByIDForUpdate(ctx context.Context, eventID int32) (Event, error) {
+	row := q.db.QueryRow(ctx, getEventByIDForUpdate, eventID)
+	var i Event
+	err := row.Scan(
+		&i.EventID,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+		&i.RouteID,
+		&i.CreatorUserID,
+		&i.Capacity,
+		&i.RecurrenceRule,
+		&i.TimeZone,
+	)
+	return i, err
+}
+
+const getEventOccurrence = `-- name: GetEventOccurrence :one
+SELECT event_id, occurrence_date, is_cancelled, title, description, event_date, meeting_point FROM event_occurrences WHERE event_id = $1 AND occurrence_date = $2
+`
+
+type GetEventOccurrenceParams struct {
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Get the changes to a single occurrence of a recurring event
+func (q *Queries) GetEventOccurrence(ctx context.Context, arg GetEventOccurrenceParams) (EventOccurrence, error) {
+	row := q.db.QueryRow(ctx, getEventOccurrence, arg.EventID, arg.OccurrenceDate)
+	var i EventOccurrence
+	err := row.Scan(
+		&i.EventID,
+		&i.OccurrenceDate,
+		&i.IsCancelled,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+	)
+	return i, err
 }
 
-// Get all events within a date range for a specific route
-func (q *Queries) GetEventsByDateRangeAndRouteID(ctx context.Context, arg GetEventsByDateRangeAndRouteIDParams) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEventsByDateRangeAndRouteID, arg.EventDate, arg.EventDate_2, arg.RouteID)
+const getEventOccurrencesByEventID = `-- name: GetEventOccurrencesByEventID :many
+SELECT event_id, occurrence_date, is_cancelled, title, description, event_date, meeting_point FROM event_occurrences WHERE event_id = $1 ORDER BY occurrence_date
+`
+
+// Get the changed and cancelled occurrences of a recurring event
+func (q *Queries) GetEventOccurrencesByEventID(ctx context.Context, eventID int32) ([]EventOccurrence, error) {
+	rows, err := q.db.Query(ctx, getEventOccurrencesByEventID, eventID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Event
+	var items []EventOccurrence
 	for rows.Next() {
-		var i Event
+		var i EventOccurrence
 		if err := rows.Scan(
 			&i.EventID,
+			&i.OccurrenceDate,
+			&i.IsCancelled,
 			&i.Title,
 			&i.Description,
 			&i.EventDate,
 			&i.MeetingPoint,
-			&i.RouteID,
-			&i.CreatorUserID,
 		); err != nil {
 			return nil, err
 		}
@@ -1056,25 +2355,15 @@ func (q *Queries) GetEventsByDateRangeAndRouteID(ctx context.Context, arg GetEve
 	return items, nil
 }
 
-const getEventsByDateRangeAndRouteIDAndUserID = `-- name: GetEventsByDateRangeAndRouteIDAndUserID :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE event_date >= $1 AND event_date <= $2 AND route_id = $3 AND creator_user_id = $4 ORDER BY event_date
-`
+const getEvents = `-- name: GetEvents :many
 
-type GetEventsByDateRangeAndRouteIDAndUserIDParams struct {
-	EventDate     pgtype.Timestamptz
-	EventDate_2   pgtype.Timestamptz
-	RouteID       pgtype.Int4
-	CreatorUserID pgtype.Int4
-}
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events ORDER BY event_date
+`
 
-// Get all events within a date range for a specific route and user
-func (q *Queries) GetEventsByDateRangeAndRouteIDAndUserID(ctx context.Context, arg GetEventsByDateRangeAndRouteIDAndUserIDParams) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEventsByDateRangeAndRouteIDAndUserID,
-		arg.EventDate,
-		arg.EventDate_2,
-		arg.RouteID,
-		arg.CreatorUserID,
-	)
+// ----------------------------------------------------------------------------------------------------------------------
+// Get all events, ordered by event date
+func (q *Queries) GetEvents(ctx context.Context) ([]Event, error) {
+	rows, err := q.db.Query(ctx, getEvents)
 	if err != nil {
 		return nil, err
 	}
@@ -1090,6 +2379,9 @@ func (q *Queries) GetEventsByDateRangeAndRouteIDAndUserID(ctx context.Context, a
 			&i.MeetingPoint,
 			&i.RouteID,
 			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
 		); err != nil {
 			return nil, err
 		}
@@ -1101,19 +2393,18 @@ func (q *Queries) GetEventsByDateRangeAndRouteIDAndUserID(ctx context.Context, a
 	return items, nil
 }
 
-const getEventsByDateRangeAndUserID = `-- name: GetEventsByDateRangeAndUserID :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE event_date >= $1 AND event_date <= $2 AND creator_user_id = $3 ORDER BY event_date
+const getEventsByDateRange = `-- name: GetEventsByDateRange :many
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE event_date >= $1 AND event_date <= $2 ORDER BY event_date
 `
 
-type GetEventsByDateRangeAndUserIDParams struct {
-	EventDate     pgtype.Timestamptz
-	EventDate_2   pgtype.Timestamptz
-	CreatorUserID pgtype.Int4
+type GetEventsByDateRangeParams struct {
+	EventDate   pgtype.Timestamptz
+	EventDate_2 pgtype.Timestamptz
 }
 
-// Get all events within a date range for a specific user
-func (q *Queries) GetEventsByDateRangeAndUserID(ctx context.Context, arg GetEventsByDateRangeAndUserIDParams) ([]Event, error) {
-	rows, err := q.db.Query(ctx, getEventsByDateRangeAndUserID, arg.EventDate, arg.EventDate_2, arg.CreatorUserID)
+// Get all events within a date range
+func (q *Queries) GetEventsByDateRange(ctx context.Context, arg GetEventsByDateRangeParams) ([]Event, error) {
+	rows, err := q.db.Query(ctx, getEventsByDateRange, arg.EventDate, arg.EventDate_2)
 	if err != nil {
 		return nil, err
 	}
@@ -1129,6 +2420,9 @@ func (q *Queries) GetEventsByDateRangeAndUserID(ctx context.Context, arg GetEven
 			&i.MeetingPoint,
 			&i.RouteID,
 			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
 		); err != nil {
 			return nil, err
 		}
@@ -1141,7 +2435,7 @@ func (q *Queries) GetEventsByDateRangeAndUserID(ctx context.Context, arg GetEven
 }
 
 const getEventsByRouteID = `-- name: GetEventsByRouteID :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE route_id = $1 ORDER BY event_date
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE route_id = $1 ORDER BY event_date
 `
 
 // Get all events for a specific route
@@ -1162,6 +2456,9 @@ func (q *Queries) GetEventsByRouteID(ctx context.Context, routeID pgtype.Int4) (
 			&i.MeetingPoint,
 			&i.RouteID,
 			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
 		); err != nil {
 			return nil, err
 		}
@@ -1174,7 +2471,7 @@ func (q *Queries) GetEventsByRouteID(ctx context.Context, routeID pgtype.Int4) (
 }
 
 const getEventsByUserID = `-- name: GetEventsByUserID :many
-SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id FROM events WHERE creator_user_id = $1 ORDER BY event_date
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE creator_user_id = $1 ORDER BY event_date
 `
 
 // Get all events created by a specific user
@@ -1195,6 +2492,9 @@ func (q *Queries) GetEventsByUserID(ctx context.Context, creatorUserID pgtype.In
 			&i.MeetingPoint,
 			&i.RouteID,
 			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
 		); err != nil {
 			return nil, err
 		}
@@ -1206,30 +2506,24 @@ func (q *Queries) GetEventsByUserID(ctx context.Context, creatorUserID pgtype.In
 	return items, nil
 }
 
-const getLockedPosts = `-- name: GetLockedPosts :many
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE is_locked = TRUE ORDER BY creation_date DESC
+const getFollowedCategories = `-- name: GetFollowedCategories :many
+SELECT categories.category_id, categories.name, categories.description FROM categories
+INNER JOIN category_follows ON categories.category_id = category_follows.category_id
+WHERE category_follows.user_id = $1
+ORDER BY categories.name
 `
 
-func (q *Queries) GetLockedPosts(ctx context.Context) ([]Post, error) {
-	rows, err := q.db.Query(ctx, getLockedPosts)
+// Get the categories a user follows, by name
+func (q *Queries) GetFollowedCategories(ctx context.Context, userID int32) ([]Category, error) {
+	rows, err := q.db.Query(ctx, getFollowedCategories, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []Category
 	for rows.Next() {
-		var i Post
-		if err := rows.Scan(
-			&i.PostID,
-			&i.Title,
-			&i.Content,
-			&i.CreationDate,
-			&i.UserID,
-			&i.IsSticky,
-			&i.IsLocked,
-			&i.PostCategoryID,
-			&i.AdditionalNotes,
-		); err != nil {
+		var i Category
+		if err := rows.Scan(&i.CategoryID, &i.Name, &i.Description); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -1240,48 +2534,48 @@ func (q *Queries) GetLockedPosts(ctx context.Context) ([]Post, error) {
 	return items, nil
 }
 
-const getLogById = `-- name: GetLogById :one
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE log_id = $1
+const getFollowedCategoryPostsSince = `-- name: GetFollowedCategoryPostsSince :many
+SELECT posts.post_id, posts.title, posts.creation_date, categories.name AS category_name, authors.username AS author_username
+FROM posts
+INNER JOIN category_follows ON posts.post_category_id = category_follows.category_id
+INNER JOIN categories ON posts.post_category_id = categories.category_id
+LEFT JOIN users AS authors ON posts.user_id = authors.user_id
+WHERE category_follows.user_id = $1::int AND posts.creation_date > $2::timestamptz
+AND (posts.user_id IS NULL OR posts.user_id <> $1::int)
+ORDER BY posts.creation_date DESC
+LIMIT $3
 `
 
-func (q *Queries) GetLogById(ctx context.Context, logID int32) (ForumModerationLog, error) {
-	row := q.db.QueryRow(ctx, getLogById, logID)
-	var i ForumModerationLog
-	err := row.Scan(
-		&i.LogID,
-		&i.Action,
-		&i.ActionDate,
-		&i.ModeratorUserID,
-		&i.AffectedUserID,
-		&i.PostID,
-		&i.CommentID,
-		&i.Reason,
-	)
-	return i, err
+type GetFollowedCategoryPostsSinceParams struct {
+	UserID    int32
+	Since     pgtype.Timestamptz
+	PageLimit int32
 }
 
-const getLogsByAction = `-- name: GetLogsByAction :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE action = $1
-`
+type GetFollowedCategoryPostsSinceRow struct {
+	PostID         int32
+	Title          string
+	CreationDate   pgtype.Timestamptz
+	CategoryName   string
+	AuthorUsername pgtype.Text
+}
 
-func (q *Queries) GetLogsByAction(ctx context.Context, action string) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByAction, action)
+// Get the posts created since a date in the categories a user follows, newest first, for their digest
+func (q *Queries) GetFollowedCategoryPostsSince(ctx context.Context, arg GetFollowedCategoryPostsSinceParams) ([]GetFollowedCategoryPostsSinceRow, error) {
+	rows, err := q.db.Query(ctx, getFollowedCategoryPostsSince, arg.UserID, arg.Since, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []GetFollowedCategoryPostsSinceRow
 	for rows.Next() {
-		var i ForumModerationLog
+		var i GetFollowedCategoryPostsSinceRow
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
 			&i.PostID,
-			&i.CommentID,
-			&i.Reason,
+			&i.Title,
+			&i.CreationDate,
+			&i.CategoryName,
+			&i.AuthorUsername,
 		); err != nil {
 			return nil, err
 		}
@@ -1293,28 +2587,91 @@ func (q *Queries) GetLogsByAction(ctx context.Context, action string) ([]ForumMo
 	return items, nil
 }
 
-const getLogsByAffectedUser = `-- name: GetLogsByAffectedUser :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE affected_user_id = $1 ORDER BY action_date DESC
+const getLatestCommentID = `-- name: GetLatestCommentID :one
+SELECT COALESCE(MAX(comment_id), 0)::int AS latest_id FROM comments
+`
+
+func (q *Queries) GetLatestCommentID(ctx context.Context) (int32, error) {
+	row := q.db.QueryRow(ctx, getLatestCommentID)
+	var latestID int32
+	err := row.Scan(&latestID)
+	return latestID, err
+}
+
+const getLatestNotificationID = `-- name: GetLatestNotificationID :one
+
+SELECT COALESCE(MAX(notification_id), 0)::int AS latest_id FROM notifications
+`
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Get the ID of the newest notification, where the real-time stream starts for a new connection
+func (q *Queries) GetLatestNotificationID(ctx context.Context) (int32, error) {
+	row := q.db.QueryRow(ctx, getLatestNotificationID)
+	var latestID int32
+	err := row.Scan(&latestID)
+	return latestID, err
+}
+
+const getLatestPostRevisionNumber = `-- name: GetLatestPostRevisionNumber :one
+SELECT COALESCE(MAX(revision_number), 0)::int AS revision_number FROM post_revisions WHERE post_id = $1
+`
+
+// Get the number of the latest revision of a post, 0 if it has never been edited
+func (q *Queries) GetLatestPostRevisionNumber(ctx context.Context, postID int32) (int32, error) {
+	row := q.db.QueryRow(ctx, getLatestPostRevisionNumber, postID)
+	var revisionNumber int32
+	err := row.Scan(&revisionNumber)
+	return revisionNumber, err
+}
+
+const getLatestPrivateMessageID = `-- name: GetLatestPrivateMessageID :one
+SELECT COALESCE(MAX(message_id), 0)::int AS latest_id FROM private_messages
+`
+
+func (q *Queries) GetLatestPrivateMessageID(ctx context.Context) (int32, error) {
+	row := q.db.QueryRow(ctx, getLatestPrivateMessageID)
+	var latestID int32
+	err := row.Scan(&latestID)
+	return latestID, err
+}
+
+const getLockedPosts = `-- name: GetLockedPosts :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts WHERE is_locked = TRUE
+AND ($1::timestamptz IS NULL OR (posts.creation_date, posts.post_id) < ($1::timestamptz, $2::int))
+ORDER BY creation_date DESC, post_id DESC
+LIMIT $3
 `
 
-func (q *Queries) GetLogsByAffectedUser(ctx context.Context, affectedUserID pgtype.Int4) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByAffectedUser, affectedUserID)
+type GetLockedPostsParams struct {
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+// Get a page of locked posts, newest first, starting after the cursor
+func (q *Queries) GetLockedPosts(ctx context.Context, arg GetLockedPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getLockedPosts, arg.CursorDate, arg.CursorID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []Post
 	for rows.Next() {
-		var i ForumModerationLog
+		var i Post
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
 			&i.PostID,
-			&i.CommentID,
-			&i.Reason,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1326,28 +2683,103 @@ func (q *Queries) GetLogsByAffectedUser(ctx context.Context, affectedUserID pgty
 	return items, nil
 }
 
-const getLogsByComment = `-- name: GetLogsByComment :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE comment_id = $1
+const getLogById = `-- name: GetLogById :one
+SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE log_id = $1
+`
+
+func (q *Queries) GetLogById(ctx context.Context, logID int32) (ForumModerationLog, error) {
+	row := q.db.QueryRow(ctx, getLogById, logID)
+	var i ForumModerationLog
+	err := row.Scan(
+		&i.LogID,
+		&i.Action,
+		&i.ActionDate,
+		&i.ModeratorUserID,
+		&i.AffectedUserID,
+		&i.PostID,
+		&i.CommentID,
+		&i.Reason,
+	)
+	return i, err
+}
+
+const getNextWaitlistedRSVPForUpdate = `-- name: GetNextWaitlistedRSVPForUpdate :one
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND rsvp_status = 'waitlisted' AND occurrence_date IS NOT DISTINCT FROM $2::timestamptz
+ORDER BY rsvp_date, rsvp_id LIMIT 1 FOR UPDATE
+`
+
+type GetNextWaitlistedRSVPForUpdateParams struct {
+	EventID        pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Get and lock the first RSVP on the waitlist of a specific event occurrence
+func (q *Queries) GetNextWaitlistedRSVPForUpdate(ctx context.Context, arg GetNextWaitlistedRSVPForUpdateParams) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, getNextWaitlistedRSVPForUpdate, arg.EventID, arg.OccurrenceDate)
+	var i Rsvp
+	err := row.Scan(
+		&i.RsvpID,
+		&i.EventID,
+		&i.UserID,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
+	)
+	return i, err
+}
+
+const getNotificationById = `-- name: GetNotificationById :one
+SELECT notification_id, user_id, content, creation_date, is_read, notification_type, actor_user_id, post_id, comment_id, message_id, event_id FROM notifications WHERE notification_id = $1
+`
+
+// Get a single notification by its ID
+func (q *Queries) GetNotificationById(ctx context.Context, notificationID int32) (Notification, error) {
+	row := q.db.QueryRow(ctx, getNotificationById, notificationID)
+	var i Notification
+	err := row.Scan(
+		&i.NotificationID,
+		&i.UserID,
+		&i.Content,
+		&i.CreationDate,
+		&i.IsRead,
+		&i.NotificationType,
+		&i.ActorUserID,
+		&i.PostID,
+		&i.CommentID,
+		&i.MessageID,
+		&i.EventID,
+	)
+	return i, err
+}
+
+const getNotifications = `-- name: GetNotifications :many
+
+SELECT notification_id, user_id, content, creation_date, is_read, notification_type, actor_user_id, post_id, comment_id, message_id, event_id FROM notifications ORDER BY creation_date DESC
 `
 
-func (q *Queries) GetLogsByComment(ctx context.Context, commentID pgtype.Int4) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByComment, commentID)
+// ----------------------------------------------------------------------------------------------------------------------
+// Get all notifications, ordered by creation_date
+func (q *Queries) GetNotifications(ctx context.Context) ([]Notification, error) {
+	rows, err := q.db.Query(ctx, getNotifications)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []Notification
 	for rows.Next() {
-		var i ForumModerationLog
+		var i Notification
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
+			&i.NotificationID,
+			&i.UserID,
+			&i.Content,
+			&i.CreationDate,
+			&i.IsRead,
+			&i.NotificationType,
+			&i.ActorUserID,
 			&i.PostID,
 			&i.CommentID,
-			&i.Reason,
+			&i.MessageID,
+			&i.EventID,
 		); err != nil {
 			return nil, err
 		}
@@ -1359,28 +2791,70 @@ func (q *Queries) GetLogsByComment(ctx context.Context, commentID pgtype.Int4) (
 	return items, nil
 }
 
-const getLogsByModerator = `-- name: GetLogsByModerator :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE moderator_user_id = $1 ORDER BY action_date DESC
+const getNotificationsByUserId = `-- name: GetNotificationsByUserId :many
+SELECT notifications.notification_id, notifications.user_id, notifications.content, notifications.creation_date, notifications.is_read, notifications.notification_type, notifications.actor_user_id, notifications.post_id, notifications.comment_id, notifications.message_id, notifications.event_id, actors.username AS actor_username
+FROM notifications
+LEFT JOIN users AS actors ON notifications.actor_user_id = actors.user_id
+WHERE notifications.user_id = $1::int
+AND (NOT $2::boolean OR notifications.is_read = FALSE)
+AND ($3::timestamptz IS NULL OR (notifications.creation_date, notifications.notification_id) < ($3::timestamptz, $4::int))
+ORDER BY notifications.creation_date DESC, notifications.notification_id DESC
+LIMIT $5
 `
 
-func (q *Queries) GetLogsByModerator(ctx context.Context, moderatorUserID pgtype.Int4) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByModerator, moderatorUserID)
+type GetNotificationsByUserIdParams struct {
+	UserID     int32
+	UnreadOnly bool
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type GetNotificationsByUserIdRow struct {
+	NotificationID   int32
+	UserID           pgtype.Int4
+	Content          string
+	CreationDate     pgtype.Timestamptz
+	IsRead           pgtype.Bool
+	NotificationType string
+	ActorUserID      pgtype.Int4
+	PostID           pgtype.Int4
+	CommentID        pgtype.Int4
+	MessageID        pgtype.Int4
+	EventID          pgtype.Int4
+	ActorUsername    pgtype.Text
+}
+
+// Get a page of a user's notifications, newest first, starting after the cursor. Only unread notifications are
+// included when unread_only is true.
+func (q *Queries) GetNotificationsByUserId(ctx context.Context, arg GetNotificationsByUserIdParams) ([]GetNotificationsByUserIdRow, error) {
+	rows, err := q.db.Query(ctx, getNotificationsByUserId,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []GetNotificationsByUserIdRow
 	for rows.Next() {
-		var i ForumModerationLog
+		var i GetNotificationsByUserIdRow
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
+			&i.NotificationID,
+			&i.UserID,
+			&i.Content,
+			&i.CreationDate,
+			&i.IsRead,
+			&i.NotificationType,
+			&i.ActorUserID,
 			&i.PostID,
 			&i.CommentID,
-			&i.Reason,
+			&i.MessageID,
+			&i.EventID,
+			&i.ActorUsername,
 		); err != nil {
 			return nil, err
 		}
@@ -1392,28 +2866,51 @@ func (q *Queries) GetLogsByModerator(ctx context.Context, moderatorUserID pgtype
 	return items, nil
 }
 
-const getLogsByPost = `-- name: GetLogsByPost :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE post_id = $1
+const getNotificationsByUserIdAfter = `-- name: GetNotificationsByUserIdAfter :many
+SELECT notification_id, user_id, content, creation_date, is_read, notification_type, actor_user_id, post_id, comment_id, message_id, event_id FROM notifications WHERE user_id = $1::int
+AND (notification_id > $2::int OR creation_date > $3::timestamptz)
+AND notification_id > $4::int
+ORDER BY notification_id
+LIMIT $5
 `
 
-func (q *Queries) GetLogsByPost(ctx context.Context, postID pgtype.Int4) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByPost, postID)
+type GetNotificationsByUserIdAfterParams struct {
+	UserID      int32
+	AfterID     int32
+	Since       pgtype.Timestamptz
+	PageAfterID int32
+	PageLimit   int32
+}
+
+// Get a user's notifications created after after_id or since a time, oldest first, to resume the real-time stream.
+// The time catches notifications committed after ones with higher IDs. Pages start after page_after_id.
+func (q *Queries) GetNotificationsByUserIdAfter(ctx context.Context, arg GetNotificationsByUserIdAfterParams) ([]Notification, error) {
+	rows, err := q.db.Query(ctx, getNotificationsByUserIdAfter,
+		arg.UserID,
+		arg.AfterID,
+		arg.Since,
+		arg.PageAfterID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []Notification
 	for rows.Next() {
-		var i ForumModerationLog
+		var i Notification
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
+			&i.NotificationID,
+			&i.UserID,
+			&i.Content,
+			&i.CreationDate,
+			&i.IsRead,
+			&i.NotificationType,
+			&i.ActorUserID,
 			&i.PostID,
 			&i.CommentID,
-			&i.Reason,
+			&i.MessageID,
+			&i.EventID,
 		); err != nil {
 			return nil, err
 		}
@@ -1425,28 +2922,56 @@ func (q *Queries) GetLogsByPost(ctx context.Context, postID pgtype.Int4) ([]Foru
 	return items, nil
 }
 
-const getLogsByReason = `-- name: GetLogsByReason :many
-SELECT log_id, action, action_date, moderator_user_id, affected_user_id, post_id, comment_id, reason FROM forum_moderation_log WHERE reason = $1
+const getOneOffEventsPage = `-- name: GetOneOffEventsPage :many
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events
+WHERE recurrence_rule IS NULL AND event_date >= $1::timestamptz AND event_date <= $2::timestamptz
+AND ($3::int IS NULL OR route_id = $3::int)
+AND ($4::int IS NULL OR creator_user_id = $4::int)
+AND ($5::timestamptz IS NULL OR (event_date, event_id) > ($5::timestamptz, $6::int))
+ORDER BY event_date, event_id
+LIMIT $7
 `
 
-func (q *Queries) GetLogsByReason(ctx context.Context, reason pgtype.Text) ([]ForumModerationLog, error) {
-	rows, err := q.db.Query(ctx, getLogsByReason, reason)
+type GetOneOffEventsPageParams struct {
+	FromDate      pgtype.Timestamptz
+	ToDate        pgtype.Timestamptz
+	RouteID       pgtype.Int4
+	CreatorUserID pgtype.Int4
+	CursorDate    pgtype.Timestamptz
+	CursorID      pgtype.Int4
+	PageLimit     int32
+}
+
+// Get a page of one-off events starting within a date range, optionally for a specific route and creator,
+// ordered by start time, starting after the cursor
+func (q *Queries) GetOneOffEventsPage(ctx context.Context, arg GetOneOffEventsPageParams) ([]Event, error) {
+	rows, err := q.db.Query(ctx, getOneOffEventsPage,
+		arg.FromDate,
+		arg.ToDate,
+		arg.RouteID,
+		arg.CreatorUserID,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ForumModerationLog
+	var items []Event
 	for rows.Next() {
-		var i ForumModerationLog
+		var i Event
 		if err := rows.Scan(
-			&i.LogID,
-			&i.Action,
-			&i.ActionDate,
-			&i.ModeratorUserID,
-			&i.AffectedUserID,
-			&i.PostID,
-			&i.CommentID,
-			&i.Reason,
+			&i.EventID,
+			&i.Title,
+			&i.Description,
+			&i.EventDate,
+			&i.MeetingPoint,
+			&i.RouteID,
+			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
 		); err != nil {
 			return nil, err
 		}
@@ -1459,7 +2984,7 @@ func (q *Queries) GetLogsByReason(ctx context.Context, reason pgtype.Text) ([]Fo
 }
 
 const getPost = `-- name: GetPost :one
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE post_id = $1
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts WHERE post_id = $1
 `
 
 func (q *Queries) GetPost(ctx context.Context, postID int32) (Post, error) {
@@ -1469,39 +2994,115 @@ func (q *Queries) GetPost(ctx context.Context, postID int32) (Post, error) {
 		&i.PostID,
 		&i.Title,
 		&i.Content,
+		&i.ContentHtml,
 		&i.CreationDate,
 		&i.UserID,
 		&i.IsSticky,
 		&i.IsLocked,
 		&i.PostCategoryID,
 		&i.AdditionalNotes,
+		&i.EditedAt,
+		&i.Score,
+		&i.LastActivityDate,
 	)
 	return i, err
 }
 
-const getPostsByCategory = `-- name: GetPostsByCategory :many
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE post_category_id = $1 ORDER BY creation_date DESC
+const getPostForUpdate = `-- name: GetPostForUpdate :one
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts WHERE post_id = $1 FOR UPDATE
 `
 
-func (q *Queries) GetPostsByCategory(ctx context.Context, postCategoryID pgtype.Int4) ([]Post, error) {
-	rows, err := q.db.Query(ctx, getPostsByCategory, postCategoryID)
+// Get a post by id, locking it until the end of the transaction
+func (q *Queries) GetPostForUpdate(ctx context.Context, postID int32) (Post, error) {
+	row := q.db.QueryRow(ctx, getPostForUpdate, postID)
+	var i Post
+	err := row.Scan(
+		&i.PostID,
+		&i.Title,
+		&i.Content,
+		&i.ContentHtml,
+		&i.CreationDate,
+		&i.UserID,
+		&i.IsSticky,
+		&i.IsLocked,
+		&i.PostCategoryID,
+		&i.AdditionalNotes,
+		&i.EditedAt,
+		&i.Score,
+		&i.LastActivityDate,
+	)
+	return i, err
+}
+
+const getPostRevision = `-- name: GetPostRevision :one
+SELECT revision_id, post_id, revision_number, title, content, post_category_id, additional_notes, editor_user_id, creation_date FROM post_revisions WHERE post_id = $1 AND revision_number = $2
+`
+
+type GetPostRevisionParams struct {
+	PostID         int32
+	RevisionNumber int32
+}
+
+// Get a single revision of a post by its number
+func (q *Queries) GetPostRevision(ctx context.Context, arg GetPostRevisionParams) (PostRevision, error) {
+	row := q.db.QueryRow(ctx, getPostRevision, arg.PostID, arg.RevisionNumber)
+	var i PostRevision
+	err := row.Scan(
+		&i.RevisionID,
+		&i.PostID,
+		&i.RevisionNumber,
+		&i.Title,
+		&i.Content,
+		&i.PostCategoryID,
+		&i.AdditionalNotes,
+		&i.EditorUserID,
+		&i.CreationDate,
+	)
+	return i, err
+}
+
+const getPostRevisionsByPostID = `-- name: GetPostRevisionsByPostID :many
+SELECT post_revisions.revision_id, post_revisions.post_id, post_revisions.revision_number, post_revisions.title, post_revisions.content, post_revisions.post_category_id, post_revisions.additional_notes, post_revisions.editor_user_id, post_revisions.creation_date, users.username
+FROM post_revisions
+LEFT JOIN users ON post_revisions.editor_user_id = users.user_id
+WHERE post_revisions.post_id = $1
+ORDER BY post_revisions.revision_number
+`
+
+type GetPostRevisionsByPostIDRow struct {
+	RevisionID      int32
+	PostID          int32
+	RevisionNumber  int32
+	Title           string
+	Content         string
+	PostCategoryID  pgtype.Int4
+	AdditionalNotes pgtype.Text
+	EditorUserID    pgtype.Int4
+	CreationDate    pgtype.Timestamptz
+	Username        pgtype.Text
+}
+
+// Get all revisions of a post, oldest first
+func (q *Queries) GetPostRevisionsByPostID(ctx context.Context, postID int32) ([]GetPostRevisionsByPostIDRow, error) {
+	rows, err := q.db.Query(ctx, getPostRevisionsByPostID, postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostRevisionsByPostIDRow
 	for rows.Next() {
-		var i Post
+		var i GetPostRevisionsByPostIDRow
 		if err := rows.Scan(
+			&i.RevisionID,
 			&i.PostID,
+			&i.RevisionNumber,
 			&i.Title,
 			&i.Content,
-			&i.CreationDate,
-			&i.UserID,
-			&i.IsSticky,
-			&i.IsLocked,
 			&i.PostCategoryID,
 			&i.AdditionalNotes,
+			&i.EditorUserID,
+			&i.CreationDate,
+			&i.Username,
 		); err != nil {
 			return nil, err
 		}
@@ -1513,84 +3114,62 @@ func (q *Queries) GetPostsByCategory(ctx context.Context, postCategoryID pgtype.
 	return items, nil
 }
 
-const getPostsByUser = `-- name: GetPostsByUser :many
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE user_id = $1 ORDER BY creation_date DESC
+const getPostVote = `-- name: GetPostVote :one
+SELECT post_id, user_id, value, creation_date FROM post_votes WHERE post_id = $1 AND user_id = $2
 `
 
-func (q *Queries) GetPostsByUser(ctx context.Context, userID pgtype.Int4) ([]Post, error) {
-	rows, err := q.db.Query(ctx, getPostsByUser, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Post
-	for rows.Next() {
-		var i Post
-		if err := rows.Scan(
-			&i.PostID,
-			&i.Title,
-			&i.Content,
-			&i.CreationDate,
-			&i.UserID,
-			&i.IsSticky,
-			&i.IsLocked,
-			&i.PostCategoryID,
-			&i.AdditionalNotes,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+type GetPostVoteParams struct {
+	PostID int32
+	UserID int32
 }
 
-const getPrivateMessageById = `-- name: GetPrivateMessageById :one
-SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read FROM private_messages WHERE message_id = $1
-`
-
-// Get a private message by its ID
-func (q *Queries) GetPrivateMessageById(ctx context.Context, messageID int32) (PrivateMessage, error) {
-	row := q.db.QueryRow(ctx, getPrivateMessageById, messageID)
-	var i PrivateMessage
+// Get a user's vote on a post
+func (q *Queries) GetPostVote(ctx context.Context, arg GetPostVoteParams) (PostVote, error) {
+	row := q.db.QueryRow(ctx, getPostVote, arg.PostID, arg.UserID)
+	var i PostVote
 	err := row.Scan(
-		&i.MessageID,
-		&i.Content,
-		&i.SenderUserID,
-		&i.ReceiverUserID,
-		&i.SentDate,
-		&i.IsRead,
+		&i.PostID,
+		&i.UserID,
+		&i.Value,
+		&i.CreationDate,
 	)
 	return i, err
 }
 
-const getPrivateMessagesByReceiverId = `-- name: GetPrivateMessagesByReceiverId :many
-SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read FROM private_messages WHERE receiver_user_id = $1 ORDER BY sent_date DESC
+const getPostWatch = `-- name: GetPostWatch :one
+SELECT user_id, post_id, creation_date FROM post_watches WHERE user_id = $1 AND post_id = $2
 `
 
-// Get all private messages received by a specific user
-func (q *Queries) GetPrivateMessagesByReceiverId(ctx context.Context, receiverUserID pgtype.Int4) ([]PrivateMessage, error) {
-	rows, err := q.db.Query(ctx, getPrivateMessagesByReceiverId, receiverUserID)
+type GetPostWatchParams struct {
+	UserID int32
+	PostID int32
+}
+
+func (q *Queries) GetPostWatch(ctx context.Context, arg GetPostWatchParams) (PostWatch, error) {
+	row := q.db.QueryRow(ctx, getPostWatch, arg.UserID, arg.PostID)
+	var i PostWatch
+	err := row.Scan(&i.UserID, &i.PostID, &i.CreationDate)
+	return i, err
+}
+
+const getPostWatcherIDs = `-- name: GetPostWatcherIDs :many
+SELECT user_id FROM post_watches WHERE post_id = $1
+`
+
+// Get the users watching a post
+func (q *Queries) GetPostWatcherIDs(ctx context.Context, postID int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, getPostWatcherIDs, postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []PrivateMessage
+	var items []int32
 	for rows.Next() {
-		var i PrivateMessage
-		if err := rows.Scan(
-			&i.MessageID,
-			&i.Content,
-			&i.SenderUserID,
-			&i.ReceiverUserID,
-			&i.SentDate,
-			&i.IsRead,
-		); err != nil {
+		var userID int32
+		if err := rows.Scan(&userID); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, userID)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -1598,27 +3177,52 @@ func (q *Queries) GetPrivateMessagesByReceiverId(ctx context.Context, receiverUs
 	return items, nil
 }
 
-const getPrivateMessagesBySenderId = `-- name: GetPrivateMessagesBySenderId :many
-SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read FROM private_messages WHERE sender_user_id = $1 ORDER BY sent_date DESC
+const getPostsByCategoryActive = `-- name: GetPostsByCategoryActive :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.post_category_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < ($3::boolean, $4::timestamptz, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT $5
 `
 
-// Get all private messages sent by a specific user
-func (q *Queries) GetPrivateMessagesBySenderId(ctx context.Context, senderUserID pgtype.Int4) ([]PrivateMessage, error) {
-	rows, err := q.db.Query(ctx, getPrivateMessagesBySenderId, senderUserID)
+type GetPostsByCategoryActiveParams struct {
+	PostCategoryID pgtype.Int4
+	CursorID       pgtype.Int4
+	CursorSticky   pgtype.Bool
+	CursorDate     pgtype.Timestamptz
+	PageLimit      int32
+}
+
+// Get a page of posts in a specific category, sticky posts first and then latest comment first, starting after the cursor
+func (q *Queries) GetPostsByCategoryActive(ctx context.Context, arg GetPostsByCategoryActiveParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByCategoryActive,
+		arg.PostCategoryID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []PrivateMessage
+	var items []Post
 	for rows.Next() {
-		var i PrivateMessage
+		var i Post
 		if err := rows.Scan(
-			&i.MessageID,
+			&i.PostID,
+			&i.Title,
 			&i.Content,
-			&i.SenderUserID,
-			&i.ReceiverUserID,
-			&i.SentDate,
-			&i.IsRead,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1630,46 +3234,74 @@ func (q *Queries) GetPrivateMessagesBySenderId(ctx context.Context, senderUserID
 	return items, nil
 }
 
-const getRSVPByID = `-- name: GetRSVPByID :one
-SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date FROM rsvps WHERE rsvp_id = $1
+const getPostsByCategoryHot = `-- name: GetPostsByCategoryHot :many
+SELECT ranked.post_id, ranked.title, ranked.content, ranked.content_html, ranked.creation_date, ranked.user_id, ranked.is_sticky, ranked.is_locked, ranked.post_category_id, ranked.additional_notes, ranked.edited_at, ranked.score, ranked.last_activity_date, ranked.sort_key
+FROM (
+  SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+  WHERE posts.post_category_id = $1
+) AS ranked
+WHERE ($2::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < ($3::boolean, $2::float8, $4::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT $5
 `
 
-// Get a specific RSVP by its ID
-func (q *Queries) GetRSVPByID(ctx context.Context, rsvpID int32) (Rsvp, error) {
-	row := q.db.QueryRow(ctx, getRSVPByID, rsvpID)
-	var i Rsvp
-	err := row.Scan(
-		&i.RsvpID,
-		&i.EventID,
-		&i.UserID,
-		&i.RsvpStatus,
-		&i.RsvpDate,
+type GetPostsByCategoryHotParams struct {
+	PostCategoryID pgtype.Int4
+	CursorKey      pgtype.Float8
+	CursorSticky   pgtype.Bool
+	CursorID       pgtype.Int4
+	PageLimit      int32
+}
+
+type GetPostsByCategoryHotRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	SortKey          float64
+}
+
+// Get a page of posts in a specific category, sticky posts first and then by score decayed by age, starting after the cursor
+func (q *Queries) GetPostsByCategoryHot(ctx context.Context, arg GetPostsByCategoryHotParams) ([]GetPostsByCategoryHotRow, error) {
+	rows, err := q.db.Query(ctx, getPostsByCategoryHot,
+		arg.PostCategoryID,
+		arg.CursorKey,
+		arg.CursorSticky,
+		arg.CursorID,
+		arg.PageLimit,
 	)
-	return i, err
-}
-
-const getRSVPs = `-- name: GetRSVPs :many
-
-SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date FROM rsvps
-`
-
-// ----------------------------------------------------------------------------------------------------------------------
-// Get all RSVPs
-func (q *Queries) GetRSVPs(ctx context.Context) ([]Rsvp, error) {
-	rows, err := q.db.Query(ctx, getRSVPs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Rsvp
+	var items []GetPostsByCategoryHotRow
 	for rows.Next() {
-		var i Rsvp
+		var i GetPostsByCategoryHotRow
 		if err := rows.Scan(
-			&i.RsvpID,
-			&i.EventID,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
-			&i.RsvpStatus,
-			&i.RsvpDate,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+			&i.SortKey,
 		); err != nil {
 			return nil, err
 		}
@@ -1681,26 +3313,52 @@ func (q *Queries) GetRSVPs(ctx context.Context) ([]Rsvp, error) {
 	return items, nil
 }
 
-const getRSVPsByEventID = `-- name: GetRSVPsByEventID :many
-SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date FROM rsvps WHERE event_id = $1
+const getPostsByCategoryNew = `-- name: GetPostsByCategoryNew :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.post_category_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < ($3::boolean, $4::timestamptz, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT $5
 `
 
-// Get all RSVPs for a specific event
-func (q *Queries) GetRSVPsByEventID(ctx context.Context, eventID pgtype.Int4) ([]Rsvp, error) {
-	rows, err := q.db.Query(ctx, getRSVPsByEventID, eventID)
+type GetPostsByCategoryNewParams struct {
+	PostCategoryID pgtype.Int4
+	CursorID       pgtype.Int4
+	CursorSticky   pgtype.Bool
+	CursorDate     pgtype.Timestamptz
+	PageLimit      int32
+}
+
+// Get a page of posts in a specific category, sticky posts first and then newest first, starting after the cursor
+func (q *Queries) GetPostsByCategoryNew(ctx context.Context, arg GetPostsByCategoryNewParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByCategoryNew,
+		arg.PostCategoryID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Rsvp
+	var items []Post
 	for rows.Next() {
-		var i Rsvp
+		var i Post
 		if err := rows.Scan(
-			&i.RsvpID,
-			&i.EventID,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
-			&i.RsvpStatus,
-			&i.RsvpDate,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1712,31 +3370,52 @@ func (q *Queries) GetRSVPsByEventID(ctx context.Context, eventID pgtype.Int4) ([
 	return items, nil
 }
 
-const getRSVPsByEventIDAndUserID = `-- name: GetRSVPsByEventIDAndUserID :many
-SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date FROM rsvps WHERE event_id = $1 AND user_id = $2
+const getPostsByCategoryTop = `-- name: GetPostsByCategoryTop :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.post_category_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < ($3::boolean, $4::int, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT $5
 `
 
-type GetRSVPsByEventIDAndUserIDParams struct {
-	EventID pgtype.Int4
-	UserID  pgtype.Int4
+type GetPostsByCategoryTopParams struct {
+	PostCategoryID pgtype.Int4
+	CursorID       pgtype.Int4
+	CursorSticky   pgtype.Bool
+	CursorScore    pgtype.Int4
+	PageLimit      int32
 }
 
-// Get all RSVPs for a specific event and user
-func (q *Queries) GetRSVPsByEventIDAndUserID(ctx context.Context, arg GetRSVPsByEventIDAndUserIDParams) ([]Rsvp, error) {
-	rows, err := q.db.Query(ctx, getRSVPsByEventIDAndUserID, arg.EventID, arg.UserID)
+// Get a page of posts in a specific category, sticky posts first and then highest score first, starting after the cursor
+func (q *Queries) GetPostsByCategoryTop(ctx context.Context, arg GetPostsByCategoryTopParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByCategoryTop,
+		arg.PostCategoryID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorScore,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Rsvp
+	var items []Post
 	for rows.Next() {
-		var i Rsvp
+		var i Post
 		if err := rows.Scan(
-			&i.RsvpID,
-			&i.EventID,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
-			&i.RsvpStatus,
-			&i.RsvpDate,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1748,26 +3427,52 @@ func (q *Queries) GetRSVPsByEventIDAndUserID(ctx context.Context, arg GetRSVPsBy
 	return items, nil
 }
 
-const getRSVPsByUserID = `-- name: GetRSVPsByUserID :many
-SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date FROM rsvps WHERE user_id = $1
+const getPostsByUserActive = `-- name: GetPostsByUserActive :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.user_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < ($3::boolean, $4::timestamptz, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT $5
 `
 
-// Get all RSVPs for a specific user
-func (q *Queries) GetRSVPsByUserID(ctx context.Context, userID pgtype.Int4) ([]Rsvp, error) {
-	rows, err := q.db.Query(ctx, getRSVPsByUserID, userID)
+type GetPostsByUserActiveParams struct {
+	UserID       pgtype.Int4
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorDate   pgtype.Timestamptz
+	PageLimit    int32
+}
+
+// Get a page of posts by a specific user, sticky posts first and then latest comment first, starting after the cursor
+func (q *Queries) GetPostsByUserActive(ctx context.Context, arg GetPostsByUserActiveParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByUserActive,
+		arg.UserID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Rsvp
+	var items []Post
 	for rows.Next() {
-		var i Rsvp
+		var i Post
 		if err := rows.Scan(
-			&i.RsvpID,
-			&i.EventID,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
-			&i.RsvpStatus,
-			&i.RsvpDate,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1779,66 +3484,74 @@ func (q *Queries) GetRSVPsByUserID(ctx context.Context, userID pgtype.Int4) ([]R
 	return items, nil
 }
 
-const getRole = `-- name: GetRole :one
-SELECT role_id, role_name FROM roles WHERE role_id = $1
-`
-
-// Get a role by id
-func (q *Queries) GetRole(ctx context.Context, roleID int32) (Role, error) {
-	row := q.db.QueryRow(ctx, getRole, roleID)
-	var i Role
-	err := row.Scan(&i.RoleID, &i.RoleName)
-	return i, err
-}
-
-const getRouteByID = `-- name: GetRouteByID :one
-SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes WHERE route_id = $1
+const getPostsByUserHot = `-- name: GetPostsByUserHot :many
+SELECT ranked.post_id, ranked.title, ranked.content, ranked.content_html, ranked.creation_date, ranked.user_id, ranked.is_sticky, ranked.is_locked, ranked.post_category_id, ranked.additional_notes, ranked.edited_at, ranked.score, ranked.last_activity_date, ranked.sort_key
+FROM (
+  SELECT posts.post_id, posts.title, posts.content, posts.content_html, posts.creation_date, posts.user_id, posts.is_sticky, posts.is_locked, posts.post_category_id, posts.additional_notes, posts.edited_at, posts.score, posts.last_activity_date, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+  WHERE posts.user_id = $1
+) AS ranked
+WHERE ($2::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < ($3::boolean, $2::float8, $4::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT $5
 `
 
-// Get a specific route by its ID
-func (q *Queries) GetRouteByID(ctx context.Context, routeID int32) (Route, error) {
-	row := q.db.QueryRow(ctx, getRouteByID, routeID)
-	var i Route
-	err := row.Scan(
-		&i.RouteID,
-		&i.Name,
-		&i.Description,
-		&i.StartLocation,
-		&i.EndLocation,
-		&i.Distance,
-		&i.ElevationGain,
-		&i.RouteMapLink,
-		&i.UserID,
+type GetPostsByUserHotParams struct {
+	UserID       pgtype.Int4
+	CursorKey    pgtype.Float8
+	CursorSticky pgtype.Bool
+	CursorID     pgtype.Int4
+	PageLimit    int32
+}
+
+type GetPostsByUserHotRow struct {
+	PostID           int32
+	Title            string
+	Content          string
+	ContentHtml      pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	UserID           pgtype.Int4
+	IsSticky         pgtype.Bool
+	IsLocked         pgtype.Bool
+	PostCategoryID   pgtype.Int4
+	AdditionalNotes  pgtype.Text
+	EditedAt         pgtype.Timestamptz
+	Score            int32
+	LastActivityDate pgtype.Timestamptz
+	SortKey          float64
+}
+
+// Get a page of posts by a specific user, sticky posts first and then by score decayed by age, starting after the cursor
+func (q *Queries) GetPostsByUserHot(ctx context.Context, arg GetPostsByUserHotParams) ([]GetPostsByUserHotRow, error) {
+	rows, err := q.db.Query(ctx, getPostsByUserHot,
+		arg.UserID,
+		arg.CursorKey,
+		arg.CursorSticky,
+		arg.CursorID,
+		arg.PageLimit,
 	)
-	return i, err
-}
-
-const getRoutes = `-- name: GetRoutes :many
-
-SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes ORDER BY name
-`
-
-// ----------------------------------------------------------------------------------------------------------------------
-// Get all routes, ordered by name
-func (q *Queries) GetRoutes(ctx context.Context) ([]Route, error) {
-	rows, err := q.db.Query(ctx, getRoutes)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Route
+	var items []GetPostsByUserHotRow
 	for rows.Next() {
-		var i Route
+		var i GetPostsByUserHotRow
 		if err := rows.Scan(
-			&i.RouteID,
-			&i.Name,
-			&i.Description,
-			&i.StartLocation,
-			&i.EndLocation,
-			&i.Distance,
-			&i.ElevationGain,
-			&i.RouteMapLink,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+			&i.SortKey,
 		); err != nil {
 			return nil, err
 		}
@@ -1850,30 +3563,52 @@ func (q *Queries) GetRoutes(ctx context.Context) ([]Route, error) {
 	return items, nil
 }
 
-const getRoutesByDistance = `-- name: GetRoutesByDistance :many
-SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes ORDER BY distance
+const getPostsByUserNew = `-- name: GetPostsByUserNew :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.user_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < ($3::boolean, $4::timestamptz, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT $5
 `
 
-// Get all routes, ordered by distance
-func (q *Queries) GetRoutesByDistance(ctx context.Context) ([]Route, error) {
-	rows, err := q.db.Query(ctx, getRoutesByDistance)
+type GetPostsByUserNewParams struct {
+	UserID       pgtype.Int4
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorDate   pgtype.Timestamptz
+	PageLimit    int32
+}
+
+// Get a page of posts by a specific user, sticky posts first and then newest first, starting after the cursor
+func (q *Queries) GetPostsByUserNew(ctx context.Context, arg GetPostsByUserNewParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByUserNew,
+		arg.UserID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorDate,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Route
+	var items []Post
 	for rows.Next() {
-		var i Route
+		var i Post
 		if err := rows.Scan(
-			&i.RouteID,
-			&i.Name,
-			&i.Description,
-			&i.StartLocation,
-			&i.EndLocation,
-			&i.Distance,
-			&i.ElevationGain,
-			&i.RouteMapLink,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1885,30 +3620,52 @@ func (q *Queries) GetRoutesByDistance(ctx context.Context) ([]Route, error) {
 	return items, nil
 }
 
-const getRoutesByUserID = `-- name: GetRoutesByUserID :many
-SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes WHERE user_id = $1 ORDER BY name
+const getPostsByUserTop = `-- name: GetPostsByUserTop :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts
+WHERE posts.user_id = $1
+AND ($2::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < ($3::boolean, $4::int, $2::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT $5
 `
 
-// Get all routes created by a specific user
-func (q *Queries) GetRoutesByUserID(ctx context.Context, userID pgtype.Int4) ([]Route, error) {
-	rows, err := q.db.Query(ctx, getRoutesByUserID, userID)
+type GetPostsByUserTopParams struct {
+	UserID       pgtype.Int4
+	CursorID     pgtype.Int4
+	CursorSticky pgtype.Bool
+	CursorScore  pgtype.Int4
+	PageLimit    int32
+}
+
+// Get a page of posts by a specific user, sticky posts first and then highest score first, starting after the cursor
+func (q *Queries) GetPostsByUserTop(ctx context.Context, arg GetPostsByUserTopParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getPostsByUserTop,
+		arg.UserID,
+		arg.CursorID,
+		arg.CursorSticky,
+		arg.CursorScore,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Route
+	var items []Post
 	for rows.Next() {
-		var i Route
+		var i Post
 		if err := rows.Scan(
-			&i.RouteID,
-			&i.Name,
-			&i.Description,
-			&i.StartLocation,
-			&i.EndLocation,
-			&i.Distance,
-			&i.ElevationGain,
-			&i.RouteMapLink,
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
 			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1920,30 +3677,26 @@ func (q *Queries) GetRoutesByUserID(ctx context.Context, userID pgtype.Int4) ([]
 	return items, nil
 }
 
-const getStickyPosts = `-- name: GetStickyPosts :many
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE is_sticky = TRUE ORDER BY creation_date DESC
+const getPostsWithoutContentHTML = `-- name: GetPostsWithoutContentHTML :many
+SELECT post_id, content FROM posts WHERE content_html IS NULL ORDER BY post_id LIMIT $1
 `
 
-func (q *Queries) GetStickyPosts(ctx context.Context) ([]Post, error) {
-	rows, err := q.db.Query(ctx, getStickyPosts)
+type GetPostsWithoutContentHTMLRow struct {
+	PostID  int32
+	Content string
+}
+
+// Get the content of posts whose Markdown has not been rendered yet
+func (q *Queries) GetPostsWithoutContentHTML(ctx context.Context, limit int32) ([]GetPostsWithoutContentHTMLRow, error) {
+	rows, err := q.db.Query(ctx, getPostsWithoutContentHTML, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsWithoutContentHTMLRow
 	for rows.Next() {
-		var i Post
-		if err := rows.Scan(
-			&i.PostID,
-			&i.Title,
-			&i.Content,
-			&i.CreationDate,
-			&i.UserID,
-			&i.IsSticky,
-			&i.IsLocked,
-			&i.PostCategoryID,
-			&i.AdditionalNotes,
-		); err != nil {
+		var i GetPostsWithoutContentHTMLRow
+		if err := rows.Scan(&i.PostID, &i.Content); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -1954,29 +3707,48 @@ func (q *Queries) GetStickyPosts(ctx context.Context) ([]Post, error) {
 	return items, nil
 }
 
-const getUnlockedPosts = `-- name: GetUnlockedPosts :many
-SELECT post_id, title, content, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes FROM posts WHERE is_locked = FALSE ORDER BY creation_date DESC
+const getPrivateMessageById = `-- name: GetPrivateMessageById :one
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages WHERE message_id = $1
 `
 
-func (q *Queries) GetUnlockedPosts(ctx context.Context) ([]Post, error) {
-	rows, err := q.db.Query(ctx, getUnlockedPosts)
+// Get a private message by its ID
+func (q *Queries) GetPrivateMessageById(ctx context.Context, messageID int32) (PrivateMessage, error) {
+	row := q.db.QueryRow(ctx, getPrivateMessageById, messageID)
+	var i PrivateMessage
+	err := row.Scan(
+		&i.MessageID,
+		&i.Content,
+		&i.SenderUserID,
+		&i.ReceiverUserID,
+		&i.SentDate,
+		&i.IsRead,
+		&i.DeliveredDate,
+	)
+	return i, err
+}
+
+const getPrivateMessagesByReceiverId = `-- name: GetPrivateMessagesByReceiverId :many
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages WHERE receiver_user_id = $1 ORDER BY sent_date DESC
+`
+
+// Get all private messages received by a specific user
+func (q *Queries) GetPrivateMessagesByReceiverId(ctx context.Context, receiverUserID pgtype.Int4) ([]PrivateMessage, error) {
+	rows, err := q.db.Query(ctx, getPrivateMessagesByReceiverId, receiverUserID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []PrivateMessage
 	for rows.Next() {
-		var i Post
+		var i PrivateMessage
 		if err := rows.Scan(
-			&i.PostID,
-			&i.Title,
+			&i.MessageID,
 			&i.Content,
-			&i.CreationDate,
-			&i.UserID,
-			&i.IsSticky,
-			&i.IsLocked,
-			&i.PostCategoryID,
-			&i.AdditionalNotes,
+			&i.SenderUserID,
+			&i.ReceiverUserID,
+			&i.SentDate,
+			&i.IsRead,
+			&i.DeliveredDate,
 		); err != nil {
 			return nil, err
 		}
@@ -1988,13 +3760,13 @@ func (q *Queries) GetUnlockedPosts(ctx context.Context) ([]Post, error) {
 	return items, nil
 }
 
-const getUnreadPrivateMessagesByReceiverId = `-- name: GetUnreadPrivateMessagesByReceiverId :many
-SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read FROM private_messages WHERE receiver_user_id = $1 AND is_read = FALSE ORDER BY sent_date DESC
+const getPrivateMessagesBySenderId = `-- name: GetPrivateMessagesBySenderId :many
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages WHERE sender_user_id = $1 ORDER BY sent_date DESC
 `
 
-// Get all unread private messages received by a specific user
-func (q *Queries) GetUnreadPrivateMessagesByReceiverId(ctx context.Context, receiverUserID pgtype.Int4) ([]PrivateMessage, error) {
-	rows, err := q.db.Query(ctx, getUnreadPrivateMessagesByReceiverId, receiverUserID)
+// Get all private messages sent by a specific user
+func (q *Queries) GetPrivateMessagesBySenderId(ctx context.Context, senderUserID pgtype.Int4) ([]PrivateMessage, error) {
+	rows, err := q.db.Query(ctx, getPrivateMessagesBySenderId, senderUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -2009,6 +3781,7 @@ func (q *Queries) GetUnreadPrivateMessagesByReceiverId(ctx context.Context, rece
 			&i.ReceiverUserID,
 			&i.SentDate,
 			&i.IsRead,
+			&i.DeliveredDate,
 		); err != nil {
 			return nil, err
 		}
@@ -2020,168 +3793,131 @@ func (q *Queries) GetUnreadPrivateMessagesByReceiverId(ctx context.Context, rece
 	return items, nil
 }
 
-const getUser = `-- name: GetUser :one
-SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users WHERE user_id = $1
+const getPrivateMessagesByUserIdAfter = `-- name: GetPrivateMessagesByUserIdAfter :many
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages
+WHERE (sender_user_id = $1::int OR receiver_user_id = $1::int)
+AND (message_id > $2::int OR sent_date > $3::timestamptz)
+AND message_id > $4::int
+ORDER BY message_id
+LIMIT $5
 `
 
-type GetUserRow struct {
-	UserID           int32
-	Username         string
-	Email            string
-	RegistrationDate pgtype.Timestamptz
-	ProfilePicture   pgtype.Text
-	Biography        pgtype.Text
-	LastLoginDate    pgtype.Timestamptz
-	IsActive         pgtype.Bool
-	RoleID           pgtype.Int4
+type GetPrivateMessagesByUserIdAfterParams struct {
+	UserID      int32
+	AfterID     int32
+	Since       pgtype.Timestamptz
+	PageAfterID int32
+	PageLimit   int32
 }
 
-// Get a user by id, no password_hash
-func (q *Queries) GetUser(ctx context.Context, userID int32) (GetUserRow, error) {
-	row := q.db.QueryRow(ctx, getUser, userID)
-	var i GetUserRow
-	err := row.Scan(
-		&i.UserID,
-		&i.Username,
-		&i.Email,
-		&i.RegistrationDate,
-		&i.ProfilePicture,
-		&i.Biography,
-		&i.LastLoginDate,
-		&i.IsActive,
-		&i.RoleID,
+// Get the messages a user has sent or received after after_id or since a time, oldest first, to resume the real-time
+// stream. Pages start after page_after_id.
+func (q *Queries) GetPrivateMessagesByUserIdAfter(ctx context.Context, arg GetPrivateMessagesByUserIdAfterParams) ([]PrivateMessage, error) {
+	rows, err := q.db.Query(ctx, getPrivateMessagesByUserIdAfter,
+		arg.UserID,
+		arg.AfterID,
+		arg.Since,
+		arg.PageAfterID,
+		arg.PageLimit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrivateMessage
+	for rows.Next() {
+		var i PrivateMessage
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Content,
+			&i.SenderUserID,
+			&i.ReceiverUserID,
+			&i.SentDate,
+			&i.IsRead,
+			&i.DeliveredDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT user_id, username, email, password_hash, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users WHERE email = $1
+const getRSVPByEventIDAndUserID = `-- name: GetRSVPByEventIDAndUserID :one
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND user_id = $2 AND occurrence_date IS NOT DISTINCT FROM $3::timestamptz
 `
 
-// Get a user by email
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i User
-	err := row.Scan(
-		&i.UserID,
-		&i.Username,
-		&i.Email,
-		&i.PasswordHash,
-		&i.RegistrationDate,
-		&i.ProfilePicture,
-		&i.Biography,
-		&i.LastLoginDate,
-		&i.IsActive,
-		&i.RoleID,
-	)
-	return i, err
+type GetRSVPByEventIDAndUserIDParams struct {
+	EventID        pgtype.Int4
+	UserID         pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
 }
 
-const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT user_id, username, email, password_hash, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users WHERE username = $1
-`
-
-// Get a user by username
-func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByUsername, username)
-	var i User
+// Get the RSVP of a specific user to a specific event occurrence
+func (q *Queries) GetRSVPByEventIDAndUserID(ctx context.Context, arg GetRSVPByEventIDAndUserIDParams) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, getRSVPByEventIDAndUserID, arg.EventID, arg.UserID, arg.OccurrenceDate)
+	var i Rsvp
 	err := row.Scan(
+		&i.RsvpID,
+		&i.EventID,
 		&i.UserID,
-		&i.Username,
-		&i.Email,
-		&i.PasswordHash,
-		&i.RegistrationDate,
-		&i.ProfilePicture,
-		&i.Biography,
-		&i.LastLoginDate,
-		&i.IsActive,
-		&i.RoleID,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
 	)
 	return i, err
 }
 
-const getUserSession = `-- name: GetUserSession :one
-
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE session_id = $1
+const getRSVPByID = `-- name: GetRSVPByID :one
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE rsvp_id = $1
 `
 
-// ----------------------------------------------------------------------------------------------------------------------
-// Get a single user session by session_id
-func (q *Queries) GetUserSession(ctx context.Context, sessionID pgtype.UUID) (UserSession, error) {
-	row := q.db.QueryRow(ctx, getUserSession, sessionID)
-	var i UserSession
+// Get a specific RSVP by its ID
+func (q *Queries) GetRSVPByID(ctx context.Context, rsvpID int32) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, getRSVPByID, rsvpID)
+	var i Rsvp
 	err := row.Scan(
-		&i.SessionID,
+		&i.RsvpID,
+		&i.EventID,
 		&i.UserID,
-		&i.CreationDate,
-		&i.ExpiryDate,
-		&i.IpAddress,
-		&i.UserAgent,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
 	)
 	return i, err
 }
 
-const getUserSessionAndRoleName = `-- name: GetUserSessionAndRoleName :one
-SELECT user_sessions.session_id, user_sessions.user_id, user_sessions.expiry_date, user_sessions.ip_address, user_sessions.user_agent, user_sessions.creation_date, users.role_id, roles.role_name
-FROM user_sessions
-INNER JOIN users ON user_sessions.user_id = users.user_id
-INNER JOIN roles ON users.role_id = roles.role_id
-WHERE session_id = $1
+const getRSVPUserIDsByEventID = `-- name: GetRSVPUserIDsByEventID :many
+SELECT user_id FROM rsvps
+WHERE event_id = $1 AND rsvp_status <> 'declined' AND user_id IS NOT NULL
+AND (NOT $2::boolean OR occurrence_date IS NOT DISTINCT FROM $3::timestamptz)
+GROUP BY user_id
 `
 
-type GetUserSessionAndRoleNameRow struct {
-	SessionID    pgtype.UUID
-	UserID       pgtype.Int4
-	ExpiryDate   pgtype.Timestamptz
-	IpAddress    *netip.Addr
-	UserAgent    pgtype.Text
-	CreationDate pgtype.Timestamptz
-	RoleID       pgtype.Int4
-	RoleName     string
-}
-
-// Get a single user session by session_id, with role_name
-func (q *Queries) GetUserSessionAndRoleName(ctx context.Context, sessionID pgtype.UUID) (GetUserSessionAndRoleNameRow, error) {
-	row := q.db.QueryRow(ctx, getUserSessionAndRoleName, sessionID)
-	var i GetUserSessionAndRoleNameRow
-	err := row.Scan(
-		&i.SessionID,
-		&i.UserID,
-		&i.ExpiryDate,
-		&i.IpAddress,
-		&i.UserAgent,
-		&i.CreationDate,
-		&i.RoleID,
-		&i.RoleName,
-	)
-	return i, err
+type GetRSVPUserIDsByEventIDParams struct {
+	EventID          pgtype.Int4
+	SingleOccurrence bool
+	OccurrenceDate   pgtype.Timestamptz
 }
 
-const getUserSessionsByDate = `-- name: GetUserSessionsByDate :many
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE DATE(creation_date) = $1
-`
-
-// Get all sessions created on a specific date
-func (q *Queries) GetUserSessionsByDate(ctx context.Context, creationDate pgtype.Date) ([]UserSession, error) {
-	rows, err := q.db.Query(ctx, getUserSessionsByDate, creationDate)
+// Get the users who have RSVPed to an event, other than declining, either to any occurrence or, when
+// single_occurrence is true, to the occurrence at occurrence_date
+func (q *Queries) GetRSVPUserIDsByEventID(ctx context.Context, arg GetRSVPUserIDsByEventIDParams) ([]pgtype.Int4, error) {
+	rows, err := q.db.Query(ctx, getRSVPUserIDsByEventID, arg.EventID, arg.SingleOccurrence, arg.OccurrenceDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserSession
+	var items []pgtype.Int4
 	for rows.Next() {
-		var i UserSession
-		if err := rows.Scan(
-			&i.SessionID,
-			&i.UserID,
-			&i.CreationDate,
-			&i.ExpiryDate,
-			&i.IpAddress,
-			&i.UserAgent,
-		); err != nil {
+		var userID pgtype.Int4
+		if err := rows.Scan(&userID); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, userID)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -2189,27 +3925,29 @@ func (q *Queries) GetUserSessionsByDate(ctx context.Context, creationDate pgtype
 	return items, nil
 }
 
-const getUserSessionsByIP = `-- name: GetUserSessionsByIP :many
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE ip_address = $1
+const getRSVPs = `-- name: GetRSVPs :many
+
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps
 `
 
-// Get all sessions from a specific IP address
-func (q *Queries) GetUserSessionsByIP(ctx context.Context, ipAddress *netip.Addr) ([]UserSession, error) {
-	rows, err := q.db.Query(ctx, getUserSessionsByIP, ipAddress)
+// ----------------------------------------------------------------------------------------------------------------------
+// Get all RSVPs
+func (q *Queries) GetRSVPs(ctx context.Context) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserSession
+	var items []Rsvp
 	for rows.Next() {
-		var i UserSession
+		var i Rsvp
 		if err := rows.Scan(
-			&i.SessionID,
+			&i.RsvpID,
+			&i.EventID,
 			&i.UserID,
-			&i.CreationDate,
-			&i.ExpiryDate,
-			&i.IpAddress,
-			&i.UserAgent,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
 		); err != nil {
 			return nil, err
 		}
@@ -2221,27 +3959,27 @@ func (q *Queries) GetUserSessionsByIP(ctx context.Context, ipAddress *netip.Addr
 	return items, nil
 }
 
-const getUserSessionsByUserId = `-- name: GetUserSessionsByUserId :many
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE user_id = $1
+const getRSVPsByEventID = `-- name: GetRSVPsByEventID :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1
 `
 
-// Get all sessions for a specific user_id
-func (q *Queries) GetUserSessionsByUserId(ctx context.Context, userID pgtype.Int4) ([]UserSession, error) {
-	rows, err := q.db.Query(ctx, getUserSessionsByUserId, userID)
+// Get all RSVPs for a specific event
+func (q *Queries) GetRSVPsByEventID(ctx context.Context, eventID pgtype.Int4) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByEventID, eventID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserSession
+	var items []Rsvp
 	for rows.Next() {
-		var i UserSession
+		var i Rsvp
 		if err := rows.Scan(
-			&i.SessionID,
+			&i.RsvpID,
+			&i.EventID,
 			&i.UserID,
-			&i.CreationDate,
-			&i.ExpiryDate,
-			&i.IpAddress,
-			&i.UserAgent,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
 		); err != nil {
 			return nil, err
 		}
@@ -2253,27 +3991,32 @@ func (q *Queries) GetUserSessionsByUserId(ctx context.Context, userID pgtype.Int
 	return items, nil
 }
 
-const getUserSessionsFilteredByDate = `-- name: GetUserSessionsFilteredByDate :many
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE creation_date > $1
+const getRSVPsByEventIDAndUserID = `-- name: GetRSVPsByEventIDAndUserID :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND user_id = $2
 `
 
-// Get all user sessions after a specific date
-func (q *Queries) GetUserSessionsFilteredByDate(ctx context.Context, creationDate pgtype.Timestamptz) ([]UserSession, error) {
-	rows, err := q.db.Query(ctx, getUserSessionsFilteredByDate, creationDate)
+type GetRSVPsByEventIDAndUserIDParams struct {
+	EventID pgtype.Int4
+	UserID  pgtype.Int4
+}
+
+// Get all RSVPs for a specific event and user
+func (q *Queries) GetRSVPsByEventIDAndUserID(ctx context.Context, arg GetRSVPsByEventIDAndUserIDParams) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByEventIDAndUserID, arg.EventID, arg.UserID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserSession
+	var items []Rsvp
 	for rows.Next() {
-		var i UserSession
+		var i Rsvp
 		if err := rows.Scan(
-			&i.SessionID,
+			&i.RsvpID,
+			&i.EventID,
 			&i.UserID,
-			&i.CreationDate,
-			&i.ExpiryDate,
-			&i.IpAddress,
-			&i.UserAgent,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
 		); err != nil {
 			return nil, err
 		}
@@ -2285,27 +4028,32 @@ func (q *Queries) GetUserSessionsFilteredByDate(ctx context.Context, creationDat
 	return items, nil
 }
 
-const getUserSessionsOrderedByDate = `-- name: GetUserSessionsOrderedByDate :many
-SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions ORDER BY creation_date
+const getRSVPsByEventOccurrence = `-- name: GetRSVPsByEventOccurrence :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND occurrence_date IS NOT DISTINCT FROM $2::timestamptz
 `
 
-// Get all user sessions ordered by creation_date
-func (q *Queries) GetUserSessionsOrderedByDate(ctx context.Context) ([]UserSession, error) {
-	rows, err := q.db.Query(ctx, getUserSessionsOrderedByDate)
+type GetRSVPsByEventOccurrenceParams struct {
+	EventID        pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Get all RSVPs for a specific event occurrence. The occurrence date is NULL for one-off events.
+func (q *Queries) GetRSVPsByEventOccurrence(ctx context.Context, arg GetRSVPsByEventOccurrenceParams) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByEventOccurrence, arg.EventID, arg.OccurrenceDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserSession
+	var items []Rsvp
 	for rows.Next() {
-		var i UserSession
+		var i Rsvp
 		if err := rows.Scan(
-			&i.SessionID,
+			&i.RsvpID,
+			&i.EventID,
 			&i.UserID,
-			&i.CreationDate,
-			&i.ExpiryDate,
-			&i.IpAddress,
-			&i.UserAgent,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
 		); err != nil {
 			return nil, err
 		}
@@ -2317,70 +4065,116 @@ func (q *Queries) GetUserSessionsOrderedByDate(ctx context.Context) ([]UserSessi
 	return items, nil
 }
 
-const getUserWithRoleName = `-- name: GetUserWithRoleName :one
-SELECT users.user_id, users.username, users.email, users.registration_date, users.profile_picture, users.biography, users.last_login_date, users.is_active, users.role_id, roles.role_name
-FROM users
-INNER JOIN roles ON users.role_id = roles.role_id
-WHERE users.user_id = $1
+const getRSVPsByEventOccurrencePage = `-- name: GetRSVPsByEventOccurrencePage :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND occurrence_date IS NOT DISTINCT FROM $2::timestamptz
+AND ($3::int IS NULL OR rsvp_id > $3::int)
+ORDER BY rsvp_id
+LIMIT $4
 `
 
-type GetUserWithRoleNameRow struct {
-	UserID           int32
-	Username         string
-	Email            string
-	RegistrationDate pgtype.Timestamptz
-	ProfilePicture   pgtype.Text
-	Biography        pgtype.Text
-	LastLoginDate    pgtype.Timestamptz
-	IsActive         pgtype.Bool
-	RoleID           pgtype.Int4
-	RoleName         string
+type GetRSVPsByEventOccurrencePageParams struct {
+	EventID        pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+	CursorID       pgtype.Int4
+	PageLimit      int32
 }
 
-// Get a user by id, no password_hash, with role_name
-func (q *Queries) GetUserWithRoleName(ctx context.Context, userID int32) (GetUserWithRoleNameRow, error) {
-	row := q.db.QueryRow(ctx, getUserWithRoleName, userID)
-	var i GetUserWithRoleNameRow
-	err := row.Scan(
-		&i.UserID,
-		&i.Username,
-		&i.Email,
-		&i.RegistrationDate,
-		&i.ProfilePicture,
-		&i.Biography,
-		&i.LastLoginDate,
-		&i.IsActive,
-		&i.RoleID,
-		&i.RoleName,
+// Get a page of the RSVPs for a specific event occurrence, in the order they were first made, starting after the cursor
+func (q *Queries) GetRSVPsByEventOccurrencePage(ctx context.Context, arg GetRSVPsByEventOccurrencePageParams) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByEventOccurrencePage,
+		arg.EventID,
+		arg.OccurrenceDate,
+		arg.CursorID,
+		arg.PageLimit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Rsvp
+	for rows.Next() {
+		var i Rsvp
+		if err := rows.Scan(
+			&i.RsvpID,
+			&i.EventID,
+			&i.UserID,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const invalidateUserSession = `-- name: InvalidateUserSession :exec
-UPDATE user_sessions SET expiry_date = TIMESTAMP '1970-01-01 00:00:00' WHERE session_id = $1
+const getRSVPsByUserID = `-- name: GetRSVPsByUserID :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE user_id = $1
 `
 
-// Invalidate a user session by setting the expiry_date to a past date
-func (q *Queries) InvalidateUserSession(ctx context.Context, sessionID pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, invalidateUserSession, sessionID)
-	return err
+// Get all RSVPs for a specific user
+func (q *Queries) GetRSVPsByUserID(ctx context.Context, userID pgtype.Int4) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Rsvp
+	for rows.Next() {
+		var i Rsvp
+		if err := rows.Scan(
+			&i.RsvpID,
+			&i.EventID,
+			&i.UserID,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const listRoles = `-- name: ListRoles :many
-SELECT role_id, role_name FROM roles
+const getRSVPsByUserIDPage = `-- name: GetRSVPsByUserIDPage :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE user_id = $1
+AND ($2::int IS NULL OR rsvp_id < $2::int)
+ORDER BY rsvp_id DESC
+LIMIT $3
 `
 
-// Get all roles
-func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
-	rows, err := q.db.Query(ctx, listRoles)
+type GetRSVPsByUserIDPageParams struct {
+	UserID    pgtype.Int4
+	CursorID  pgtype.Int4
+	PageLimit int32
+}
+
+// Get a page of the RSVPs of a specific user, newest first, starting after the cursor
+func (q *Queries) GetRSVPsByUserIDPage(ctx context.Context, arg GetRSVPsByUserIDPageParams) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getRSVPsByUserIDPage, arg.UserID, arg.CursorID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Role
+	var items []Rsvp
 	for rows.Next() {
-		var i Role
-		if err := rows.Scan(&i.RoleID, &i.RoleName); err != nil {
+		var i Rsvp
+		if err := rows.Scan(
+			&i.RsvpID,
+			&i.EventID,
+			&i.UserID,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -2391,32 +4185,1771 @@ func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
 	return items, nil
 }
 
-const lockPost = `-- name: LockPost :exec
-UPDATE posts SET is_locked = TRUE WHERE post_id = $1
+const getRecurringEventsStartingBefore = `-- name: GetRecurringEventsStartingBefore :many
+SELECT event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone FROM events WHERE recurrence_rule IS NOT NULL AND event_date <= $1 ORDER BY event_date
 `
 
-func (q *Queries) LockPost(ctx context.Context, postID int32) error {
-	_, err := q.db.Exec(ctx, lockPost, postID)
+// Get all recurring events whose first occurrence is before a specific date
+func (q *Queries) GetRecurringEventsStartingBefore(ctx context.Context, eventDate pgtype.Timestamptz) ([]Event, error) {
+	rows, err := q.db.Query(ctx, getRecurringEventsStartingBefore, eventDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.EventID,
+			&i.Title,
+			&i.Description,
+			&i.EventDate,
+			&i.MeetingPoint,
+			&i.RouteID,
+			&i.CreatorUserID,
+			&i.Capacity,
+			&i.RecurrenceRule,
+			&i.TimeZone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReport = `-- name: GetReport :one
+SELECT report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date FROM reports WHERE report_id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, reportID int32) (Report, error) {
+	row := q.db.QueryRow(ctx, getReport, reportID)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const getReportForUpdate = `-- name: GetReportForUpdate :one
+SELECT report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date FROM reports WHERE report_id = $1 FOR UPDATE
+`
+
+// Get a report and lock it until the end of the transaction
+func (q *Queries) GetReportForUpdate(ctx context.Context, reportID int32) (Report, error) {
+	row := q.db.QueryRow(ctx, getReportForUpdate, reportID)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const getReportSubmissions = `-- name: GetReportSubmissions :many
+SELECT report_submissions.submission_id, report_submissions.report_id, report_submissions.reporter_user_id, report_submissions.reason_category, report_submissions.note, report_submissions.creation_date, users.username AS reporter_username
+FROM report_submissions
+LEFT JOIN users ON report_submissions.reporter_user_id = users.user_id
+WHERE report_submissions.report_id = $1
+ORDER BY report_submissions.creation_date, report_submissions.submission_id
+`
+
+type GetReportSubmissionsRow struct {
+	SubmissionID     int32
+	ReportID         int32
+	ReporterUserID   pgtype.Int4
+	ReasonCategory   string
+	Note             pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	ReporterUsername pgtype.Text
+}
+
+// Get the submissions of a report with their reporters' usernames, oldest first
+func (q *Queries) GetReportSubmissions(ctx context.Context, reportID int32) ([]GetReportSubmissionsRow, error) {
+	rows, err := q.db.Query(ctx, getReportSubmissions, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReportSubmissionsRow
+	for rows.Next() {
+		var i GetReportSubmissionsRow
+		if err := rows.Scan(
+			&i.SubmissionID,
+			&i.ReportID,
+			&i.ReporterUserID,
+			&i.ReasonCategory,
+			&i.Note,
+			&i.CreationDate,
+			&i.ReporterUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReports = `-- name: GetReports :many
+SELECT reports.report_id, reports.target_type, reports.target_id, reports.target_user_id, reports.status, reports.submission_count, reports.creation_date, reports.last_reported_date, reports.reviewer_user_id, reports.resolution_action, reports.resolution_note, reports.resolution_date, target_users.username AS target_username, reviewers.username AS reviewer_username
+FROM reports
+LEFT JOIN users AS target_users ON reports.target_user_id = target_users.user_id
+LEFT JOIN users AS reviewers ON reports.reviewer_user_id = reviewers.user_id
+WHERE ($1::text IS NULL OR reports.status = $1::text)
+AND ($2::text IS NULL OR reports.target_type = $2::text)
+AND ($3::timestamptz IS NULL OR (reports.last_reported_date, reports.report_id) < ($3::timestamptz, $4::int))
+ORDER BY reports.last_reported_date DESC, reports.report_id DESC
+LIMIT $5
+`
+
+type GetReportsParams struct {
+	Status     pgtype.Text
+	TargetType pgtype.Text
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type GetReportsRow struct {
+	ReportID         int32
+	TargetType       string
+	TargetID         int32
+	TargetUserID     pgtype.Int4
+	Status           string
+	SubmissionCount  int32
+	CreationDate     pgtype.Timestamptz
+	LastReportedDate pgtype.Timestamptz
+	ReviewerUserID   pgtype.Int4
+	ResolutionAction pgtype.Text
+	ResolutionNote   pgtype.Text
+	ResolutionDate   pgtype.Timestamptz
+	TargetUsername   pgtype.Text
+	ReviewerUsername pgtype.Text
+}
+
+// Get a page of reports matching the given filters, most recently reported first, starting after the cursor.
+// Each filter is skipped when NULL.
+func (q *Queries) GetReports(ctx context.Context, arg GetReportsParams) ([]GetReportsRow, error) {
+	rows, err := q.db.Query(ctx, getReports,
+		arg.Status,
+		arg.TargetType,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReportsRow
+	for rows.Next() {
+		var i GetReportsRow
+		if err := rows.Scan(
+			&i.ReportID,
+			&i.TargetType,
+			&i.TargetID,
+			&i.TargetUserID,
+			&i.Status,
+			&i.SubmissionCount,
+			&i.CreationDate,
+			&i.LastReportedDate,
+			&i.ReviewerUserID,
+			&i.ResolutionAction,
+			&i.ResolutionNote,
+			&i.ResolutionDate,
+			&i.TargetUsername,
+			&i.ReviewerUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRole = `-- name: GetRole :one
+SELECT role_id, role_name FROM roles WHERE role_id = $1
+`
+
+// Get a role by id
+func (q *Queries) GetRole(ctx context.Context, roleID int32) (Role, error) {
+	row := q.db.QueryRow(ctx, getRole, roleID)
+	var i Role
+	err := row.Scan(&i.RoleID, &i.RoleName)
+	return i, err
+}
+
+const getRouteByID = `-- name: GetRouteByID :one
+SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes WHERE route_id = $1
+`
+
+// Get a specific route by its ID
+func (q *Queries) GetRouteByID(ctx context.Context, routeID int32) (Route, error) {
+	row := q.db.QueryRow(ctx, getRouteByID, routeID)
+	var i Route
+	err := row.Scan(
+		&i.RouteID,
+		&i.Name,
+		&i.Description,
+		&i.StartLocation,
+		&i.EndLocation,
+		&i.Distance,
+		&i.ElevationGain,
+		&i.RouteMapLink,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getRouteByIDForUpdate = `-- name: GetRouteByIDForUpdate :one
+SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes WHERE route_id = $1 FOR UPDATE
+`
+
+// Get a specific route by its ID, locking it until the end of the transaction
+func (q *Queries) GetRouteByIDForUpdate(ctx context.Context, routeID int32) (Route, error) {
+	row := q.db.QueryRow(ctx, getRouteByIDForUpdate, routeID)
+	var i Route
+	err := row.Scan(
+		&i.RouteID,
+		&i.Name,
+		&i.Description,
+		&i.StartLocation,
+		&i.EndLocation,
+		&i.Distance,
+		&i.ElevationGain,
+		&i.RouteMapLink,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getRoutePointsByRouteID = `-- name: GetRoutePointsByRouteID :many
+SELECT route_id, point_index, latitude, longitude, elevation FROM route_points WHERE route_id = $1 ORDER BY point_index
+`
+
+// Get the track points of a route in order
+func (q *Queries) GetRoutePointsByRouteID(ctx context.Context, routeID int32) ([]RoutePoint, error) {
+	rows, err := q.db.Query(ctx, getRoutePointsByRouteID, routeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RoutePoint
+	for rows.Next() {
+		var i RoutePoint
+		if err := rows.Scan(
+			&i.RouteID,
+			&i.PointIndex,
+			&i.Latitude,
+			&i.Longitude,
+			&i.Elevation,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoutes = `-- name: GetRoutes :many
+
+SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes
+WHERE $1::int IS NULL OR (name, route_id) > ($2::text, $1::int)
+ORDER BY name, route_id
+LIMIT $3
+`
+
+type GetRoutesParams struct {
+	CursorID   pgtype.Int4
+	CursorName pgtype.Text
+	PageLimit  int32
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Get a page of routes, ordered by name, starting after the cursor
+func (q *Queries) GetRoutes(ctx context.Context, arg GetRoutesParams) ([]Route, error) {
+	rows, err := q.db.Query(ctx, getRoutes, arg.CursorID, arg.CursorName, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Route
+	for rows.Next() {
+		var i Route
+		if err := rows.Scan(
+			&i.RouteID,
+			&i.Name,
+			&i.Description,
+			&i.StartLocation,
+			&i.EndLocation,
+			&i.Distance,
+			&i.ElevationGain,
+			&i.RouteMapLink,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoutesByDistance = `-- name: GetRoutesByDistance :many
+SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes
+WHERE $1::int IS NULL
+OR ($2::float8 IS NULL AND distance IS NULL AND route_id > $1::int)
+OR ($2::float8 IS NOT NULL AND (distance IS NULL OR (distance, route_id) > ($2::float8, $1::int)))
+ORDER BY distance NULLS LAST, route_id
+LIMIT $3
+`
+
+type GetRoutesByDistanceParams struct {
+	CursorID       pgtype.Int4
+	CursorDistance pgtype.Float8
+	PageLimit      int32
+}
+
+// Get a page of routes, shortest first and routes without a distance last, starting after the cursor.
+// A cursor without a distance is on a route without one.
+func (q *Queries) GetRoutesByDistance(ctx context.Context, arg GetRoutesByDistanceParams) ([]Route, error) {
+	rows, err := q.db.Query(ctx, getRoutesByDistance, arg.CursorID, arg.CursorDistance, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Route
+	for rows.Next() {
+		var i Route
+		if err := rows.Scan(
+			&i.RouteID,
+			&i.Name,
+			&i.Description,
+			&i.StartLocation,
+			&i.EndLocation,
+			&i.Distance,
+			&i.ElevationGain,
+			&i.RouteMapLink,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoutesByUserID = `-- name: GetRoutesByUserID :many
+SELECT route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id FROM routes WHERE user_id = $1
+AND ($2::int IS NULL OR (name, route_id) > ($3::text, $2::int))
+ORDER BY name, route_id
+LIMIT $4
+`
+
+type GetRoutesByUserIDParams struct {
+	UserID     pgtype.Int4
+	CursorID   pgtype.Int4
+	CursorName pgtype.Text
+	PageLimit  int32
+}
+
+// Get a page of routes created by a specific user, ordered by name, starting after the cursor
+func (q *Queries) GetRoutesByUserID(ctx context.Context, arg GetRoutesByUserIDParams) ([]Route, error) {
+	rows, err := q.db.Query(ctx, getRoutesByUserID,
+		arg.UserID,
+		arg.CursorID,
+		arg.CursorName,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Route
+	for rows.Next() {
+		var i Route
+		if err := rows.Scan(
+			&i.RouteID,
+			&i.Name,
+			&i.Description,
+			&i.StartLocation,
+			&i.EndLocation,
+			&i.Distance,
+			&i.ElevationGain,
+			&i.RouteMapLink,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStickyPosts = `-- name: GetStickyPosts :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts WHERE is_sticky = TRUE
+AND ($1::timestamptz IS NULL OR (posts.creation_date, posts.post_id) < ($1::timestamptz, $2::int))
+ORDER BY creation_date DESC, post_id DESC
+LIMIT $3
+`
+
+type GetStickyPostsParams struct {
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+// Get a page of sticky posts, newest first, starting after the cursor
+func (q *Queries) GetStickyPosts(ctx context.Context, arg GetStickyPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getStickyPosts, arg.CursorDate, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTopLevelCommentsByPost = `-- name: GetTopLevelCommentsByPost :many
+SELECT comments.comment_id, comments.content, comments.content_html, comments.creation_date, comments.post_id, comments.user_id, comments.score, comments.parent_comment_id, comments.root_comment_id, comments.depth, comments.is_deleted, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
+FROM comments 
+LEFT JOIN users ON comments.user_id = users.user_id 
+WHERE comments.post_id = $1 AND comments.parent_comment_id IS NULL
+AND ($2::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < ($2::timestamptz, $3::int))
+ORDER BY comments.creation_date DESC, comments.comment_id DESC
+LIMIT $4
+`
+
+type GetTopLevelCommentsByPostParams struct {
+	PostID     pgtype.Int4
+	CursorDate pgtype.Timestamptz
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type GetTopLevelCommentsByPostRow struct {
+	CommentID       int32
+	Content         string
+	ContentHtml     pgtype.Text
+	CreationDate    pgtype.Timestamptz
+	PostID          pgtype.Int4
+	UserID          pgtype.Int4
+	Score           int32
+	ParentCommentID pgtype.Int4
+	RootCommentID   pgtype.Int4
+	Depth           int32
+	IsDeleted       bool
+	Username        pgtype.Text
+	ReplyCount      int32
+}
+
+// Get a page of the top level comments of a post, newest first, starting after the cursor, with their number of direct replies
+func (q *Queries) GetTopLevelCommentsByPost(ctx context.Context, arg GetTopLevelCommentsByPostParams) ([]GetTopLevelCommentsByPostRow, error) {
+	rows, err := q.db.Query(ctx, getTopLevelCommentsByPost,
+		arg.PostID,
+		arg.CursorDate,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTopLevelCommentsByPostRow
+	for rows.Next() {
+		var i GetTopLevelCommentsByPostRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.PostID,
+			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
+			&i.Username,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnlockedPosts = `-- name: GetUnlockedPosts :many
+SELECT post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date FROM posts WHERE is_locked = FALSE ORDER BY creation_date DESC
+`
+
+func (q *Queries) GetUnlockedPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.Query(ctx, getUnlockedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.UserID,
+			&i.IsSticky,
+			&i.IsLocked,
+			&i.PostCategoryID,
+			&i.AdditionalNotes,
+			&i.EditedAt,
+			&i.Score,
+			&i.LastActivityDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadNotificationsByUserId = `-- name: GetUnreadNotificationsByUserId :many
+SELECT notification_id, user_id, content, creation_date, is_read, notification_type, actor_user_id, post_id, comment_id, message_id, event_id FROM notifications WHERE user_id = $1 AND is_read = FALSE ORDER BY creation_date DESC
+`
+
+// Get all unread notifications for a specific user, ordered by creation_date
+func (q *Queries) GetUnreadNotificationsByUserId(ctx context.Context, userID pgtype.Int4) ([]Notification, error) {
+	rows, err := q.db.Query(ctx, getUnreadNotificationsByUserId, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.NotificationID,
+			&i.UserID,
+			&i.Content,
+			&i.CreationDate,
+			&i.IsRead,
+			&i.NotificationType,
+			&i.ActorUserID,
+			&i.PostID,
+			&i.CommentID,
+			&i.MessageID,
+			&i.EventID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadPrivateMessagesByReceiverId = `-- name: GetUnreadPrivateMessagesByReceiverId :many
+SELECT message_id, content, sender_user_id, receiver_user_id, sent_date, is_read, delivered_date FROM private_messages WHERE receiver_user_id = $1 AND is_read = FALSE ORDER BY sent_date DESC
+`
+
+// Get all unread private messages received by a specific user
+func (q *Queries) GetUnreadPrivateMessagesByReceiverId(ctx context.Context, receiverUserID pgtype.Int4) ([]PrivateMessage, error) {
+	rows, err := q.db.Query(ctx, getUnreadPrivateMessagesByReceiverId, receiverUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrivateMessage
+	for rows.Next() {
+		var i PrivateMessage
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Content,
+			&i.SenderUserID,
+			&i.ReceiverUserID,
+			&i.SentDate,
+			&i.IsRead,
+			&i.DeliveredDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUser = `-- name: GetUser :one
+SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, email_verified_at, role_id FROM users WHERE user_id = $1
+`
+
+type GetUserRow struct {
+	UserID           int32
+	Username         string
+	Email            string
+	RegistrationDate pgtype.Timestamptz
+	ProfilePicture   pgtype.Text
+	Biography        pgtype.Text
+	LastLoginDate    pgtype.Timestamptz
+	IsActive         pgtype.Bool
+	EmailVerifiedAt  pgtype.Timestamptz
+	RoleID           pgtype.Int4
+}
+
+// Get a user by id, no password_hash
+func (q *Queries) GetUser(ctx context.Context, userID int32) (GetUserRow, error) {
+	row := q.db.QueryRow(ctx, getUser, userID)
+	var i GetUserRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Email,
+		&i.RegistrationDate,
+		&i.ProfilePicture,
+		&i.Biography,
+		&i.LastLoginDate,
+		&i.IsActive,
+		&i.EmailVerifiedAt,
+		&i.RoleID,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT user_id, username, email, password_hash, registration_date, profile_picture, biography, last_login_date, is_active, suspended_until, suspension_reason, email_verified_at, verification_sent_date, role_id FROM users WHERE email = $1
+`
+
+// Get a user by email
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.RegistrationDate,
+		&i.ProfilePicture,
+		&i.Biography,
+		&i.LastLoginDate,
+		&i.IsActive,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.EmailVerifiedAt,
+		&i.VerificationSentDate,
+		&i.RoleID,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT user_id, username, email, password_hash, registration_date, profile_picture, biography, last_login_date, is_active, suspended_until, suspension_reason, email_verified_at, verification_sent_date, role_id FROM users WHERE username = $1
+`
+
+// Get a user by username
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.RegistrationDate,
+		&i.ProfilePicture,
+		&i.Biography,
+		&i.LastLoginDate,
+		&i.IsActive,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.EmailVerifiedAt,
+		&i.VerificationSentDate,
+		&i.RoleID,
+	)
+	return i, err
+}
+
+const getUserSession = `-- name: GetUserSession :one
+
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE session_id = $1
+`
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Get a single user session by session_id
+func (q *Queries) GetUserSession(ctx context.Context, sessionID pgtype.UUID) (UserSession, error) {
+	row := q.db.QueryRow(ctx, getUserSession, sessionID)
+	var i UserSession
+	err := row.Scan(
+		&i.SessionID,
+		&i.UserID,
+		&i.CreationDate,
+		&i.ExpiryDate,
+		&i.IpAddress,
+		&i.UserAgent,
+	)
+	return i, err
+}
+
+const getUserSessionAndRoleName = `-- name: GetUserSessionAndRoleName :one
+SELECT user_sessions.session_id, user_sessions.user_id, user_sessions.expiry_date, user_sessions.ip_address, user_sessions.user_agent, user_sessions.creation_date, users.role_id, roles.role_name,
+users.is_active, users.suspended_until, users.suspension_reason, users.email_verified_at
+FROM user_sessions
+INNER JOIN users ON user_sessions.user_id = users.user_id
+INNER JOIN roles ON users.role_id = roles.role_id
+WHERE session_id = $1
+`
+
+type GetUserSessionAndRoleNameRow struct {
+	SessionID        pgtype.UUID
+	UserID           pgtype.Int4
+	ExpiryDate       pgtype.Timestamptz
+	IpAddress        *netip.Addr
+	UserAgent        pgtype.Text
+	CreationDate     pgtype.Timestamptz
+	RoleID           pgtype.Int4
+	RoleName         string
+	IsActive         pgtype.Bool
+	SuspendedUntil   pgtype.Timestamptz
+	SuspensionReason pgtype.Text
+	EmailVerifiedAt  pgtype.Timestamptz
+}
+
+// Get a single user session by session_id, with role_name
+func (q *Queries) GetUserSessionAndRoleName(ctx context.Context, sessionID pgtype.UUID) (GetUserSessionAndRoleNameRow, error) {
+	row := q.db.QueryRow(ctx, getUserSessionAndRoleName, sessionID)
+	var i GetUserSessionAndRoleNameRow
+	err := row.Scan(
+		&i.SessionID,
+		&i.UserID,
+		&i.ExpiryDate,
+		&i.IpAddress,
+		&i.UserAgent,
+		&i.CreationDate,
+		&i.RoleID,
+		&i.RoleName,
+		&i.IsActive,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserSessionsByDate = `-- name: GetUserSessionsByDate :many
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE DATE(creation_date) = $1
+`
+
+// Get all sessions created on a specific date
+func (q *Queries) GetUserSessionsByDate(ctx context.Context, creationDate pgtype.Date) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getUserSessionsByDate, creationDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.UserID,
+			&i.CreationDate,
+			&i.ExpiryDate,
+			&i.IpAddress,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSessionsByIP = `-- name: GetUserSessionsByIP :many
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE ip_address = $1
+`
+
+// Get all sessions from a specific IP address
+func (q *Queries) GetUserSessionsByIP(ctx context.Context, ipAddress *netip.Addr) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getUserSessionsByIP, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.UserID,
+			&i.CreationDate,
+			&i.ExpiryDate,
+			&i.IpAddress,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSessionsByUserId = `-- name: GetUserSessionsByUserId :many
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE user_id = $1
+`
+
+// Get all sessions for a specific user_id
+func (q *Queries) GetUserSessionsByUserId(ctx context.Context, userID pgtype.Int4) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getUserSessionsByUserId, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.UserID,
+			&i.CreationDate,
+			&i.ExpiryDate,
+			&i.IpAddress,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSessionsFilteredByDate = `-- name: GetUserSessionsFilteredByDate :many
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions WHERE creation_date > $1
+`
+
+// Get all user sessions after a specific date
+func (q *Queries) GetUserSessionsFilteredByDate(ctx context.Context, creationDate pgtype.Timestamptz) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getUserSessionsFilteredByDate, creationDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.UserID,
+			&i.CreationDate,
+			&i.ExpiryDate,
+			&i.IpAddress,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSessionsOrderedByDate = `-- name: GetUserSessionsOrderedByDate :many
+SELECT session_id, user_id, creation_date, expiry_date, ip_address, user_agent FROM user_sessions ORDER BY creation_date
+`
+
+// Get all user sessions ordered by creation_date
+func (q *Queries) GetUserSessionsOrderedByDate(ctx context.Context) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getUserSessionsOrderedByDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserSession
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.UserID,
+			&i.CreationDate,
+			&i.ExpiryDate,
+			&i.IpAddress,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserWithRoleName = `-- name: GetUserWithRoleName :one
+SELECT users.user_id, users.username, users.email, users.registration_date, users.profile_picture, users.biography, users.last_login_date, users.is_active, users.role_id, roles.role_name
+FROM users
+INNER JOIN roles ON users.role_id = roles.role_id
+WHERE users.user_id = $1
+`
+
+type GetUserWithRoleNameRow struct {
+	UserID           int32
+	Username         string
+	Email            string
+	RegistrationDate pgtype.Timestamptz
+	ProfilePicture   pgtype.Text
+	Biography        pgtype.Text
+	LastLoginDate    pgtype.Timestamptz
+	IsActive         pgtype.Bool
+	RoleID           pgtype.Int4
+	RoleName         string
+}
+
+// Get a user by id, no password_hash, with role_name
+func (q *Queries) GetUserWithRoleName(ctx context.Context, userID int32) (GetUserWithRoleNameRow, error) {
+	row := q.db.QueryRow(ctx, getUserWithRoleName, userID)
+	var i GetUserWithRoleNameRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Email,
+		&i.RegistrationDate,
+		&i.ProfilePicture,
+		&i.Biography,
+		&i.LastLoginDate,
+		&i.IsActive,
+		&i.RoleID,
+		&i.RoleName,
+	)
+	return i, err
+}
+
+const getUsersDueForDigest = `-- name: GetUsersDueForDigest :many
+SELECT users.user_id, users.username, users.email,
+  COALESCE(email_preferences.digest_frequency, 'weekly')::text AS digest_frequency, email_preferences.last_digest_date
+FROM users
+LEFT JOIN email_preferences ON users.user_id = email_preferences.user_id
+WHERE users.is_active = TRUE AND users.email_verified_at IS NOT NULL
+AND COALESCE(email_preferences.digest_frequency, 'weekly') <> 'off'
+AND (
+  email_preferences.last_digest_date IS NULL
+  OR (email_preferences.digest_frequency = 'daily' AND email_preferences.last_digest_date <= $1::timestamptz)
+  OR (email_preferences.digest_frequency = 'weekly' AND email_preferences.last_digest_date <= $2::timestamptz)
+)
+ORDER BY users.user_id
+`
+
+type GetUsersDueForDigestParams struct {
+	DailyBefore  pgtype.Timestamptz
+	WeeklyBefore pgtype.Timestamptz
+}
+
+type GetUsersDueForDigestRow struct {
+	UserID          int32
+	Username        string
+	Email           string
+	DigestFrequency string
+	LastDigestDate  pgtype.Timestamptz
+}
+
+// Get the active, verified users whose next digest is due, which is when they have never had one or their latest is
+// older than their digest frequency
+func (q *Queries) GetUsersDueForDigest(ctx context.Context, arg GetUsersDueForDigestParams) ([]GetUsersDueForDigestRow, error) {
+	rows, err := q.db.Query(ctx, getUsersDueForDigest, arg.DailyBefore, arg.WeeklyBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersDueForDigestRow
+	for rows.Next() {
+		var i GetUsersDueForDigestRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Email,
+			&i.DigestFrequency,
+			&i.LastDigestDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWaitlistedOccurrencesByEventID = `-- name: GetWaitlistedOccurrencesByEventID :many
+SELECT occurrence_date FROM rsvps WHERE event_id = $1 AND rsvp_status = 'waitlisted' GROUP BY occurrence_date ORDER BY occurrence_date
+`
+
+// Get the occurrences of a specific event that have a waitlist
+func (q *Queries) GetWaitlistedOccurrencesByEventID(ctx context.Context, eventID pgtype.Int4) ([]pgtype.Timestamptz, error) {
+	rows, err := q.db.Query(ctx, getWaitlistedOccurrencesByEventID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.Timestamptz
+	for rows.Next() {
+		var occurrenceDate pgtype.Timestamptz
+		if err := rows.Scan(&occurrenceDate); err != nil {
+			return nil, err
+		}
+		items = append(items, occurrenceDate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWaitlistedRSVPsByEventID = `-- name: GetWaitlistedRSVPsByEventID :many
+SELECT rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date FROM rsvps WHERE event_id = $1 AND rsvp_status = 'waitlisted' AND occurrence_date IS NOT DISTINCT FROM $2::timestamptz
+ORDER BY rsvp_date, rsvp_id
+`
+
+type GetWaitlistedRSVPsByEventIDParams struct {
+	EventID        pgtype.Int4
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Get the waitlist of a specific event occurrence, in the order members joined it
+func (q *Queries) GetWaitlistedRSVPsByEventID(ctx context.Context, arg GetWaitlistedRSVPsByEventIDParams) ([]Rsvp, error) {
+	rows, err := q.db.Query(ctx, getWaitlistedRSVPsByEventID, arg.EventID, arg.OccurrenceDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Rsvp
+	for rows.Next() {
+		var i Rsvp
+		if err := rows.Scan(
+			&i.RsvpID,
+			&i.EventID,
+			&i.UserID,
+			&i.RsvpStatus,
+			&i.RsvpDate,
+			&i.OccurrenceDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWatchedCommentsByUserIdAfter = `-- name: GetWatchedCommentsByUserIdAfter :many
+SELECT comments.comment_id, comments.content, comments.content_html, comments.creation_date, comments.post_id, comments.user_id, comments.score, comments.parent_comment_id, comments.root_comment_id, comments.depth, comments.is_deleted FROM comments
+INNER JOIN post_watches ON comments.post_id = post_watches.post_id
+WHERE post_watches.user_id = $1::int AND comments.is_deleted = FALSE
+AND (comments.comment_id > $2::int OR comments.creation_date > $3::timestamptz)
+AND comments.comment_id > $4::int
+ORDER BY comments.comment_id
+LIMIT $5
+`
+
+type GetWatchedCommentsByUserIdAfterParams struct {
+	UserID      int32
+	AfterID     int32
+	Since       pgtype.Timestamptz
+	PageAfterID int32
+	PageLimit   int32
+}
+
+// Get the comments made after after_id or since a time on the posts a user watches, oldest first, to resume the
+// real-time stream. Pages start after page_after_id.
+func (q *Queries) GetWatchedCommentsByUserIdAfter(ctx context.Context, arg GetWatchedCommentsByUserIdAfterParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, getWatchedCommentsByUserIdAfter,
+		arg.UserID,
+		arg.AfterID,
+		arg.Since,
+		arg.PageAfterID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.Content,
+			&i.ContentHtml,
+			&i.CreationDate,
+			&i.PostID,
+			&i.UserID,
+			&i.Score,
+			&i.ParentCommentID,
+			&i.RootCommentID,
+			&i.Depth,
+			&i.IsDeleted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasConversation = `-- name: HasConversation :one
+SELECT EXISTS (
+  SELECT 1 FROM private_messages
+  WHERE (sender_user_id = $1::int AND receiver_user_id = $2::int)
+  OR (sender_user_id = $2::int AND receiver_user_id = $1::int)
+)::boolean AS has_conversation
+`
+
+type HasConversationParams struct {
+	UserID        int32
+	PartnerUserID int32
+}
+
+// Check whether two users have exchanged a private message in either direction
+func (q *Queries) HasConversation(ctx context.Context, arg HasConversationParams) (bool, error) {
+	row := q.db.QueryRow(ctx, hasConversation, arg.UserID, arg.PartnerUserID)
+	var hasConversation bool
+	err := row.Scan(&hasConversation)
+	return hasConversation, err
+}
+
+const incrementReportSubmissionCount = `-- name: IncrementReportSubmissionCount :one
+UPDATE reports SET submission_count = submission_count + 1 WHERE report_id = $1
+RETURNING report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date
+`
+
+func (q *Queries) IncrementReportSubmissionCount(ctx context.Context, reportID int32) (Report, error) {
+	row := q.db.QueryRow(ctx, incrementReportSubmissionCount, reportID)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const invalidateUserSession = `-- name: InvalidateUserSession :exec
+UPDATE user_sessions SET expiry_date = TIMESTAMP '1970-01-01 00:00:00' WHERE session_id = $1
+`
+
+// Invalidate a user session by setting the expiry_date to a past date
+func (q *Queries) InvalidateUserSession(ctx context.Context, sessionID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, invalidateUserSession, sessionID)
+	return err
+}
+
+const listRoles = `-- name: ListRoles :many
+SELECT role_id, role_name FROM roles
+`
+
+// Get all roles
+func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
+	rows, err := q.db.Query(ctx, listRoles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Role
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(&i.RoleID, &i.RoleName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockPost = `-- name: LockPost :exec
+UPDATE posts SET is_locked = TRUE WHERE post_id = $1
+`
+
+func (q *Queries) LockPost(ctx context.Context, postID int32) error {
+	_, err := q.db.Exec(ctx, lockPost, postID)
+	return err
+}
+
+const markAllNotificationsAsReadByUserId = `-- name: MarkAllNotificationsAsReadByUserId :execrows
+UPDATE notifications SET is_read = TRUE WHERE user_id = $1 AND is_read = FALSE
+`
+
+// Mark every notification of a specific user as read
+func (q *Queries) MarkAllNotificationsAsReadByUserId(ctx context.Context, userID pgtype.Int4) (int64, error) {
+	result, err := q.db.Exec(ctx, markAllNotificationsAsReadByUserId, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markConversationAsRead = `-- name: MarkConversationAsRead :many
+UPDATE private_messages SET is_read = TRUE
+WHERE receiver_user_id = $1::int AND sender_user_id = $2::int AND is_read = FALSE
+AND ($3::int IS NULL OR message_id <= $3::int)
+RETURNING message_id
+`
+
+type MarkConversationAsReadParams struct {
+	UserID        int32
+	PartnerUserID int32
+	UpToMessageID pgtype.Int4
+}
+
+// Mark the messages a user has received from another user as read, up to and including up_to_message_id
+// when it is given, returning the IDs of the messages that were unread
+func (q *Queries) MarkConversationAsRead(ctx context.Context, arg MarkConversationAsReadParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, markConversationAsRead, arg.UserID, arg.PartnerUserID, arg.UpToMessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var messageID int32
+		if err := rows.Scan(&messageID); err != nil {
+			return nil, err
+		}
+		items = append(items, messageID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNotificationAsRead = `-- name: MarkNotificationAsRead :exec
+UPDATE notifications SET is_read = TRUE WHERE notification_id = $1
+`
+
+// Mark a notification as read
+func (q *Queries) MarkNotificationAsRead(ctx context.Context, notificationID int32) error {
+	_, err := q.db.Exec(ctx, markNotificationAsRead, notificationID)
+	return err
+}
+
+const markNotificationAsReadByNotificationIdAndUserId = `-- name: MarkNotificationAsReadByNotificationIdAndUserId :execrows
+UPDATE notifications SET is_read = TRUE WHERE notification_id = $1 AND user_id = $2
+`
+
+type MarkNotificationAsReadByNotificationIdAndUserIdParams struct {
+	NotificationID int32
+	UserID         pgtype.Int4
+}
+
+// Mark a notification of a specific user as read
+func (q *Queries) MarkNotificationAsReadByNotificationIdAndUserId(ctx context.Context, arg MarkNotificationAsReadByNotificationIdAndUserIdParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markNotificationAsReadByNotificationIdAndUserId, arg.NotificationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markPrivateMessageAsRead = `-- name: MarkPrivateMessageAsRead :exec
+UPDATE private_messages SET is_read = TRUE WHERE message_id = $1
+`
+
+// Mark a private message as read
+func (q *Queries) MarkPrivateMessageAsRead(ctx context.Context, messageID int32) error {
+	_, err := q.db.Exec(ctx, markPrivateMessageAsRead, messageID)
+	return err
+}
+
+const markPrivateMessageDelivered = `-- name: MarkPrivateMessageDelivered :execrows
+UPDATE private_messages SET delivered_date = CURRENT_TIMESTAMP WHERE message_id = $1 AND delivered_date IS NULL
+`
+
+// Record that a message reached its receiver, unless it already had, so that only the first of the receiver's
+// connections to get it sends a delivery receipt
+func (q *Queries) MarkPrivateMessageDelivered(ctx context.Context, messageID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, markPrivateMessageDelivered, messageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const redeemStreamTicket = `-- name: RedeemStreamTicket :one
+DELETE FROM stream_tickets WHERE ticket = $1 AND expiry_date > $2::timestamptz
+RETURNING session_id
+`
+
+type RedeemStreamTicketParams struct {
+	Ticket pgtype.UUID
+	Now    pgtype.Timestamptz
+}
+
+// Use up a stream ticket that has not expired, returning the session it opens a stream for
+func (q *Queries) RedeemStreamTicket(ctx context.Context, arg RedeemStreamTicketParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, redeemStreamTicket, arg.Ticket, arg.Now)
+	var sessionID pgtype.UUID
+	err := row.Scan(&sessionID)
+	return sessionID, err
+}
+
+const reinstateExpiredSuspensions = `-- name: ReinstateExpiredSuspensions :many
+UPDATE users SET is_active = TRUE, suspended_until = NULL, suspension_reason = NULL
+WHERE is_active = FALSE AND suspended_until <= $1::timestamptz
+RETURNING user_id
+`
+
+// Reactivate the users whose suspensions have ended, returning their IDs
+func (q *Queries) ReinstateExpiredSuspensions(ctx context.Context, now pgtype.Timestamptz) ([]int32, error) {
+	rows, err := q.db.Query(ctx, reinstateExpiredSuspensions, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var userID int32
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		items = append(items, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseDigest = `-- name: ReleaseDigest :exec
+UPDATE email_preferences SET last_digest_date = $1::timestamptz
+WHERE user_id = $2::int AND last_digest_date = $3::timestamptz
+`
+
+type ReleaseDigestParams struct {
+	PreviousDigestDate pgtype.Timestamptz
+	UserID             int32
+	ClaimedDigestDate  pgtype.Timestamptz
+}
+
+// Undo the claim of a digest that could not be sent, so that the next run retries it
+func (q *Queries) ReleaseDigest(ctx context.Context, arg ReleaseDigestParams) error {
+	_, err := q.db.Exec(ctx, releaseDigest, arg.PreviousDigestDate, arg.UserID, arg.ClaimedDigestDate)
+	return err
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports SET status = $2, reviewer_user_id = $3, resolution_action = $4, resolution_note = $5, resolution_date = CURRENT_TIMESTAMP
+WHERE report_id = $1
+RETURNING report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date
+`
+
+type ResolveReportParams struct {
+	ReportID         int32
+	Status           string
+	ReviewerUserID   pgtype.Int4
+	ResolutionAction pgtype.Text
+	ResolutionNote   pgtype.Text
+}
+
+// Mark a report as actioned or dismissed
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRow(ctx, resolveReport,
+		arg.ReportID,
+		arg.Status,
+		arg.ReviewerUserID,
+		arg.ResolutionAction,
+		arg.ResolutionNote,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const reviewReport = `-- name: ReviewReport :one
+UPDATE reports SET status = 'in_review', reviewer_user_id = $2 WHERE report_id = $1
+RETURNING report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date
+`
+
+type ReviewReportParams struct {
+	ReportID       int32
+	ReviewerUserID pgtype.Int4
+}
+
+// Mark a report as in review by a moderator
+func (q *Queries) ReviewReport(ctx context.Context, arg ReviewReportParams) (Report, error) {
+	row := q.db.QueryRow(ctx, reviewReport, arg.ReportID, arg.ReviewerUserID)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const searchComments = `-- name: SearchComments :many
+SELECT comments.comment_id, comments.post_id, comments.creation_date, comments.user_id, users.username, posts.title AS post_title,
+  ts_rank_cd(comment_search_document(comments.content), websearch_to_tsquery('english', $1::text))::float8 AS rank,
+  ts_headline('english', comments.content, websearch_to_tsquery('english', $1::text), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')::text AS snippet
+FROM comments
+INNER JOIN posts ON comments.post_id = posts.post_id
+LEFT JOIN users ON comments.user_id = users.user_id
+WHERE comment_search_document(comments.content) @@ websearch_to_tsquery('english', $1::text)
+AND ($2::int IS NULL OR posts.post_category_id = $2::int)
+AND ($3::int IS NULL OR comments.user_id = $3::int)
+AND ($4::float8 IS NULL OR (ts_rank_cd(comment_search_document(comments.content), websearch_to_tsquery('english', $1::text))::float8, comments.comment_id) < ($4::float8, $5::int))
+ORDER BY rank DESC, comments.comment_id DESC
+LIMIT $6
+`
+
+type SearchCommentsParams struct {
+	Query      string
+	CategoryID pgtype.Int4
+	AuthorID   pgtype.Int4
+	CursorRank pgtype.Float8
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type SearchCommentsRow struct {
+	CommentID    int32
+	PostID       pgtype.Int4
+	CreationDate pgtype.Timestamptz
+	UserID       pgtype.Int4
+	Username     pgtype.Text
+	PostTitle    string
+	Rank         float64
+	Snippet      string
+}
+
+// Full-text search of comment content, best match first, starting after the cursor
+func (q *Queries) SearchComments(ctx context.Context, arg SearchCommentsParams) ([]SearchCommentsRow, error) {
+	rows, err := q.db.Query(ctx, searchComments,
+		arg.Query,
+		arg.CategoryID,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchCommentsRow
+	for rows.Next() {
+		var i SearchCommentsRow
+		if err := rows.Scan(
+			&i.CommentID,
+			&i.PostID,
+			&i.CreationDate,
+			&i.UserID,
+			&i.Username,
+			&i.PostTitle,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT posts.post_id, posts.title, posts.creation_date, posts.user_id, posts.post_category_id, users.username,
+  ts_rank_cd(post_search_document(posts.title, posts.content), websearch_to_tsquery('english', $1::text))::float8 AS rank,
+  ts_headline('english', posts.title, websearch_to_tsquery('english', $1::text), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS title_highlight,
+  ts_headline('english', posts.content, websearch_to_tsquery('english', $1::text), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')::text AS snippet
+FROM posts
+LEFT JOIN users ON posts.user_id = users.user_id
+WHERE post_search_document(posts.title, posts.content) @@ websearch_to_tsquery('english', $1::text)
+AND ($2::int IS NULL OR posts.post_category_id = $2::int)
+AND ($3::int IS NULL OR posts.user_id = $3::int)
+AND ($4::float8 IS NULL OR (ts_rank_cd(post_search_document(posts.title, posts.content), websearch_to_tsquery('english', $1::text))::float8, posts.post_id) < ($4::float8, $5::int))
+ORDER BY rank DESC, posts.post_id DESC
+LIMIT $6
+`
+
+type SearchPostsParams struct {
+	Query      string
+	CategoryID pgtype.Int4
+	AuthorID   pgtype.Int4
+	CursorRank pgtype.Float8
+	CursorID   pgtype.Int4
+	PageLimit  int32
+}
+
+type SearchPostsRow struct {
+	PostID         int32
+	Title          string
+	CreationDate   pgtype.Timestamptz
+	UserID         pgtype.Int4
+	PostCategoryID pgtype.Int4
+	Username       pgtype.Text
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// Full-text search of post titles and content, best match first, starting after the cursor
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.Query(ctx, searchPosts,
+		arg.Query,
+		arg.CategoryID,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Title,
+			&i.CreationDate,
+			&i.UserID,
+			&i.PostCategoryID,
+			&i.Username,
+			&i.Rank,
+			&i.TitleHighlight,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setCommentContentHTML = `-- name: SetCommentContentHTML :exec
+UPDATE comments SET content_html = $2 WHERE comment_id = $1 AND content_html IS NULL
+`
+
+type SetCommentContentHTMLParams struct {
+	CommentID   int32
+	ContentHtml pgtype.Text
+}
+
+// Store the rendered content of a comment, unless it was rendered by an update in the meantime
+func (q *Queries) SetCommentContentHTML(ctx context.Context, arg SetCommentContentHTMLParams) error {
+	_, err := q.db.Exec(ctx, setCommentContentHTML, arg.CommentID, arg.ContentHtml)
+	return err
+}
+
+const setPostContentHTML = `-- name: SetPostContentHTML :exec
+UPDATE posts SET content_html = $2 WHERE post_id = $1 AND content_html IS NULL
+`
+
+type SetPostContentHTMLParams struct {
+	PostID      int32
+	ContentHtml pgtype.Text
+}
+
+// Store the rendered content of a post, unless it was rendered by an update in the meantime
+func (q *Queries) SetPostContentHTML(ctx context.Context, arg SetPostContentHTMLParams) error {
+	_, err := q.db.Exec(ctx, setPostContentHTML, arg.PostID, arg.ContentHtml)
+	return err
+}
+
+const softDeleteComment = `-- name: SoftDeleteComment :exec
+UPDATE comments SET content = '', content_html = '', user_id = NULL, is_deleted = TRUE WHERE comment_id = $1
+`
+
+// Replace a comment that has replies with a placeholder, removing its content and author
+func (q *Queries) SoftDeleteComment(ctx context.Context, commentID int32) error {
+	_, err := q.db.Exec(ctx, softDeleteComment, commentID)
+	return err
+}
+
+const stickyPost = `-- name: StickyPost :exec
+UPDATE posts SET is_sticky = TRUE WHERE post_id = $1
+`
+
+func (q *Queries) StickyPost(ctx context.Context, postID int32) error {
+	_, err := q.db.Exec(ctx, stickyPost, postID)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users SET is_active = FALSE, suspended_until = $1::timestamptz, suspension_reason = $2::text
+WHERE user_id = $3
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   pgtype.Timestamptz
+	SuspensionReason string
+	UserID           int32
+}
+
+// Suspend a user until suspended_until, or ban them permanently when it is NULL
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.Exec(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.UserID)
 	return err
 }
 
-const markPrivateMessageAsRead = `-- name: MarkPrivateMessageAsRead :exec
-UPDATE private_messages SET is_read = TRUE WHERE message_id = $1
+const touchPostActivity = `-- name: TouchPostActivity :exec
+UPDATE posts SET last_activity_date = CURRENT_TIMESTAMP WHERE post_id = $1
 `
 
-// Mark a private message as read
-func (q *Queries) MarkPrivateMessageAsRead(ctx context.Context, messageID int32) error {
-	_, err := q.db.Exec(ctx, markPrivateMessageAsRead, messageID)
+// Mark a post as active now, for the active sort order
+func (q *Queries) TouchPostActivity(ctx context.Context, postID int32) error {
+	_, err := q.db.Exec(ctx, touchPostActivity, postID)
 	return err
 }
 
-const stickyPost = `-- name: StickyPost :exec
-UPDATE posts SET is_sticky = TRUE WHERE post_id = $1
+const unfollowCategory = `-- name: UnfollowCategory :execrows
+DELETE FROM category_follows WHERE user_id = $1 AND category_id = $2
 `
 
-func (q *Queries) StickyPost(ctx context.Context, postID int32) error {
-	_, err := q.db.Exec(ctx, stickyPost, postID)
-	return err
+type UnfollowCategoryParams struct {
+	UserID     int32
+	CategoryID int32
+}
+
+func (q *Queries) UnfollowCategory(ctx context.Context, arg UnfollowCategoryParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unfollowCategory, arg.UserID, arg.CategoryID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const unlockPost = `-- name: UnlockPost :exec
@@ -2437,6 +5970,36 @@ func (q *Queries) UnstickyPost(ctx context.Context, postID int32) error {
 	return err
 }
 
+const unsubscribeFromDigests = `-- name: UnsubscribeFromDigests :execrows
+UPDATE email_preferences SET digest_frequency = 'off' WHERE unsubscribe_token = $1
+`
+
+// Stop sending digests to the user an unsubscribe token belongs to
+func (q *Queries) UnsubscribeFromDigests(ctx context.Context, unsubscribeToken pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, unsubscribeFromDigests, unsubscribeToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unwatchPost = `-- name: UnwatchPost :execrows
+DELETE FROM post_watches WHERE user_id = $1 AND post_id = $2
+`
+
+type UnwatchPostParams struct {
+	UserID int32
+	PostID int32
+}
+
+func (q *Queries) UnwatchPost(ctx context.Context, arg UnwatchPostParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unwatchPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateBookmark = `-- name: UpdateBookmark :exec
 UPDATE bookmarks SET user_id = $2, post_id = $3 WHERE bookmark_id = $1
 `
@@ -2470,48 +6033,89 @@ func (q *Queries) UpdateCategory(ctx context.Context, arg UpdateCategoryParams)
 }
 
 const updateComment = `-- name: UpdateComment :one
-UPDATE comments SET content = $2 WHERE comment_id = $1 RETURNING comment_id, content, creation_date, post_id, user_id
+UPDATE comments SET content = $2, content_html = $3 WHERE comment_id = $1 RETURNING comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted
 `
 
 type UpdateCommentParams struct {
-	CommentID int32
-	Content   string
+	CommentID   int32
+	Content     string
+	ContentHtml pgtype.Text
 }
 
 // Update a comment's content
 func (q *Queries) UpdateComment(ctx context.Context, arg UpdateCommentParams) (Comment, error) {
-	row := q.db.QueryRow(ctx, updateComment, arg.CommentID, arg.Content)
+	row := q.db.QueryRow(ctx, updateComment, arg.CommentID, arg.Content, arg.ContentHtml)
 	var i Comment
 	err := row.Scan(
 		&i.CommentID,
 		&i.Content,
+		&i.ContentHtml,
 		&i.CreationDate,
 		&i.PostID,
 		&i.UserID,
+		&i.Score,
+		&i.ParentCommentID,
+		&i.RootCommentID,
+		&i.Depth,
+		&i.IsDeleted,
 	)
 	return i, err
 }
 
 const updateCommentByCommentIdAndUserId = `-- name: UpdateCommentByCommentIdAndUserId :one
-UPDATE comments SET content = $3 WHERE comment_id = $1 AND user_id = $2 RETURNING comment_id, content, creation_date, post_id, user_id
+UPDATE comments SET content = $3, content_html = $4 WHERE comment_id = $1 AND user_id = $2 RETURNING comment_id, content, content_html, creation_date, post_id, user_id, score, parent_comment_id, root_comment_id, depth, is_deleted
 `
 
 type UpdateCommentByCommentIdAndUserIdParams struct {
-	CommentID int32
-	UserID    pgtype.Int4
-	Content   string
+	CommentID   int32
+	UserID      pgtype.Int4
+	Content     string
+	ContentHtml pgtype.Text
 }
 
 // Update a comment's content by its ID and the ID of the user who made it
 func (q *Queries) UpdateCommentByCommentIdAndUserId(ctx context.Context, arg UpdateCommentByCommentIdAndUserIdParams) (Comment, error) {
-	row := q.db.QueryRow(ctx, updateCommentByCommentIdAndUserId, arg.CommentID, arg.UserID, arg.Content)
+	row := q.db.QueryRow(ctx, updateCommentByCommentIdAndUserId,
+		arg.CommentID,
+		arg.UserID,
+		arg.Content,
+		arg.ContentHtml,
+	)
 	var i Comment
 	err := row.Scan(
 		&i.CommentID,
 		&i.Content,
+		&i.ContentHtml,
 		&i.CreationDate,
 		&i.PostID,
 		&i.UserID,
+		&i.Score,
+		&i.ParentCommentID,
+		&i.RootCommentID,
+		&i.Depth,
+		&i.IsDeleted,
+	)
+	return i, err
+}
+
+const updateDigestFrequency = `-- name: UpdateDigestFrequency :one
+UPDATE email_preferences SET digest_frequency = $2 WHERE user_id = $1
+RETURNING user_id, digest_frequency, unsubscribe_token, last_digest_date
+`
+
+type UpdateDigestFrequencyParams struct {
+	UserID          int32
+	DigestFrequency string
+}
+
+func (q *Queries) UpdateDigestFrequency(ctx context.Context, arg UpdateDigestFrequencyParams) (EmailPreference, error) {
+	row := q.db.QueryRow(ctx, updateDigestFrequency, arg.UserID, arg.DigestFrequency)
+	var i EmailPreference
+	err := row.Scan(
+		&i.UserID,
+		&i.DigestFrequency,
+		&i.UnsubscribeToken,
+		&i.LastDigestDate,
 	)
 	return i, err
 }
@@ -2545,6 +6149,54 @@ func (q *Queries) UpdateEvent(ctx context.Context, arg UpdateEventParams) error
 	return err
 }
 
+const updateEventByEventIdAndUserId = `-- name: UpdateEventByEventIdAndUserId :one
+UPDATE events SET title = $3, description = $4, event_date = $5, meeting_point = $6, route_id = $7, capacity = $8, recurrence_rule = $9, time_zone = $10
+WHERE event_id = $1 AND creator_user_id = $2 RETURNING event_id, title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone
+`
+
+type UpdateEventByEventIdAndUserIdParams struct {
+	EventID        int32
+	CreatorUserID  pgtype.Int4
+	Title          string
+	Description    pgtype.Text
+	EventDate      pgtype.Timestamptz
+	MeetingPoint   string
+	RouteID        pgtype.Int4
+	Capacity       pgtype.Int4
+	RecurrenceRule pgtype.Text
+	TimeZone       string
+}
+
+// Update a specific event by its ID and the ID of the user who created it
+func (q *Queries) UpdateEventByEventIdAndUserId(ctx context.Context, arg UpdateEventByEventIdAndUserIdParams) (Event, error) {
+	row := q.db.QueryRow(ctx, updateEventByEventIdAndUserId,
+		arg.EventID,
+		arg.CreatorUserID,
+		arg.Title,
+		arg.Description,
+		arg.EventDate,
+		arg.MeetingPoint,
+		arg.RouteID,
+		arg.Capacity,
+		arg.RecurrenceRule,
+		arg.TimeZone,
+	)
+	var i Event
+	err := row.Scan(
+		&i.EventID,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+		&i.RouteID,
+		&i.CreatorUserID,
+		&i.Capacity,
+		&i.RecurrenceRule,
+		&i.TimeZone,
+	)
+	return i, err
+}
+
 const updateLastLogin = `-- name: UpdateLastLogin :exec
 UPDATE users SET last_login_date = CURRENT_TIMESTAMP WHERE user_id = $1
 `
@@ -2555,58 +6207,63 @@ func (q *Queries) UpdateLastLogin(ctx context.Context, userID int32) error {
 	return err
 }
 
-const updateLog = `-- name: UpdateLog :exec
-UPDATE forum_moderation_log
-SET action = $2, moderator_user_id = $3, affected_user_id = $4, post_id = $5, comment_id = $6, reason = $7
-WHERE log_id = $1
+const updateNotification = `-- name: UpdateNotification :exec
+UPDATE notifications SET content = $2 WHERE notification_id = $1
 `
 
-type UpdateLogParams struct {
-	LogID           int32
-	Action          string
-	ModeratorUserID pgtype.Int4
-	AffectedUserID  pgtype.Int4
-	PostID          pgtype.Int4
-	CommentID       pgtype.Int4
-	Reason          pgtype.Text
+type UpdateNotificationParams struct {
+	NotificationID int32
+	Content        string
 }
 
-func (q *Queries) UpdateLog(ctx context.Context, arg UpdateLogParams) error {
-	_, err := q.db.Exec(ctx, updateLog,
-		arg.LogID,
-		arg.Action,
-		arg.ModeratorUserID,
-		arg.AffectedUserID,
-		arg.PostID,
-		arg.CommentID,
-		arg.Reason,
-	)
+// Update a notification's content
+func (q *Queries) UpdateNotification(ctx context.Context, arg UpdateNotificationParams) error {
+	_, err := q.db.Exec(ctx, updateNotification, arg.NotificationID, arg.Content)
 	return err
 }
 
-const updatePost = `-- name: UpdatePost :exec
-UPDATE posts SET title = $2, content = $3, user_id = $4, post_category_id = $5, additional_notes = $6 WHERE post_id = $1
+const updatePost = `-- name: UpdatePost :one
+UPDATE posts SET title = $2, content = $3, content_html = $4, post_category_id = $5, additional_notes = $6, edited_at = CURRENT_TIMESTAMP
+WHERE post_id = $1
+RETURNING post_id, title, content, content_html, creation_date, user_id, is_sticky, is_locked, post_category_id, additional_notes, edited_at, score, last_activity_date
 `
 
 type UpdatePostParams struct {
 	PostID          int32
 	Title           string
 	Content         string
-	UserID          pgtype.Int4
+	ContentHtml     pgtype.Text
 	PostCategoryID  pgtype.Int4
 	AdditionalNotes pgtype.Text
 }
 
-func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) error {
-	_, err := q.db.Exec(ctx, updatePost,
+// Update a post's details and mark it as edited
+func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, error) {
+	row := q.db.QueryRow(ctx, updatePost,
 		arg.PostID,
 		arg.Title,
 		arg.Content,
-		arg.UserID,
+		arg.ContentHtml,
 		arg.PostCategoryID,
 		arg.AdditionalNotes,
 	)
-	return err
+	var i Post
+	err := row.Scan(
+		&i.PostID,
+		&i.Title,
+		&i.Content,
+		&i.ContentHtml,
+		&i.CreationDate,
+		&i.UserID,
+		&i.IsSticky,
+		&i.IsLocked,
+		&i.PostCategoryID,
+		&i.AdditionalNotes,
+		&i.EditedAt,
+		&i.Score,
+		&i.LastActivityDate,
+	)
+	return i, err
 }
 
 const updatePrivateMessage = `-- name: UpdatePrivateMessage :exec
@@ -2624,20 +6281,28 @@ func (q *Queries) UpdatePrivateMessage(ctx context.Context, arg UpdatePrivateMes
 	return err
 }
 
-const updateRSVP = `-- name: UpdateRSVP :exec
-UPDATE rsvps SET event_id = $2, user_id = $3 WHERE rsvp_id = $1
+const updateRSVP = `-- name: UpdateRSVP :one
+UPDATE rsvps SET rsvp_status = $2, rsvp_date = CURRENT_TIMESTAMP WHERE rsvp_id = $1 RETURNING rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date
 `
 
 type UpdateRSVPParams struct {
-	RsvpID  int32
-	EventID pgtype.Int4
-	UserID  pgtype.Int4
+	RsvpID     int32
+	RsvpStatus string
 }
 
-// Update a specific RSVP
-func (q *Queries) UpdateRSVP(ctx context.Context, arg UpdateRSVPParams) error {
-	_, err := q.db.Exec(ctx, updateRSVP, arg.RsvpID, arg.EventID, arg.UserID)
-	return err
+// Update the status of a specific RSVP
+func (q *Queries) UpdateRSVP(ctx context.Context, arg UpdateRSVPParams) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, updateRSVP, arg.RsvpID, arg.RsvpStatus)
+	var i Rsvp
+	err := row.Scan(
+		&i.RsvpID,
+		&i.EventID,
+		&i.UserID,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
+	)
+	return i, err
 }
 
 const updateRole = `-- name: UpdateRole :exec
@@ -2688,8 +6353,71 @@ func (q *Queries) UpdateRoute(ctx context.Context, arg UpdateRouteParams) error
 	return err
 }
 
+const updateRouteByRouteIdAndUserId = `-- name: UpdateRouteByRouteIdAndUserId :one
+UPDATE routes SET name = $3, description = $4, start_location = $5, end_location = $6, distance = $7, elevation_gain = $8, route_map_link = $9
+WHERE route_id = $1 AND user_id = $2 RETURNING route_id, name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id
+`
+
+type UpdateRouteByRouteIdAndUserIdParams struct {
+	RouteID       int32
+	UserID        pgtype.Int4
+	Name          string
+	Description   pgtype.Text
+	StartLocation string
+	EndLocation   string
+	Distance      pgtype.Float8
+	ElevationGain pgtype.Int4
+	RouteMapLink  pgtype.Text
+}
+
+// Update a specific route by its ID and the ID of the user who created it
+func (q *Queries) UpdateRouteByRouteIdAndUserId(ctx context.Context, arg UpdateRouteByRouteIdAndUserIdParams) (Route, error) {
+	row := q.db.QueryRow(ctx, updateRouteByRouteIdAndUserId,
+		arg.RouteID,
+		arg.UserID,
+		arg.Name,
+		arg.Description,
+		arg.StartLocation,
+		arg.EndLocation,
+		arg.Distance,
+		arg.ElevationGain,
+		arg.RouteMapLink,
+	)
+	var i Route
+	err := row.Scan(
+		&i.RouteID,
+		&i.Name,
+		&i.Description,
+		&i.StartLocation,
+		&i.EndLocation,
+		&i.Distance,
+		&i.ElevationGain,
+		&i.RouteMapLink,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const updateRouteTrackStats = `-- name: UpdateRouteTrackStats :exec
+UPDATE routes SET distance = $2, elevation_gain = $3 WHERE route_id = $1
+`
+
+type UpdateRouteTrackStatsParams struct {
+	RouteID       int32
+	Distance      pgtype.Float8
+	ElevationGain pgtype.Int4
+}
+
+// Update the distance and elevation gain of a route derived from its GPX track
+func (q *Queries) UpdateRouteTrackStats(ctx context.Context, arg UpdateRouteTrackStatsParams) error {
+	_, err := q.db.Exec(ctx, updateRouteTrackStats, arg.RouteID, arg.Distance, arg.ElevationGain)
+	return err
+}
+
 const updateUserExcludingSensitive = `-- name: UpdateUserExcludingSensitive :exec
-UPDATE users SET username = $2, email = $3, profile_picture = $4, biography = $5 WHERE user_id = $1
+UPDATE users SET username = $2, email = $3, profile_picture = $4, biography = $5,
+email_verified_at = CASE WHEN email = $3 THEN email_verified_at END
+WHERE user_id = $1
 `
 
 type UpdateUserExcludingSensitiveParams struct {
@@ -2700,7 +6428,8 @@ type UpdateUserExcludingSensitiveParams struct {
 	Biography      pgtype.Text
 }
 
-// Update a user's details, no password_hash
+// Update a user's details, no password_hash. Changing the email address makes it unverified again. The time the last
+// verification email was sent is kept, so that changing the address does not get around the resend throttle.
 func (q *Queries) UpdateUserExcludingSensitive(ctx context.Context, arg UpdateUserExcludingSensitiveParams) error {
 	_, err := q.db.Exec(ctx, updateUserExcludingSensitive,
 		arg.UserID,
@@ -2765,3 +6494,202 @@ func (q *Queries) UpdateUserSession(ctx context.Context, arg UpdateUserSessionPa
 	)
 	return err
 }
+
+const upsertCalendarToken = `-- name: UpsertCalendarToken :one
+INSERT INTO calendar_tokens (user_id, token) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, creation_date = CURRENT_TIMESTAMP
+RETURNING user_id, token, creation_date
+`
+
+type UpsertCalendarTokenParams struct {
+	UserID int32
+	Token  pgtype.UUID
+}
+
+// Create or replace the private calendar feed token of a user
+func (q *Queries) UpsertCalendarToken(ctx context.Context, arg UpsertCalendarTokenParams) (CalendarToken, error) {
+	row := q.db.QueryRow(ctx, upsertCalendarToken, arg.UserID, arg.Token)
+	var i CalendarToken
+	err := row.Scan(&i.UserID, &i.Token, &i.CreationDate)
+	return i, err
+}
+
+const upsertCommentVote = `-- name: UpsertCommentVote :exec
+INSERT INTO comment_votes (comment_id, user_id, value) VALUES ($1, $2, $3)
+ON CONFLICT (comment_id, user_id) DO UPDATE SET value = EXCLUDED.value, creation_date = CURRENT_TIMESTAMP
+`
+
+type UpsertCommentVoteParams struct {
+	CommentID int32
+	UserID    int32
+	Value     int16
+}
+
+// Create or change a user's vote on a comment
+func (q *Queries) UpsertCommentVote(ctx context.Context, arg UpsertCommentVoteParams) error {
+	_, err := q.db.Exec(ctx, upsertCommentVote, arg.CommentID, arg.UserID, arg.Value)
+	return err
+}
+
+const upsertEventOccurrence = `-- name: UpsertEventOccurrence :one
+INSERT INTO event_occurrences (event_id, occurrence_date, is_cancelled, title, description, event_date, meeting_point)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (event_id, occurrence_date) DO UPDATE SET is_cancelled = EXCLUDED.is_cancelled, title = EXCLUDED.title,
+description = EXCLUDED.description, event_date = EXCLUDED.event_date, meeting_point = EXCLUDED.meeting_point
+RETURNING event_id, occurrence_date, is_cancelled, title, description, event_date, meeting_point
+`
+
+type UpsertEventOccurrenceParams struct {
+	EventID        int32
+	OccurrenceDate pgtype.Timestamptz
+	IsCancelled    bool
+	Title          pgtype.Text
+	Description    pgtype.Text
+	EventDate      pgtype.Timestamptz
+	MeetingPoint   pgtype.Text
+}
+
+// Cancel or change a single occurrence of a recurring event
+func (q *Queries) UpsertEventOccurrence(ctx context.Context, arg UpsertEventOccurrenceParams) (EventOccurrence, error) {
+	row := q.db.QueryRow(ctx, upsertEventOccurrence,
+		arg.EventID,
+		arg.OccurrenceDate,
+		arg.IsCancelled,
+		arg.Title,
+		arg.Description,
+		arg.EventDate,
+		arg.MeetingPoint,
+	)
+	var i EventOccurrence
+	err := row.Scan(
+		&i.EventID,
+		&i.OccurrenceDate,
+		&i.IsCancelled,
+		&i.Title,
+		&i.Description,
+		&i.EventDate,
+		&i.MeetingPoint,
+	)
+	return i, err
+}
+
+const upsertPostVote = `-- name: UpsertPostVote :exec
+INSERT INTO post_votes (post_id, user_id, value) VALUES ($1, $2, $3)
+ON CONFLICT (post_id, user_id) DO UPDATE SET value = EXCLUDED.value, creation_date = CURRENT_TIMESTAMP
+`
+
+type UpsertPostVoteParams struct {
+	PostID int32
+	UserID int32
+	Value  int16
+}
+
+// Create or change a user's vote on a post
+func (q *Queries) UpsertPostVote(ctx context.Context, arg UpsertPostVoteParams) error {
+	_, err := q.db.Exec(ctx, upsertPostVote, arg.PostID, arg.UserID, arg.Value)
+	return err
+}
+
+const upsertRSVP = `-- name: UpsertRSVP :one
+INSERT INTO rsvps (event_id, user_id, rsvp_status, occurrence_date) VALUES ($1, $2, $3, $4)
+ON CONFLICT (event_id, user_id, occurrence_date) DO UPDATE SET rsvp_status = EXCLUDED.rsvp_status, rsvp_date = CURRENT_TIMESTAMP
+RETURNING rsvp_id, event_id, user_id, rsvp_status, rsvp_date, occurrence_date
+`
+
+type UpsertRSVPParams struct {
+	EventID        pgtype.Int4
+	UserID         pgtype.Int4
+	RsvpStatus     string
+	OccurrenceDate pgtype.Timestamptz
+}
+
+// Create a user's RSVP to an event occurrence, or change its status if the user has already RSVP'd
+func (q *Queries) UpsertRSVP(ctx context.Context, arg UpsertRSVPParams) (Rsvp, error) {
+	row := q.db.QueryRow(ctx, upsertRSVP,
+		arg.EventID,
+		arg.UserID,
+		arg.RsvpStatus,
+		arg.OccurrenceDate,
+	)
+	var i Rsvp
+	err := row.Scan(
+		&i.RsvpID,
+		&i.EventID,
+		&i.UserID,
+		&i.RsvpStatus,
+		&i.RsvpDate,
+		&i.OccurrenceDate,
+	)
+	return i, err
+}
+
+const upsertReport = `-- name: UpsertReport :one
+INSERT INTO reports (target_type, target_id, target_user_id) VALUES ($1, $2, $3)
+ON CONFLICT (target_type, target_id) WHERE status IN ('open', 'in_review')
+DO UPDATE SET last_reported_date = CURRENT_TIMESTAMP
+RETURNING report_id, target_type, target_id, target_user_id, status, submission_count, creation_date, last_reported_date, reviewer_user_id, resolution_action, resolution_note, resolution_date
+`
+
+type UpsertReportParams struct {
+	TargetType   string
+	TargetID     int32
+	TargetUserID pgtype.Int4
+}
+
+// Open a report on a target, or return the report that is already open or in review for it
+func (q *Queries) UpsertReport(ctx context.Context, arg UpsertReportParams) (Report, error) {
+	row := q.db.QueryRow(ctx, upsertReport, arg.TargetType, arg.TargetID, arg.TargetUserID)
+	var i Report
+	err := row.Scan(
+		&i.ReportID,
+		&i.TargetType,
+		&i.TargetID,
+		&i.TargetUserID,
+		&i.Status,
+		&i.SubmissionCount,
+		&i.CreationDate,
+		&i.LastReportedDate,
+		&i.ReviewerUserID,
+		&i.ResolutionAction,
+		&i.ResolutionNote,
+		&i.ResolutionDate,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND email = $2 AND email_verified_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	UserID int32
+	Email  string
+}
+
+// Mark a user's email address as verified, as long as it is still the address the verification link was sent to
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyUserEmail, arg.UserID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const watchPost = `-- name: WatchPost :exec
+
+INSERT INTO post_watches (user_id, post_id) VALUES ($1, $2)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type WatchPostParams struct {
+	UserID int32
+	PostID int32
+}
+
+// ----------------------------------------------------------------------------------------------------------------------
+// Watch a post, doing nothing if the user already watches it
+func (q *Queries) WatchPost(ctx context.Context, arg WatchPostParams) error {
+	_, err := q.db.Exec(ctx, watchPost, arg.UserID, arg.PostID)
+	return err
+}
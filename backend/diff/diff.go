@@ -0,0 +1,189 @@
+// Package diff computes line-based unified diffs of text
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines shown around each change
+const ContextLines = 3
+
+type op struct {
+	kind byte // ' ' for unchanged, '-' for removed, '+' for added
+	text string
+}
+
+// Unified returns the unified diff turning a into b, with fromName and toName as the file headers.
+// It returns an empty string when a and b have the same lines.
+func Unified(fromName, toName, a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var changes []int
+	for i, o := range ops {
+		if o.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	// line numbers in a and b before each op, 1-based
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	aLine[0], bLine[0] = 1, 1
+	for i, o := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if o.kind != '+' {
+			aLine[i+1]++
+		}
+		if o.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changes); {
+		// changes separated by at most twice the context share a hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j]-1 <= 2*ContextLines {
+			j++
+		}
+		start := max(changes[i]-ContextLines, 0)
+		end := min(changes[j]+ContextLines+1, len(ops))
+
+		aCount, bCount := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine[start], aCount), hunkRange(bLine[start], bCount))
+		for _, o := range ops[start:end] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.text)
+			sb.WriteByte('\n')
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// hunkRange formats the start and length of a hunk. An empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the edit script from a to b that keeps their longest common subsequence of lines.
+// It uses Hirschberg's algorithm, so it needs memory linear in the number of lines rather than a full LCS table.
+func diffLines(a, b []string) []op {
+	var ops []op
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		ops = append(ops, op{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	common := 0
+	for common < len(a) && common < len(b) && a[len(a)-1-common] == b[len(b)-1-common] {
+		common++
+	}
+	suffix := a[len(a)-common:]
+	a, b = a[:len(a)-common], b[:len(b)-common]
+
+	ops = appendEdits(ops, a, b)
+	for _, line := range suffix {
+		ops = append(ops, op{' ', line})
+	}
+	return ops
+}
+
+// appendEdits appends the edit script from a to b to ops, splitting a in half and b where the halves'
+// common subsequences meet
+func appendEdits(ops []op, a, b []string) []op {
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, op{'+', line})
+		}
+		return ops
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, op{'-', line})
+		}
+		return ops
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				ops = appendEdits(ops, nil, b[:j])
+				ops = append(ops, op{' ', line})
+				return appendEdits(ops, nil, b[j+1:])
+			}
+		}
+		ops = append(ops, op{'-', a[0]})
+		return appendEdits(ops, nil, b)
+	}
+
+	mid := len(a) / 2
+	head := lcsLengths(a[:mid], b)
+	tail := lcsLengthsFromEnd(a[mid:], b)
+	split := 0
+	for j := range head {
+		if head[j]+tail[j] > head[split]+tail[split] {
+			split = j
+		}
+	}
+	ops = appendEdits(ops, a[:mid], b[:split])
+	return appendEdits(ops, a[mid:], b[split:])
+}
+
+// lcsLengths returns the length of the longest common subsequence of a and b[:j] for each j
+func lcsLengths(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsLengthsFromEnd returns the length of the longest common subsequence of a and b[j:] for each j
+func lcsLengthsFromEnd(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
@@ -0,0 +1,102 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns lines 1 to n, replacing the lines in changed
+func numberedLines(n int, changed map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := changed[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "empty", a: "", b: "", want: ""},
+		{name: "identical", a: "one\ntwo\n", b: "one\ntwo\n", want: ""},
+		{name: "line endings only", a: "one\r\ntwo\r\n", b: "one\ntwo", want: ""},
+		{name: "insert into empty", a: "", b: "one\ntwo\n", want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n"},
+		{name: "delete everything", a: "one\ntwo\n", b: "", want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-one\n-two\n"},
+		{name: "replace single line", a: "one", b: "two", want: "--- a\n+++ b\n@@ -1 +1 @@\n-one\n+two\n"},
+		{
+			name: "pure insert",
+			a:    numberedLines(10, nil),
+			b:    strings.Replace(numberedLines(10, nil), "5\n", "5\nnew\n", 1),
+			want: "--- a\n+++ b\n@@ -3,6 +3,7 @@\n 3\n 4\n 5\n+new\n 6\n 7\n 8\n",
+		},
+		{
+			name: "pure delete",
+			a:    numberedLines(10, nil),
+			b:    strings.Replace(numberedLines(10, nil), "5\n", "", 1),
+			want: "--- a\n+++ b\n@@ -2,7 +2,6 @@\n 2\n 3\n 4\n-5\n 6\n 7\n 8\n",
+		},
+		{
+			// six unchanged lines between the changes, which is twice the context
+			name: "changes merged into one hunk",
+			a:    numberedLines(20, nil),
+			b:    numberedLines(20, map[int]string{5: "five", 12: "twelve"}),
+			want: "--- a\n+++ b\n@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+twelve\n 13\n 14\n 15\n",
+		},
+		{
+			name: "changes in separate hunks",
+			a:    numberedLines(20, nil),
+			b:    numberedLines(20, map[int]string{5: "five", 13: "thirteen"}),
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+				"@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+thirteen\n 14\n 15\n 16\n",
+		},
+	}
+	for _, test := range tests {
+		if got := Unified("a", "b", test.a, test.b); got != test.want {
+			t.Errorf("%s: Unified() =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffLinesKeepsLongestCommonSubsequence(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		common int
+	}{
+		{a: "abcabba", b: "cbabac", common: 4},
+		{a: "abcdef", b: "fedcba", common: 1},
+		{a: "aaaa", b: "aa", common: 2},
+		{a: "xaybzc", b: "abc", common: 3},
+	}
+	for _, test := range tests {
+		a, b := strings.Split(test.a, ""), strings.Split(test.b, "")
+		ops := diffLines(a, b)
+
+		var gotA, gotB []string
+		common := 0
+		for _, o := range ops {
+			if o.kind != '+' {
+				gotA = append(gotA, o.text)
+			}
+			if o.kind != '-' {
+				gotB = append(gotB, o.text)
+			}
+			if o.kind == ' ' {
+				common++
+			}
+		}
+		if strings.Join(gotA, "") != test.a || strings.Join(gotB, "") != test.b {
+			t.Errorf("diffLines(%q, %q) turns %q into %q", test.a, test.b, strings.Join(gotA, ""), strings.Join(gotB, ""))
+		}
+		if common != test.common {
+			t.Errorf("diffLines(%q, %q) keeps %d lines, want %d", test.a, test.b, common, test.common)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package gpx reads and writes the subset of GPX 1.1 needed to store cycling routes
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+)
+
+const earthRadiusKm = 6371.0
+
+// Point is a single track point
+type Point struct {
+	Latitude  float64
+	Longitude float64
+	Elevation *float64 // nil when the GPX file has no <ele> for the point
+}
+
+// Track is a flattened GPX file: all track segments (or route points) joined in order
+type Track struct {
+	Name        string
+	Description string
+	Points      []Point
+}
+
+type gpxFile struct {
+	XMLName  xml.Name     `xml:"gpx"`
+	Version  string       `xml:"version,attr"`
+	Creator  string       `xml:"creator,attr"`
+	Xmlns    string       `xml:"xmlns,attr,omitempty"`
+	Metadata *gpxMetadata `xml:"metadata,omitempty"`
+	Routes   []gpxRoute   `xml:"rte"`
+	Tracks   []gpxTrack   `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name        string `xml:"name,omitempty"`
+	Description string `xml:"desc,omitempty"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Name        string       `xml:"name,omitempty"`
+	Description string       `xml:"desc,omitempty"`
+	Segments    []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+}
+
+// Decode parses a GPX document. Track points are preferred; route points are used when the file has no tracks.
+func Decode(r io.Reader) (*Track, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	t := &Track{}
+	if f.Metadata != nil {
+		t.Name = f.Metadata.Name
+		t.Description = f.Metadata.Description
+	}
+
+	for _, trk := range f.Tracks {
+		if t.Name == "" {
+			t.Name = trk.Name
+		}
+		if t.Description == "" {
+			t.Description = trk.Description
+		}
+		for _, seg := range trk.Segments {
+			t.Points = appendPoints(t.Points, seg.Points)
+		}
+	}
+
+	if len(t.Points) == 0 {
+		for _, rte := range f.Routes {
+			if t.Name == "" {
+				t.Name = rte.Name
+			}
+			t.Points = appendPoints(t.Points, rte.Points)
+		}
+	}
+
+	if len(t.Points) == 0 {
+		return nil, errors.New("gpx file contains no track or route points")
+	}
+
+	for _, p := range t.Points {
+		// written as a negated range check so that NaN coordinates are rejected too
+		if !(p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180) {
+			return nil, errors.New("gpx file contains coordinates out of range")
+		}
+	}
+
+	return t, nil
+}
+
+// appendPoints converts GPX points, treating elevations that are not finite numbers as missing
+func appendPoints(points []Point, gpxPoints []gpxPoint) []Point {
+	for _, p := range gpxPoints {
+		elevation := p.Elevation
+		if elevation != nil && (math.IsNaN(*elevation) || math.IsInf(*elevation, 0)) {
+			elevation = nil
+		}
+		points = append(points, Point{Latitude: p.Latitude, Longitude: p.Longitude, Elevation: elevation})
+	}
+	return points
+}
+
+// Encode writes the track as a GPX 1.1 document with a single track segment
+func Encode(w io.Writer, t *Track) error {
+	seg := gpxSegment{Points: make([]gpxPoint, len(t.Points))}
+	for i, p := range t.Points {
+		seg.Points[i] = gpxPoint{Latitude: p.Latitude, Longitude: p.Longitude, Elevation: p.Elevation}
+	}
+
+	f := gpxFile{
+		Version:  "1.1",
+		Creator:  "cvwo-assignment",
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Metadata: &gpxMetadata{Name: t.Name, Description: t.Description},
+		Tracks:   []gpxTrack{{Name: t.Name, Segments: []gpxSegment{seg}}},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(f)
+}
+
+// Distance returns the length of the track in kilometres
+func (t *Track) Distance() float64 {
+	total := 0.0
+	for i := 1; i < len(t.Points); i++ {
+		total += haversine(t.Points[i-1], t.Points[i])
+	}
+	return total
+}
+
+// ElevationGain returns the total ascent of the track in metres, skipping points without elevation
+func (t *Track) ElevationGain() int32 {
+	gain := 0.0
+	var last *float64
+	for _, p := range t.Points {
+		if p.Elevation == nil {
+			continue
+		}
+		if last != nil && *p.Elevation > *last {
+			gain += *p.Elevation - *last
+		}
+		last = p.Elevation
+	}
+	return int32(math.Round(gain))
+}
+
+func haversine(a, b Point) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
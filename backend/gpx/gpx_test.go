@@ -0,0 +1,162 @@
+package gpx
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func elevation(e float64) *float64 {
+	return &e
+}
+
+func TestDecode(t *testing.T) {
+	track, err := Decode(strings.NewReader(`<?xml version="1.0"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>Coastal loop</name>
+    <desc>Flat and windy</desc>
+    <trkseg>
+      <trkpt lat="1.30" lon="103.80"><ele>10</ele></trkpt>
+      <trkpt lat="1.31" lon="103.81"><ele>NaN</ele></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="1.32" lon="103.82"></trkpt>
+    </trkseg>
+  </trk>
+  <rte><rtept lat="0" lon="0"/></rte>
+</gpx>`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if track.Name != "Coastal loop" || track.Description != "Flat and windy" {
+		t.Errorf("Decode name and description = %q, %q", track.Name, track.Description)
+	}
+	if len(track.Points) != 3 {
+		t.Fatalf("Decode joined %d points, want the 3 track points", len(track.Points))
+	}
+	if e := track.Points[0].Elevation; e == nil || *e != 10 {
+		t.Errorf("first point elevation = %v, want 10", e)
+	}
+	if e := track.Points[1].Elevation; e != nil {
+		t.Errorf("NaN elevation decoded as %v, want none", *e)
+	}
+	if e := track.Points[2].Elevation; e != nil {
+		t.Errorf("missing elevation decoded as %v, want none", *e)
+	}
+}
+
+func TestDecodeRoutePoints(t *testing.T) {
+	track, err := Decode(strings.NewReader(`<gpx version="1.1"><rte><name>Planned</name><rtept lat="1" lon="2"/><rtept lat="3" lon="4"/></rte></gpx>`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if track.Name != "Planned" || len(track.Points) != 2 || track.Points[1].Latitude != 3 {
+		t.Errorf("Decode = %+v, want the route and its 2 points", track)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not xml":            "not a gpx file",
+		"no points":          `<gpx version="1.1"><trk><trkseg></trkseg></trk></gpx>`,
+		"latitude range":     `<gpx version="1.1"><trk><trkseg><trkpt lat="91" lon="0"/></trkseg></trk></gpx>`,
+		"longitude range":    `<gpx version="1.1"><trk><trkseg><trkpt lat="0" lon="-181"/></trkseg></trk></gpx>`,
+		"NaN latitude":       `<gpx version="1.1"><trk><trkseg><trkpt lat="NaN" lon="0"/></trkseg></trk></gpx>`,
+		"infinite longitude": `<gpx version="1.1"><trk><trkseg><trkpt lat="0" lon="Inf"/></trkseg></trk></gpx>`,
+	} {
+		if _, err := Decode(strings.NewReader(doc)); err == nil {
+			t.Errorf("Decode accepted a file with %s", name)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	want := &Track{
+		Name:        "Coastal loop",
+		Description: "Flat & windy",
+		Points: []Point{
+			{Latitude: 1.3, Longitude: 103.8, Elevation: elevation(12.5)},
+			{Latitude: 1.31, Longitude: 103.81},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, want); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode of encoded track failed: %v", err)
+	}
+	if got.Name != want.Name || got.Description != want.Description || len(got.Points) != len(want.Points) {
+		t.Fatalf("round trip = %+v, want %+v", got, want)
+	}
+	for i, p := range got.Points {
+		w := want.Points[i]
+		if p.Latitude != w.Latitude || p.Longitude != w.Longitude || (p.Elevation == nil) != (w.Elevation == nil) ||
+			(p.Elevation != nil && *p.Elevation != *w.Elevation) {
+			t.Errorf("point %d = %+v, want %+v", i, p, w)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   float64
+	}{
+		{name: "no points", want: 0},
+		{name: "one point", points: []Point{{Latitude: 1, Longitude: 1}}, want: 0},
+		// a degree of latitude is 2πR/360
+		{name: "one degree north", points: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 1, Longitude: 0}}, want: 111.195},
+		{name: "one degree east on the equator", points: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}, want: 111.195},
+		{name: "out and back", points: []Point{{Latitude: 0, Longitude: 0}, {Latitude: 1, Longitude: 0}, {Latitude: 0, Longitude: 0}}, want: 222.390},
+		{name: "across the antimeridian", points: []Point{{Latitude: 0, Longitude: 179.5}, {Latitude: 0, Longitude: -179.5}}, want: 111.195},
+		{name: "London to Paris", points: []Point{{Latitude: 51.5074, Longitude: -0.1278}, {Latitude: 48.8566, Longitude: 2.3522}}, want: 343.56},
+	}
+	for _, test := range tests {
+		got := (&Track{Points: test.points}).Distance()
+		if math.Abs(got-test.want) > 0.01 {
+			t.Errorf("%s: Distance() = %.3f, want %.3f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestElevationGain(t *testing.T) {
+	tests := []struct {
+		name       string
+		elevations []*float64
+		want       int32
+	}{
+		{name: "no elevations", elevations: []*float64{nil, nil}, want: 0},
+		{name: "climb and descent", elevations: []*float64{elevation(10), elevation(25), elevation(5), elevation(12)}, want: 22},
+		{name: "missing elevations skipped", elevations: []*float64{elevation(10), nil, elevation(30), nil}, want: 20},
+		{name: "rounded", elevations: []*float64{elevation(0), elevation(0.4), elevation(0.8)}, want: 1},
+		{name: "descent only", elevations: []*float64{elevation(100), elevation(50)}, want: 0},
+	}
+	for _, test := range tests {
+		track := &Track{}
+		for _, e := range test.elevations {
+			track.Points = append(track.Points, Point{Elevation: e})
+		}
+		if got := track.ElevationGain(); got != test.want {
+			t.Errorf("%s: ElevationGain() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestElevationGainIgnoresNaN(t *testing.T) {
+	track, err := Decode(strings.NewReader(`<gpx version="1.1"><trk><trkseg>
+<trkpt lat="0" lon="0"><ele>10</ele></trkpt>
+<trkpt lat="0" lon="0.01"><ele>NaN</ele></trkpt>
+<trkpt lat="0" lon="0.02"><ele>40</ele></trkpt>
+</trkseg></trk></gpx>`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got := track.ElevationGain(); got != 30 {
+		t.Errorf("ElevationGain() = %d, want 30 ignoring the NaN elevation", got)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/netip"
 	"server/db"
+	"server/mail"
+	"server/stream"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,9 +21,14 @@ import (
 )
 
 type Handler struct {
-	Log     *logrus.Logger
-	Dbpool  *pgxpool.Pool
-	Queries *db.Queries
+	Log                *logrus.Logger
+	Dbpool             *pgxpool.Pool
+	Queries            *db.Queries
+	Stream             *stream.Hub
+	Mailer             mail.Mailer
+	SiteURL            string   // where users reach the site, without a trailing slash, for links in emails
+	VerificationSecret []byte   // signs email verification links
+	AllowedOrigins     []string // the frontend's origins, the only ones allowed to open chat WebSockets
 }
 
 func (h *Handler) Ping(c *gin.Context) {
@@ -77,6 +84,11 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
+	if suspension, suspended := accountSuspension(user.IsActive, user.SuspendedUntil, user.SuspensionReason); suspended {
+		c.JSON(http.StatusForbidden, suspension)
+		return
+	}
+
 	parsedIpAddress, err := getIPAddress(c.Request)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
@@ -117,7 +129,7 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Logged in!", "session_id": sessionID.String(), "user_id": user.UserID})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged in!", "session_id": sessionID.String(), "user_id": user.UserID, "email_verified": user.EmailVerifiedAt.Valid})
 }
 
 // Logout logs the user out by invalidating the session
@@ -162,15 +174,34 @@ func (h *Handler) Logout(c *gin.Context) {
 // InjectRoleNameAndUserID is a middleware that injects the user's role name and user ID into the context
 func (h *Handler) InjectRoleNameAndUserID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sessionID := c.GetHeader("session_id")
-		if sessionID == "" {
+		var sessionID pgtype.UUID
+		if header := c.GetHeader("session_id"); header != "" {
+			id, err := uuid.Parse(header)
+			if err != nil {
+				c.Set("RoleName", "Guest")
+				c.Set("Error", fmt.Errorf("invalid session ID"))
+				c.Next()
+				return
+			}
+			sessionID = pgtype.UUID{Bytes: id, Valid: true}
+		} else if ticket := c.Query("ticket"); ticket != "" && isStreamRequest(c) {
+			// browsers cannot set headers on EventSource and WebSocket connections, so these send a ticket in the query
+			id, err := h.redeemStreamTicket(ticket)
+			if err != nil {
+				c.Set("RoleName", "Guest")
+				c.Set("Error", fmt.Errorf("invalid stream ticket"))
+				c.Next()
+				return
+			}
+			sessionID = id
+		} else {
 			c.Set("RoleName", "Guest")
 			c.Set("Error", fmt.Errorf("no session header found"))
 			c.Next()
 			return
 		}
 
-		uuid, err := uuid.Parse(sessionID)
+		userSession, err := h.Queries.GetUserSessionAndRoleName(context.Background(), sessionID)
 		if err != nil {
 			c.Set("RoleName", "Guest")
 			c.Set("Error", fmt.Errorf("invalid session ID"))
@@ -178,20 +209,18 @@ func (h *Handler) InjectRoleNameAndUserID() gin.HandlerFunc {
 			return
 		}
 
-		var sessionID16Bytes [16]byte
-		copy(sessionID16Bytes[:], uuid[:])
-
-		userSession, err := h.Queries.GetUserSessionAndRoleName(context.Background(), pgtype.UUID{Bytes: sessionID16Bytes, Valid: true})
-		if err != nil {
+		if userSession.ExpiryDate.Time.Before(time.Now()) {
 			c.Set("RoleName", "Guest")
-			c.Set("Error", fmt.Errorf("invalid session ID"))
+			c.Set("Error", fmt.Errorf("session expired"))
 			c.Next()
 			return
 		}
 
-		if userSession.ExpiryDate.Time.Before(time.Now()) {
+		// suspended users are treated as guests, and EnsureRole refuses them with the suspension error
+		if suspension, suspended := accountSuspension(userSession.IsActive, userSession.SuspendedUntil, userSession.SuspensionReason); suspended {
 			c.Set("RoleName", "Guest")
-			c.Set("Error", fmt.Errorf("session expired"))
+			c.Set("Error", fmt.Errorf("account suspended"))
+			c.Set("Suspension", suspension)
 			c.Next()
 			return
 		}
@@ -201,13 +230,42 @@ func (h *Handler) InjectRoleNameAndUserID() gin.HandlerFunc {
 
 		c.Set("RoleName", userSession.RoleName)   // type string
 		c.Set("UserID", userSession.UserID.Int32) // type int32
+		c.Set("SessionID", sessionID)             // type pgtype.UUID
+		c.Set("EmailVerified", userSession.EmailVerifiedAt.Valid)
 		c.Next()
 	}
 }
 
+// streamPaths are the routes opening a server-sent event stream or a WebSocket, which accept a stream ticket in place
+// of the session header
+var streamPaths = map[string]bool{"/api/stream": true, "/api/messages/ws": true}
+
+// isStreamRequest reports whether a request opens a server-sent event stream or a WebSocket
+func isStreamRequest(c *gin.Context) bool {
+	return c.Request.Method == http.MethodGet && streamPaths[c.FullPath()]
+}
+
+// redeemStreamTicket uses up a stream ticket, returning the session it was issued for
+func (h *Handler) redeemStreamTicket(ticket string) (pgtype.UUID, error) {
+	id, err := uuid.Parse(ticket)
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return h.Queries.RedeemStreamTicket(context.Background(), db.RedeemStreamTicketParams{
+		Ticket: pgtype.UUID{Bytes: id, Valid: true},
+		Now:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+}
+
 // EnsureRole is a middleware that ensures the user has the required role to perform an action
 func (h *Handler) EnsureRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if suspension, ok := c.Get("Suspension"); ok {
+			c.JSON(http.StatusForbidden, suspension)
+			c.Abort()
+			return
+		}
+
 		userRole := c.GetString("RoleName")
 
 		if userRole == "" {
@@ -228,7 +286,21 @@ func (h *Handler) EnsureRole(roles ...string) gin.HandlerFunc {
 	}
 }
 
-// validateRoleAndUserID checks if the user is the owner of the resource or an admin
+// EnsureEmailVerified is a middleware that refuses users who have not verified their email address yet. It guards the
+// routes that create or change content, votes, RSVPs and reports, while deleting their own content stays open to them.
+// It must run after EnsureRole, which refuses guests.
+func (h *Handler) EnsureEmailVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("EmailVerified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before posting, voting or messaging", "email_verified": false})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// validateUserID checks if the user is the owner of the account being modified
 func (h *Handler) validateUserID(c *gin.Context, userIDToModify int32) bool {
 	userID, ok := c.Get("UserID")
 	if !ok {
@@ -243,3 +315,19 @@ func (h *Handler) validateUserID(c *gin.Context, userIDToModify int32) bool {
 	}
 	return true
 }
+
+// getUserID returns the ID of the logged in user injected by InjectRoleNameAndUserID
+func getUserID(c *gin.Context) (int32, bool) {
+	userID, ok := c.Get("UserID")
+	if !ok {
+		return 0, false
+	}
+	userIDInt32, ok := userID.(int32)
+	return userIDInt32, ok
+}
+
+// isModerator reports whether the logged in user is a moderator or an admin
+func isModerator(c *gin.Context) bool {
+	role := c.GetString("RoleName")
+	return role == "Moderator" || role == "Admin"
+}
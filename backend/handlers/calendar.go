@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"server/ical"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// defaultEventDuration is the length given to calendar entries, since events only store a start time
+const defaultEventDuration = 2 * time.Hour
+
+// routeNames looks up the names of the routes linked to the given occurrences, keyed by route ID
+func (h *Handler) routeNames(ctx context.Context, events []Occurrence) (map[int32]string, error) {
+	names := make(map[int32]string)
+	for _, event := range events {
+		if !event.RouteID.Valid {
+			continue
+		}
+		if _, ok := names[event.RouteID.Int32]; ok {
+			continue
+		}
+		route, err := h.Queries.GetRouteByID(ctx, event.RouteID.Int32)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names[route.RouteID] = route.Name
+	}
+	return names, nil
+}
+
+// buildCalendar converts event occurrences into a calendar, adding the meeting point as the location
+// and the linked route name to the description. Each occurrence of a recurring event is a separate entry.
+func (h *Handler) buildCalendar(ctx context.Context, name string, events []Occurrence) (*ical.Calendar, error) {
+	names, err := h.routeNames(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &ical.Calendar{Name: name}
+	for _, event := range events {
+		description := event.Description.String
+		if routeName, ok := names[event.RouteID.Int32]; event.RouteID.Valid && ok {
+			if description != "" {
+				description += "\n\n"
+			}
+			description += "Route: " + routeName
+		}
+		uid := fmt.Sprintf("event-%d@cvwo-assignment", event.EventID)
+		if event.OccurrenceDate != nil {
+			uid = fmt.Sprintf("event-%d-%s@cvwo-assignment", event.EventID, event.OccurrenceDate.UTC().Format("20060102T150405Z"))
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         uid,
+			Summary:     event.Title,
+			Description: description,
+			Location:    event.MeetingPoint,
+			Start:       event.EventDate.Time,
+			End:         event.EventDate.Time.Add(defaultEventDuration),
+			Cancelled:   event.IsCancelled,
+		})
+	}
+	return cal, nil
+}
+
+// writeCalendar sends a calendar as a text/calendar response, either inline for feeds or as an attachment for downloads
+func (h *Handler) writeCalendar(c *gin.Context, disposition, filename string, cal *ical.Calendar) {
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, filename))
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := ical.Encode(c.Writer, cal); err != nil {
+		h.Log.Errorf("Unable to encode calendar: %v\n", err)
+	}
+}
+
+// ExportEventICSHandler handles GET requests to download a single event as an .ics file.
+// Recurring events include their occurrences over the next year.
+func (h *Handler) ExportEventICSHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	event, err := h.Queries.GetEventByID(context.Background(), int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+
+	occurrences := []Occurrence{{Event: event}}
+	if event.RecurrenceRule.Valid {
+		now := time.Now()
+		occurrences, err = h.expandEvents(context.Background(), []db.Event{event}, now, now.Add(defaultEventRange), 0)
+		if err != nil {
+			h.Log.Errorf("Unable to expand event occurrences: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+			return
+		}
+	}
+
+	cal, err := h.buildCalendar(context.Background(), event.Title, occurrences)
+	if err != nil {
+		h.Log.Errorf("Unable to build calendar: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	h.writeCalendar(c, "attachment", fmt.Sprintf("event-%d.ics", event.EventID), cal)
+}
+
+// GetEventsCalendarHandler handles GET requests for the public calendar feed of event occurrences over the next year
+func (h *Handler) GetEventsCalendarHandler(c *gin.Context) {
+	now := time.Now()
+	upcoming, err := h.occurrencesBetween(context.Background(), now, now.Add(defaultEventRange))
+	if err != nil {
+		h.Log.Errorf("Unable to fetch events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	cal, err := h.buildCalendar(context.Background(), "Upcoming events", upcoming)
+	if err != nil {
+		h.Log.Errorf("Unable to build calendar: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	h.writeCalendar(c, "inline", "events.ics", cal)
+}
+
+// calendarTokenResponse returns the token of a user's private feed along with the path to subscribe to
+func calendarTokenResponse(token db.CalendarToken) gin.H {
+	value := uuid.UUID(token.Token.Bytes).String()
+	return gin.H{"token": value, "path": "/api/events/calendar/user/" + value}
+}
+
+// GetCalendarTokenHandler handles GET requests to get the logged in user's private calendar feed token,
+// creating one on first use
+func (h *Handler) GetCalendarTokenHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	token, err := h.Queries.GetCalendarTokenByUserID(context.Background(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		token, err = h.createCalendarToken(userID)
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to get calendar token: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, calendarTokenResponse(token))
+}
+
+// RegenerateCalendarTokenHandler handles POST requests to replace the logged in user's private calendar feed token.
+// Subscriptions using the old token stop working.
+func (h *Handler) RegenerateCalendarTokenHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	token, err := h.createCalendarToken(userID)
+	if err != nil {
+		h.Log.Errorf("Unable to create calendar token: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar token"})
+		return
+	}
+
+	response := calendarTokenResponse(token)
+	response["message"] = "Calendar token regenerated successfully"
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) createCalendarToken(userID int32) (db.CalendarToken, error) {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return db.CalendarToken{}, err
+	}
+	return h.Queries.UpsertCalendarToken(context.Background(), db.UpsertCalendarTokenParams{
+		UserID: userID,
+		Token:  pgtype.UUID{Bytes: token, Valid: true},
+	})
+}
+
+// GetUserCalendarHandler handles GET requests for a user's private calendar feed of the events they are going to.
+// The feed is identified by its token alone so that calendar apps can subscribe without a session.
+func (h *Handler) GetUserCalendarHandler(c *gin.Context) {
+	token, err := uuid.Parse(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	ctx := context.Background()
+	calendarToken, err := h.Queries.GetCalendarTokenByToken(ctx, pgtype.UUID{Bytes: token, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get calendar"})
+		return
+	}
+
+	events, err := h.goingOccurrences(ctx, calendarToken.UserID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	cal, err := h.buildCalendar(ctx, "My events", events)
+	if err != nil {
+		h.Log.Errorf("Unable to build calendar: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	h.writeCalendar(c, "inline", "my-events.ics", cal)
+}
+
+// goingOccurrences returns the occurrences a user has RSVPed going to, skipping occurrences no longer in their series
+func (h *Handler) goingOccurrences(ctx context.Context, userID int32) ([]Occurrence, error) {
+	rsvps, err := h.Queries.GetRSVPsByUserID(ctx, pgtype.Int4{Int32: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Occurrence
+	for _, rsvp := range rsvps {
+		if rsvp.RsvpStatus != RSVPGoing || !rsvp.EventID.Valid {
+			continue
+		}
+		event, err := h.Queries.GetEventByID(ctx, rsvp.EventID.Int32)
+		if err != nil {
+			return nil, err
+		}
+		var occurrenceDate *time.Time
+		if rsvp.OccurrenceDate.Valid {
+			occurrenceDate = &rsvp.OccurrenceDate.Time
+		}
+		occurrence, err := resolveOccurrence(ctx, h.Queries, event, occurrenceDate)
+		if errors.Is(err, errInvalidOccurrence) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, occurrence)
+	}
+	return events, nil
+}
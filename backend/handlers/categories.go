@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"server/db"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetFollowedCategoriesHandler handles GET requests to get the categories the logged in user follows
+func (h *Handler) GetFollowedCategoriesHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	categories, err := h.Queries.GetFollowedCategories(context.Background(), userID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch followed categories: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get followed categories"})
+		return
+	}
+	if categories == nil {
+		categories = []db.Category{}
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// FollowCategoryHandler handles PUT requests to follow a category, whose new posts are then listed in the logged in
+// user's digest emails
+func (h *Handler) FollowCategoryHandler(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	if _, err := h.Queries.GetCategory(ctx, int32(categoryID)); errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	} else if err != nil {
+		h.Log.Errorf("Unable to fetch category: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow category"})
+		return
+	}
+
+	if err := h.Queries.FollowCategory(ctx, db.FollowCategoryParams{UserID: userID, CategoryID: int32(categoryID)}); err != nil {
+		h.Log.Errorf("Unable to follow category: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category followed successfully"})
+}
+
+// UnfollowCategoryHandler handles DELETE requests to stop following a category
+func (h *Handler) UnfollowCategoryHandler(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	deleted, err := h.Queries.UnfollowCategory(context.Background(), db.UnfollowCategoryParams{UserID: userID, CategoryID: int32(categoryID)})
+	if err != nil {
+		h.Log.Errorf("Unable to unfollow category: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow category"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category follow not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category unfollowed successfully"})
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/db"
+	"server/stream"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/net/websocket"
+)
+
+// Chat frame types. Typing indicators and receipts are not stored, and reach the other user's connections through the
+// stream hub like stored events do.
+const (
+	chatMessage   = "message"   // a new private message, sent by the client to send one
+	chatSent      = "sent"      // the message a client sent was saved
+	chatTyping    = "typing"    // a user started or stopped typing to the other
+	chatDelivered = "delivered" // messages reached one of their receiver's connections
+	chatRead      = "read"      // a user read messages, sent by the client to mark them as read
+	chatPing      = "ping"
+	chatPong      = "pong"
+	chatError     = "error"
+)
+
+const (
+	// chatPingInterval is how often the server sends a ping, which clients answer with a pong
+	chatPingInterval = 25 * time.Second
+	// chatReadTimeout is how long a connection may go without sending a frame before it is closed
+	chatReadTimeout  = 60 * time.Second
+	chatWriteTimeout = 10 * time.Second
+	// chatMaxFrameBytes leaves room for a message of maxMessageLength bytes, escaped, in a frame
+	chatMaxFrameBytes = 8 * maxMessageLength
+	// chatTypingInterval is the least time between the typing indicators a connection publishes
+	chatTypingInterval = time.Second
+)
+
+var errChatOrigin = errors.New("origin not allowed")
+
+// chatIncomingFrame is a frame sent by a client. UserID is the other user in the conversation.
+type chatIncomingFrame struct {
+	Type          string `json:"type"`
+	ClientID      string `json:"client_id"` // echoed back in the reply, so that clients can match them up
+	UserID        int32  `json:"user_id"`
+	Content       string `json:"content"`
+	Typing        bool   `json:"typing"`
+	UpToMessageID *int32 `json:"up_to_message_id"` // optional for read frames, marks every message when omitted
+}
+
+// chatOutgoingFrame is a frame sent to a client. UserID is the user who typed, received or read.
+type chatOutgoingFrame struct {
+	Type           string             `json:"type"`
+	ClientID       string             `json:"client_id,omitempty"`
+	PrivateMessage *db.PrivateMessage `json:"private_message,omitempty"`
+	UserID         int32              `json:"user_id,omitempty"`
+	Typing         *bool              `json:"typing,omitempty"`
+	MessageIDs     []int32            `json:"message_ids,omitempty"`
+	Error          string             `json:"error,omitempty"`
+}
+
+// publishChatFrame sends a frame to the connections of userIDs on every server instance. Typing indicators and
+// receipts are best-effort, so errors are logged.
+func (h *Handler) publishChatFrame(ctx context.Context, userIDs []int32, frame chatOutgoingFrame) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		h.Log.Errorf("Unable to encode chat frame: %v\n", err)
+		return
+	}
+	if err := h.Stream.Publish(ctx, stream.Event{Type: frame.Type, UserIDs: userIDs, Data: data}); err != nil {
+		h.Log.Errorf("Unable to publish chat frame: %v\n", err)
+	}
+}
+
+// publishReadReceipt tells the sender of messages that the reader has read them, and the reader's other connections
+// that they no longer need to show them as unread
+func (h *Handler) publishReadReceipt(ctx context.Context, readerID, senderID int32, messageIDs []int32) {
+	if len(messageIDs) == 0 {
+		return
+	}
+	h.publishChatFrame(ctx, []int32{senderID, readerID}, chatOutgoingFrame{Type: chatRead, UserID: readerID, MessageIDs: messageIDs})
+}
+
+// chatConn is a logged in user's WebSocket connection. Only the goroutine running serve writes to it.
+type chatConn struct {
+	h      *Handler
+	ws     *websocket.Conn
+	userID int32
+	// sentIDs are the messages sent on this connection, which were already returned in sent frames
+	sentIDs map[int32]bool
+	// partnerIDs are the users known to share a conversation with the connection's user
+	partnerIDs map[int32]bool
+	// lastTyping is when the latest typing indicator was published, and typing is whether it was a start
+	lastTyping time.Time
+	typing     bool
+}
+
+func (conn *chatConn) send(frame chatOutgoingFrame) error {
+	conn.ws.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+	return websocket.JSON.Send(conn.ws, frame)
+}
+
+// read receives the client's frames until the connection fails or closes, which cancels ctx
+func (conn *chatConn) read(ctx context.Context, cancel context.CancelFunc, frames chan<- []byte) {
+	defer cancel()
+	for {
+		conn.ws.SetReadDeadline(time.Now().Add(chatReadTimeout))
+		var frame []byte
+		if err := websocket.Message.Receive(conn.ws, &frame); err != nil {
+			return
+		}
+		select {
+		case frames <- frame:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// serve exchanges frames with the client until either side closes the connection
+func (conn *chatConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub := conn.h.Stream.Subscribe(conn.userID)
+	defer conn.h.Stream.Unsubscribe(sub)
+
+	frames := make(chan []byte)
+	go conn.read(ctx, cancel, frames)
+
+	ping := time.NewTicker(chatPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Lagged:
+			// the client reconnects and fetches the messages it missed from the REST API
+			conn.send(chatOutgoingFrame{Type: chatError, Error: "Connection fell behind, reconnect to continue"})
+			return
+		case <-sub.Revoked:
+			conn.send(chatOutgoingFrame{Type: chatError, Error: "Your account has been suspended or banned"})
+			return
+		case <-ping.C:
+			err = conn.send(chatOutgoingFrame{Type: chatPing})
+		case frame := <-frames:
+			err = conn.handleFrame(ctx, frame)
+		case event := <-sub.Events:
+			err = conn.handleEvent(ctx, event)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				conn.h.Log.Errorf("Unable to serve chat: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// handleFrame acts on a frame from the client. Only errors writing to the connection are returned, others are sent
+// to the client.
+func (conn *chatConn) handleFrame(ctx context.Context, data []byte) error {
+	var frame chatIncomingFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return conn.send(chatOutgoingFrame{Type: chatError, Error: "Invalid frame"})
+	}
+
+	switch frame.Type {
+	case chatPing:
+		return conn.send(chatOutgoingFrame{Type: chatPong, ClientID: frame.ClientID})
+	case chatPong:
+		return nil
+	case chatMessage:
+		message, err := conn.h.sendPrivateMessage(ctx, conn.userID, frame.UserID, frame.Content)
+		if _, ok := messageErrorStatus(err); ok {
+			return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: err.Error()})
+		}
+		if err != nil {
+			conn.h.Log.Errorf("Unable to send message: %v\n", err)
+			return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: "Failed to send message"})
+		}
+		conn.sentIDs[message.MessageID] = true
+		conn.partnerIDs[frame.UserID] = true
+		return conn.send(chatOutgoingFrame{Type: chatSent, ClientID: frame.ClientID, PrivateMessage: &message})
+	case chatTyping:
+		return conn.handleTyping(ctx, frame)
+	case chatRead:
+		params := db.MarkConversationAsReadParams{UserID: conn.userID, PartnerUserID: frame.UserID}
+		if frame.UpToMessageID != nil {
+			params.UpToMessageID = pgtype.Int4{Int32: *frame.UpToMessageID, Valid: true}
+		}
+		messageIDs, err := conn.h.Queries.MarkConversationAsRead(ctx, params)
+		if err != nil {
+			conn.h.Log.Errorf("Unable to mark messages as read: %v\n", err)
+			return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: "Failed to mark messages as read"})
+		}
+		conn.h.publishReadReceipt(ctx, conn.userID, frame.UserID, messageIDs)
+		return nil
+	default:
+		return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: "Unknown frame type"})
+	}
+}
+
+// handleTyping publishes a typing indicator to a user who shares a conversation with the connection's user. Indicators
+// arriving within chatTypingInterval of the last one are dropped, unless they stop one that was started, which would
+// otherwise be left showing.
+func (conn *chatConn) handleTyping(ctx context.Context, frame chatIncomingFrame) error {
+	if frame.UserID == conn.userID {
+		return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: errMessageToSelf.Error()})
+	}
+	if !conn.partnerIDs[frame.UserID] {
+		ok, err := conn.h.Queries.HasConversation(ctx, db.HasConversationParams{UserID: conn.userID, PartnerUserID: frame.UserID})
+		if err != nil {
+			conn.h.Log.Errorf("Unable to check conversation: %v\n", err)
+			return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: "Failed to send typing indicator"})
+		}
+		if !ok {
+			return conn.send(chatOutgoingFrame{Type: chatError, ClientID: frame.ClientID, Error: "Conversation not found"})
+		}
+		conn.partnerIDs[frame.UserID] = true
+	}
+
+	now := time.Now()
+	if now.Sub(conn.lastTyping) < chatTypingInterval && !(conn.typing && !frame.Typing) {
+		return nil
+	}
+	conn.lastTyping, conn.typing = now, frame.Typing
+	typing := frame.Typing
+	conn.h.publishChatFrame(ctx, []int32{frame.UserID}, chatOutgoingFrame{Type: chatTyping, UserID: conn.userID, Typing: &typing})
+	return nil
+}
+
+// handleEvent sends an event from the stream hub to the client. New messages the user receives are acknowledged to
+// their sender with a delivery receipt, by whichever of the user's connections gets them first.
+func (conn *chatConn) handleEvent(ctx context.Context, event stream.Event) error {
+	switch event.Type {
+	case stream.EventMessage:
+		if conn.sentIDs[event.ID] {
+			delete(conn.sentIDs, event.ID)
+			return nil
+		}
+		message, err := conn.h.Queries.GetPrivateMessageById(ctx, event.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			conn.h.Log.Errorf("Unable to fetch message: %v\n", err)
+			return nil
+		}
+		if message.SenderUserID.Int32 != conn.userID && message.ReceiverUserID.Int32 != conn.userID {
+			return nil
+		}
+		if err := conn.send(chatOutgoingFrame{Type: chatMessage, PrivateMessage: &message}); err != nil {
+			return err
+		}
+		if message.ReceiverUserID.Int32 != conn.userID || message.IsRead.Bool {
+			return nil
+		}
+		delivered, err := conn.h.Queries.MarkPrivateMessageDelivered(ctx, message.MessageID)
+		if err != nil {
+			conn.h.Log.Errorf("Unable to mark message as delivered: %v\n", err)
+			return nil
+		}
+		if delivered > 0 {
+			conn.h.publishChatFrame(ctx, []int32{message.SenderUserID.Int32}, chatOutgoingFrame{
+				Type:       chatDelivered,
+				UserID:     conn.userID,
+				MessageIDs: []int32{message.MessageID},
+			})
+		}
+		return nil
+	case chatTyping, chatDelivered, chatRead:
+		var frame chatOutgoingFrame
+		if err := json.Unmarshal(event.Data, &frame); err != nil {
+			conn.h.Log.Errorf("Invalid chat frame: %v\n", err)
+			return nil
+		}
+		return conn.send(frame)
+	default:
+		return nil
+	}
+}
+
+// ChatHandler handles GET requests to open a WebSocket for the logged in user's private conversations. Messages sent
+// on it are saved like those sent to SendMessageHandler, and new messages, typing indicators, delivery receipts and
+// read receipts are pushed to it as they happen.
+func (h *Handler) ChatHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, req *http.Request) error {
+			// browsers always send the origin of the page opening a WebSocket, and other clients may leave it out
+			if origin := req.Header.Get("Origin"); origin != "" && !slices.Contains(h.AllowedOrigins, origin) {
+				return errChatOrigin
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = chatMaxFrameBytes
+			conn := &chatConn{h: h, ws: ws, userID: userID, sentIDs: make(map[int32]bool), partnerIDs: make(map[int32]bool)}
+			conn.serve(context.Background())
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
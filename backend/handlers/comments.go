@@ -2,20 +2,28 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"server/db"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// maxCommentDepth is how deeply replies can be nested below a top level comment
+const maxCommentDepth = 5
+
 type CreateCommentApiParams struct {
-	Content string
-	PostID  int
+	Content         string
+	PostID          int
+	ParentCommentID *int // the comment being replied to, nil for a top level comment
 }
 
-// CreateCommentHandler handles POST requests to create a new comment
+// CreateCommentHandler handles POST requests to create a new comment, optionally as a reply to another comment.
+// Only moderators can comment on locked posts. The author of the post, or of the comment replied to, is notified.
 func (h *Handler) CreateCommentHandler(c *gin.Context) {
 	var req CreateCommentApiParams
 
@@ -36,15 +44,70 @@ func (h *Handler) CreateCommentHandler(c *gin.Context) {
 		return
 	}
 
-	comment, err := h.Queries.CreateComment(context.Background(), db.CreateCommentParams{
-		Content: req.Content,
-		PostID:  pgtype.Int4{Int32: int32(req.PostID), Valid: true},
-		UserID:  pgtype.Int4{Int32: userID, Valid: true},
-	})
+	post, err := h.Queries.GetPost(context.Background(), int32(req.PostID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	// moderators can still comment on locked posts, for example to explain why they were locked
+	if post.IsLocked.Bool && !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Post is locked"})
+		return
+	}
+
+	params := db.CreateCommentParams{
+		Content:     req.Content,
+		ContentHtml: renderContent(req.Content),
+		PostID:      pgtype.Int4{Int32: int32(req.PostID), Valid: true},
+		UserID:      pgtype.Int4{Int32: userID, Valid: true},
+	}
+	var parent *db.Comment
+	if req.ParentCommentID != nil {
+		comment, err := h.Queries.GetComment(context.Background(), int32(*req.ParentCommentID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		if err != nil {
+			h.Log.Errorf("Unable to fetch parent comment: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+			return
+		}
+		parent = &comment
+		if parent.PostID != params.PostID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment belongs to another post"})
+			return
+		}
+		if parent.IsDeleted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reply to a deleted comment"})
+			return
+		}
+		if parent.Depth >= maxCommentDepth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Replies cannot be nested more than %d levels deep", maxCommentDepth)})
+			return
+		}
+		params.ParentCommentID = pgtype.Int4{Int32: parent.CommentID, Valid: true}
+		params.RootCommentID = parent.RootCommentID
+		if !parent.RootCommentID.Valid {
+			params.RootCommentID = pgtype.Int4{Int32: parent.CommentID, Valid: true}
+		}
+		params.Depth = parent.Depth + 1
+	}
+
+	comment, err := h.Queries.CreateComment(context.Background(), params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
+	if err := h.Queries.TouchPostActivity(context.Background(), int32(req.PostID)); err != nil {
+		h.Log.Errorf("Unable to update post activity: %v\n", err)
+	}
+	h.notifyComment(context.Background(), post, parent, comment)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment created successfully", "comment": comment})
 }
@@ -67,7 +130,35 @@ func (h *Handler) GetCommentHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, comment)
 }
 
-// GetCommentsByPostHandler handles GET requests to get all comments for a specific post
+// CommentNode is a comment with its replies, oldest first
+type CommentNode struct {
+	db.GetCommentsByPostRow
+	Replies []*CommentNode
+}
+
+// buildCommentTree nests replies under the top level comments they belong to.
+// Replies must be ordered so that every comment comes after its parent.
+func buildCommentTree(roots []db.GetCommentsByPostRow, replies []db.GetCommentsByRootIDsRow) []*CommentNode {
+	nodes := make(map[int32]*CommentNode, len(roots)+len(replies))
+	tree := make([]*CommentNode, 0, len(roots))
+	for _, root := range roots {
+		node := &CommentNode{GetCommentsByPostRow: root, Replies: []*CommentNode{}}
+		nodes[root.CommentID] = node
+		tree = append(tree, node)
+	}
+	for _, reply := range replies {
+		node := &CommentNode{GetCommentsByPostRow: db.GetCommentsByPostRow(reply), Replies: []*CommentNode{}}
+		nodes[reply.CommentID] = node
+		if parent, ok := nodes[reply.ParentCommentID.Int32]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	return tree
+}
+
+// GetCommentsByPostHandler handles GET requests to get a page of comments for a specific post, newest first.
+// With view=flat (the default) every comment is listed with its parent ID and reply count.
+// With view=tree only top level comments are paginated, each with its nested replies.
 func (h *Handler) GetCommentsByPostHandler(c *gin.Context) {
 	postIDStr := c.Param("postID")
 	postID, err := strconv.Atoi(postIDStr)
@@ -75,17 +166,62 @@ func (h *Handler) GetCommentsByPostHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
 		return
 	}
-
-	comments, err := h.Queries.GetCommentsByPost(context.Background(), pgtype.Int4{Int32: int32(postID), Valid: true})
+	page, err := parsePageParams(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, comments)
+	switch c.DefaultQuery("view", "flat") {
+	case "flat":
+		comments, err := h.Queries.GetCommentsByPost(context.Background(), db.GetCommentsByPostParams{
+			PostID:     pgtype.Int4{Int32: int32(postID), Valid: true},
+			CursorDate: page.cursorTime(),
+			CursorID:   page.cursorID(),
+			PageLimit:  page.queryLimit(),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, pageResponse(page, comments, func(comment db.GetCommentsByPostRow) pageCursor {
+			return pageCursor{Time: comment.CreationDate.Time, ID: comment.CommentID}
+		}))
+	case "tree":
+		rows, err := h.Queries.GetTopLevelCommentsByPost(context.Background(), db.GetTopLevelCommentsByPostParams{
+			PostID:     pgtype.Int4{Int32: int32(postID), Valid: true},
+			CursorDate: page.cursorTime(),
+			CursorID:   page.cursorID(),
+			PageLimit:  page.queryLimit(),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+			return
+		}
+		roots := make([]db.GetCommentsByPostRow, len(rows))
+		rootIDs := make([]int32, len(rows))
+		for i, row := range rows {
+			roots[i] = db.GetCommentsByPostRow(row)
+			rootIDs[i] = row.CommentID
+		}
+
+		// the extra row fetched to detect the next page is dropped by pageResponse, so its replies are not needed
+		replies, err := h.Queries.GetCommentsByRootIDs(context.Background(), rootIDs[:min(len(rootIDs), int(page.Limit))])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, pageResponse(page, buildCommentTree(roots, replies), func(node *CommentNode) pageCursor {
+			return pageCursor{Time: node.CreationDate.Time, ID: node.CommentID}
+		}))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view must be flat or tree"})
+	}
 }
 
-// GetCommentsByUserHandler handles GET requests to get all comments made by a specific user
+// GetCommentsByUserHandler handles GET requests to get a page of comments made by a specific user, newest first
 func (h *Handler) GetCommentsByUserHandler(c *gin.Context) {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.Atoi(userIDStr)
@@ -93,19 +229,38 @@ func (h *Handler) GetCommentsByUserHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	comments, err := h.Queries.GetCommentsByUser(context.Background(), pgtype.Int4{Int32: int32(userID), Valid: true})
+	comments, err := h.Queries.GetCommentsByUser(context.Background(), db.GetCommentsByUserParams{
+		UserID:     pgtype.Int4{Int32: int32(userID), Valid: true},
+		CursorDate: page.cursorTime(),
+		CursorID:   page.cursorID(),
+		PageLimit:  page.queryLimit(),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
 		return
 	}
 
-	c.JSON(http.StatusOK, comments)
+	c.JSON(http.StatusOK, pageResponse(page, comments, func(comment db.Comment) pageCursor {
+		return pageCursor{Time: comment.CreationDate.Time, ID: comment.CommentID}
+	}))
 }
 
-// UpdateCommentHandler handles PUT requests to update a comment's content
+type UpdateCommentApiParams struct {
+	CommentID int32
+	Content   string
+	Reason    string // required when a moderator edits another user's comment
+}
+
+// UpdateCommentHandler handles PUT requests to update a comment's content.
+// Edits by moderators to other users' comments are logged.
 func (h *Handler) UpdateCommentHandler(c *gin.Context) {
-	var req db.UpdateCommentParams
+	var req UpdateCommentApiParams
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -124,20 +279,82 @@ func (h *Handler) UpdateCommentHandler(c *gin.Context) {
 		return
 	}
 
-	comment, err := h.Queries.UpdateCommentByCommentIdAndUserId(context.Background(), db.UpdateCommentByCommentIdAndUserIdParams{
-		Content:   req.Content,
-		CommentID: req.CommentID,
-		UserID:    pgtype.Int4{Int32: userID, Valid: true},
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	existing, err := qtx.GetCommentForUpdate(ctx, req.CommentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch comment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if existing.IsDeleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot edit a deleted comment"})
+		return
+	}
+	moderated, ok := authorizeContentChange(c, existing.UserID, req.Reason)
+	if !ok {
+		return
+	}
+	locked, err := isPostLocked(ctx, qtx, existing.PostID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if locked && !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Post is locked"})
+		return
+	}
+
+	comment, err := qtx.UpdateComment(ctx, db.UpdateCommentParams{
+		CommentID:   existing.CommentID,
+		Content:     req.Content,
+		ContentHtml: renderContent(req.Content),
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
 	}
+	if moderated {
+		err := qtx.CreateLog(ctx, db.CreateLogParams{
+			Action:          logActionEditComment,
+			ModeratorUserID: pgtype.Int4{Int32: userID, Valid: true},
+			AffectedUserID:  existing.UserID,
+			PostID:          existing.PostID,
+			CommentID:       pgtype.Int4{Int32: existing.CommentID, Valid: true},
+			Reason:          pgtype.Text{String: req.Reason, Valid: true},
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to create moderation log: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully", "comment": comment})
 }
 
-// DeleteCommentHandler handles DELETE requests to delete a comment by its ID
+// DeleteCommentHandler handles DELETE requests to delete a comment by its ID.
+// A comment with replies is kept as a placeholder so that its thread stays intact.
+// Moderators deleting another user's comment must give a reason query parameter, which is logged.
 func (h *Handler) DeleteCommentHandler(c *gin.Context) {
 	commentIDStr := c.Param("commentID")
 	commentID, err := strconv.Atoi(commentIDStr)
@@ -158,14 +375,105 @@ func (h *Handler) DeleteCommentHandler(c *gin.Context) {
 		return
 	}
 
-	err = h.Queries.DeleteCommentByCommentIdAndUserId(context.Background(), db.DeleteCommentByCommentIdAndUserIdParams{
-		CommentID: int32(commentID),
-		UserID:    pgtype.Int4{Int32: userID, Valid: true},
-	})
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	comment, err := qtx.GetCommentForUpdate(ctx, int32(commentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
 	if err != nil {
+		h.Log.Errorf("Unable to fetch comment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	if comment.IsDeleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	reason := c.Query("reason")
+	moderated, ok := authorizeContentChange(c, comment.UserID, reason)
+	if !ok {
+		return
+	}
+
+	if err := deleteComment(ctx, qtx, comment); err != nil {
+		h.Log.Errorf("Unable to delete comment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	if moderated {
+		err := qtx.CreateLog(ctx, db.CreateLogParams{
+			Action:          logActionDeleteComment,
+			ModeratorUserID: pgtype.Int4{Int32: userID, Valid: true},
+			AffectedUserID:  comment.UserID,
+			PostID:          comment.PostID,
+			CommentID:       pgtype.Int4{Int32: comment.CommentID, Valid: true},
+			Reason:          pgtype.Text{String: reason, Valid: true},
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to create moderation log: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
+
+// isPostLocked reports whether the post a comment is on has been locked
+func isPostLocked(ctx context.Context, q *db.Queries, postID pgtype.Int4) (bool, error) {
+	if !postID.Valid {
+		return false, nil
+	}
+	post, err := q.GetPost(ctx, postID.Int32)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return post.IsLocked.Bool, nil
+}
+
+// deleteComment replaces a comment with a placeholder if it has replies and deletes it otherwise.
+// Placeholders left without replies by the deletion are deleted as well.
+func deleteComment(ctx context.Context, q *db.Queries, comment db.Comment) error {
+	for {
+		replies, err := q.CountCommentReplies(ctx, pgtype.Int4{Int32: comment.CommentID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if replies > 0 {
+			return q.SoftDeleteComment(ctx, comment.CommentID)
+		}
+		if err := q.DeleteComment(ctx, comment.CommentID); err != nil {
+			return err
+		}
+
+		if !comment.ParentCommentID.Valid {
+			return nil
+		}
+		comment, err = q.GetCommentForUpdate(ctx, comment.ParentCommentID.Int32)
+		if err != nil {
+			return err
+		}
+		if !comment.IsDeleted {
+			return nil
+		}
+	}
+}
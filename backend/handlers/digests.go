@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"server/db"
+	"server/mail"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// siteName is how emails refer to the site
+const siteName = "the forum"
+
+// Digest frequencies. Users who have not chosen one get weekly digests.
+const (
+	digestOff    = "off"
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// digestPeriods are how long each digest frequency waits between digests, and how far ahead digests list events
+var digestPeriods = map[string]time.Duration{
+	digestDaily:  24 * time.Hour,
+	digestWeekly: 7 * 24 * time.Hour,
+}
+
+const (
+	digestNotificationLimit = 10
+	digestPostLimit         = 20
+)
+
+// digestEmail is the data of the digest templates in the mail package
+type digestEmail struct {
+	SiteName       string
+	SiteURL        string
+	UnsubscribeURL string
+	Username       string
+	Frequency      string
+	UnreadCount    int64
+	Notifications  []db.GetNotificationsByUserIdRow
+	Posts          []digestPost
+	Events         []digestEvent
+}
+
+type digestPost struct {
+	db.GetFollowedCategoryPostsSinceRow
+	URL string
+}
+
+type digestEvent struct {
+	Title        string
+	Start        time.Time
+	MeetingPoint string
+}
+
+// unsubscribeURL returns the link that stops a user's digests without logging in
+func (h *Handler) unsubscribeURL(token pgtype.UUID) string {
+	return h.SiteURL + "/api/unsubscribe/" + uuid.UUID(token.Bytes).String()
+}
+
+// SendEmailDigests emails a digest to every user whose daily or weekly digest is due. Each digest is claimed and
+// committed before it is sent, so that restarts and other server instances running the job never send it twice
+// and no transaction is held open while the mail server is slow. A failed delivery releases the claim, so that the
+// digest is retried on the next run.
+func (h *Handler) SendEmailDigests(ctx context.Context, now time.Time) error {
+	users, err := h.Queries.GetUsersDueForDigest(ctx, db.GetUsersDueForDigestParams{
+		DailyBefore:  pgtype.Timestamptz{Time: now.Add(-digestPeriods[digestDaily]), Valid: true},
+		WeeklyBefore: pgtype.Timestamptz{Time: now.Add(-digestPeriods[digestWeekly]), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	// one undeliverable address should not hold back everyone else's digest
+	for _, user := range users {
+		if err := h.sendEmailDigest(ctx, user, now); err != nil {
+			h.Log.Errorf("Unable to send digest to user %d: %v\n", user.UserID, err)
+		}
+	}
+	return nil
+}
+
+// sendEmailDigest claims and sends a single digest, releasing the claim if it cannot be sent
+func (h *Handler) sendEmailDigest(ctx context.Context, user db.GetUsersDueForDigestRow, now time.Time) error {
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	preferences, err := h.Queries.ClaimDigest(ctx, db.ClaimDigestParams{
+		UserID:             user.UserID,
+		UnsubscribeToken:   pgtype.UUID{Bytes: token, Valid: true},
+		Now:                pgtype.Timestamptz{Time: now, Valid: true},
+		PreviousDigestDate: user.LastDigestDate,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := h.deliverEmailDigest(ctx, user, preferences, now); err != nil {
+		releaseErr := h.Queries.ReleaseDigest(ctx, db.ReleaseDigestParams{
+			PreviousDigestDate: user.LastDigestDate,
+			UserID:             user.UserID,
+			ClaimedDigestDate:  pgtype.Timestamptz{Time: now, Valid: true},
+		})
+		if releaseErr != nil {
+			h.Log.Errorf("Unable to release digest of user %d: %v\n", user.UserID, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// deliverEmailDigest builds and sends a claimed digest. Digests with nothing to report are not sent.
+func (h *Handler) deliverEmailDigest(ctx context.Context, user db.GetUsersDueForDigestRow, preferences db.EmailPreference, now time.Time) error {
+	period := digestPeriods[user.DigestFrequency]
+	since := now.Add(-period)
+	if user.LastDigestDate.Valid {
+		since = user.LastDigestDate.Time
+	}
+
+	digest := digestEmail{
+		SiteName:       siteName,
+		SiteURL:        h.SiteURL,
+		UnsubscribeURL: h.unsubscribeURL(preferences.UnsubscribeToken),
+		Username:       user.Username,
+		Frequency:      user.DigestFrequency,
+	}
+
+	var err error
+	digest.UnreadCount, err = h.Queries.CountUnreadNotificationsByUserId(ctx, pgtype.Int4{Int32: user.UserID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if digest.UnreadCount > 0 {
+		digest.Notifications, err = h.Queries.GetNotificationsByUserId(ctx, db.GetNotificationsByUserIdParams{
+			UserID:     user.UserID,
+			UnreadOnly: true,
+			PageLimit:  digestNotificationLimit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	posts, err := h.Queries.GetFollowedCategoryPostsSince(ctx, db.GetFollowedCategoryPostsSinceParams{
+		UserID:    user.UserID,
+		Since:     pgtype.Timestamptz{Time: since, Valid: true},
+		PageLimit: digestPostLimit,
+	})
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		digest.Posts = append(digest.Posts, digestPost{post, fmt.Sprintf("%s/posts/%d", h.SiteURL, post.PostID)})
+	}
+
+	occurrences, err := h.goingOccurrences(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+	for _, occurrence := range occurrences {
+		start := occurrence.EventDate.Time
+		if occurrence.IsCancelled || start.Before(now) || start.After(now.Add(period)) {
+			continue
+		}
+		digest.Events = append(digest.Events, digestEvent{Title: occurrence.Title, Start: start, MeetingPoint: occurrence.MeetingPoint})
+	}
+
+	if digest.UnreadCount == 0 && len(digest.Posts) == 0 && len(digest.Events) == 0 {
+		return nil
+	}
+	msg, err := mail.Render("digest", digest)
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+	msg.Subject = fmt.Sprintf("Your %s digest", user.DigestFrequency)
+	msg.Headers = map[string]string{
+		"List-Unsubscribe":      "<" + digest.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return h.Mailer.Send(ctx, msg)
+}
+
+// getEmailPreferences returns a user's email preferences, creating the defaults on first use
+func (h *Handler) getEmailPreferences(ctx context.Context, userID int32) (db.EmailPreference, error) {
+	preferences, err := h.Queries.GetEmailPreferencesByUserID(ctx, userID)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return preferences, err
+	}
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return db.EmailPreference{}, err
+	}
+	return h.Queries.CreateEmailPreferences(ctx, db.CreateEmailPreferencesParams{
+		UserID:           userID,
+		UnsubscribeToken: pgtype.UUID{Bytes: token, Valid: true},
+	})
+}
+
+// emailPreferencesResponse leaves out the unsubscribe token, which is only sent by email
+func emailPreferencesResponse(preferences db.EmailPreference) gin.H {
+	return gin.H{"digest_frequency": preferences.DigestFrequency, "last_digest_date": preferences.LastDigestDate}
+}
+
+// GetEmailPreferencesHandler handles GET requests to get the logged in user's email preferences
+func (h *Handler) GetEmailPreferencesHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	preferences, err := h.getEmailPreferences(context.Background(), userID)
+	if err != nil {
+		h.Log.Errorf("Unable to get email preferences: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get email preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, emailPreferencesResponse(preferences))
+}
+
+type UpdateEmailPreferencesApiParams struct {
+	DigestFrequency string // off, daily or weekly
+}
+
+// UpdateEmailPreferencesHandler handles PUT requests to change how often the logged in user gets digest emails
+func (h *Handler) UpdateEmailPreferencesHandler(c *gin.Context) {
+	var req UpdateEmailPreferencesApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DigestFrequency != digestOff && req.DigestFrequency != digestDaily && req.DigestFrequency != digestWeekly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Digest frequency must be off, daily or weekly"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	if _, err := h.getEmailPreferences(ctx, userID); err != nil {
+		h.Log.Errorf("Unable to get email preferences: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email preferences"})
+		return
+	}
+	preferences, err := h.Queries.UpdateDigestFrequency(ctx, db.UpdateDigestFrequencyParams{
+		UserID:          userID,
+		DigestFrequency: req.DigestFrequency,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to update email preferences: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email preferences"})
+		return
+	}
+
+	response := emailPreferencesResponse(preferences)
+	response["message"] = "Email preferences updated successfully"
+	c.JSON(http.StatusOK, response)
+}
+
+// unsubscribePage is shown by unsubscribe links. Opening one changes nothing, so that link scanners and prefetching do
+// not unsubscribe users, and the form on the page posts to UnsubscribeHandler.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Unsubscribe from {{.SiteName}}</title>
+</head>
+<body>
+{{if .Unsubscribed}}<p>You will no longer get digest emails from {{.SiteName}}.</p>
+{{else}}<p>Stop getting digest emails from {{.SiteName}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+type unsubscribePageData struct {
+	SiteName     string
+	Unsubscribed bool
+}
+
+// renderUnsubscribePage writes the unsubscribe confirmation page, or the page saying it is done
+func (h *Handler) renderUnsubscribePage(c *gin.Context, unsubscribed bool) {
+	var page bytes.Buffer
+	if err := unsubscribePage.Execute(&page, unsubscribePageData{SiteName: siteName, Unsubscribed: unsubscribed}); err != nil {
+		h.Log.Errorf("Unable to render unsubscribe page: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// UnsubscribePageHandler handles GET requests to the unsubscribe links in emails, which ask the user to confirm
+// before their digests are turned off
+func (h *Handler) UnsubscribePageHandler(c *gin.Context) {
+	token, err := uuid.Parse(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe link not found"})
+		return
+	}
+
+	_, err = h.Queries.GetEmailPreferencesByToken(context.Background(), pgtype.UUID{Bytes: token, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe link not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch email preferences: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+
+	h.renderUnsubscribePage(c, false)
+}
+
+// UnsubscribeHandler handles POST requests to the unsubscribe links in emails, which turn off digests for the user
+// the token belongs to. They are sent by the form on the unsubscribe page, which gets a page back, and by mail
+// clients doing the one-click unsubscribe of RFC 8058.
+func (h *Handler) UnsubscribeHandler(c *gin.Context) {
+	token, err := uuid.Parse(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe link not found"})
+		return
+	}
+
+	updated, err := h.Queries.UnsubscribeFromDigests(context.Background(), pgtype.UUID{Bytes: token, Valid: true})
+	if err != nil {
+		h.Log.Errorf("Unable to unsubscribe: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe link not found"})
+		return
+	}
+
+	if c.NegotiateFormat(binding.MIMEJSON, binding.MIMEHTML) == binding.MIMEHTML {
+		h.renderUnsubscribePage(c, true)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from digest emails successfully"})
+}
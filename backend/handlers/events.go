@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// defaultEventRange is how far ahead event lists look when no end date is given, and the longest range they may ask for
+const defaultEventRange = 365 * 24 * time.Hour
+
+type CreateEventApiParams struct {
+	Title          string
+	Description    string
+	EventDate      time.Time
+	MeetingPoint   string
+	RouteID        *int32
+	Capacity       *int32
+	RecurrenceRule string
+	TimeZone       string // IANA time zone of recurring events, such as Asia/Singapore, defaulting to UTC
+}
+
+type UpdateEventApiParams struct {
+	EventID        int
+	Title          string
+	Description    string
+	EventDate      time.Time
+	MeetingPoint   string
+	RouteID        *int32
+	Capacity       *int32
+	RecurrenceRule string
+	TimeZone       string
+}
+
+// parseTimeQuery parses a query parameter given either as RFC 3339 or as a YYYY-MM-DD date
+func parseTimeQuery(c *gin.Context, key string, fallback time.Time) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s, expected RFC 3339 or YYYY-MM-DD", key)
+}
+
+// parseEventRange parses the from and to query parameters of event lists, defaulting to now and one year ahead
+func parseEventRange(c *gin.Context) (time.Time, time.Time, error) {
+	from, err := parseTimeQuery(c, "from", time.Now())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseTimeQuery(c, "to", from.Add(defaultEventRange))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	if to.Sub(from) > defaultEventRange {
+		return time.Time{}, time.Time{}, errors.New("from and to must be at most a year apart")
+	}
+	return from, to, nil
+}
+
+// parseOptionalIDQuery parses an optional integer ID query parameter
+func parseOptionalIDQuery(c *gin.Context, key string) (pgtype.Int4, error) {
+	value := c.Query(key)
+	if value == "" {
+		return pgtype.Int4{}, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return pgtype.Int4{}, fmt.Errorf("invalid %s", key)
+	}
+	return pgtype.Int4{Int32: int32(id), Valid: true}, nil
+}
+
+// validateEventInput checks the fields shared by event creation and update
+func (h *Handler) validateEventInput(title, meetingPoint string, eventDate time.Time, routeID, capacity *int32) error {
+	if title == "" || meetingPoint == "" {
+		return errors.New("title and meeting point are required")
+	}
+	if eventDate.IsZero() {
+		return errors.New("event date is required")
+	}
+	if capacity != nil && *capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	if routeID != nil {
+		if _, err := h.Queries.GetRouteByID(context.Background(), *routeID); err != nil {
+			return errors.New("route does not exist")
+		}
+	}
+	return nil
+}
+
+// GetEventsHandler handles GET requests to list a page of event occurrences ordered by start time, with recurring events
+// expanded into their occurrences.
+// Optional filters: from and to (RFC 3339 or YYYY-MM-DD, defaulting to now and one year ahead, and at most a year
+// apart), route_id and creator_id.
+func (h *Handler) GetEventsHandler(c *gin.Context) {
+	from, to, err := parseEventRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	routeID, err := parseOptionalIDQuery(c, "route_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	creatorID, err := parseOptionalIDQuery(c, "creator_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := context.Background()
+	toTz := pgtype.Timestamptz{Time: to, Valid: true}
+	events, err := h.Queries.GetOneOffEventsPage(ctx, db.GetOneOffEventsPageParams{
+		FromDate:      pgtype.Timestamptz{Time: from, Valid: true},
+		ToDate:        toTz,
+		RouteID:       routeID,
+		CreatorUserID: creatorID,
+		CursorDate:    page.cursorTime(),
+		CursorID:      page.cursorID(),
+		PageLimit:     page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	// recurring events are fetched separately since their first occurrence may be before the range,
+	// and only expanded from the start of the page until each has given enough occurrences to fill it
+	series, err := h.Queries.GetRecurringEventsStartingBefore(ctx, toTz)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch recurring events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+	var matching []db.Event
+	for _, event := range series {
+		if (!routeID.Valid || event.RouteID == routeID) && (!creatorID.Valid || event.CreatorUserID == creatorID) {
+			matching = append(matching, event)
+		}
+	}
+	pageStart := from
+	if page.Cursor != nil && page.Cursor.Time.After(from) {
+		pageStart = page.Cursor.Time
+	}
+	// one more than the query limit, since an occurrence at the cursor itself may have been on the previous page
+	occurrences, err := h.expandEvents(ctx, append(events, matching...), pageStart, to, int(page.queryLimit())+1)
+	if err != nil {
+		h.Log.Errorf("Unable to expand events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, occurrencesAfter(occurrences, page.Cursor), func(o Occurrence) pageCursor {
+		return pageCursor{Time: o.EventDate.Time, ID: o.EventID}
+	}))
+}
+
+// GetEventHandler handles GET requests to fetch a single event
+func (h *Handler) GetEventHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	event, err := h.Queries.GetEventByID(context.Background(), int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// CreateEventHandler handles POST requests to create a new event organised by the logged in user
+func (h *Handler) CreateEventHandler(c *gin.Context) {
+	var req CreateEventApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.validateEventInput(req.Title, req.MeetingPoint, req.EventDate, req.RouteID, req.Capacity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recurrenceRule, err := parseRecurrenceRule(req.RecurrenceRule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	timeZone, err := parseTimeZone(req.TimeZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	event, err := h.Queries.CreateEvent(context.Background(), db.CreateEventParams{
+		Title:          req.Title,
+		Description:    pgtype.Text{String: req.Description, Valid: true},
+		EventDate:      pgtype.Timestamptz{Time: req.EventDate, Valid: true},
+		MeetingPoint:   req.MeetingPoint,
+		RouteID:        int4FromPtr(req.RouteID),
+		CreatorUserID:  pgtype.Int4{Int32: userID, Valid: true},
+		Capacity:       int4FromPtr(req.Capacity),
+		RecurrenceRule: recurrenceRule,
+		TimeZone:       timeZone,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event created successfully", "event": event})
+}
+
+// UpdateEventHandler handles PUT requests to update an event organised by the logged in user
+func (h *Handler) UpdateEventHandler(c *gin.Context) {
+	var req UpdateEventApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.validateEventInput(req.Title, req.MeetingPoint, req.EventDate, req.RouteID, req.Capacity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recurrenceRule, err := parseRecurrenceRule(req.RecurrenceRule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	timeZone, err := parseTimeZone(req.TimeZone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	// lock the event so RSVPs made during the update see the new capacity
+	_, err = qtx.GetEventByIDForUpdate(ctx, int32(req.EventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found or not organised by you"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to lock event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	event, err := qtx.UpdateEventByEventIdAndUserId(ctx, db.UpdateEventByEventIdAndUserIdParams{
+		EventID:        int32(req.EventID),
+		CreatorUserID:  pgtype.Int4{Int32: userID, Valid: true},
+		Title:          req.Title,
+		Description:    pgtype.Text{String: req.Description, Valid: true},
+		EventDate:      pgtype.Timestamptz{Time: req.EventDate, Valid: true},
+		MeetingPoint:   req.MeetingPoint,
+		RouteID:        int4FromPtr(req.RouteID),
+		Capacity:       int4FromPtr(req.Capacity),
+		RecurrenceRule: recurrenceRule,
+		TimeZone:       timeZone,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found or not organised by you"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to update event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	// a raised or removed capacity frees spots on the waitlist of every occurrence
+	waitlisted, err := qtx.GetWaitlistedOccurrencesByEventID(ctx, pgtype.Int4{Int32: event.EventID, Valid: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	for _, occurrenceDate := range waitlisted {
+		var date *time.Time
+		if occurrenceDate.Valid {
+			date = &occurrenceDate.Time
+		}
+		occurrence, err := resolveOccurrence(ctx, qtx, event, date)
+		if errors.Is(err, errInvalidOccurrence) {
+			// the occurrence is no longer part of the series
+			continue
+		}
+		if err == nil {
+			err = promoteWaitlist(ctx, qtx, occurrence)
+		}
+		if err != nil {
+			h.Log.Errorf("Unable to promote waitlist: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	h.notifyEventChange(ctx, event, pgtype.Timestamptz{}, userID, fmt.Sprintf("%s has been updated by its organiser.", event.Title))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event updated successfully", "event": event})
+}
+
+// DeleteEventHandler handles DELETE requests to delete an event organised by the logged in user
+func (h *Handler) DeleteEventHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := context.Background()
+	// RSVPs are deleted with the event, so whom to notify is looked up first
+	event, err := h.Queries.GetEventByID(ctx, int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found or not organised by you"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+		return
+	}
+	userIDs, err := h.Queries.GetRSVPUserIDsByEventID(ctx, db.GetRSVPUserIDsByEventIDParams{
+		EventID: pgtype.Int4{Int32: event.EventID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch RSVPs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+		return
+	}
+
+	deleted, err := h.Queries.DeleteEventByEventIdAndUserId(ctx, db.DeleteEventByEventIdAndUserIdParams{
+		EventID:       int32(eventID),
+		CreatorUserID: pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to delete event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found or not organised by you"})
+		return
+	}
+
+	h.notifyUsers(ctx, userIDs, event.EventID, userID, fmt.Sprintf("%s has been cancelled by its organiser.", event.Title))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEventRange(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to time.Time
+		invalid  bool
+	}{
+		{query: "?from=2024-01-01", from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{query: "?from=2024-01-01&to=2024-01-31T18:00:00Z", from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2024, 1, 31, 18, 0, 0, 0, time.UTC)},
+		{query: "?from=2024-01-01&to=2024-12-31", from: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{query: "?from=2024-01-01&to=2025-01-01", invalid: true},
+		{query: "?from=2024-02-01&to=2024-01-01", invalid: true},
+		{query: "?from=yesterday", invalid: true},
+	}
+	for _, test := range tests {
+		from, to, err := parseEventRange(pageTestContext("/" + test.query))
+		if test.invalid {
+			if err == nil {
+				t.Errorf("parseEventRange(%q) = %v, %v, want an error", test.query, from, to)
+			}
+			continue
+		}
+		if err != nil || !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("parseEventRange(%q) = %v, %v, %v, want %v, %v", test.query, from, to, err, test.from, test.to)
+		}
+	}
+}
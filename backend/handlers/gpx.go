@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"server/db"
+	"server/gpx"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxGPXFileSize = 10 << 20 // 10 MiB
+
+// readGPXUpload parses the GPX file sent in the "file" field of a multipart form
+func readGPXUpload(c *gin.Context) (*gpx.Track, error) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("missing GPX file in form field \"file\"")
+	}
+	if fileHeader.Size > maxGPXFileSize {
+		return nil, errors.New("GPX file is too large")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	track, err := gpx.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GPX file: %v", err)
+	}
+	return track, nil
+}
+
+func formatCoordinates(p gpx.Point) string {
+	return fmt.Sprintf("%.5f, %.5f", p.Latitude, p.Longitude)
+}
+
+// saveRouteTrack replaces the stored track points of a route and updates the route's derived distance and elevation gain
+func saveRouteTrack(ctx context.Context, qtx *db.Queries, routeID int32, track *gpx.Track) error {
+	if err := qtx.DeleteRoutePointsByRouteID(ctx, routeID); err != nil {
+		return err
+	}
+
+	params := db.CreateRoutePointsParams{
+		RouteID:      routeID,
+		PointIndexes: make([]int32, len(track.Points)),
+		Latitudes:    make([]float64, len(track.Points)),
+		Longitudes:   make([]float64, len(track.Points)),
+		Elevations:   make([]float64, len(track.Points)),
+	}
+	for i, p := range track.Points {
+		params.PointIndexes[i] = int32(i)
+		params.Latitudes[i] = p.Latitude
+		params.Longitudes[i] = p.Longitude
+		params.Elevations[i] = math.NaN()
+		if p.Elevation != nil {
+			params.Elevations[i] = *p.Elevation
+		}
+	}
+	if err := qtx.CreateRoutePoints(ctx, params); err != nil {
+		return err
+	}
+
+	return qtx.UpdateRouteTrackStats(ctx, db.UpdateRouteTrackStatsParams{
+		RouteID:       routeID,
+		Distance:      pgtype.Float8{Float64: track.Distance(), Valid: true},
+		ElevationGain: pgtype.Int4{Int32: track.ElevationGain(), Valid: true},
+	})
+}
+
+// ImportRouteGPXHandler handles multipart POST requests to create a new route from an uploaded GPX file.
+// The optional form fields name, description, start_location, end_location and route_map_link override the GPX metadata.
+func (h *Handler) ImportRouteGPXHandler(c *gin.Context) {
+	track, err := readGPXUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	name := c.DefaultPostForm("name", track.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Route name is required when the GPX file has none"})
+		return
+	}
+	description := c.DefaultPostForm("description", track.Description)
+	startLocation := c.DefaultPostForm("start_location", formatCoordinates(track.Points[0]))
+	endLocation := c.DefaultPostForm("end_location", formatCoordinates(track.Points[len(track.Points)-1]))
+	routeMapLink := c.PostForm("route_map_link")
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create route"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	route, err := qtx.CreateRoute(ctx, db.CreateRouteParams{
+		Name:          name,
+		Description:   pgtype.Text{String: description, Valid: true},
+		StartLocation: startLocation,
+		EndLocation:   endLocation,
+		RouteMapLink:  pgtype.Text{String: routeMapLink, Valid: routeMapLink != ""},
+		UserID:        pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create route"})
+		return
+	}
+
+	if err := saveRouteTrack(ctx, qtx, route.RouteID, track); err != nil {
+		h.Log.Errorf("Unable to save route track: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save GPX track"})
+		return
+	}
+
+	route, err = qtx.GetRouteByID(ctx, route.RouteID)
+	if err != nil || tx.Commit(ctx) != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create route"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route created successfully", "route": route, "points": len(track.Points)})
+}
+
+// ReplaceRouteGPXHandler handles multipart PUT requests to replace the GPX track of a route owned by the logged in user
+func (h *Handler) ReplaceRouteGPXHandler(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID"})
+		return
+	}
+
+	track, err := readGPXUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	// lock the route so that concurrent edits see the new track
+	route, err := qtx.GetRouteByIDForUpdate(ctx, int32(routeID))
+	if err != nil || !route.UserID.Valid || route.UserID.Int32 != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found or not owned by you"})
+		return
+	}
+
+	if err := saveRouteTrack(ctx, qtx, route.RouteID, track); err != nil {
+		h.Log.Errorf("Unable to save route track: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save GPX track"})
+		return
+	}
+
+	route, err = qtx.GetRouteByID(ctx, route.RouteID)
+	if err != nil || tx.Commit(ctx) != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route updated successfully", "route": route, "points": len(track.Points)})
+}
+
+// ExportRouteGPXHandler handles GET requests to download a route as a GPX file
+func (h *Handler) ExportRouteGPXHandler(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID"})
+		return
+	}
+
+	route, err := h.Queries.GetRouteByID(context.Background(), int32(routeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get route"})
+		return
+	}
+
+	points, err := h.Queries.GetRoutePointsByRouteID(context.Background(), route.RouteID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch route points: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get route points"})
+		return
+	}
+
+	track := &gpx.Track{Name: route.Name, Description: route.Description.String}
+	for _, p := range points {
+		point := gpx.Point{Latitude: p.Latitude, Longitude: p.Longitude}
+		if p.Elevation.Valid {
+			elevation := p.Elevation.Float64
+			point.Elevation = &elevation
+		}
+		track.Points = append(track.Points, point)
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"route-%d.gpx\"", route.RouteID))
+	c.Header("Content-Type", "application/gpx+xml")
+	c.Status(http.StatusOK)
+	if err := gpx.Encode(c.Writer, track); err != nil {
+		h.Log.Errorf("Unable to encode GPX: %v\n", err)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"server/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// logExportBatchSize is the number of log entries fetched at a time when exporting to CSV
+const logExportBatchSize = 500
+
+var logCSVHeader = []string{
+	"log_id", "action", "action_date", "moderator_user_id", "moderator_username",
+	"affected_user_id", "affected_username", "post_id", "comment_id", "reason",
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern so that user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// parseLogFilters parses the filter query parameters of the moderation log.
+// reason matches entries whose reason contains it, and from and to bound the action date.
+func parseLogFilters(c *gin.Context) (db.GetAllLogsParams, error) {
+	var params db.GetAllLogsParams
+	var err error
+
+	if action := c.Query("action"); action != "" {
+		params.Action = pgtype.Text{String: action, Valid: true}
+	}
+	if reason := c.Query("reason"); reason != "" {
+		params.Reason = pgtype.Text{String: likeEscaper.Replace(reason), Valid: true}
+	}
+	if params.ModeratorUserID, err = parseOptionalIDQuery(c, "moderator_id"); err != nil {
+		return params, err
+	}
+	if params.AffectedUserID, err = parseOptionalIDQuery(c, "affected_user_id"); err != nil {
+		return params, err
+	}
+	if params.PostID, err = parseOptionalIDQuery(c, "post_id"); err != nil {
+		return params, err
+	}
+	if params.CommentID, err = parseOptionalIDQuery(c, "comment_id"); err != nil {
+		return params, err
+	}
+
+	from, err := parseTimeQuery(c, "from", time.Time{})
+	if err != nil {
+		return params, err
+	}
+	to, err := parseTimeQuery(c, "to", time.Time{})
+	if err != nil {
+		return params, err
+	}
+	params.FromDate = pgtype.Timestamptz{Time: from, Valid: !from.IsZero()}
+	params.ToDate = pgtype.Timestamptz{Time: to, Valid: !to.IsZero()}
+
+	return params, nil
+}
+
+// GetModerationLogsHandler handles GET requests from moderators to get a page of the moderation log, newest first.
+// Filters can be combined, and format=csv exports every matching entry instead of a page.
+func (h *Handler) GetModerationLogsHandler(c *gin.Context) {
+	params, err := parseLogFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		h.exportModerationLogs(c, params)
+		return
+	}
+
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params.CursorDate = page.cursorTime()
+	params.CursorID = page.cursorID()
+	params.PageLimit = page.queryLimit()
+
+	logs, err := h.Queries.GetAllLogs(context.Background(), params)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch moderation logs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get moderation logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, logs, func(log db.GetAllLogsRow) pageCursor {
+		return pageCursor{Time: log.ActionDate.Time, ID: log.LogID}
+	}))
+}
+
+// exportModerationLogs writes every log entry matching the filters as CSV, fetching them in batches
+func (h *Handler) exportModerationLogs(c *gin.Context, params db.GetAllLogsParams) {
+	ctx := context.Background()
+	params.PageLimit = logExportBatchSize
+
+	logs, err := h.Queries.GetAllLogs(ctx, params)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch moderation logs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get moderation logs"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="moderation-log.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(logCSVHeader)
+	for {
+		for _, log := range logs {
+			w.Write([]string{
+				strconv.Itoa(int(log.LogID)),
+				csvSafe(log.Action),
+				log.ActionDate.Time.UTC().Format(time.RFC3339),
+				formatOptionalID(log.ModeratorUserID),
+				csvSafe(log.ModeratorUsername.String),
+				formatOptionalID(log.AffectedUserID),
+				csvSafe(log.AffectedUsername.String),
+				formatOptionalID(log.PostID),
+				formatOptionalID(log.CommentID),
+				csvSafe(log.Reason.String),
+			})
+		}
+		if len(logs) < logExportBatchSize {
+			break
+		}
+
+		last := logs[len(logs)-1]
+		params.CursorDate = last.ActionDate
+		params.CursorID = pgtype.Int4{Int32: last.LogID, Valid: true}
+		logs, err = h.Queries.GetAllLogs(ctx, params)
+		if err != nil {
+			// the response has already started, so the export can only be cut short
+			h.Log.Errorf("Unable to fetch moderation logs: %v\n", err)
+			break
+		}
+	}
+	w.Flush()
+}
+
+func formatOptionalID(id pgtype.Int4) string {
+	if !id.Valid {
+		return ""
+	}
+	return strconv.Itoa(int(id.Int32))
+}
+
+// csvSafe prefixes values that spreadsheet applications would otherwise run as formulas
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "spam", want: "spam"},
+		{value: "lock_post", want: "lock_post"},
+		{value: "a=b", want: "a=b"},
+		{value: "=HYPERLINK(\"http://example.com\")", want: "'=HYPERLINK(\"http://example.com\")"},
+		{value: "+1", want: "'+1"},
+		{value: "-1", want: "'-1"},
+		{value: "@SUM(A1:A2)", want: "'@SUM(A1:A2)"},
+		{value: "\tindented", want: "'\tindented"},
+		{value: "\rreturn", want: "'\rreturn"},
+		{value: "'already quoted", want: "'already quoted"},
+	}
+	for _, test := range tests {
+		if got := csvSafe(test.value); got != test.want {
+			t.Errorf("csvSafe(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"server/db"
+	"server/markdown"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const renderBatchSize = 100
+
+// renderContent renders Markdown content to the sanitised HTML stored alongside it
+func renderContent(source string) pgtype.Text {
+	return pgtype.Text{String: markdown.Render(source), Valid: true}
+}
+
+// RenderMissingContentHTML renders the content of posts and comments that have no stored HTML,
+// such as rows inserted directly into the database
+func (h *Handler) RenderMissingContentHTML(ctx context.Context, now time.Time) error {
+	for {
+		posts, err := h.Queries.GetPostsWithoutContentHTML(ctx, renderBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			err := h.Queries.SetPostContentHTML(ctx, db.SetPostContentHTMLParams{
+				PostID:      post.PostID,
+				ContentHtml: renderContent(post.Content),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(posts) < renderBatchSize {
+			break
+		}
+	}
+
+	for {
+		comments, err := h.Queries.GetCommentsWithoutContentHTML(ctx, renderBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			err := h.Queries.SetCommentContentHTML(ctx, db.SetCommentContentHTMLParams{
+				CommentID:   comment.CommentID,
+				ContentHtml: renderContent(comment.Content),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(comments) < renderBatchSize {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"server/db"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const maxMessageLength = 5000
+
+var (
+	errEmptyMessage     = errors.New("message cannot be empty")
+	errMessageTooLong   = errors.New("message is too long")
+	errMessageToSelf    = errors.New("you cannot message yourself")
+	errReceiverNotFound = errors.New("receiver not found")
+)
+
+// sendPrivateMessage validates and saves a private message and notifies its receiver.
+// The errors above and errEmailNotVerified are safe to show to the sender, and errReceiverNotFound is returned when the receiver does not exist.
+func (h *Handler) sendPrivateMessage(ctx context.Context, senderID, receiverID int32, content string) (db.PrivateMessage, error) {
+	if strings.TrimSpace(content) == "" {
+		return db.PrivateMessage{}, errEmptyMessage
+	}
+	if len(content) > maxMessageLength {
+		return db.PrivateMessage{}, errMessageTooLong
+	}
+	if senderID == receiverID {
+		return db.PrivateMessage{}, errMessageToSelf
+	}
+	if sender, err := h.Queries.GetUser(ctx, senderID); err != nil {
+		return db.PrivateMessage{}, err
+	} else if !sender.EmailVerifiedAt.Valid {
+		return db.PrivateMessage{}, errEmailNotVerified
+	}
+	if _, err := h.Queries.GetUser(ctx, receiverID); errors.Is(err, pgx.ErrNoRows) {
+		return db.PrivateMessage{}, errReceiverNotFound
+	} else if err != nil {
+		return db.PrivateMessage{}, err
+	}
+
+	message, err := h.Queries.CreatePrivateMessage(ctx, db.CreatePrivateMessageParams{
+		Content:        content,
+		SenderUserID:   pgtype.Int4{Int32: senderID, Valid: true},
+		ReceiverUserID: pgtype.Int4{Int32: receiverID, Valid: true},
+	})
+	if err != nil {
+		return db.PrivateMessage{}, err
+	}
+	h.notifyPrivateMessage(ctx, message)
+	return message, nil
+}
+
+// messageErrorStatus returns the status code of an error from sendPrivateMessage, and false if it is not one of its
+// user facing errors
+func messageErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, errEmailNotVerified):
+		return http.StatusForbidden, true
+	case errors.Is(err, errReceiverNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, errEmptyMessage), errors.Is(err, errMessageTooLong), errors.Is(err, errMessageToSelf):
+		return http.StatusBadRequest, true
+	default:
+		return 0, false
+	}
+}
+
+type SendMessageApiParams struct {
+	ReceiverUserID int32
+	Content        string
+}
+
+// SendMessageHandler handles POST requests to send a private message to another user
+func (h *Handler) SendMessageHandler(c *gin.Context) {
+	var req SendMessageApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	message, err := h.sendPrivateMessage(context.Background(), userID, req.ReceiverUserID, req.Content)
+	if status, ok := messageErrorStatus(err); ok {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to send message: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Message sent successfully", "private_message": message})
+}
+
+// GetConversationsHandler handles GET requests to get a page of the logged in user's conversations, most recently
+// active first. Each has the other user, the latest message, and the number of messages the logged in user has not read.
+func (h *Handler) GetConversationsHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	conversations, err := h.Queries.GetConversations(context.Background(), db.GetConversationsParams{
+		UserID:    userID,
+		CursorID:  page.cursorID(),
+		PageLimit: page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch conversations: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, conversations, func(conversation db.GetConversationsRow) pageCursor {
+		return pageCursor{ID: conversation.MessageID}
+	}))
+}
+
+// GetConversationHandler handles GET requests to get a page of the messages between the logged in user and the user
+// in the userID path parameter, newest first
+func (h *Handler) GetConversationHandler(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	messages, err := h.Queries.GetConversationMessages(context.Background(), db.GetConversationMessagesParams{
+		UserID:        userID,
+		PartnerUserID: int32(partnerID),
+		CursorID:      page.cursorID(),
+		PageLimit:     page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch messages: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, messages, func(message db.PrivateMessage) pageCursor {
+		return pageCursor{ID: message.MessageID}
+	}))
+}
+
+type MarkConversationReadApiParams struct {
+	UpToMessageID *int32 // optional, marks every message when omitted
+}
+
+// MarkConversationReadHandler handles POST requests to mark the messages the logged in user has received from the user
+// in the userID path parameter as read
+func (h *Handler) MarkConversationReadHandler(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req MarkConversationReadApiParams
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	params := db.MarkConversationAsReadParams{UserID: userID, PartnerUserID: int32(partnerID)}
+	if req.UpToMessageID != nil {
+		params.UpToMessageID = pgtype.Int4{Int32: *req.UpToMessageID, Valid: true}
+	}
+	ctx := context.Background()
+	messageIDs, err := h.Queries.MarkConversationAsRead(ctx, params)
+	if err != nil {
+		h.Log.Errorf("Unable to mark messages as read: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
+		return
+	}
+	h.publishReadReceipt(ctx, userID, int32(partnerID), messageIDs)
+	if messageIDs == nil {
+		messageIDs = []int32{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Messages marked as read successfully", "message_ids": messageIDs})
+}
+
+// GetUnreadMessageCountHandler handles GET requests to count the messages the logged in user has not read
+func (h *Handler) GetUnreadMessageCountHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	count, err := h.Queries.CountUnreadPrivateMessages(context.Background(), pgtype.Int4{Int32: userID, Valid: true})
+	if err != nil {
+		h.Log.Errorf("Unable to count unread messages: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
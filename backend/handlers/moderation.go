@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"server/db"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Actions recorded in forum_moderation_log
+const (
+	logActionLockPost      = "lock_post"
+	logActionUnlockPost    = "unlock_post"
+	logActionStickyPost    = "sticky_post"
+	logActionUnstickyPost  = "unsticky_post"
+	logActionEditPost      = "edit_post"
+	logActionDeletePost    = "delete_post"
+	logActionEditComment   = "edit_comment"
+	logActionDeleteComment = "delete_comment"
+	logActionDeleteMessage = "delete_message"
+	logActionSuspendUser   = "suspend_user"
+	logActionBanUser       = "ban_user"
+	logActionReinstateUser = "reinstate_user"
+)
+
+// authorizeContentChange checks that the logged in user can edit or delete content owned by ownerID,
+// writing an error response and returning false if not. Owners can change their own content, and moderators and
+// admins can change anyone's as long as they give a reason. moderated reports whether the change must be logged.
+func authorizeContentChange(c *gin.Context, ownerID pgtype.Int4, reason string) (moderated bool, ok bool) {
+	userID, hasUser := getUserID(c)
+	if hasUser && ownerID.Valid && ownerID.Int32 == userID {
+		return false, true
+	}
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own content"})
+		return false, false
+	}
+	if strings.TrimSpace(reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to change another user's content"})
+		return false, false
+	}
+	return true, true
+}
+
+type ModeratePostApiParams struct {
+	Reason string // optional
+}
+
+// moderatePost applies a moderation action to the post in the id path parameter and records it in the moderation log
+func (h *Handler) moderatePost(c *gin.Context, action string, apply func(ctx context.Context, q *db.Queries, post *db.Post) error, message string) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	var req ModeratePostApiParams
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	moderatorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate post"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	post, err := qtx.GetPostForUpdate(ctx, int32(postID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate post"})
+		return
+	}
+
+	if err := apply(ctx, qtx, &post); err != nil {
+		h.Log.Errorf("Unable to %s: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate post"})
+		return
+	}
+	err = qtx.CreateLog(ctx, db.CreateLogParams{
+		Action:          action,
+		ModeratorUserID: pgtype.Int4{Int32: moderatorID, Valid: true},
+		AffectedUserID:  post.UserID,
+		PostID:          pgtype.Int4{Int32: post.PostID, Valid: true},
+		Reason:          pgtype.Text{String: req.Reason, Valid: req.Reason != ""},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create moderation log: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate post"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "post": post})
+}
+
+// LockPostHandler handles POST requests from moderators to lock a post, preventing new comments, comment edits and votes
+// by anyone but moderators
+func (h *Handler) LockPostHandler(c *gin.Context) {
+	h.moderatePost(c, logActionLockPost, func(ctx context.Context, q *db.Queries, post *db.Post) error {
+		post.IsLocked = pgtype.Bool{Bool: true, Valid: true}
+		return q.LockPost(ctx, post.PostID)
+	}, "Post locked successfully")
+}
+
+// UnlockPostHandler handles POST requests from moderators to unlock a post
+func (h *Handler) UnlockPostHandler(c *gin.Context) {
+	h.moderatePost(c, logActionUnlockPost, func(ctx context.Context, q *db.Queries, post *db.Post) error {
+		post.IsLocked = pgtype.Bool{Bool: false, Valid: true}
+		return q.UnlockPost(ctx, post.PostID)
+	}, "Post unlocked successfully")
+}
+
+// StickyPostHandler handles POST requests from moderators to pin a post to the top of post listings
+func (h *Handler) StickyPostHandler(c *gin.Context) {
+	h.moderatePost(c, logActionStickyPost, func(ctx context.Context, q *db.Queries, post *db.Post) error {
+		post.IsSticky = pgtype.Bool{Bool: true, Valid: true}
+		return q.StickyPost(ctx, post.PostID)
+	}, "Post stickied successfully")
+}
+
+// UnstickyPostHandler handles POST requests from moderators to unpin a post
+func (h *Handler) UnstickyPostHandler(c *gin.Context) {
+	h.moderatePost(c, logActionUnstickyPost, func(ctx context.Context, q *db.Queries, post *db.Post) error {
+		post.IsSticky = pgtype.Bool{Bool: false, Valid: true}
+		return q.UnstickyPost(ctx, post.PostID)
+	}, "Post unstickied successfully")
+}
+
+// GetStickyPostsHandler handles GET requests to list a page of the sticky posts, newest first
+func (h *Handler) GetStickyPostsHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts, err := h.Queries.GetStickyPosts(context.Background(), db.GetStickyPostsParams{
+		CursorDate: page.cursorTime(),
+		CursorID:   page.cursorID(),
+		PageLimit:  page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch sticky posts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get posts"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse(page, posts, func(p db.Post) pageCursor {
+		return pageCursor{Time: p.CreationDate.Time, ID: p.PostID}
+	}))
+}
+
+// GetLockedPostsHandler handles GET requests from moderators to list a page of the locked posts, newest first
+func (h *Handler) GetLockedPostsHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts, err := h.Queries.GetLockedPosts(context.Background(), db.GetLockedPostsParams{
+		CursorDate: page.cursorTime(),
+		CursorID:   page.cursorID(),
+		PageLimit:  page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch locked posts: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get posts"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse(page, posts, func(p db.Post) pageCursor {
+		return pageCursor{Time: p.CreationDate.Time, ID: p.PostID}
+	}))
+}
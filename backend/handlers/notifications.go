@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"server/db"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Notification types, which tell clients what a notification links to
+const (
+	notificationCommentOnPost    = "comment_on_post"
+	notificationReplyToComment   = "reply_to_comment"
+	notificationPrivateMessage   = "private_message"
+	notificationEventChanged     = "event_changed"
+	notificationEventReminder    = "event_reminder"
+	notificationWaitlistPromoted = "waitlist_promoted"
+)
+
+// notify creates a notification, logging rather than returning errors since notifications are sent after the action
+// that caused them has already succeeded. Users are never notified about their own actions.
+func (h *Handler) notify(ctx context.Context, params db.CreateNotificationParams) {
+	if !params.UserID.Valid || (params.ActorUserID.Valid && params.ActorUserID.Int32 == params.UserID.Int32) {
+		return
+	}
+	if _, err := h.Queries.CreateNotification(ctx, params); err != nil {
+		h.Log.Errorf("Unable to create notification: %v\n", err)
+	}
+}
+
+// notifyComment notifies the author of a post about a new comment on it, or the author of a comment about a reply to it
+func (h *Handler) notifyComment(ctx context.Context, post db.Post, parent *db.Comment, comment db.Comment) {
+	actor, err := h.Queries.GetUser(ctx, comment.UserID.Int32)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch user: %v\n", err)
+		return
+	}
+
+	params := db.CreateNotificationParams{
+		UserID:           post.UserID,
+		NotificationType: notificationCommentOnPost,
+		Content:          fmt.Sprintf("%s commented on your post %q.", actor.Username, post.Title),
+		ActorUserID:      comment.UserID,
+		PostID:           comment.PostID,
+		CommentID:        pgtype.Int4{Int32: comment.CommentID, Valid: true},
+	}
+	if parent != nil {
+		params.UserID = parent.UserID
+		params.NotificationType = notificationReplyToComment
+		params.Content = fmt.Sprintf("%s replied to your comment on %q.", actor.Username, post.Title)
+	}
+	h.notify(ctx, params)
+}
+
+// notifyPrivateMessage notifies the receiver of a private message
+func (h *Handler) notifyPrivateMessage(ctx context.Context, message db.PrivateMessage) {
+	sender, err := h.Queries.GetUser(ctx, message.SenderUserID.Int32)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch user: %v\n", err)
+		return
+	}
+
+	h.notify(ctx, db.CreateNotificationParams{
+		UserID:           message.ReceiverUserID,
+		NotificationType: notificationPrivateMessage,
+		Content:          fmt.Sprintf("%s sent you a message.", sender.Username),
+		ActorUserID:      message.SenderUserID,
+		MessageID:        pgtype.Int4{Int32: message.MessageID, Valid: true},
+	})
+}
+
+// notifyEventChange notifies every member who has RSVPed to an event, other than declining, that it has changed.
+// occurrenceDate limits the notifications to the members of a single occurrence of a recurring event.
+func (h *Handler) notifyEventChange(ctx context.Context, event db.Event, occurrenceDate pgtype.Timestamptz, actorID int32, content string) {
+	userIDs, err := h.Queries.GetRSVPUserIDsByEventID(ctx, db.GetRSVPUserIDsByEventIDParams{
+		EventID:          pgtype.Int4{Int32: event.EventID, Valid: true},
+		SingleOccurrence: occurrenceDate.Valid,
+		OccurrenceDate:   occurrenceDate,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch RSVPs: %v\n", err)
+		return
+	}
+	h.notifyUsers(ctx, userIDs, event.EventID, actorID, content)
+}
+
+// notifyUsers sends the same event change notification to each of userIDs
+func (h *Handler) notifyUsers(ctx context.Context, userIDs []pgtype.Int4, eventID, actorID int32, content string) {
+	for _, userID := range userIDs {
+		h.notify(ctx, db.CreateNotificationParams{
+			UserID:           userID,
+			NotificationType: notificationEventChanged,
+			Content:          content,
+			ActorUserID:      pgtype.Int4{Int32: actorID, Valid: true},
+			EventID:          pgtype.Int4{Int32: eventID, Valid: true},
+		})
+	}
+}
+
+// GetNotificationsHandler handles GET requests to get a page of the logged in user's notifications, newest first.
+// unread=true lists only unread notifications.
+func (h *Handler) GetNotificationsHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	notifications, err := h.Queries.GetNotificationsByUserId(context.Background(), db.GetNotificationsByUserIdParams{
+		UserID:     userID,
+		UnreadOnly: c.Query("unread") == "true",
+		CursorDate: page.cursorTime(),
+		CursorID:   page.cursorID(),
+		PageLimit:  page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch notifications: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, notifications, func(notification db.GetNotificationsByUserIdRow) pageCursor {
+		return pageCursor{Time: notification.CreationDate.Time, ID: notification.NotificationID}
+	}))
+}
+
+// GetUnreadNotificationCountHandler handles GET requests to count the logged in user's unread notifications
+func (h *Handler) GetUnreadNotificationCountHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	count, err := h.Queries.CountUnreadNotificationsByUserId(context.Background(), pgtype.Int4{Int32: userID, Valid: true})
+	if err != nil {
+		h.Log.Errorf("Unable to count unread notifications: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// MarkNotificationReadHandler handles POST requests to mark one of the logged in user's notifications as read
+func (h *Handler) MarkNotificationReadHandler(c *gin.Context) {
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	updated, err := h.Queries.MarkNotificationAsReadByNotificationIdAndUserId(context.Background(), db.MarkNotificationAsReadByNotificationIdAndUserIdParams{
+		NotificationID: int32(notificationID),
+		UserID:         pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to mark notification as read: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read successfully"})
+}
+
+// MarkAllNotificationsReadHandler handles POST requests to mark all of the logged in user's notifications as read
+func (h *Handler) MarkAllNotificationsReadHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	updated, err := h.Queries.MarkAllNotificationsAsReadByUserId(context.Background(), pgtype.Int4{Int32: userID, Valid: true})
+	if err != nil {
+		h.Log.Errorf("Unable to mark notifications as read: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read successfully", "updated": updated})
+}
+
+// DeleteNotificationHandler handles DELETE requests to delete one of the logged in user's notifications
+func (h *Handler) DeleteNotificationHandler(c *gin.Context) {
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	deleted, err := h.Queries.DeleteNotificationByNotificationIdAndUserId(context.Background(), db.DeleteNotificationByNotificationIdAndUserIdParams{
+		NotificationID: int32(notificationID),
+		UserID:         pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to delete notification: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted successfully"})
+}
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"server/rrule"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Occurrence is a single occurrence of an event. One-off events have one occurrence without an OccurrenceDate.
+// Occurrences of recurring events carry their original start time, which identifies them even after they are
+// rescheduled, while EventDate and the other event fields reflect any changes made to that occurrence.
+type Occurrence struct {
+	db.Event
+	OccurrenceDate *time.Time
+	IsCancelled    bool
+}
+
+var errInvalidOccurrence = errors.New("invalid occurrence")
+
+// key returns the occurrence date as stored on RSVPs, which is NULL for one-off events
+func (o Occurrence) key() pgtype.Timestamptz {
+	if o.OccurrenceDate == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *o.OccurrenceDate, Valid: true}
+}
+
+// parseRecurrenceRule validates an RRULE and returns it in canonical form, or NULL for one-off events
+func parseRecurrenceRule(s string) (pgtype.Text, error) {
+	if s == "" {
+		return pgtype.Text{}, nil
+	}
+	rule, err := rrule.Parse(s)
+	if err != nil {
+		return pgtype.Text{}, fmt.Errorf("invalid recurrence rule: %v", err)
+	}
+	return pgtype.Text{String: rule.String(), Valid: true}, nil
+}
+
+// parseTimeZone validates the IANA time zone of an event, defaulting to UTC
+func parseTimeZone(name string) (string, error) {
+	if name == "" {
+		return "UTC", nil
+	}
+	// LoadLocation takes Local to mean the server's time zone, which is what time zones on events replace
+	if name == "Local" {
+		return "", errors.New("invalid time zone")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return "", fmt.Errorf("invalid time zone: %v", err)
+	}
+	return name, nil
+}
+
+// eventLocation returns the time zone the recurrence rule of an event is expanded in
+func eventLocation(event db.Event) (*time.Location, error) {
+	return time.LoadLocation(event.TimeZone)
+}
+
+// parseOccurrenceQuery parses the optional occurrence query parameter identifying an occurrence of a recurring event
+func parseOccurrenceQuery(c *gin.Context) (*time.Time, error) {
+	value := c.Query("occurrence")
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("invalid occurrence, expected RFC 3339")
+	}
+	return &t, nil
+}
+
+// applyOccurrenceChanges returns the occurrence of a recurring event starting at start, with the changes
+// made to that occurrence applied
+func applyOccurrenceChanges(event db.Event, start time.Time, changes *db.EventOccurrence) Occurrence {
+	occurrenceDate := start
+	occurrence := Occurrence{Event: event, OccurrenceDate: &occurrenceDate}
+	occurrence.EventDate = pgtype.Timestamptz{Time: start, Valid: true}
+	if changes == nil {
+		return occurrence
+	}
+	occurrence.IsCancelled = changes.IsCancelled
+	if changes.Title.Valid {
+		occurrence.Title = changes.Title.String
+	}
+	if changes.Description.Valid {
+		occurrence.Description = changes.Description
+	}
+	if changes.EventDate.Valid {
+		occurrence.EventDate = changes.EventDate
+	}
+	if changes.MeetingPoint.Valid {
+		occurrence.MeetingPoint = changes.MeetingPoint.String
+	}
+	return occurrence
+}
+
+// expandSeries returns the occurrences of a recurring event that start within [from, to], including
+// occurrences rescheduled into the range and excluding those rescheduled out of it
+func expandSeries(ctx context.Context, q *db.Queries, event db.Event, from, to time.Time, limit int) ([]Occurrence, error) {
+	changed, err := q.GetEventOccurrencesByEventID(ctx, event.EventID)
+	if err != nil {
+		return nil, err
+	}
+	return expandOccurrences(event, changed, from, to, limit)
+}
+
+// expandOccurrences expands the recurrence rule of an event within [from, to] and applies the changes made to its
+// occurrences. Occurrences are expanded in the event's time zone, so that they keep their local start time across
+// daylight saving changes.
+// A positive limit stops expanding the rule once it has given that many unchanged occurrences, which are then the
+// earliest unchanged ones in the range. Changed occurrences do not count towards it since they may have been
+// rescheduled later, and are all returned.
+func expandOccurrences(event db.Event, changed []db.EventOccurrence, from, to time.Time, limit int) ([]Occurrence, error) {
+	rule, err := rrule.Parse(event.RecurrenceRule.String)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := eventLocation(event)
+	if err != nil {
+		return nil, err
+	}
+	dtstart := event.EventDate.Time.In(loc)
+
+	changes := make(map[int64]*db.EventOccurrence, len(changed))
+	for i := range changed {
+		changes[changed[i].OccurrenceDate.Time.UnixMicro()] = &changed[i]
+	}
+
+	inRange := func(o Occurrence) bool {
+		return !o.EventDate.Time.Before(from) && !o.EventDate.Time.After(to)
+	}
+
+	var occurrences []Occurrence
+	expanded := make(map[int64]bool)
+	unchanged := 0
+	rule.Each(dtstart, from, to, func(start time.Time) bool {
+		expanded[start.UnixMicro()] = true
+		change := changes[start.UnixMicro()]
+		if occurrence := applyOccurrenceChanges(event, start, change); inRange(occurrence) {
+			occurrences = append(occurrences, occurrence)
+		}
+		if change == nil {
+			unchanged++
+		}
+		return limit <= 0 || unchanged < limit
+	})
+	for i, change := range changed {
+		start := change.OccurrenceDate.Time.In(loc)
+		if expanded[start.UnixMicro()] || !rule.IsOccurrence(dtstart, start) {
+			continue
+		}
+		if occurrence := applyOccurrenceChanges(event, start, &changed[i]); inRange(occurrence) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences, nil
+}
+
+// expandEvents returns the occurrences within [from, to] of the given one-off and recurring events, ordered by start time.
+// A positive limit bounds the occurrences expanded from each recurring event as in expandOccurrences.
+func (h *Handler) expandEvents(ctx context.Context, events []db.Event, from, to time.Time, limit int) ([]Occurrence, error) {
+	occurrences := []Occurrence{}
+	for _, event := range events {
+		if !event.RecurrenceRule.Valid {
+			if !event.EventDate.Time.Before(from) && !event.EventDate.Time.After(to) {
+				occurrences = append(occurrences, Occurrence{Event: event})
+			}
+			continue
+		}
+		series, err := expandSeries(ctx, h.Queries, event, from, to, limit)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, series...)
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].EventDate.Time.Before(occurrences[j].EventDate.Time)
+	})
+	return occurrences, nil
+}
+
+// occurrencesAfter sorts occurrences by start time and event ID, the keyset order of event lists, and drops those
+// up to and including the cursor
+func occurrencesAfter(occurrences []Occurrence, cursor *pageCursor) []Occurrence {
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		if !a.EventDate.Time.Equal(b.EventDate.Time) {
+			return a.EventDate.Time.Before(b.EventDate.Time)
+		}
+		return a.EventID < b.EventID
+	})
+	if cursor == nil {
+		return occurrences
+	}
+	i := sort.Search(len(occurrences), func(i int) bool {
+		o := occurrences[i]
+		return o.EventDate.Time.After(cursor.Time) || (o.EventDate.Time.Equal(cursor.Time) && o.EventID > cursor.ID)
+	})
+	return occurrences[i:]
+}
+
+// occurrencesBetween fetches the events with an occurrence that may start within [from, to] and returns those
+// occurrences, ordered by start time
+func (h *Handler) occurrencesBetween(ctx context.Context, from, to time.Time) ([]Occurrence, error) {
+	events, err := h.Queries.GetEventsByDateRange(ctx, db.GetEventsByDateRangeParams{
+		EventDate:   pgtype.Timestamptz{Time: from, Valid: true},
+		EventDate_2: pgtype.Timestamptz{Time: to, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var oneOff []db.Event
+	for _, event := range events {
+		if !event.RecurrenceRule.Valid {
+			oneOff = append(oneOff, event)
+		}
+	}
+	// recurring events are fetched separately since their first occurrence may be before the range
+	series, err := h.Queries.GetRecurringEventsStartingBefore(ctx, pgtype.Timestamptz{Time: to, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	return h.expandEvents(ctx, append(oneOff, series...), from, to, 0)
+}
+
+// resolveOccurrence finds the occurrence of an event being referred to. One-off events take no occurrence date,
+// while recurring events require the original start time of one of their occurrences.
+func resolveOccurrence(ctx context.Context, q *db.Queries, event db.Event, occurrenceDate *time.Time) (Occurrence, error) {
+	if !event.RecurrenceRule.Valid {
+		if occurrenceDate != nil && !occurrenceDate.Equal(event.EventDate.Time) {
+			return Occurrence{}, fmt.Errorf("%w: event does not recur", errInvalidOccurrence)
+		}
+		return Occurrence{Event: event}, nil
+	}
+	if occurrenceDate == nil {
+		return Occurrence{}, fmt.Errorf("%w: occurrence is required for recurring events", errInvalidOccurrence)
+	}
+
+	rule, err := rrule.Parse(event.RecurrenceRule.String)
+	if err != nil {
+		return Occurrence{}, err
+	}
+	loc, err := eventLocation(event)
+	if err != nil {
+		return Occurrence{}, err
+	}
+	start := occurrenceDate.In(loc)
+	if !rule.IsOccurrence(event.EventDate.Time.In(loc), start) {
+		return Occurrence{}, fmt.Errorf("%w: not an occurrence of this event", errInvalidOccurrence)
+	}
+
+	changes, err := q.GetEventOccurrence(ctx, db.GetEventOccurrenceParams{
+		EventID:        event.EventID,
+		OccurrenceDate: pgtype.Timestamptz{Time: start, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return applyOccurrenceChanges(event, start, nil), nil
+	}
+	if err != nil {
+		return Occurrence{}, err
+	}
+	return applyOccurrenceChanges(event, start, &changes), nil
+}
+
+// getOrganisedRecurringEvent fetches a recurring event organised by the logged in user, writing the error response if there is none
+func (h *Handler) getOrganisedRecurringEvent(c *gin.Context) (db.Event, bool) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return db.Event{}, false
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return db.Event{}, false
+	}
+
+	event, err := h.Queries.GetEventByID(context.Background(), int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (!event.CreatorUserID.Valid || event.CreatorUserID.Int32 != userID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found or not organised by you"})
+		return db.Event{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return db.Event{}, false
+	}
+	if !event.RecurrenceRule.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event does not recur"})
+		return db.Event{}, false
+	}
+	return event, true
+}
+
+// GetEventOccurrencesHandler handles GET requests to list the occurrences of a single event.
+// Optional filters: from and to (RFC 3339 or YYYY-MM-DD, defaulting to now and one year ahead, and at most a year apart).
+func (h *Handler) GetEventOccurrencesHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	from, to, err := parseEventRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.Queries.GetEventByID(context.Background(), int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+
+	occurrences, err := h.expandEvents(context.Background(), []db.Event{event}, from, to, 0)
+	if err != nil {
+		h.Log.Errorf("Unable to expand event occurrences: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+type UpdateEventOccurrenceApiParams struct {
+	OccurrenceDate time.Time
+	Title          *string
+	Description    *string
+	EventDate      *time.Time
+	MeetingPoint   *string
+}
+
+// UpdateEventOccurrenceHandler handles PUT requests from the organiser to change a single occurrence of a recurring event.
+// Fields left out keep their current value for the occurrence.
+func (h *Handler) UpdateEventOccurrenceHandler(c *gin.Context) {
+	var req UpdateEventOccurrenceApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Title != nil && *req.Title == "") || (req.MeetingPoint != nil && *req.MeetingPoint == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title and meeting point cannot be empty"})
+		return
+	}
+
+	event, ok := h.getOrganisedRecurringEvent(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	occurrence, err := resolveOccurrence(ctx, h.Queries, event, &req.OccurrenceDate)
+	if errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+
+	changes, err := h.Queries.GetEventOccurrence(ctx, db.GetEventOccurrenceParams{EventID: event.EventID, OccurrenceDate: occurrence.key()})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+
+	params := db.UpsertEventOccurrenceParams{
+		EventID:        event.EventID,
+		OccurrenceDate: occurrence.key(),
+		IsCancelled:    changes.IsCancelled,
+		Title:          changes.Title,
+		Description:    changes.Description,
+		EventDate:      changes.EventDate,
+		MeetingPoint:   changes.MeetingPoint,
+	}
+	if req.Title != nil {
+		params.Title = pgtype.Text{String: *req.Title, Valid: true}
+	}
+	if req.Description != nil {
+		params.Description = pgtype.Text{String: *req.Description, Valid: true}
+	}
+	if req.EventDate != nil {
+		params.EventDate = pgtype.Timestamptz{Time: *req.EventDate, Valid: true}
+	}
+	if req.MeetingPoint != nil {
+		params.MeetingPoint = pgtype.Text{String: *req.MeetingPoint, Valid: true}
+	}
+
+	changes, err = h.Queries.UpsertEventOccurrence(ctx, params)
+	if err != nil {
+		h.Log.Errorf("Unable to update event occurrence: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update occurrence"})
+		return
+	}
+
+	h.notifyOccurrenceChange(ctx, c, event, occurrence.key(), "%s on %s has been changed by its organiser.")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Occurrence updated successfully",
+		"occurrence": applyOccurrenceChanges(event, *occurrence.OccurrenceDate, &changes),
+	})
+}
+
+type CancelEventOccurrenceApiParams struct {
+	OccurrenceDate time.Time
+}
+
+// CancelEventOccurrenceHandler handles POST requests from the organiser to cancel a single occurrence of a recurring event.
+// The rest of the series is unaffected.
+func (h *Handler) CancelEventOccurrenceHandler(c *gin.Context) {
+	var req CancelEventOccurrenceApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, ok := h.getOrganisedRecurringEvent(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	occurrence, err := resolveOccurrence(ctx, h.Queries, event, &req.OccurrenceDate)
+	if errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+
+	changes, err := h.Queries.GetEventOccurrence(ctx, db.GetEventOccurrenceParams{EventID: event.EventID, OccurrenceDate: occurrence.key()})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+
+	changes, err = h.Queries.UpsertEventOccurrence(ctx, db.UpsertEventOccurrenceParams{
+		EventID:        event.EventID,
+		OccurrenceDate: occurrence.key(),
+		IsCancelled:    true,
+		Title:          changes.Title,
+		Description:    changes.Description,
+		EventDate:      changes.EventDate,
+		MeetingPoint:   changes.MeetingPoint,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to cancel event occurrence: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel occurrence"})
+		return
+	}
+
+	h.notifyOccurrenceChange(ctx, c, event, occurrence.key(), "%s on %s has been cancelled by its organiser.")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Occurrence cancelled successfully",
+		"occurrence": applyOccurrenceChanges(event, *occurrence.OccurrenceDate, &changes),
+	})
+}
+
+// RestoreEventOccurrenceHandler handles DELETE requests from the organiser to undo all changes to a single occurrence
+// of a recurring event, including a cancellation. The occurrence is given by the occurrence query parameter.
+func (h *Handler) RestoreEventOccurrenceHandler(c *gin.Context) {
+	occurrenceDate, err := parseOccurrenceQuery(c)
+	if err != nil || occurrenceDate == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "occurrence is required, expected RFC 3339"})
+		return
+	}
+
+	event, ok := h.getOrganisedRecurringEvent(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	key := pgtype.Timestamptz{Time: *occurrenceDate, Valid: true}
+	deleted, err := h.Queries.DeleteEventOccurrence(ctx, db.DeleteEventOccurrenceParams{
+		EventID:        event.EventID,
+		OccurrenceDate: key,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to restore event occurrence: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore occurrence"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Occurrence has no changes"})
+		return
+	}
+
+	h.notifyOccurrenceChange(ctx, c, event, key, "%s on %s has been restored to its original details by its organiser.")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Occurrence restored successfully"})
+}
+
+// notifyOccurrenceChange notifies the members of a single occurrence that the organiser has changed it.
+// format is given the event title and the occurrence date.
+func (h *Handler) notifyOccurrenceChange(ctx context.Context, c *gin.Context, event db.Event, occurrenceDate pgtype.Timestamptz, format string) {
+	userID, _ := getUserID(c)
+	h.notifyEventChange(ctx, event, occurrenceDate, userID, fmt.Sprintf(format, event.Title, occurrenceDate.Time.Format(time.RFC1123)))
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"server/db"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		invalid bool
+	}{
+		{name: "", want: "UTC"},
+		{name: "UTC", want: "UTC"},
+		{name: "Europe/London", want: "Europe/London"},
+		{name: "Asia/Singapore", want: "Asia/Singapore"},
+		{name: "Local", invalid: true},
+		{name: "Mars/Olympus_Mons", invalid: true},
+	}
+	for _, test := range tests {
+		got, err := parseTimeZone(test.name)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("parseTimeZone(%q) = %q, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseTimeZone(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+// weeklyEvent is a weekly event starting on Thursday 21 March 2024 at 09:00 in London, ten days before the clocks
+// go forward
+func weeklyEvent(timeZone string) db.Event {
+	return db.Event{
+		EventID:        1,
+		Title:          "Morning ride",
+		EventDate:      pgtype.Timestamptz{Time: time.Date(2024, 3, 21, 9, 0, 0, 0, time.UTC), Valid: true},
+		MeetingPoint:   "Town hall",
+		RecurrenceRule: pgtype.Text{String: "FREQ=WEEKLY;COUNT=4", Valid: true},
+		TimeZone:       timeZone,
+	}
+}
+
+func occurrenceStarts(occurrences []Occurrence) []time.Time {
+	starts := make([]time.Time, len(occurrences))
+	for i, o := range occurrences {
+		starts[i] = o.EventDate.Time.UTC()
+	}
+	return starts
+}
+
+func TestExpandOccurrencesKeepsLocalTimeAcrossDaylightSaving(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		timeZone string
+		want     []time.Time
+	}{
+		{
+			// 09:00 GMT, then 09:00 BST which is 08:00 UTC
+			timeZone: "Europe/London",
+			want: []time.Time{
+				time.Date(2024, 3, 21, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 28, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 4, 8, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 11, 8, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			timeZone: "UTC",
+			want: []time.Time{
+				time.Date(2024, 3, 21, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 3, 28, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 4, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 4, 11, 9, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	for _, test := range tests {
+		occurrences, err := expandOccurrences(weeklyEvent(test.timeZone), nil, from, to, 0)
+		if err != nil {
+			t.Fatalf("expandOccurrences in %s failed: %v", test.timeZone, err)
+		}
+		got := occurrenceStarts(occurrences)
+		if len(got) != len(test.want) {
+			t.Fatalf("in %s got occurrences %v, want %v", test.timeZone, got, test.want)
+		}
+		for i := range test.want {
+			if !got[i].Equal(test.want[i]) {
+				t.Errorf("in %s occurrence %d starts at %v, want %v", test.timeZone, i, got[i], test.want[i])
+			}
+			if o := occurrences[i]; o.OccurrenceDate == nil || !o.OccurrenceDate.Equal(test.want[i]) {
+				t.Errorf("in %s occurrence %d has occurrence date %v, want %v", test.timeZone, i, o.OccurrenceDate, test.want[i])
+			}
+		}
+	}
+}
+
+func TestExpandOccurrencesAppliesChanges(t *testing.T) {
+	event := weeklyEvent("Europe/London")
+	second := time.Date(2024, 3, 28, 9, 0, 0, 0, time.UTC)
+	third := time.Date(2024, 4, 4, 8, 0, 0, 0, time.UTC)
+	fourth := time.Date(2024, 4, 11, 8, 0, 0, 0, time.UTC)
+	moved := time.Date(2024, 3, 29, 17, 0, 0, 0, time.UTC)
+	changed := []db.EventOccurrence{
+		{EventID: 1, OccurrenceDate: pgtype.Timestamptz{Time: second, Valid: true}, EventDate: pgtype.Timestamptz{Time: moved, Valid: true}, Title: pgtype.Text{String: "Evening ride", Valid: true}},
+		{EventID: 1, OccurrenceDate: pgtype.Timestamptz{Time: third, Valid: true}, IsCancelled: true},
+		// not an occurrence of the rule, so it is ignored
+		{EventID: 1, OccurrenceDate: pgtype.Timestamptz{Time: third.Add(time.Hour), Valid: true}},
+	}
+
+	// the range starts after the second occurrence's original start, but it was rescheduled into the range
+	from := second.Add(time.Hour)
+	to := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	occurrences, err := expandOccurrences(event, changed, from, to, 0)
+	if err != nil {
+		t.Fatalf("expandOccurrences failed: %v", err)
+	}
+
+	byOccurrence := make(map[time.Time]Occurrence)
+	for _, o := range occurrences {
+		byOccurrence[o.OccurrenceDate.UTC()] = o
+	}
+	if len(occurrences) != 3 {
+		t.Fatalf("got %d occurrences %v, want 3", len(occurrences), occurrenceStarts(occurrences))
+	}
+	if o, ok := byOccurrence[second]; !ok || !o.EventDate.Time.Equal(moved) || o.Title != "Evening ride" || o.MeetingPoint != "Town hall" {
+		t.Errorf("rescheduled occurrence = %+v, want it moved to %v with the new title", o, moved)
+	}
+	if o, ok := byOccurrence[third]; !ok || !o.IsCancelled {
+		t.Errorf("cancelled occurrence = %+v, want it listed as cancelled", o)
+	}
+	if o, ok := byOccurrence[fourth]; !ok || o.IsCancelled || o.Title != "Morning ride" {
+		t.Errorf("unchanged occurrence = %+v, want the event as it is", o)
+	}
+
+	// rescheduled out of the range
+	occurrences, err = expandOccurrences(event, changed, second.Add(-time.Hour), second.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatalf("expandOccurrences failed: %v", err)
+	}
+	if len(occurrences) != 0 {
+		t.Errorf("got occurrences %v, want none after the only one was moved out of the range", occurrenceStarts(occurrences))
+	}
+}
+
+func TestExpandOccurrencesLimit(t *testing.T) {
+	event := weeklyEvent("UTC")
+	event.RecurrenceRule = pgtype.Text{String: "FREQ=DAILY", Valid: true}
+	first := event.EventDate.Time
+	moved := first.AddDate(0, 0, 30)
+	changed := []db.EventOccurrence{
+		// rescheduled past the occurrences the limit stops at
+		{EventID: 1, OccurrenceDate: pgtype.Timestamptz{Time: first, Valid: true}, EventDate: pgtype.Timestamptz{Time: moved, Valid: true}},
+		{EventID: 1, OccurrenceDate: pgtype.Timestamptz{Time: first.AddDate(0, 0, 1), Valid: true}, IsCancelled: true},
+	}
+	occurrences, err := expandOccurrences(event, changed, first, first.AddDate(1, 0, 0), 2)
+	if err != nil {
+		t.Fatalf("expandOccurrences failed: %v", err)
+	}
+
+	want := []time.Time{moved, first.AddDate(0, 0, 1), first.AddDate(0, 0, 2), first.AddDate(0, 0, 3)}
+	got := occurrenceStarts(occurrences)
+	if len(got) != len(want) {
+		t.Fatalf("got occurrences %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d starts at %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func testOccurrence(eventID int32, start time.Time) Occurrence {
+	return Occurrence{Event: db.Event{EventID: eventID, EventDate: pgtype.Timestamptz{Time: start, Valid: true}}}
+}
+
+func TestOccurrencesAfter(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	occurrences := []Occurrence{
+		testOccurrence(3, base.Add(time.Hour)),
+		testOccurrence(2, base),
+		testOccurrence(1, base),
+		testOccurrence(4, base.Add(2*time.Hour)),
+	}
+
+	sorted := occurrencesAfter(occurrences, nil)
+	want := []int32{1, 2, 3, 4}
+	if len(sorted) != len(want) {
+		t.Fatalf("got %d occurrences, want %d", len(sorted), len(want))
+	}
+	for i, id := range want {
+		if sorted[i].EventID != id {
+			t.Errorf("occurrence %d is event %d, want %d", i, sorted[i].EventID, id)
+		}
+	}
+
+	tests := []struct {
+		cursor pageCursor
+		want   []int32
+	}{
+		{cursor: pageCursor{Time: base, ID: 1}, want: []int32{2, 3, 4}},
+		{cursor: pageCursor{Time: base, ID: 2}, want: []int32{3, 4}},
+		{cursor: pageCursor{Time: base.Add(time.Hour), ID: 3}, want: []int32{4}},
+		{cursor: pageCursor{Time: base.Add(2 * time.Hour), ID: 4}, want: nil},
+		{cursor: pageCursor{Time: base.Add(-time.Hour), ID: 9}, want: []int32{1, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		cursor := test.cursor
+		got := occurrencesAfter(occurrences, &cursor)
+		if len(got) != len(test.want) {
+			t.Errorf("after %+v got %d occurrences, want %v", cursor, len(got), test.want)
+			continue
+		}
+		for i, id := range test.want {
+			if got[i].EventID != id {
+				t.Errorf("after %+v occurrence %d is event %d, want %d", cursor, i, got[i].EventID, id)
+			}
+		}
+	}
+}
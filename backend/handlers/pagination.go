@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor is the position of the last item of a page in the keyset order of a list endpoint.
+// Lists ordered by time set Time, lists ordered by a computed key such as search rank or score set Key,
+// lists ordered by name set Name, lists with sticky posts first set Sticky, and all lists set ID.
+type pageCursor struct {
+	Time   time.Time `json:"t,omitempty"`
+	Key    *float64  `json:"k,omitempty"`
+	Name   string    `json:"n,omitempty"`
+	Sticky bool      `json:"s,omitempty"`
+	ID     int32     `json:"i"`
+}
+
+// pageParams are the limit and cursor query parameters shared by all paginated list endpoints
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+}
+
+// parsePageParams parses the limit and opaque cursor query parameters
+func parsePageParams(c *gin.Context) (pageParams, error) {
+	params := pageParams{Limit: defaultPageLimit}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageParams{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		params.Limit = int32(limit)
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return pageParams{}, errors.New("invalid cursor")
+		}
+		var cursor pageCursor
+		if err := json.Unmarshal(raw, &cursor); err != nil {
+			return pageParams{}, errors.New("invalid cursor")
+		}
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+// queryLimit is the number of rows to fetch: one more than the page size, to tell whether there is a next page
+func (p pageParams) queryLimit() int32 {
+	return p.Limit + 1
+}
+
+func (p pageParams) cursorTime() pgtype.Timestamptz {
+	if p.Cursor == nil || p.Cursor.Time.IsZero() {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: p.Cursor.Time, Valid: true}
+}
+
+func (p pageParams) cursorKey() pgtype.Float8 {
+	if p.Cursor == nil || p.Cursor.Key == nil {
+		return pgtype.Float8{}
+	}
+	return pgtype.Float8{Float64: *p.Cursor.Key, Valid: true}
+}
+
+// cursorScore is the Key of lists ordered by an integer score
+func (p pageParams) cursorScore() pgtype.Int4 {
+	if p.Cursor == nil || p.Cursor.Key == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: int32(*p.Cursor.Key), Valid: true}
+}
+
+func (p pageParams) cursorName() pgtype.Text {
+	if p.Cursor == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: p.Cursor.Name, Valid: true}
+}
+
+func (p pageParams) cursorSticky() pgtype.Bool {
+	if p.Cursor == nil {
+		return pgtype.Bool{}
+	}
+	return pgtype.Bool{Bool: p.Cursor.Sticky, Valid: true}
+}
+
+func (p pageParams) cursorID() pgtype.Int4 {
+	if p.Cursor == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: p.Cursor.ID, Valid: true}
+}
+
+func encodeCursor(cursor pageCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// pageResponse builds the response envelope of a paginated list from rows fetched with queryLimit.
+// next_cursor is null on the last page.
+func pageResponse[T any](p pageParams, rows []T, cursorOf func(T) pageCursor) gin.H {
+	if rows == nil {
+		rows = []T{}
+	}
+	var next *string
+	if len(rows) > int(p.Limit) {
+		rows = rows[:p.Limit]
+		cursor := encodeCursor(cursorOf(rows[len(rows)-1]))
+		next = &cursor
+	}
+	return gin.H{"items": rows, "next_cursor": next}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func pageTestContext(target string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func TestParsePageParamsLimit(t *testing.T) {
+	tests := []struct {
+		query   string
+		limit   int32
+		invalid bool
+	}{
+		{query: "", limit: defaultPageLimit},
+		{query: "?limit=1", limit: 1},
+		{query: "?limit=100", limit: maxPageLimit},
+		{query: "?limit=0", invalid: true},
+		{query: "?limit=101", invalid: true},
+		{query: "?limit=-5", invalid: true},
+		{query: "?limit=ten", invalid: true},
+	}
+	for _, test := range tests {
+		page, err := parsePageParams(pageTestContext("/" + test.query))
+		if test.invalid {
+			if err == nil {
+				t.Errorf("parsePageParams(%q) succeeded, want an error", test.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePageParams(%q) failed: %v", test.query, err)
+			continue
+		}
+		if page.Limit != test.limit || page.Cursor != nil {
+			t.Errorf("parsePageParams(%q) = %+v, want limit %d and no cursor", test.query, page, test.limit)
+		}
+		if page.queryLimit() != test.limit+1 {
+			t.Errorf("queryLimit() = %d, want %d", page.queryLimit(), test.limit+1)
+		}
+	}
+}
+
+func TestParsePageParamsCursorRoundTrip(t *testing.T) {
+	key := 12.5
+	cursors := []pageCursor{
+		{ID: 7},
+		{Time: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), ID: 42},
+		{Key: &key, Sticky: true, ID: 3},
+		{Name: "Coastal loop", ID: 9},
+	}
+	for _, cursor := range cursors {
+		page, err := parsePageParams(pageTestContext("/?cursor=" + encodeCursor(cursor)))
+		if err != nil {
+			t.Errorf("parsePageParams failed for cursor %+v: %v", cursor, err)
+			continue
+		}
+		got := page.Cursor
+		if got == nil {
+			t.Errorf("cursor %+v was not decoded", cursor)
+			continue
+		}
+		if !got.Time.Equal(cursor.Time) || got.Name != cursor.Name || got.Sticky != cursor.Sticky || got.ID != cursor.ID ||
+			(got.Key == nil) != (cursor.Key == nil) || (got.Key != nil && *got.Key != *cursor.Key) {
+			t.Errorf("cursor round trip = %+v, want %+v", *got, cursor)
+		}
+		if id := page.cursorID(); !id.Valid || id.Int32 != cursor.ID {
+			t.Errorf("cursorID() = %+v, want %d", id, cursor.ID)
+		}
+		if name := page.cursorName(); !name.Valid || name.String != cursor.Name {
+			t.Errorf("cursorName() = %+v, want %q", name, cursor.Name)
+		}
+		if ts := page.cursorTime(); ts.Valid != !cursor.Time.IsZero() {
+			t.Errorf("cursorTime() = %+v for cursor time %v", ts, cursor.Time)
+		}
+		if k := page.cursorKey(); k.Valid != (cursor.Key != nil) {
+			t.Errorf("cursorKey() = %+v for cursor key %v", k, cursor.Key)
+		}
+	}
+}
+
+func TestParsePageParamsInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"not*base64", "bm90IGpzb24"} {
+		if _, err := parsePageParams(pageTestContext("/?cursor=" + cursor)); err == nil {
+			t.Errorf("parsePageParams accepted cursor %q", cursor)
+		}
+	}
+}
+
+func TestPageParamsWithoutCursor(t *testing.T) {
+	page := pageParams{Limit: defaultPageLimit}
+	if page.cursorID().Valid || page.cursorTime().Valid || page.cursorKey().Valid || page.cursorName().Valid || page.cursorSticky().Valid ||
+		page.cursorScore().Valid {
+		t.Error("cursor accessors are set without a cursor")
+	}
+}
+
+func TestScoreCursorRoundTrip(t *testing.T) {
+	for _, score := range []int32{0, 42, -7} {
+		page, err := parsePageParams(pageTestContext("/?cursor=" + encodeCursor(scoreCursor(score, pgtype.Bool{Bool: true, Valid: true}, 5))))
+		if err != nil {
+			t.Fatalf("parsePageParams failed for score %d: %v", score, err)
+		}
+		if got := page.cursorScore(); !got.Valid || got.Int32 != score || !page.Cursor.Sticky || page.Cursor.ID != 5 {
+			t.Errorf("score cursor decodes to %+v with score %+v, want score %d", page.Cursor, got, score)
+		}
+	}
+}
+
+func TestPageResponse(t *testing.T) {
+	cursorOf := func(id int32) pageCursor { return pageCursor{ID: id} }
+	page := pageParams{Limit: 2}
+
+	response := pageResponse(page, []int32{1, 2, 3}, cursorOf)
+	if items := response["items"].([]int32); len(items) != 2 || items[1] != 2 {
+		t.Errorf("items = %v, want the first 2 rows", items)
+	}
+	next := response["next_cursor"].(*string)
+	if next == nil {
+		t.Fatal("next_cursor is null with more rows than the limit")
+	}
+	following, err := parsePageParams(pageTestContext("/?cursor=" + *next))
+	if err != nil || following.Cursor == nil || following.Cursor.ID != 2 {
+		t.Errorf("next_cursor decodes to %+v, %v, want the last item on the page", following.Cursor, err)
+	}
+
+	response = pageResponse(page, []int32{1, 2}, cursorOf)
+	if next := response["next_cursor"].(*string); next != nil {
+		t.Errorf("next_cursor = %q on the last page, want null", *next)
+	}
+
+	response = pageResponse(page, nil, cursorOf)
+	if items := response["items"].([]int32); items == nil || len(items) != 0 {
+		t.Errorf("items = %#v for no rows, want an empty list", items)
+	}
+}
@@ -2,17 +2,204 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"server/db"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// GetPostsHandler handles GET requests to fetch all posts
+// postSorts are the sort orders of post listings, each with its own query, see GetAllPostsNew in query.sql
+var postSorts = map[string]bool{"new": true, "top": true, "hot": true, "active": true}
+
+// parsePostSort parses the sort query parameter of post listings, defaulting to newest first
+func parsePostSort(c *gin.Context) (string, error) {
+	sort := c.DefaultQuery("sort", "new")
+	if !postSorts[sort] {
+		return "", errors.New("sort must be one of new, top, hot or active")
+	}
+	return sort, nil
+}
+
+// postActivityDate is the time a post is ordered by in the active sort, matching the key of GetAllPostsActive
+func postActivityDate(lastActivityDate, creationDate pgtype.Timestamptz) time.Time {
+	if lastActivityDate.Valid {
+		return lastActivityDate.Time
+	}
+	return creationDate.Time
+}
+
+// scoreCursor returns the cursor of a post in the top sort, which keeps the score as its key
+func scoreCursor(score int32, sticky pgtype.Bool, postID int32) pageCursor {
+	key := float64(score)
+	return pageCursor{Key: &key, Sticky: sticky.Bool, ID: postID}
+}
+
+// getAllPostsPage fetches a page of all posts in the given sort order
+func (h *Handler) getAllPostsPage(ctx context.Context, sort string, page pageParams) (gin.H, error) {
+	switch sort {
+	case "top":
+		posts, err := h.Queries.GetAllPostsTop(ctx, db.GetAllPostsTopParams{
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorScore:  page.cursorScore(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetAllPostsTopRow) pageCursor {
+			return scoreCursor(p.Score, p.IsSticky, p.PostID)
+		}), err
+	case "hot":
+		posts, err := h.Queries.GetAllPostsHot(ctx, db.GetAllPostsHotParams{
+			CursorKey:    page.cursorKey(),
+			CursorSticky: page.cursorSticky(),
+			CursorID:     page.cursorID(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetAllPostsHotRow) pageCursor {
+			return pageCursor{Key: &p.SortKey, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	case "active":
+		posts, err := h.Queries.GetAllPostsActive(ctx, db.GetAllPostsActiveParams{
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorDate:   page.cursorTime(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetAllPostsActiveRow) pageCursor {
+			return pageCursor{Time: postActivityDate(p.LastActivityDate, p.CreationDate), Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	default:
+		posts, err := h.Queries.GetAllPostsNew(ctx, db.GetAllPostsNewParams{
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorDate:   page.cursorTime(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetAllPostsNewRow) pageCursor {
+			return pageCursor{Time: p.CreationDate.Time, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	}
+}
+
+// getCategoryPostsPage fetches a page of the posts in a category in the given sort order
+func (h *Handler) getCategoryPostsPage(ctx context.Context, categoryID pgtype.Int4, sort string, page pageParams) (gin.H, error) {
+	switch sort {
+	case "top":
+		posts, err := h.Queries.GetPostsByCategoryTop(ctx, db.GetPostsByCategoryTopParams{
+			PostCategoryID: categoryID,
+			CursorID:       page.cursorID(),
+			CursorSticky:   page.cursorSticky(),
+			CursorScore:    page.cursorScore(),
+			PageLimit:      page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return scoreCursor(p.Score, p.IsSticky, p.PostID)
+		}), err
+	case "hot":
+		posts, err := h.Queries.GetPostsByCategoryHot(ctx, db.GetPostsByCategoryHotParams{
+			PostCategoryID: categoryID,
+			CursorKey:      page.cursorKey(),
+			CursorSticky:   page.cursorSticky(),
+			CursorID:       page.cursorID(),
+			PageLimit:      page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetPostsByCategoryHotRow) pageCursor {
+			return pageCursor{Key: &p.SortKey, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	case "active":
+		posts, err := h.Queries.GetPostsByCategoryActive(ctx, db.GetPostsByCategoryActiveParams{
+			PostCategoryID: categoryID,
+			CursorID:       page.cursorID(),
+			CursorSticky:   page.cursorSticky(),
+			CursorDate:     page.cursorTime(),
+			PageLimit:      page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return pageCursor{Time: postActivityDate(p.LastActivityDate, p.CreationDate), Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	default:
+		posts, err := h.Queries.GetPostsByCategoryNew(ctx, db.GetPostsByCategoryNewParams{
+			PostCategoryID: categoryID,
+			CursorID:       page.cursorID(),
+			CursorSticky:   page.cursorSticky(),
+			CursorDate:     page.cursorTime(),
+			PageLimit:      page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return pageCursor{Time: p.CreationDate.Time, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	}
+}
+
+// getUserPostsPage fetches a page of the posts by a user in the given sort order
+func (h *Handler) getUserPostsPage(ctx context.Context, userID pgtype.Int4, sort string, page pageParams) (gin.H, error) {
+	switch sort {
+	case "top":
+		posts, err := h.Queries.GetPostsByUserTop(ctx, db.GetPostsByUserTopParams{
+			UserID:       userID,
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorScore:  page.cursorScore(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return scoreCursor(p.Score, p.IsSticky, p.PostID)
+		}), err
+	case "hot":
+		posts, err := h.Queries.GetPostsByUserHot(ctx, db.GetPostsByUserHotParams{
+			UserID:       userID,
+			CursorKey:    page.cursorKey(),
+			CursorSticky: page.cursorSticky(),
+			CursorID:     page.cursorID(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.GetPostsByUserHotRow) pageCursor {
+			return pageCursor{Key: &p.SortKey, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	case "active":
+		posts, err := h.Queries.GetPostsByUserActive(ctx, db.GetPostsByUserActiveParams{
+			UserID:       userID,
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorDate:   page.cursorTime(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return pageCursor{Time: postActivityDate(p.LastActivityDate, p.CreationDate), Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	default:
+		posts, err := h.Queries.GetPostsByUserNew(ctx, db.GetPostsByUserNewParams{
+			UserID:       userID,
+			CursorID:     page.cursorID(),
+			CursorSticky: page.cursorSticky(),
+			CursorDate:   page.cursorTime(),
+			PageLimit:    page.queryLimit(),
+		})
+		return pageResponse(page, posts, func(p db.Post) pageCursor {
+			return pageCursor{Time: p.CreationDate.Time, Sticky: p.IsSticky.Bool, ID: p.PostID}
+		}), err
+	}
+}
+
+// GetPostsHandler handles GET requests to fetch a page of posts, sticky posts first and then in the order given by
+// the sort query parameter
 func (h *Handler) GetPostsHandler(c *gin.Context) {
-	posts, err := h.Queries.GetAllPosts(context.Background())
+	sort, err := parsePostSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	posts, err := h.getAllPostsPage(context.Background(), sort, page)
 	if err != nil {
 		h.Log.Errorf("Unable to fetch posts: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to fetch posts"})
@@ -39,7 +226,8 @@ func (h *Handler) GetPostHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, post)
 }
 
-// GetPostsByCategoryHandler handles GET requests to get all posts for a specific category
+// GetPostsByCategoryHandler handles GET requests to get a page of posts for a specific category,
+// sticky posts first and then in the order given by the sort query parameter
 func (h *Handler) GetPostsByCategoryHandler(c *gin.Context) {
 	postCategoryIDStr := c.Param("postCategoryID")
 	postCategoryID, err := strconv.Atoi(postCategoryIDStr)
@@ -47,8 +235,18 @@ func (h *Handler) GetPostsByCategoryHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post category ID"})
 		return
 	}
+	sort, err := parsePostSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	posts, err := h.Queries.GetPostsByCategory(context.Background(), pgtype.Int4{Int32: int32(postCategoryID), Valid: true})
+	posts, err := h.getCategoryPostsPage(context.Background(), pgtype.Int4{Int32: int32(postCategoryID), Valid: true}, sort, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get posts"})
 		return
@@ -57,7 +255,8 @@ func (h *Handler) GetPostsByCategoryHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, posts)
 }
 
-// GetPostsByUserHandler handles GET requests to get all posts made by a specific user
+// GetPostsByUserHandler handles GET requests to get a page of posts made by a specific user,
+// sticky posts first and then in the order given by the sort query parameter
 func (h *Handler) GetPostsByUserHandler(c *gin.Context) {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.Atoi(userIDStr)
@@ -65,8 +264,18 @@ func (h *Handler) GetPostsByUserHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	sort, err := parsePostSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	posts, err := h.Queries.GetPostsByUser(context.Background(), pgtype.Int4{Int32: int32(userID), Valid: true})
+	posts, err := h.getUserPostsPage(context.Background(), pgtype.Int4{Int32: int32(userID), Valid: true}, sort, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get posts"})
 		return
@@ -106,6 +315,7 @@ func (h *Handler) CreatePostHandler(c *gin.Context) {
 	err := h.Queries.CreatePost(context.Background(), db.CreatePostParams{
 		Title:           req.Title,
 		Content:         req.Content,
+		ContentHtml:     renderContent(req.Content),
 		UserID:          pgtype.Int4{Int32: userID, Valid: true},
 		PostCategoryID:  pgtype.Int4{Int32: int32(1), Valid: true},
 		AdditionalNotes: pgtype.Text{String: req.AdditionalNotes, Valid: true},
@@ -124,9 +334,11 @@ type UpdatePostApiParams struct {
 	Content         string
 	PostCategoryID  int
 	AdditionalNotes string
+	Reason          string // required when a moderator edits another user's post
 }
 
-// UpdatePostHandler handles PUT requests to update an existing post
+// UpdatePostHandler handles PUT requests to update an existing post.
+// Each update that changes the post is recorded as a new revision, and edits by moderators are logged.
 func (h *Handler) UpdatePostHandler(c *gin.Context) {
 	var req UpdatePostApiParams
 
@@ -135,35 +347,88 @@ func (h *Handler) UpdatePostHandler(c *gin.Context) {
 		return
 	}
 
-	userIDInterface, userExists := c.Get("UserID")
-	if !userExists {
+	userID, ok := getUserID(c)
+	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
 		return
 	}
 
-	userID, userOk := userIDInterface.(int32)
-	if !userOk {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	post, err := qtx.GetPostForUpdate(ctx, int32(req.PostID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	moderated, ok := authorizeContentChange(c, post.UserID, req.Reason)
+	if !ok {
 		return
 	}
 
-	err := h.Queries.UpdatePost(context.Background(), db.UpdatePostParams{
-		PostID:          int32(req.PostID),
+	params := db.UpdatePostParams{
+		PostID:          post.PostID,
 		Title:           req.Title,
 		Content:         req.Content,
-		UserID:          pgtype.Int4{Int32: userID, Valid: true},
+		ContentHtml:     renderContent(req.Content),
 		PostCategoryID:  pgtype.Int4{Int32: int32(req.PostCategoryID), Valid: true},
 		AdditionalNotes: pgtype.Text{String: req.AdditionalNotes, Valid: true},
-	})
+	}
+	if params.Title == post.Title && params.Content == post.Content &&
+		params.PostCategoryID == post.PostCategoryID && params.AdditionalNotes == post.AdditionalNotes {
+		c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully", "post": post})
+		return
+	}
+
+	updated, err := qtx.UpdatePost(ctx, params)
 	if err != nil {
+		h.Log.Errorf("Unable to update post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	if err := recordPostRevision(ctx, qtx, post, updated, userID); err != nil {
+		h.Log.Errorf("Unable to record post revision: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	if moderated {
+		err := qtx.CreateLog(ctx, db.CreateLogParams{
+			Action:          logActionEditPost,
+			ModeratorUserID: pgtype.Int4{Int32: userID, Valid: true},
+			AffectedUserID:  post.UserID,
+			PostID:          pgtype.Int4{Int32: post.PostID, Valid: true},
+			Reason:          pgtype.Text{String: req.Reason, Valid: true},
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to create moderation log: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Post updated successfully", "post": updated})
 }
 
-// DeletePostHandler handles DELETE requests to delete a post
+// DeletePostHandler handles DELETE requests to delete a post.
+// Moderators deleting another user's post must give a reason query parameter, which is logged.
 func (h *Handler) DeletePostHandler(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -173,28 +438,63 @@ func (h *Handler) DeletePostHandler(c *gin.Context) {
 		return
 	}
 
-	userIDInterface, userExists := c.Get("UserID")
-	if !userExists {
+	userID, ok := getUserID(c)
+	if !ok {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
 		return
 	}
+	reason := c.Query("reason")
 
-	userID, userOk := userIDInterface.(int32)
-	if !userOk {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to delete post"})
 		return
 	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
 
-	err = h.Queries.DeletePostByPostIdAndUserId(context.Background(), db.DeletePostByPostIdAndUserIdParams{
-		PostID: int32(id),
-		UserID: pgtype.Int4{Int32: userID, Valid: true},
-	})
-
+	post, err := qtx.GetPostForUpdate(ctx, int32(id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
 	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to delete post"})
+		return
+	}
+	moderated, ok := authorizeContentChange(c, post.UserID, reason)
+	if !ok {
+		return
+	}
+
+	if err := qtx.DeletePost(ctx, post.PostID); err != nil {
 		h.Log.Errorf("Unable to delete post: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to delete post"})
 		return
 	}
+	if moderated {
+		err := qtx.CreateLog(ctx, db.CreateLogParams{
+			Action:          logActionDeletePost,
+			ModeratorUserID: pgtype.Int4{Int32: userID, Valid: true},
+			AffectedUserID:  post.UserID,
+			PostID:          pgtype.Int4{Int32: post.PostID, Valid: true},
+			Reason:          pgtype.Text{String: reason, Valid: true},
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to create moderation log: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to delete post"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Unable to delete post"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
 }
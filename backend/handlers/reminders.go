@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"server/db"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// eventReminders are how long before an occurrence starts reminders are sent, from earliest to latest.
+// When an occurrence is already closer than a reminder's lead time, only the latest reminder due is sent.
+var eventReminders = []struct {
+	Type     string
+	LeadTime time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"1h", time.Hour},
+}
+
+// reminderDue returns the reminder to send for an occurrence starting at start, if any
+func reminderDue(now, start time.Time) (string, time.Duration, bool) {
+	until := start.Sub(now)
+	if until <= 0 {
+		return "", 0, false
+	}
+	for i := len(eventReminders) - 1; i >= 0; i-- {
+		if until <= eventReminders[i].LeadTime {
+			return eventReminders[i].Type, eventReminders[i].LeadTime, true
+		}
+	}
+	return "", 0, false
+}
+
+// SendEventReminders notifies every member going to an occurrence that starts within the reminder lead times.
+// Each reminder is recorded in the same transaction as its notification, so restarts and other server instances
+// running the job never send it twice.
+func (h *Handler) SendEventReminders(ctx context.Context, now time.Time) error {
+	to := now.Add(eventReminders[0].LeadTime)
+
+	occurrences, err := h.occurrencesBetween(ctx, now, to)
+	if err != nil {
+		return err
+	}
+
+	for _, occurrence := range occurrences {
+		if occurrence.IsCancelled {
+			continue
+		}
+		reminderType, leadTime, ok := reminderDue(now, occurrence.EventDate.Time)
+		if !ok {
+			continue
+		}
+
+		rsvps, err := h.Queries.GetRSVPsByEventOccurrence(ctx, db.GetRSVPsByEventOccurrenceParams{
+			EventID:        pgtype.Int4{Int32: occurrence.EventID, Valid: true},
+			OccurrenceDate: occurrence.key(),
+		})
+		if err != nil {
+			return err
+		}
+		for _, rsvp := range rsvps {
+			if rsvp.RsvpStatus != RSVPGoing || !rsvp.UserID.Valid {
+				continue
+			}
+			if err := h.sendEventReminder(ctx, occurrence, rsvp.UserID.Int32, reminderType, leadTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sendEventReminder records and sends a single reminder, doing nothing if it has already been sent
+func (h *Handler) sendEventReminder(ctx context.Context, occurrence Occurrence, userID int32, reminderType string, leadTime time.Duration) error {
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	created, err := qtx.CreateEventReminder(ctx, db.CreateEventReminderParams{
+		EventID:        occurrence.EventID,
+		OccurrenceDate: occurrence.key(),
+		UserID:         userID,
+		ReminderType:   reminderType,
+	})
+	if err != nil {
+		return err
+	}
+	if created == 0 {
+		return nil
+	}
+
+	_, err = qtx.CreateNotification(ctx, db.CreateNotificationParams{
+		UserID:           pgtype.Int4{Int32: userID, Valid: true},
+		NotificationType: notificationEventReminder,
+		Content: fmt.Sprintf("Reminder: %s starts within %s (%s) at %s.",
+			occurrence.Title, formatLeadTime(leadTime), occurrence.EventDate.Time.Format(time.RFC1123), occurrence.MeetingPoint),
+		EventID: pgtype.Int4{Int32: occurrence.EventID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func formatLeadTime(d time.Duration) string {
+	hours := int(d.Hours())
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
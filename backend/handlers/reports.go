@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Report statuses. Reports start open, can be taken into review by a moderator, and are resolved as actioned or dismissed.
+const (
+	reportStatusOpen      = "open"
+	reportStatusInReview  = "in_review"
+	reportStatusActioned  = "actioned"
+	reportStatusDismissed = "dismissed"
+)
+
+const maxReportNoteLength = 1000
+
+var reportReasonCategories = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"sexual":         true,
+	"violence":       true,
+	"misinformation": true,
+	"other":          true,
+}
+
+// reportActionTargets maps the actions that can be taken when resolving a report to the target type they apply to.
+// Banning applies to the reported user, or to the author of any reported content.
+var reportActionTargets = map[string]string{
+	logActionLockPost:      "post",
+	logActionDeletePost:    "post",
+	logActionDeleteComment: "comment",
+	logActionDeleteMessage: "message",
+}
+
+type CreateReportApiParams struct {
+	TargetType     string // post, comment, message or user
+	TargetID       int32
+	ReasonCategory string // spam, harassment, hate, sexual, violence, misinformation or other
+	Note           string // optional
+}
+
+// CreateReportHandler handles POST requests to report a post, comment, private message or user to the moderators.
+// Reports of a target that already has an unresolved report are added to it, and each user can report it once.
+func (h *Handler) CreateReportHandler(c *gin.Context) {
+	var req CreateReportApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reportReasonCategories[req.ReasonCategory] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason category"})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > maxReportNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Note is too long"})
+		return
+	}
+	if req.ReasonCategory == "other" && req.Note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required for reports with the other reason category"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	targetUserID, err := h.getReportTargetUser(ctx, req.TargetType, req.TargetID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported " + req.TargetType + " not found"})
+		return
+	}
+	if errors.Is(err, errInvalidReportTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TargetType must be post, comment, message or user"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch reported %s: %v\n", req.TargetType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	if targetUserID.Valid && targetUserID.Int32 == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report yourself or your own content"})
+		return
+	}
+
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	report, err := qtx.UpsertReport(ctx, db.UpsertReportParams{
+		TargetType:   req.TargetType,
+		TargetID:     req.TargetID,
+		TargetUserID: targetUserID,
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	added, err := qtx.CreateReportSubmission(ctx, db.CreateReportSubmissionParams{
+		ReportID:       report.ReportID,
+		ReporterUserID: pgtype.Int4{Int32: userID, Valid: true},
+		ReasonCategory: req.ReasonCategory,
+		Note:           pgtype.Text{String: req.Note, Valid: req.Note != ""},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create report submission: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+	if added == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this " + req.TargetType})
+		return
+	}
+	if _, err := qtx.IncrementReportSubmissionCount(ctx, report.ReportID); err != nil {
+		h.Log.Errorf("Unable to update report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Report submitted successfully", "report_id": report.ReportID})
+}
+
+var errInvalidReportTarget = errors.New("invalid report target type")
+
+// getReportTargetUser checks that the target of a report exists and returns the user it is about:
+// the author of a post or comment, the sender of a private message, or the reported user.
+// Private messages can only be reported by their receiver, and are treated as not found for anyone else.
+func (h *Handler) getReportTargetUser(ctx context.Context, targetType string, targetID, reporterID int32) (pgtype.Int4, error) {
+	switch targetType {
+	case "post":
+		post, err := h.Queries.GetPost(ctx, targetID)
+		return post.UserID, err
+	case "comment":
+		comment, err := h.Queries.GetComment(ctx, targetID)
+		if err == nil && comment.IsDeleted {
+			return pgtype.Int4{}, pgx.ErrNoRows
+		}
+		return comment.UserID, err
+	case "message":
+		message, err := h.Queries.GetPrivateMessageById(ctx, targetID)
+		if err == nil && (!message.ReceiverUserID.Valid || message.ReceiverUserID.Int32 != reporterID) {
+			return pgtype.Int4{}, pgx.ErrNoRows
+		}
+		return message.SenderUserID, err
+	case "user":
+		user, err := h.Queries.GetUser(ctx, targetID)
+		return pgtype.Int4{Int32: user.UserID, Valid: err == nil}, err
+	default:
+		return pgtype.Int4{}, errInvalidReportTarget
+	}
+}
+
+// GetReportsHandler handles GET requests from moderators to get a page of the report queue, most recently reported first.
+// status and target_type filter the queue.
+func (h *Handler) GetReportsHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params := db.GetReportsParams{
+		CursorDate: page.cursorTime(),
+		CursorID:   page.cursorID(),
+		PageLimit:  page.queryLimit(),
+	}
+	if status := c.Query("status"); status != "" {
+		params.Status = pgtype.Text{String: status, Valid: true}
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		params.TargetType = pgtype.Text{String: targetType, Valid: true}
+	}
+
+	reports, err := h.Queries.GetReports(context.Background(), params)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch reports: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, reports, func(report db.GetReportsRow) pageCursor {
+		return pageCursor{Time: report.LastReportedDate.Time, ID: report.ReportID}
+	}))
+}
+
+// GetReportHandler handles GET requests from moderators to get a report with every user's submission
+func (h *Handler) GetReportHandler(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	ctx := context.Background()
+
+	report, err := h.Queries.GetReport(ctx, int32(reportID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report"})
+		return
+	}
+	submissions, err := h.Queries.GetReportSubmissions(ctx, report.ReportID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch report submissions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report"})
+		return
+	}
+	if submissions == nil {
+		submissions = []db.GetReportSubmissionsRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report, "submissions": submissions})
+}
+
+// ReviewReportHandler handles POST requests from moderators to take an unresolved report into review
+func (h *Handler) ReviewReportHandler(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	moderatorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review report"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	report, ok := h.getUnresolvedReportForUpdate(c, qtx, int32(reportID))
+	if !ok {
+		return
+	}
+	report, err = qtx.ReviewReport(ctx, db.ReviewReportParams{
+		ReportID:       report.ReportID,
+		ReviewerUserID: pgtype.Int4{Int32: moderatorID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to review report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review report"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report taken into review successfully", "report": report})
+}
+
+// getUnresolvedReportForUpdate locks the report with the given ID, writing an error response and returning false
+// if it does not exist or has already been resolved
+func (h *Handler) getUnresolvedReportForUpdate(c *gin.Context, q *db.Queries, reportID int32) (db.Report, bool) {
+	report, err := q.GetReportForUpdate(context.Background(), reportID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return db.Report{}, false
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get report"})
+		return db.Report{}, false
+	}
+	if report.Status != reportStatusOpen && report.Status != reportStatusInReview {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report has already been resolved"})
+		return db.Report{}, false
+	}
+	return report, true
+}
+
+type ResolveReportApiParams struct {
+	Status string // actioned or dismissed
+	Action string // optional when actioned: lock_post, delete_post, delete_comment, delete_message or ban_user
+	Note   string // required when an action is taken, and recorded as the reason in the moderation log
+}
+
+// ResolveReportHandler handles POST requests from moderators to resolve a report as actioned or dismissed.
+// An actioned report can also lock or delete the reported content or ban the user it is about,
+// which is recorded in the moderation log.
+func (h *Handler) ResolveReportHandler(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+	var req ResolveReportApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Status != reportStatusActioned && req.Status != reportStatusDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be actioned or dismissed"})
+		return
+	}
+	if req.Action != "" {
+		if req.Status != reportStatusActioned {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only actioned reports can take an action"})
+			return
+		}
+		if req.Note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required to take an action"})
+			return
+		}
+	}
+	moderatorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	report, ok := h.getUnresolvedReportForUpdate(c, qtx, int32(reportID))
+	if !ok {
+		return
+	}
+	if req.Action != "" && !h.applyReportAction(c, qtx, report, req.Action, moderatorID, fmt.Sprintf("Report #%d: %s", report.ReportID, req.Note)) {
+		return
+	}
+
+	report, err = qtx.ResolveReport(ctx, db.ResolveReportParams{
+		ReportID:         report.ReportID,
+		Status:           req.Status,
+		ReviewerUserID:   pgtype.Int4{Int32: moderatorID, Valid: true},
+		ResolutionAction: pgtype.Text{String: req.Action, Valid: req.Action != ""},
+		ResolutionNote:   pgtype.Text{String: req.Note, Valid: req.Note != ""},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to resolve report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report resolved successfully", "report": report})
+}
+
+// applyReportAction takes a moderation action against the target of a report and records it in the moderation log,
+// writing an error response and returning false if it cannot be taken
+func (h *Handler) applyReportAction(c *gin.Context, q *db.Queries, report db.Report, action string, moderatorID int32, reason string) bool {
+	ctx := context.Background()
+	entry := db.CreateLogParams{
+		Action:          action,
+		ModeratorUserID: pgtype.Int4{Int32: moderatorID, Valid: true},
+		AffectedUserID:  report.TargetUserID,
+		Reason:          pgtype.Text{String: reason, Valid: true},
+	}
+
+	if action == logActionBanUser {
+		if !report.TargetUserID.Valid {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reported user not found"})
+			return false
+		}
+		user, ok := h.authorizeSanction(c, q, report.TargetUserID.Int32)
+		if !ok {
+			return false
+		}
+		if err := banUser(ctx, q, user.UserID, reason); err != nil {
+			h.Log.Errorf("Unable to ban user: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+			return false
+		}
+	} else {
+		targetType, ok := reportActionTargets[action]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be lock_post, delete_post, delete_comment, delete_message or ban_user"})
+			return false
+		}
+		if targetType != report.TargetType {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be taken on a reported %s", action, report.TargetType)})
+			return false
+		}
+
+		var err error
+		switch action {
+		case logActionLockPost, logActionDeletePost:
+			var post db.Post
+			post, err = q.GetPostForUpdate(ctx, report.TargetID)
+			if err == nil && action == logActionLockPost {
+				err = q.LockPost(ctx, post.PostID)
+			} else if err == nil {
+				err = q.DeletePost(ctx, post.PostID)
+			}
+			entry.PostID = pgtype.Int4{Int32: report.TargetID, Valid: true}
+		case logActionDeleteComment:
+			var comment db.Comment
+			comment, err = q.GetCommentForUpdate(ctx, report.TargetID)
+			if err == nil && comment.IsDeleted {
+				err = pgx.ErrNoRows
+			}
+			if err == nil {
+				err = deleteComment(ctx, q, comment)
+			}
+			entry.PostID = comment.PostID
+			entry.CommentID = pgtype.Int4{Int32: report.TargetID, Valid: true}
+		case logActionDeleteMessage:
+			_, err = q.GetPrivateMessageById(ctx, report.TargetID)
+			if err == nil {
+				err = q.DeletePrivateMessage(ctx, report.TargetID)
+			}
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reported " + report.TargetType + " no longer exists"})
+			return false
+		}
+		if err != nil {
+			h.Log.Errorf("Unable to %s: %v\n", action, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+			return false
+		}
+	}
+
+	if err := q.CreateLog(ctx, entry); err != nil {
+		h.Log.Errorf("Unable to create moderation log: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve report"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"server/diff"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// recordPostRevision records the updated version of a post as its latest revision.
+// Posts that have never been edited have no revisions yet, so their original version is recorded first as revision 1.
+func recordPostRevision(ctx context.Context, q *db.Queries, before, after db.Post, editorID int32) error {
+	latest, err := q.GetLatestPostRevisionNumber(ctx, before.PostID)
+	if err != nil {
+		return err
+	}
+	if latest == 0 {
+		_, err := q.CreatePostRevision(ctx, db.CreatePostRevisionParams{
+			PostID:          before.PostID,
+			RevisionNumber:  1,
+			Title:           before.Title,
+			Content:         before.Content,
+			PostCategoryID:  before.PostCategoryID,
+			AdditionalNotes: before.AdditionalNotes,
+			EditorUserID:    before.UserID,
+			CreationDate:    before.CreationDate,
+		})
+		if err != nil {
+			return err
+		}
+		latest = 1
+	}
+
+	_, err = q.CreatePostRevision(ctx, db.CreatePostRevisionParams{
+		PostID:          after.PostID,
+		RevisionNumber:  latest + 1,
+		Title:           after.Title,
+		Content:         after.Content,
+		PostCategoryID:  after.PostCategoryID,
+		AdditionalNotes: after.AdditionalNotes,
+		EditorUserID:    pgtype.Int4{Int32: editorID, Valid: true},
+		CreationDate:    after.EditedAt,
+	})
+	return err
+}
+
+// getPostForRevisions fetches the post in the id path parameter, writing an error response and returning false
+// unless the logged in user is its author or a moderator
+func (h *Handler) getPostForRevisions(c *gin.Context) (db.Post, bool) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return db.Post{}, false
+	}
+
+	post, err := h.Queries.GetPost(context.Background(), int32(postID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return db.Post{}, false
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post"})
+		return db.Post{}, false
+	}
+
+	userID, ok := getUserID(c)
+	isAuthor := ok && post.UserID.Valid && post.UserID.Int32 == userID
+	if !isAuthor && !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author and moderators can see the revisions of a post"})
+		return db.Post{}, false
+	}
+	return post, true
+}
+
+// GetPostRevisionsHandler handles GET requests to list the revisions of a post, oldest first.
+// A post that has never been edited has no revisions.
+func (h *Handler) GetPostRevisionsHandler(c *gin.Context) {
+	post, ok := h.getPostForRevisions(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.Queries.GetPostRevisionsByPostID(context.Background(), post.PostID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post revisions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post revisions"})
+		return
+	}
+	if revisions == nil {
+		revisions = []db.GetPostRevisionsByPostIDRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// GetPostRevisionDiffHandler handles GET requests to get a unified diff between two revisions of a post.
+// The from and to query parameters are revision numbers, defaulting to the latest revision and the one before it.
+func (h *Handler) GetPostRevisionDiffHandler(c *gin.Context) {
+	post, ok := h.getPostForRevisions(c)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+
+	latest, err := h.Queries.GetLatestPostRevisionNumber(ctx, post.PostID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post revisions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post revisions"})
+		return
+	}
+	if latest < 2 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post has not been edited"})
+		return
+	}
+
+	to, err := parseRevisionQuery(c, "to", latest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := parseRevisionQuery(c, "from", to-1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromRevision, err := h.Queries.GetPostRevision(ctx, db.GetPostRevisionParams{PostID: post.PostID, RevisionNumber: from})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Revision %d not found", from)})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post revision: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post revision"})
+		return
+	}
+	toRevision, err := h.Queries.GetPostRevision(ctx, db.GetPostRevisionParams{PostID: post.PostID, RevisionNumber: to})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Revision %d not found", to)})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post revision: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post revision"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "diff": diffRevisions(fromRevision, toRevision)})
+}
+
+// parseRevisionQuery parses an optional revision number query parameter
+func parseRevisionQuery(c *gin.Context, key string, fallback int32) (int32, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("invalid %s revision", key)
+	}
+	return int32(number), nil
+}
+
+// diffRevisions returns the unified diff of each field that differs between two revisions,
+// with the field name as the file name of its diff
+func diffRevisions(from, to db.PostRevision) string {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"content", from.Content, to.Content},
+		{"category", formatOptionalID(from.PostCategoryID), formatOptionalID(to.PostCategoryID)},
+		{"additional_notes", from.AdditionalNotes.String, to.AdditionalNotes.String},
+	}
+
+	var out string
+	for _, f := range fields {
+		out += diff.Unified(
+			fmt.Sprintf("revision %d/%s", from.RevisionNumber, f.name),
+			fmt.Sprintf("revision %d/%s", to.RevisionNumber, f.name),
+			f.from, f.to,
+		)
+	}
+	return out
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"server/db"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type CreateRouteApiParams struct {
+	Name          string
+	Description   string
+	StartLocation string
+	EndLocation   string
+	Distance      *float64
+	ElevationGain *int32
+	RouteMapLink  string
+}
+
+type UpdateRouteApiParams struct {
+	RouteID       int
+	Name          string
+	Description   string
+	StartLocation string
+	EndLocation   string
+	Distance      *float64
+	ElevationGain *int32
+	RouteMapLink  string
+}
+
+// validateRouteInput checks the fields shared by route creation and update
+func validateRouteInput(name, startLocation, endLocation string, distance *float64, elevationGain *int32) error {
+	if name == "" || startLocation == "" || endLocation == "" {
+		return errors.New("name, start location and end location are required")
+	}
+	if distance != nil && *distance < 0 {
+		return errors.New("distance cannot be negative")
+	}
+	if elevationGain != nil && *elevationGain < 0 {
+		return errors.New("elevation gain cannot be negative")
+	}
+	return nil
+}
+
+func float8FromPtr(f *float64) pgtype.Float8 {
+	if f == nil {
+		return pgtype.Float8{}
+	}
+	return pgtype.Float8{Float64: *f, Valid: true}
+}
+
+func int4FromPtr(i *int32) pgtype.Int4 {
+	if i == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: *i, Valid: true}
+}
+
+// GetRoutesHandler handles GET requests to fetch a page of routes, ordered by name or by distance with ?sort=distance
+func (h *Handler) GetRoutesHandler(c *gin.Context) {
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("sort") == "distance" {
+		routes, err := h.Queries.GetRoutesByDistance(context.Background(), db.GetRoutesByDistanceParams{
+			CursorID:       page.cursorID(),
+			CursorDistance: page.cursorKey(),
+			PageLimit:      page.queryLimit(),
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to fetch routes: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get routes"})
+			return
+		}
+		c.JSON(http.StatusOK, pageResponse(page, routes, func(r db.Route) pageCursor {
+			cursor := pageCursor{ID: r.RouteID}
+			if r.Distance.Valid {
+				cursor.Key = &r.Distance.Float64
+			}
+			return cursor
+		}))
+		return
+	}
+
+	routes, err := h.Queries.GetRoutes(context.Background(), db.GetRoutesParams{
+		CursorID:   page.cursorID(),
+		CursorName: page.cursorName(),
+		PageLimit:  page.queryLimit(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to fetch routes: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get routes"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse(page, routes, func(r db.Route) pageCursor {
+		return pageCursor{Name: r.Name, ID: r.RouteID}
+	}))
+}
+
+// GetRouteHandler handles GET requests to fetch a single route
+func (h *Handler) GetRouteHandler(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID"})
+		return
+	}
+
+	route, err := h.Queries.GetRouteByID(context.Background(), int32(routeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get route"})
+		return
+	}
+	c.JSON(http.StatusOK, route)
+}
+
+// GetRoutesByUserHandler handles GET requests to get a page of the routes created by a specific user, ordered by name
+func (h *Handler) GetRoutesByUserHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	routes, err := h.Queries.GetRoutesByUserID(context.Background(), db.GetRoutesByUserIDParams{
+		UserID:     pgtype.Int4{Int32: int32(userID), Valid: true},
+		CursorID:   page.cursorID(),
+		CursorName: page.cursorName(),
+		PageLimit:  page.queryLimit(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get routes"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse(page, routes, func(r db.Route) pageCursor {
+		return pageCursor{Name: r.Name, ID: r.RouteID}
+	}))
+}
+
+// CreateRouteHandler handles POST requests to create a new route owned by the logged in user
+func (h *Handler) CreateRouteHandler(c *gin.Context) {
+	var req CreateRouteApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateRouteInput(req.Name, req.StartLocation, req.EndLocation, req.Distance, req.ElevationGain); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	route, err := h.Queries.CreateRoute(context.Background(), db.CreateRouteParams{
+		Name:          req.Name,
+		Description:   pgtype.Text{String: req.Description, Valid: true},
+		StartLocation: req.StartLocation,
+		EndLocation:   req.EndLocation,
+		Distance:      float8FromPtr(req.Distance),
+		ElevationGain: int4FromPtr(req.ElevationGain),
+		RouteMapLink:  pgtype.Text{String: req.RouteMapLink, Valid: req.RouteMapLink != ""},
+		UserID:        pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create route"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route created successfully", "route": route})
+}
+
+// UpdateRouteHandler handles PUT requests to update a route owned by the logged in user
+func (h *Handler) UpdateRouteHandler(c *gin.Context) {
+	var req UpdateRouteApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateRouteInput(req.Name, req.StartLocation, req.EndLocation, req.Distance, req.ElevationGain); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	params := db.UpdateRouteByRouteIdAndUserIdParams{
+		RouteID:       int32(req.RouteID),
+		UserID:        pgtype.Int4{Int32: userID, Valid: true},
+		Name:          req.Name,
+		Description:   pgtype.Text{String: req.Description, Valid: true},
+		StartLocation: req.StartLocation,
+		EndLocation:   req.EndLocation,
+		Distance:      float8FromPtr(req.Distance),
+		ElevationGain: int4FromPtr(req.ElevationGain),
+		RouteMapLink:  pgtype.Text{String: req.RouteMapLink, Valid: req.RouteMapLink != ""},
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	// lock the route so that a GPX track uploaded concurrently is not overwritten by the distance and elevation
+	// gain given here
+	existing, err := qtx.GetRouteByIDForUpdate(ctx, int32(req.RouteID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found or not owned by you"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+
+	// routes with an uploaded GPX track keep the distance and elevation gain derived from the track
+	pointCount, err := qtx.CountRoutePointsByRouteID(ctx, existing.RouteID)
+	if err != nil {
+		h.Log.Errorf("Unable to count route points: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+	if pointCount > 0 {
+		params.Distance = existing.Distance
+		params.ElevationGain = existing.ElevationGain
+	}
+
+	// ownership is checked by the update itself, which only matches routes owned by the user
+	route, err := qtx.UpdateRouteByRouteIdAndUserId(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found or not owned by you"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to update route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route updated successfully", "route": route})
+}
+
+// DeleteRouteHandler handles DELETE requests to delete a route owned by the logged in user
+func (h *Handler) DeleteRouteHandler(c *gin.Context) {
+	routeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	deleted, err := h.Queries.DeleteRouteByRouteIdAndUserId(context.Background(), db.DeleteRouteByRouteIdAndUserIdParams{
+		RouteID: int32(routeID),
+		UserID:  pgtype.Int4{Int32: userID, Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to delete route: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete route"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found or not owned by you"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route deleted successfully"})
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	RSVPGoing      = "going"
+	RSVPMaybe      = "maybe"
+	RSVPDeclined   = "declined"
+	RSVPWaitlisted = "waitlisted"
+	RSVPAttended   = "attended"
+)
+
+var rsvpStatuses = []string{RSVPGoing, RSVPMaybe, RSVPDeclined, RSVPWaitlisted, RSVPAttended}
+
+// rsvpTransitions lists the statuses a member may move their own RSVP to from each status.
+// An empty from status is a member without an RSVP. Waitlisted and attended are set by the server
+// and the event organiser respectively, so members can never choose them directly. Asking to go
+// to a full event lands the member on the waitlist instead.
+var rsvpTransitions = map[string][]string{
+	"":             {RSVPGoing, RSVPMaybe, RSVPDeclined},
+	RSVPGoing:      {RSVPMaybe, RSVPDeclined},
+	RSVPMaybe:      {RSVPGoing, RSVPDeclined},
+	RSVPDeclined:   {RSVPGoing, RSVPMaybe},
+	RSVPWaitlisted: {RSVPGoing, RSVPMaybe, RSVPDeclined},
+	RSVPAttended:   {},
+}
+
+var (
+	errInvalidRSVPTransition = errors.New("invalid RSVP")
+	errEventNotFound         = errors.New("event not found")
+)
+
+// validateRSVPTransition checks that a member may change their RSVP status from one status to another
+func validateRSVPTransition(from, to string) error {
+	if from == to {
+		return nil
+	}
+	for _, allowed := range rsvpTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	if from == "" {
+		return fmt.Errorf("%w: cannot RSVP as %s", errInvalidRSVPTransition, to)
+	}
+	return fmt.Errorf("%w: cannot change RSVP from %s to %s", errInvalidRSVPTransition, from, to)
+}
+
+// saveRSVP changes a member's RSVP to an event occurrence inside a transaction that locks the event, so that
+// simultaneous RSVPs cannot overfill it. A request to go to a full occurrence is stored as waitlisted,
+// and a member leaving the going list promotes the next member on the waitlist.
+func (h *Handler) saveRSVP(ctx context.Context, eventID, userID int32, occurrenceDate *time.Time, requested string) (db.Rsvp, error) {
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		return db.Rsvp{}, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	event, err := qtx.GetEventByIDForUpdate(ctx, eventID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Rsvp{}, errEventNotFound
+	}
+	if err != nil {
+		return db.Rsvp{}, err
+	}
+	occurrence, err := resolveOccurrence(ctx, qtx, event, occurrenceDate)
+	if err != nil {
+		return db.Rsvp{}, err
+	}
+	if occurrence.IsCancelled && requested == RSVPGoing {
+		return db.Rsvp{}, fmt.Errorf("%w: occurrence is cancelled", errInvalidOccurrence)
+	}
+
+	current := ""
+	existing, err := qtx.GetRSVPByEventIDAndUserID(ctx, db.GetRSVPByEventIDAndUserIDParams{
+		EventID:        pgtype.Int4{Int32: eventID, Valid: true},
+		UserID:         pgtype.Int4{Int32: userID, Valid: true},
+		OccurrenceDate: occurrence.key(),
+	})
+	if err == nil {
+		current = existing.RsvpStatus
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return db.Rsvp{}, err
+	}
+
+	if err := validateRSVPTransition(current, requested); err != nil {
+		return db.Rsvp{}, err
+	}
+
+	status := requested
+	if requested == RSVPGoing && current != RSVPGoing && event.Capacity.Valid {
+		going, err := qtx.CountRSVPsByEventIDAndStatus(ctx, db.CountRSVPsByEventIDAndStatusParams{
+			EventID:        pgtype.Int4{Int32: eventID, Valid: true},
+			RsvpStatus:     RSVPGoing,
+			OccurrenceDate: occurrence.key(),
+		})
+		if err != nil {
+			return db.Rsvp{}, err
+		}
+		if going >= int64(event.Capacity.Int32) {
+			status = RSVPWaitlisted
+		}
+	}
+
+	// leave unchanged RSVPs alone so members keep their place on the waitlist
+	if status == current {
+		return existing, tx.Commit(ctx)
+	}
+
+	rsvp, err := qtx.UpsertRSVP(ctx, db.UpsertRSVPParams{
+		EventID:        pgtype.Int4{Int32: eventID, Valid: true},
+		UserID:         pgtype.Int4{Int32: userID, Valid: true},
+		RsvpStatus:     status,
+		OccurrenceDate: occurrence.key(),
+	})
+	if err != nil {
+		return db.Rsvp{}, err
+	}
+
+	if current == RSVPGoing {
+		if err := promoteWaitlist(ctx, qtx, occurrence); err != nil {
+			return db.Rsvp{}, err
+		}
+	}
+
+	return rsvp, tx.Commit(ctx)
+}
+
+// promoteWaitlist moves members from the front of the waitlist to going while the occurrence has free spots,
+// notifying each promoted member. It must run in a transaction holding the lock from GetEventByIDForUpdate.
+func promoteWaitlist(ctx context.Context, qtx *db.Queries, occurrence Occurrence) error {
+	if occurrence.IsCancelled {
+		return nil
+	}
+	for {
+		if occurrence.Capacity.Valid {
+			going, err := qtx.CountRSVPsByEventIDAndStatus(ctx, db.CountRSVPsByEventIDAndStatusParams{
+				EventID:        pgtype.Int4{Int32: occurrence.EventID, Valid: true},
+				RsvpStatus:     RSVPGoing,
+				OccurrenceDate: occurrence.key(),
+			})
+			if err != nil {
+				return err
+			}
+			if going >= int64(occurrence.Capacity.Int32) {
+				return nil
+			}
+		}
+
+		next, err := qtx.GetNextWaitlistedRSVPForUpdate(ctx, db.GetNextWaitlistedRSVPForUpdateParams{
+			EventID:        pgtype.Int4{Int32: occurrence.EventID, Valid: true},
+			OccurrenceDate: occurrence.key(),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := qtx.UpdateRSVP(ctx, db.UpdateRSVPParams{RsvpID: next.RsvpID, RsvpStatus: RSVPGoing}); err != nil {
+			return err
+		}
+
+		_, err = qtx.CreateNotification(ctx, db.CreateNotificationParams{
+			UserID:           next.UserID,
+			NotificationType: notificationWaitlistPromoted,
+			Content: fmt.Sprintf("A spot opened up on %s (%s). You have been moved off the waitlist and are now going.",
+				occurrence.Title, occurrence.EventDate.Time.Format(time.RFC1123)),
+			EventID: pgtype.Int4{Int32: occurrence.EventID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type UpsertRSVPApiParams struct {
+	RsvpStatus string
+}
+
+// getRSVPCounts returns the number of RSVPs for an event occurrence for every status, including statuses nobody has
+func getRSVPCounts(ctx context.Context, q *db.Queries, eventID int32, occurrenceDate pgtype.Timestamptz) (map[string]int64, error) {
+	rows, err := q.CountRSVPsByEventIDGroupedByStatus(ctx, db.CountRSVPsByEventIDGroupedByStatusParams{
+		EventID:        pgtype.Int4{Int32: eventID, Valid: true},
+		OccurrenceDate: occurrenceDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rsvpStatuses))
+	for _, status := range rsvpStatuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.RsvpStatus] = row.Count
+	}
+	return counts, nil
+}
+
+// GetEventRSVPsHandler handles GET requests to get a page of the RSVPs for an event occurrence, in the order they were
+// made, along with the counts by status and the ordered waitlist. Recurring events take the occurrence query parameter.
+func (h *Handler) GetEventRSVPsHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	occurrenceDate, err := parseOccurrenceQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := context.Background()
+	event, err := h.Queries.GetEventByID(ctx, int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	occurrence, err := resolveOccurrence(ctx, h.Queries, event, occurrenceDate)
+	if errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+
+	rsvps, err := h.Queries.GetRSVPsByEventOccurrencePage(ctx, db.GetRSVPsByEventOccurrencePageParams{
+		EventID:        pgtype.Int4{Int32: int32(eventID), Valid: true},
+		OccurrenceDate: occurrence.key(),
+		CursorID:       page.cursorID(),
+		PageLimit:      page.queryLimit(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get RSVPs"})
+		return
+	}
+
+	counts, err := getRSVPCounts(ctx, h.Queries, int32(eventID), occurrence.key())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get RSVP counts"})
+		return
+	}
+
+	waitlist, err := h.Queries.GetWaitlistedRSVPsByEventID(ctx, db.GetWaitlistedRSVPsByEventIDParams{
+		EventID:        pgtype.Int4{Int32: int32(eventID), Valid: true},
+		OccurrenceDate: occurrence.key(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get waitlist"})
+		return
+	}
+
+	response := pageResponse(page, rsvps, func(rsvp db.Rsvp) pageCursor {
+		return pageCursor{ID: rsvp.RsvpID}
+	})
+	response["counts"] = counts
+	response["waitlist"] = waitlist
+	c.JSON(http.StatusOK, response)
+}
+
+// waitlistPosition returns the 1-based position of an RSVP on its event's waitlist, or 0 if it is not waitlisted
+func (h *Handler) waitlistPosition(ctx context.Context, rsvp db.Rsvp) (int, error) {
+	if rsvp.RsvpStatus != RSVPWaitlisted {
+		return 0, nil
+	}
+	waitlist, err := h.Queries.GetWaitlistedRSVPsByEventID(ctx, db.GetWaitlistedRSVPsByEventIDParams{
+		EventID:        rsvp.EventID,
+		OccurrenceDate: rsvp.OccurrenceDate,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, waiting := range waitlist {
+		if waiting.RsvpID == rsvp.RsvpID {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// GetRSVPsByUserHandler handles GET requests to get a page of the RSVPs made by a specific user, newest first
+func (h *Handler) GetRSVPsByUserHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rsvps, err := h.Queries.GetRSVPsByUserIDPage(context.Background(), db.GetRSVPsByUserIDPageParams{
+		UserID:    pgtype.Int4{Int32: int32(userID), Valid: true},
+		CursorID:  page.cursorID(),
+		PageLimit: page.queryLimit(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get RSVPs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse(page, rsvps, func(rsvp db.Rsvp) pageCursor {
+		return pageCursor{ID: rsvp.RsvpID}
+	}))
+}
+
+// UpsertRSVPHandler handles POST and PUT requests to create or change the logged in user's RSVP to an event.
+// Recurring events take the occurrence query parameter.
+func (h *Handler) UpsertRSVPHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	occurrenceDate, err := parseOccurrenceQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req UpsertRSVPApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	rsvp, err := h.saveRSVP(ctx, int32(eventID), userID, occurrenceDate, req.RsvpStatus)
+	if errors.Is(err, errEventNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if errors.Is(err, errInvalidRSVPTransition) || errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to save RSVP: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RSVP"})
+		return
+	}
+
+	counts, err := getRSVPCounts(ctx, h.Queries, int32(eventID), rsvp.OccurrenceDate)
+	if err != nil {
+		h.Log.Errorf("Unable to count RSVPs: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get RSVP counts"})
+		return
+	}
+
+	position, err := h.waitlistPosition(ctx, rsvp)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch waitlist: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get waitlist position"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "RSVP saved successfully", "rsvp": rsvp, "counts": counts, "waitlist_position": position})
+}
+
+// MarkRSVPAttendedHandler handles POST requests from the event organiser to mark a going member as attended once the event has started.
+// Recurring events take the occurrence query parameter.
+func (h *Handler) MarkRSVPAttendedHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	attendeeID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	occurrenceDate, err := parseOccurrenceQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := context.Background()
+	event, err := h.Queries.GetEventByID(ctx, int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	if !event.CreatorUserID.Valid || event.CreatorUserID.Int32 != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the event organiser can mark attendance"})
+		return
+	}
+	occurrence, err := resolveOccurrence(ctx, h.Queries, event, occurrenceDate)
+	if errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get occurrence"})
+		return
+	}
+	if occurrence.IsCancelled || occurrence.EventDate.Time.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attendance can only be marked once the event has started"})
+		return
+	}
+
+	rsvp, err := h.Queries.GetRSVPByEventIDAndUserID(ctx, db.GetRSVPByEventIDAndUserIDParams{
+		EventID:        pgtype.Int4{Int32: int32(eventID), Valid: true},
+		UserID:         pgtype.Int4{Int32: int32(attendeeID), Valid: true},
+		OccurrenceDate: occurrence.key(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "RSVP not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get RSVP"})
+		return
+	}
+	if rsvp.RsvpStatus != RSVPGoing && rsvp.RsvpStatus != RSVPAttended {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot change RSVP from %s to %s", rsvp.RsvpStatus, RSVPAttended)})
+		return
+	}
+
+	rsvp, err = h.Queries.UpdateRSVP(ctx, db.UpdateRSVPParams{RsvpID: rsvp.RsvpID, RsvpStatus: RSVPAttended})
+	if err != nil {
+		h.Log.Errorf("Unable to update RSVP: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RSVP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attendance recorded", "rsvp": rsvp})
+}
+
+// DeleteRSVPHandler handles DELETE requests to withdraw the logged in user's RSVP to an event.
+// Recurring events take the occurrence query parameter.
+func (h *Handler) DeleteRSVPHandler(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	occurrenceDate, err := parseOccurrenceQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	event, err := qtx.GetEventByIDForUpdate(ctx, int32(eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+	occurrence, err := resolveOccurrence(ctx, qtx, event, occurrenceDate)
+	if errors.Is(err, errInvalidOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+
+	deleted, err := qtx.DeleteRSVPByEventIDAndUserID(ctx, db.DeleteRSVPByEventIDAndUserIDParams{
+		EventID:        pgtype.Int4{Int32: int32(eventID), Valid: true},
+		UserID:         pgtype.Int4{Int32: userID, Valid: true},
+		OccurrenceDate: occurrence.key(),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to delete RSVP: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "RSVP not found"})
+		return
+	}
+
+	if err := promoteWaitlist(ctx, qtx, occurrence); err != nil {
+		h.Log.Errorf("Unable to promote waitlist: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete RSVP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "RSVP deleted successfully"})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"server/db"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxSearchQueryLength = 200
+
+// highlightEscaper restores the <mark> tags added by ts_headline after the rest of a snippet has been escaped
+var highlightEscaper = strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>")
+
+// sanitiseHighlight escapes a ts_headline result so that only its <mark> highlights are HTML
+func sanitiseHighlight(s string) string {
+	return highlightEscaper.Replace(html.EscapeString(s))
+}
+
+// SearchHandler handles GET requests to search posts or comments.
+// q accepts web search syntax: "quoted phrases", OR and -excluded words.
+// type is posts (the default) or comments, and results can be filtered by category_id and author_id.
+func (h *Handler) SearchHandler(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	if len(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is too long"})
+		return
+	}
+	categoryID, err := parseOptionalIDQuery(c, "category_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	authorID, err := parseOptionalIDQuery(c, "author_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.DefaultQuery("type", "posts") {
+	case "posts":
+		posts, err := h.Queries.SearchPosts(context.Background(), db.SearchPostsParams{
+			Query:      query,
+			CategoryID: categoryID,
+			AuthorID:   authorID,
+			CursorRank: page.cursorKey(),
+			CursorID:   page.cursorID(),
+			PageLimit:  page.queryLimit(),
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to search posts: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search posts"})
+			return
+		}
+		for i := range posts {
+			posts[i].TitleHighlight = sanitiseHighlight(posts[i].TitleHighlight)
+			posts[i].Snippet = sanitiseHighlight(posts[i].Snippet)
+		}
+		c.JSON(http.StatusOK, pageResponse(page, posts, func(post db.SearchPostsRow) pageCursor {
+			return pageCursor{Key: &post.Rank, ID: post.PostID}
+		}))
+	case "comments":
+		comments, err := h.Queries.SearchComments(context.Background(), db.SearchCommentsParams{
+			Query:      query,
+			CategoryID: categoryID,
+			AuthorID:   authorID,
+			CursorRank: page.cursorKey(),
+			CursorID:   page.cursorID(),
+			PageLimit:  page.queryLimit(),
+		})
+		if err != nil {
+			h.Log.Errorf("Unable to search comments: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search comments"})
+			return
+		}
+		for i := range comments {
+			comments[i].Snippet = sanitiseHighlight(comments[i].Snippet)
+		}
+		c.JSON(http.StatusOK, pageResponse(page, comments, func(comment db.SearchCommentsRow) pageCursor {
+			return pageCursor{Key: &comment.Rank, ID: comment.CommentID}
+		}))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be posts or comments"})
+	}
+}
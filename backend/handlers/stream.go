@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db"
+	"server/stream"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// streamHeartbeatInterval is how often an idle stream sends a comment, so that proxies do not close the connection
+const streamHeartbeatInterval = 25 * time.Second
+
+// streamTicketLifetime is how long a stream ticket can be used after it is issued
+const streamTicketLifetime = 30 * time.Second
+
+// streamReplayBatch is the number of missed rows of each kind fetched at a time when a stream resumes
+const streamReplayBatch = 100
+
+// streamReplayGrace is how long before a resumed stream's cursor time rows are replayed from, even with IDs below
+// the cursor. IDs are taken when rows are inserted but rows are announced when they are committed, so a row can be
+// announced after rows with higher IDs, as long as the transaction inserting it runs for less than this.
+const streamReplayGrace = time.Minute
+
+// streamCursor is the newest notification, private message and comment a stream has sent, and when it last sent one.
+// It is sent as the ID of every event, so that a reconnecting client resumes where it left off by sending it back in
+// Last-Event-ID. Every row the stream has not sent has an ID above the cursor, or was created after Time less
+// streamReplayGrace, so resuming may send a row again and clients drop the rows they already have by their ID.
+type streamCursor struct {
+	NotificationID int32
+	MessageID      int32
+	CommentID      int32
+	Time           int64 // Unix time
+}
+
+func (cursor streamCursor) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", cursor.NotificationID, cursor.MessageID, cursor.CommentID, cursor.Time)
+}
+
+func parseStreamCursor(s string) (streamCursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return streamCursor{}, errors.New("invalid Last-Event-ID")
+	}
+	var ids [3]int32
+	for i, part := range parts[:3] {
+		id, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || id < 0 {
+			return streamCursor{}, errors.New("invalid Last-Event-ID")
+		}
+		ids[i] = int32(id)
+	}
+	unix, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || unix < 0 {
+		return streamCursor{}, errors.New("invalid Last-Event-ID")
+	}
+	return streamCursor{NotificationID: ids[0], MessageID: ids[1], CommentID: ids[2], Time: unix}, nil
+}
+
+// advance moves the cursor past a row sent in an event of the given type
+func (cursor *streamCursor) advance(eventType string, id int32) {
+	switch eventType {
+	case stream.EventNotification:
+		cursor.NotificationID = max(cursor.NotificationID, id)
+	case stream.EventMessage:
+		cursor.MessageID = max(cursor.MessageID, id)
+	case stream.EventComment:
+		cursor.CommentID = max(cursor.CommentID, id)
+	}
+}
+
+// latestStreamCursor returns the cursor of a new stream, which only sends what happens after it connects
+func (h *Handler) latestStreamCursor(ctx context.Context, now time.Time) (streamCursor, error) {
+	notificationID, err := h.Queries.GetLatestNotificationID(ctx)
+	if err != nil {
+		return streamCursor{}, err
+	}
+	messageID, err := h.Queries.GetLatestPrivateMessageID(ctx)
+	if err != nil {
+		return streamCursor{}, err
+	}
+	commentID, err := h.Queries.GetLatestCommentID(ctx)
+	if err != nil {
+		return streamCursor{}, err
+	}
+	return streamCursor{NotificationID: notificationID, MessageID: messageID, CommentID: commentID, Time: now.Unix()}, nil
+}
+
+// writeStreamEvent writes a server-sent event and flushes it to the client
+func writeStreamEvent(c *gin.Context, id string, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// streamRow identifies a row sent on a stream by the type of its event and its ID
+type streamRow struct {
+	Type string
+	ID   int32
+}
+
+// writeStreamRow sends a row, moving the cursor past it and recording it as sent
+func writeStreamRow(c *gin.Context, cursor *streamCursor, sent map[streamRow]bool, eventType string, id int32, data any) error {
+	cursor.advance(eventType, id)
+	sent[streamRow{Type: eventType, ID: id}] = true
+	return writeStreamEvent(c, cursor.String(), eventType, data)
+}
+
+// replayStream sends the notifications, private messages and watched post comments a user missed since cursor,
+// recording them in sent. The cursor keeps its time until the replay is over, so that a stream which drops during
+// it resumes with the same grace window.
+func (h *Handler) replayStream(ctx context.Context, c *gin.Context, userID int32, cursor *streamCursor, sent map[streamRow]bool) error {
+	started := time.Now()
+	resume := *cursor
+	since := pgtype.Timestamptz{Time: time.Unix(resume.Time, 0).Add(-streamReplayGrace), Valid: true}
+
+	var pageAfterID int32
+	for {
+		notifications, err := h.Queries.GetNotificationsByUserIdAfter(ctx, db.GetNotificationsByUserIdAfterParams{
+			UserID:      userID,
+			AfterID:     resume.NotificationID,
+			Since:       since,
+			PageAfterID: pageAfterID,
+			PageLimit:   streamReplayBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, notification := range notifications {
+			pageAfterID = notification.NotificationID
+			if err := writeStreamRow(c, cursor, sent, stream.EventNotification, notification.NotificationID, notification); err != nil {
+				return err
+			}
+		}
+		if len(notifications) < streamReplayBatch {
+			break
+		}
+	}
+
+	pageAfterID = 0
+	for {
+		messages, err := h.Queries.GetPrivateMessagesByUserIdAfter(ctx, db.GetPrivateMessagesByUserIdAfterParams{
+			UserID:      userID,
+			AfterID:     resume.MessageID,
+			Since:       since,
+			PageAfterID: pageAfterID,
+			PageLimit:   streamReplayBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			pageAfterID = message.MessageID
+			if err := writeStreamRow(c, cursor, sent, stream.EventMessage, message.MessageID, message); err != nil {
+				return err
+			}
+		}
+		if len(messages) < streamReplayBatch {
+			break
+		}
+	}
+
+	pageAfterID = 0
+	for {
+		comments, err := h.Queries.GetWatchedCommentsByUserIdAfter(ctx, db.GetWatchedCommentsByUserIdAfterParams{
+			UserID:      userID,
+			AfterID:     resume.CommentID,
+			Since:       since,
+			PageAfterID: pageAfterID,
+			PageLimit:   streamReplayBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			pageAfterID = comment.CommentID
+			if comment.UserID.Int32 == userID {
+				continue
+			}
+			if err := writeStreamRow(c, cursor, sent, stream.EventComment, comment.CommentID, comment); err != nil {
+				return err
+			}
+		}
+		if len(comments) < streamReplayBatch {
+			break
+		}
+	}
+	// rows committed since the replay started are announced to the subscription
+	cursor.Time = started.Unix()
+	return nil
+}
+
+// sendStreamEvent fetches the row a live event refers to and sends it, unless the replay already sent it. Every live
+// event is otherwise sent, whatever the cursor, since rows are announced in the order they are committed rather than
+// the order of their IDs. Rows that have since been deleted are skipped.
+func (h *Handler) sendStreamEvent(ctx context.Context, c *gin.Context, userID int32, cursor *streamCursor, sent map[streamRow]bool, event stream.Event, now time.Time) error {
+	row := streamRow{Type: event.Type, ID: event.ID}
+	if sent[row] {
+		delete(sent, row)
+		return nil
+	}
+
+	var data any
+	switch event.Type {
+	case stream.EventNotification:
+		notification, err := h.Queries.GetNotificationById(ctx, event.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if notification.UserID.Int32 != userID {
+			return nil
+		}
+		data = notification
+	case stream.EventMessage:
+		message, err := h.Queries.GetPrivateMessageById(ctx, event.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.SenderUserID.Int32 != userID && message.ReceiverUserID.Int32 != userID {
+			return nil
+		}
+		data = message
+	case stream.EventComment:
+		comment, err := h.Queries.GetComment(ctx, event.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if comment.IsDeleted || comment.UserID.Int32 == userID {
+			return nil
+		}
+		data = comment
+	default:
+		// events that are not stored have no place in the cursor
+		return writeStreamEvent(c, cursor.String(), event.Type, event.Data)
+	}
+	cursor.advance(event.Type, event.ID)
+	cursor.Time = now.Unix()
+	return writeStreamEvent(c, cursor.String(), event.Type, data)
+}
+
+// CreateStreamTicketHandler handles POST requests to issue a single-use ticket for the logged in user's session,
+// which is sent in the ticket query parameter when opening the stream or the chat WebSocket. Tickets expire after
+// streamTicketLifetime, so clients fetch a new one each time they reconnect.
+func (h *Handler) CreateStreamTicketHandler(c *gin.Context) {
+	sessionID, ok := c.Value("SessionID").(pgtype.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get session ID"})
+		return
+	}
+	ticket, err := uuid.NewRandom()
+	if err != nil {
+		h.Log.Errorf("Unable to generate stream ticket: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	if err := h.Queries.DeleteExpiredStreamTickets(ctx, pgtype.Timestamptz{Time: now, Valid: true}); err != nil {
+		h.Log.Errorf("Unable to delete expired stream tickets: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+	err = h.Queries.CreateStreamTicket(ctx, db.CreateStreamTicketParams{
+		Ticket:     pgtype.UUID{Bytes: ticket, Valid: true},
+		SessionID:  sessionID,
+		ExpiryDate: pgtype.Timestamptz{Time: now.Add(streamTicketLifetime), Valid: true},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create stream ticket: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket.String(), "expires_in": int(streamTicketLifetime.Seconds())})
+}
+
+// StreamHandler handles GET requests to receive the logged in user's new notifications, private messages and comments
+// on the posts they watch as server-sent events. A client that reconnects with the Last-Event-ID header, or the
+// last_event_id query parameter, first receives what it missed.
+func (h *Handler) StreamHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	ctx := c.Request.Context()
+
+	// subscribe before reading the cursor, so that nothing created in between is missed
+	sub := h.Stream.Subscribe(userID)
+	defer h.Stream.Unsubscribe(sub)
+
+	var cursor streamCursor
+	var err error
+	if lastEventID != "" {
+		cursor, err = parseStreamCursor(lastEventID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		cursor, err = h.latestStreamCursor(ctx, time.Now())
+		if err != nil {
+			h.Log.Errorf("Unable to start stream: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start stream"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+	// tell the client the cursor straight away, so that it can resume even if nothing is sent
+	if err := writeStreamEvent(c, cursor.String(), "ready", gin.H{"user_id": userID}); err != nil {
+		return
+	}
+
+	// the rows replayed to a resumed stream, whose live events may still be waiting in the subscription
+	sent := make(map[streamRow]bool)
+	if lastEventID != "" {
+		if err := h.replayStream(ctx, c, userID, &cursor, sent); err != nil {
+			if ctx.Err() == nil {
+				h.Log.Errorf("Unable to replay stream: %v\n", err)
+			}
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Lagged:
+			// the client reconnects with its last event ID and receives what it missed
+			return
+		case <-sub.Revoked:
+			writeStreamEvent(c, cursor.String(), stream.EventRevoke, gin.H{"error": "Your account has been suspended or banned"})
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event := <-sub.Events:
+			if err := h.sendStreamEvent(ctx, c, userID, &cursor, sent, event, time.Now()); err != nil {
+				if ctx.Err() == nil {
+					h.Log.Errorf("Unable to send stream event: %v\n", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// resolveStreamEvent fills in the users a stream event is for when the trigger that announced it could not, which is
+// the watchers of the post a comment was made on
+func (h *Handler) resolveStreamEvent(ctx context.Context, event *stream.Event) error {
+	if event.Type != stream.EventComment || event.PostID == 0 {
+		return nil
+	}
+	userIDs, err := h.Queries.GetPostWatcherIDs(ctx, event.PostID)
+	if err != nil {
+		return err
+	}
+	event.UserIDs = userIDs
+	return nil
+}
+
+// ListenForStreamEvents delivers the events announced by every server instance to the streams connected to this one,
+// until ctx is cancelled
+func (h *Handler) ListenForStreamEvents(ctx context.Context) {
+	h.Stream.Listen(ctx, h.resolveStreamEvent)
+}
+
+// GetPostWatchHandler handles GET requests to check whether the logged in user watches a post
+func (h *Handler) GetPostWatchHandler(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	_, err = h.Queries.GetPostWatch(context.Background(), db.GetPostWatchParams{UserID: userID, PostID: int32(postID)})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		h.Log.Errorf("Unable to fetch post watch: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get post watch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"watching": err == nil})
+}
+
+// WatchPostHandler handles PUT requests to watch a post, which sends its new comments to the logged in user's stream
+func (h *Handler) WatchPostHandler(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	if _, err := h.Queries.GetPost(ctx, int32(postID)); errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	} else if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch post"})
+		return
+	}
+
+	if err := h.Queries.WatchPost(ctx, db.WatchPostParams{UserID: userID, PostID: int32(postID)}); err != nil {
+		h.Log.Errorf("Unable to watch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch post"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post watched successfully"})
+}
+
+// UnwatchPostHandler handles DELETE requests to stop watching a post
+func (h *Handler) UnwatchPostHandler(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	deleted, err := h.Queries.UnwatchPost(context.Background(), db.UnwatchPostParams{UserID: userID, PostID: int32(postID)})
+	if err != nil {
+		h.Log.Errorf("Unable to unwatch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unwatch post"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post watch not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post unwatched successfully"})
+}
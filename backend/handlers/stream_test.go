@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"server/stream"
+	"testing"
+)
+
+func TestParseStreamCursor(t *testing.T) {
+	valid := map[string]streamCursor{
+		"0.0.0.0":               {},
+		"12.7.301.1717243200":   {NotificationID: 12, MessageID: 7, CommentID: 301, Time: 1717243200},
+		"2147483647.1.2.999999": {NotificationID: 2147483647, MessageID: 1, CommentID: 2, Time: 999999},
+	}
+	for s, want := range valid {
+		got, err := parseStreamCursor(s)
+		if err != nil || got != want {
+			t.Errorf("parseStreamCursor(%q) = %+v, %v, want %+v", s, got, err, want)
+		}
+		if got.String() != s {
+			t.Errorf("%+v.String() = %q, want %q", got, got.String(), s)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"1.2.3",            // cursors from before the time was added
+		"1.2.3.4.5",        // too many parts
+		"1.2.x.4",          // not a number
+		"-1.2.3.4",         // negative ID
+		"1.2.3.-4",         // negative time
+		"2147483648.0.0.0", // ID out of range
+		"1..3.4",
+	}
+	for _, s := range invalid {
+		if got, err := parseStreamCursor(s); err == nil {
+			t.Errorf("parseStreamCursor(%q) = %+v, want an error", s, got)
+		}
+	}
+}
+
+func TestStreamCursorAdvance(t *testing.T) {
+	cursor := streamCursor{NotificationID: 10, MessageID: 10, CommentID: 10, Time: 5}
+	cursor.advance(stream.EventNotification, 12)
+	cursor.advance(stream.EventMessage, 8) // replayed rows may arrive out of order
+	cursor.advance(stream.EventComment, 11)
+	cursor.advance(stream.EventRevoke, 99)
+
+	want := streamCursor{NotificationID: 12, MessageID: 10, CommentID: 11, Time: 5}
+	if cursor != want {
+		t.Errorf("advanced cursor = %+v, want %+v", cursor, want)
+	}
+}
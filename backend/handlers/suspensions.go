@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"server/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxSuspensionHours is the longest a suspension can last. Longer sanctions should be bans.
+const maxSuspensionHours = 365 * 24
+
+// accountSuspension returns the error response for a suspended or banned account, and false if the account is active.
+// Suspensions that have ended but not been lifted by ReinstateExpiredSuspensions yet no longer apply.
+func accountSuspension(isActive pgtype.Bool, suspendedUntil pgtype.Timestamptz, reason pgtype.Text) (gin.H, bool) {
+	if !isActive.Valid || isActive.Bool {
+		return nil, false
+	}
+	if suspendedUntil.Valid && !suspendedUntil.Time.After(time.Now()) {
+		return nil, false
+	}
+
+	body := gin.H{"error": "Your account has been banned", "reason": reason.String}
+	if suspendedUntil.Valid {
+		until := suspendedUntil.Time.UTC().Format(time.RFC3339)
+		body["error"] = "Your account is suspended until " + until
+		body["suspended_until"] = until
+	}
+	return body, true
+}
+
+// getSanctionTarget fetches the user in the id path parameter so that the logged in moderator can suspend, ban or
+// reinstate them, writing an error response and returning false if they cannot
+func (h *Handler) getSanctionTarget(c *gin.Context, q *db.Queries) (db.GetUserWithRoleNameRow, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return db.GetUserWithRoleNameRow{}, false
+	}
+	return h.authorizeSanction(c, q, int32(userID))
+}
+
+// authorizeSanction fetches a user so that the logged in moderator can suspend, ban or reinstate them,
+// writing an error response and returning false if they cannot. Only admins can sanction moderators and admins.
+func (h *Handler) authorizeSanction(c *gin.Context, q *db.Queries, userID int32) (db.GetUserWithRoleNameRow, bool) {
+	if moderatorID, ok := getUserID(c); ok && moderatorID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot sanction yourself"})
+		return db.GetUserWithRoleNameRow{}, false
+	}
+	user, err := q.GetUserWithRoleName(context.Background(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return db.GetUserWithRoleNameRow{}, false
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch user: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return db.GetUserWithRoleNameRow{}, false
+	}
+	if (user.RoleName == "Moderator" || user.RoleName == "Admin") && c.GetString("RoleName") != "Admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can sanction moderators and admins"})
+		return db.GetUserWithRoleNameRow{}, false
+	}
+	return user, true
+}
+
+// banUser bans a user permanently and deletes their sessions, logging them out everywhere
+func banUser(ctx context.Context, q *db.Queries, userID int32, reason string) error {
+	err := q.SuspendUser(ctx, db.SuspendUserParams{UserID: userID, SuspensionReason: reason})
+	if err != nil {
+		return err
+	}
+	return q.DeleteUserSessionsByUserId(ctx, pgtype.Int4{Int32: userID, Valid: true})
+}
+
+type SuspendUserApiParams struct {
+	DurationHours int
+	Reason        string
+}
+
+type SanctionUserApiParams struct {
+	Reason string
+}
+
+// sanctionUser applies a suspension, ban or reinstatement to the user in the id path parameter
+// and records it in the moderation log
+func (h *Handler) sanctionUser(c *gin.Context, action, reason string, apply func(ctx context.Context, q *db.Queries, userID int32) error, message string) {
+	moderatorID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	user, ok := h.getSanctionTarget(c, qtx)
+	if !ok {
+		return
+	}
+	if err := apply(ctx, qtx, user.UserID); err != nil {
+		h.Log.Errorf("Unable to %s: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	err = qtx.CreateLog(ctx, db.CreateLogParams{
+		Action:          action,
+		ModeratorUserID: pgtype.Int4{Int32: moderatorID, Valid: true},
+		AffectedUserID:  pgtype.Int4{Int32: user.UserID, Valid: true},
+		Reason:          pgtype.Text{String: reason, Valid: reason != ""},
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to create moderation log: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "user_id": user.UserID})
+}
+
+// SuspendUserHandler handles POST requests from moderators to suspend a user for a number of hours.
+// Suspended users keep their sessions, but cannot log in or make authenticated requests until the suspension ends.
+// Their open streams and chat connections are closed by the revoke event the users_notify_stream trigger announces.
+func (h *Handler) SuspendUserHandler(c *gin.Context) {
+	var req SuspendUserApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.DurationHours < 1 || req.DurationHours > maxSuspensionHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("DurationHours must be between 1 and %d", maxSuspensionHours)})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to suspend a user"})
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+	reason := fmt.Sprintf("%s (until %s)", req.Reason, until.UTC().Format(time.RFC3339))
+	h.sanctionUser(c, logActionSuspendUser, reason, func(ctx context.Context, q *db.Queries, userID int32) error {
+		return q.SuspendUser(ctx, db.SuspendUserParams{
+			UserID:           userID,
+			SuspendedUntil:   pgtype.Timestamptz{Time: until, Valid: true},
+			SuspensionReason: req.Reason,
+		})
+	}, "User suspended successfully")
+}
+
+// BanUserHandler handles POST requests from moderators to ban a user permanently, logging them out everywhere
+func (h *Handler) BanUserHandler(c *gin.Context) {
+	var req SanctionUserApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to ban a user"})
+		return
+	}
+
+	h.sanctionUser(c, logActionBanUser, req.Reason, func(ctx context.Context, q *db.Queries, userID int32) error {
+		return banUser(ctx, q, userID, req.Reason)
+	}, "User banned successfully")
+}
+
+// ReinstateUserHandler handles POST requests from moderators to lift a user's suspension or ban early
+func (h *Handler) ReinstateUserHandler(c *gin.Context) {
+	var req SanctionUserApiParams
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.sanctionUser(c, logActionReinstateUser, strings.TrimSpace(req.Reason), func(ctx context.Context, q *db.Queries, userID int32) error {
+		return q.ActivateUser(ctx, userID)
+	}, "User reinstated successfully")
+}
+
+// ReinstateExpiredSuspensions reactivates users whose suspensions have ended, recording each in the moderation log
+func (h *Handler) ReinstateExpiredSuspensions(ctx context.Context, now time.Time) error {
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	userIDs, err := qtx.ReinstateExpiredSuspensions(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		err := qtx.CreateLog(ctx, db.CreateLogParams{
+			Action:         logActionReinstateUser,
+			AffectedUserID: pgtype.Int4{Int32: userID, Valid: true},
+			Reason:         pgtype.Text{String: "Suspension ended", Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"server/db"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -25,6 +27,11 @@ func (h *Handler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if err := validateEmail(inputAPI.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
 	hashedPassword, err := hashPassword(inputAPI.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
@@ -40,13 +47,19 @@ func (h *Handler) CreateUser(c *gin.Context) {
 		RoleID:         pgtype.Int4{Int32: 3, Valid: true}, // RoleID set directly here to be guest
 	}
 
-	err = h.Queries.CreateUser(context.Background(), inputDB)
+	ctx := context.Background()
+	userID, err := h.Queries.CreateUser(ctx, inputDB)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User created"})
+	// the account exists even if the email fails, and the user can ask for another one
+	if err := h.sendVerificationEmail(ctx, userID, time.Now()); err != nil {
+		h.Log.Errorf("Unable to send verification email: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User created. Check your email to verify your address"})
 }
 
 func (h *Handler) GetUser(c *gin.Context) {
@@ -65,12 +78,23 @@ func (h *Handler) GetUser(c *gin.Context) {
 }
 
 func (h *Handler) GetAllUsers(c *gin.Context) {
-	users, err := h.Queries.GetAllUsers(context.Background())
+	page, err := parsePageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, err := h.Queries.GetAllUsers(context.Background(), db.GetAllUsersParams{
+		CursorID:  page.cursorID(),
+		PageLimit: page.queryLimit(),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, pageResponse(page, users, func(u db.GetAllUsersRow) pageCursor {
+		return pageCursor{ID: u.UserID}
+	}))
 }
 
 type UpdateUserExcludingSensitiveAPIParams struct {
@@ -93,6 +117,18 @@ func (h *Handler) UpdateUserExcludingSensitive(c *gin.Context) {
 		return
 	}
 
+	if err := validateEmail(input.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.Queries.GetUser(ctx, input.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	dbInput := db.UpdateUserExcludingSensitiveParams{
 		UserID:         input.UserID,
 		Username:       input.Username,
@@ -100,11 +136,25 @@ func (h *Handler) UpdateUserExcludingSensitive(c *gin.Context) {
 		ProfilePicture: pgtype.Text{String: input.ProfilePicture, Valid: true},
 		Biography:      pgtype.Text{String: input.Biography, Valid: true},
 	}
-	err := h.Queries.UpdateUserExcludingSensitive(context.Background(), dbInput)
+	err = h.Queries.UpdateUserExcludingSensitive(ctx, dbInput)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+
+	// a new address is unverified until the user follows the link sent to it
+	if input.Email != user.Email {
+		err := h.sendVerificationEmail(ctx, input.UserID, time.Now())
+		if errors.Is(err, errVerificationThrottled) {
+			c.JSON(http.StatusOK, gin.H{"message": "User updated. Request a verification email for your new address in a few minutes"})
+			return
+		}
+		if err != nil {
+			h.Log.Errorf("Unable to send verification email: %v\n", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "User updated. Check your email to verify your new address"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "User updated"})
 }
 
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"server/db"
+	"server/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// verificationLinkLifetime is how long a verification link works after it is sent
+	verificationLinkLifetime = 24 * time.Hour
+	// verificationResendInterval is how long users wait before another verification email can be sent to them
+	verificationResendInterval = 5 * time.Minute
+)
+
+var (
+	errInvalidEmail          = errors.New("invalid email address")
+	errEmailNotVerified      = errors.New("verify your email address before posting, voting or messaging")
+	errInvalidVerification   = errors.New("invalid verification link")
+	errVerificationExpired   = errors.New("verification link has expired")
+	errVerificationThrottled = fmt.Errorf("a verification email was sent less than %d minutes ago", int(verificationResendInterval.Minutes()))
+)
+
+// validateEmail checks that email is a bare address, without a display name
+func validateEmail(email string) error {
+	address, err := netmail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// verificationMAC signs a user's email address and the expiry of a link verifying it.
+// Links stop working when the address changes, since the address is part of the signature.
+func (h *Handler) verificationMAC(userID int32, email string, expires int64) []byte {
+	mac := hmac.New(sha256.New, h.VerificationSecret)
+	fmt.Fprintf(mac, "%d\n%s\n%d", userID, email, expires)
+	return mac.Sum(nil)
+}
+
+// verificationToken returns the token of a link verifying a user's email address, <user ID>.<expiry>.<signature>
+func (h *Handler) verificationToken(userID int32, email string, expires time.Time) string {
+	signature := h.verificationMAC(userID, email, expires.Unix())
+	return fmt.Sprintf("%d.%d.%s", userID, expires.Unix(), base64.RawURLEncoding.EncodeToString(signature))
+}
+
+// checkVerificationToken returns the user a verification token was sent to, after checking it has not expired and
+// was signed for their current email address
+func (h *Handler) checkVerificationToken(ctx context.Context, token string, now time.Time) (db.GetUserRow, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+
+	user, err := h.Queries.GetUser(ctx, int32(userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+	if err != nil {
+		return db.GetUserRow{}, err
+	}
+	if !hmac.Equal(signature, h.verificationMAC(user.UserID, user.Email, expires)) {
+		return db.GetUserRow{}, errInvalidVerification
+	}
+	if now.Unix() > expires {
+		return db.GetUserRow{}, errVerificationExpired
+	}
+	return user, nil
+}
+
+// verificationEmail is the data of the verify_email templates in the mail package
+type verificationEmail struct {
+	SiteName       string
+	Username       string
+	VerifyURL      string
+	ExpiresIn      string
+	UnsubscribeURL string // always empty, since users cannot opt out of verification emails
+}
+
+// sendVerificationEmail claims the right to email a user a link verifying their current address, then sends it in the
+// background so that requests do not wait on the mail server. It returns errVerificationThrottled when one was sent
+// within verificationResendInterval, or the user is already verified.
+func (h *Handler) sendVerificationEmail(ctx context.Context, userID int32, now time.Time) error {
+	claimed, err := h.Queries.ClaimVerificationEmail(ctx, db.ClaimVerificationEmailParams{
+		Now:            pgtype.Timestamptz{Time: now, Valid: true},
+		UserID:         userID,
+		ThrottleBefore: pgtype.Timestamptz{Time: now.Add(-verificationResendInterval), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return errVerificationThrottled
+	}
+
+	go h.deliverVerificationEmail(userID, now)
+	return nil
+}
+
+// deliverVerificationEmail renders and sends a verification email. Failures are logged, and the user can ask for
+// another email once verificationResendInterval has passed.
+func (h *Handler) deliverVerificationEmail(userID int32, now time.Time) {
+	ctx := context.Background()
+	user, err := h.Queries.GetUser(ctx, userID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch user: %v\n", err)
+		return
+	}
+	token := h.verificationToken(user.UserID, user.Email, now.Add(verificationLinkLifetime))
+	msg, err := mail.Render("verify_email", verificationEmail{
+		SiteName:  siteName,
+		Username:  user.Username,
+		VerifyURL: h.SiteURL + "/api/users/verify_email?token=" + url.QueryEscape(token),
+		ExpiresIn: formatLeadTime(verificationLinkLifetime),
+	})
+	if err != nil {
+		h.Log.Errorf("Unable to render verification email: %v\n", err)
+		return
+	}
+	msg.To = user.Email
+	msg.Subject = "Verify your email address"
+	if err := h.Mailer.Send(ctx, msg); err != nil {
+		h.Log.Errorf("Unable to send verification email: %v\n", err)
+	}
+}
+
+// VerifyEmailHandler handles GET requests to the verification links in emails, which mark the email address of the
+// user the link was sent to as verified
+func (h *Handler) VerifyEmailHandler(c *gin.Context) {
+	ctx := context.Background()
+	user, err := h.checkVerificationToken(ctx, c.Query("token"), time.Now())
+	if errors.Is(err, errInvalidVerification) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification link"})
+		return
+	}
+	if errors.Is(err, errVerificationExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": "Verification link has expired, request a new one"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to check verification link: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+		return
+	}
+
+	// the address is matched again so that a change made since the token was checked is not verified
+	if _, err := h.Queries.VerifyUserEmail(ctx, db.VerifyUserEmailParams{UserID: user.UserID, Email: user.Email}); err != nil {
+		h.Log.Errorf("Unable to verify email: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmailHandler handles POST requests to send the logged in user another verification link.
+// Requests are throttled to one every verificationResendInterval.
+func (h *Handler) ResendVerificationEmailHandler(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.Queries.GetUser(ctx, userID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch user: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	err = h.sendVerificationEmail(ctx, userID, time.Now())
+	if errors.Is(err, errVerificationThrottled) {
+		c.Header("Retry-After", strconv.Itoa(int(verificationResendInterval.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait a few minutes before requesting another verification email"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to send verification email: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent successfully"})
+}
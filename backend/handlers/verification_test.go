@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateEmail(t *testing.T) {
+	valid := []string{"rider@example.com", "first.last+forum@mail.example.org"}
+	invalid := []string{"", "rider", "rider@", "Rider <rider@example.com>", " rider@example.com", "rider@example.com, other@example.com"}
+	for _, email := range valid {
+		if err := validateEmail(email); err != nil {
+			t.Errorf("validateEmail(%q) = %v, want nil", email, err)
+		}
+	}
+	for _, email := range invalid {
+		if err := validateEmail(email); !errors.Is(err, errInvalidEmail) {
+			t.Errorf("validateEmail(%q) = %v, want errInvalidEmail", email, err)
+		}
+	}
+}
+
+func TestVerificationToken(t *testing.T) {
+	h := &Handler{VerificationSecret: []byte("test secret")}
+	expires := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := h.verificationToken(42, "rider@example.com", expires)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q has %d parts, want <user ID>.<expiry>.<signature>", token, len(parts))
+	}
+	if parts[0] != "42" || parts[1] != fmt.Sprint(expires.Unix()) {
+		t.Errorf("token %q does not start with the user ID and expiry", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("signature %q is not unpadded base64url: %v", parts[2], err)
+	}
+	if string(signature) != string(h.verificationMAC(42, "rider@example.com", expires.Unix())) {
+		t.Error("signature does not match verificationMAC")
+	}
+
+	if token != h.verificationToken(42, "rider@example.com", expires) {
+		t.Error("tokens for the same user, address and expiry differ")
+	}
+	others := map[string]string{
+		"another user":    h.verificationToken(43, "rider@example.com", expires),
+		"another address": h.verificationToken(42, "other@example.com", expires),
+		"another expiry":  h.verificationToken(42, "rider@example.com", expires.Add(time.Second)),
+		"another secret":  (&Handler{VerificationSecret: []byte("other secret")}).verificationToken(42, "rider@example.com", expires),
+	}
+	for name, other := range others {
+		if strings.Split(other, ".")[2] == parts[2] {
+			t.Errorf("token for %s has the same signature", name)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"server/db"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type VoteApiParams struct {
+	Value int // 1 to upvote, -1 to downvote, 0 to remove the vote
+}
+
+// bindVote binds and validates the vote in the request body
+func bindVote(c *gin.Context) (int16, bool) {
+	var req VoteApiParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if req.Value < -1 || req.Value > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value must be 1, -1 or 0"})
+		return 0, false
+	}
+	return int16(req.Value), true
+}
+
+// VotePostHandler handles PUT requests to upvote, downvote or remove the logged in user's vote on a post
+func (h *Handler) VotePostHandler(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	value, ok := bindVote(c)
+	if !ok {
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	// lock the post so concurrent votes update its score one at a time
+	post, err := qtx.GetPostForUpdate(ctx, int32(postID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	if post.IsLocked.Bool && !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Post is locked"})
+		return
+	}
+
+	var previous int16
+	vote, err := qtx.GetPostVote(ctx, db.GetPostVoteParams{PostID: int32(postID), UserID: userID})
+	if err == nil {
+		previous = vote.Value
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		h.Log.Errorf("Unable to fetch vote: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	if value == 0 {
+		err = qtx.DeletePostVote(ctx, db.DeletePostVoteParams{PostID: int32(postID), UserID: userID})
+	} else {
+		err = qtx.UpsertPostVote(ctx, db.UpsertPostVoteParams{PostID: int32(postID), UserID: userID, Value: value})
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to save vote: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	score, err := qtx.AddPostScore(ctx, db.AddPostScoreParams{PostID: int32(postID), Delta: int32(value - previous)})
+	if err != nil {
+		h.Log.Errorf("Unable to update score: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vote saved successfully", "vote": value, "score": score})
+}
+
+// VoteCommentHandler handles PUT requests to upvote, downvote or remove the logged in user's vote on a comment
+func (h *Handler) VoteCommentHandler(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("commentID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+	value, ok := bindVote(c)
+	if !ok {
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := h.Dbpool.Begin(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to begin transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := h.Queries.WithTx(tx)
+
+	// lock the comment so concurrent votes update its score one at a time
+	comment, err := qtx.GetCommentForUpdate(ctx, int32(commentID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to fetch comment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	locked, err := isPostLocked(ctx, qtx, comment.PostID)
+	if err != nil {
+		h.Log.Errorf("Unable to fetch post: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	if locked && !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Post is locked"})
+		return
+	}
+
+	var previous int16
+	vote, err := qtx.GetCommentVote(ctx, db.GetCommentVoteParams{CommentID: int32(commentID), UserID: userID})
+	if err == nil {
+		previous = vote.Value
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		h.Log.Errorf("Unable to fetch vote: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	if value == 0 {
+		err = qtx.DeleteCommentVote(ctx, db.DeleteCommentVoteParams{CommentID: int32(commentID), UserID: userID})
+	} else {
+		err = qtx.UpsertCommentVote(ctx, db.UpsertCommentVoteParams{CommentID: int32(commentID), UserID: userID, Value: value})
+	}
+	if err != nil {
+		h.Log.Errorf("Unable to save vote: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	score, err := qtx.AddCommentScore(ctx, db.AddCommentScoreParams{CommentID: int32(commentID), Delta: int32(value - previous)})
+	if err != nil {
+		h.Log.Errorf("Unable to update score: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		h.Log.Errorf("Unable to commit transaction: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vote saved successfully", "vote": value, "score": score})
+}
@@ -0,0 +1,105 @@
+// Package ical writes the subset of iCalendar (RFC 5545) needed to publish events as calendar feeds
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	prodID        = "-//cvwo-assignment//Events//EN"
+	utcDateTime   = "20060102T150405Z"
+	maxLineLength = 75 // octets, excluding the CRLF
+)
+
+// Event is a single VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time // the event has no duration when zero
+	Cancelled   bool
+}
+
+// Calendar is a VCALENDAR holding a list of events
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Encode writes the calendar as an iCalendar stream.
+// All times are written in UTC so that clients convert them to their own time zone.
+func Encode(w io.Writer, cal *Calendar) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(utcDateTime)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+prodID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+	if cal.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+escapeText(cal.Name))
+	}
+	for _, e := range cal.Events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+e.UID)
+		writeLine(bw, "DTSTAMP:"+stamp)
+		writeLine(bw, "DTSTART:"+e.Start.UTC().Format(utcDateTime))
+		if !e.End.IsZero() {
+			writeLine(bw, "DTEND:"+e.End.UTC().Format(utcDateTime))
+		}
+		writeLine(bw, "SUMMARY:"+escapeText(e.Summary))
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		if e.Location != "" {
+			writeLine(bw, "LOCATION:"+escapeText(e.Location))
+		}
+		if e.Cancelled {
+			writeLine(bw, "STATUS:CANCELLED")
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+	writeLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line terminated by CRLF, folding it into continuation lines
+// so that no line exceeds 75 octets. Lines are only split between UTF-8 characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineLength - 1 // continuation lines start with a space
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
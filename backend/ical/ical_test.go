@@ -0,0 +1,118 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := map[string]string{
+		"Morning ride":              "Morning ride",
+		"Coffee; then hills, maybe": `Coffee\; then hills\, maybe`,
+		`C:\routes`:                 `C:\\routes`,
+		"line one\nline two":        `line one\nline two`,
+		"windows\r\nline":           `windows\nline`,
+		"old mac\rline":             `old mac\nline`,
+		`already \n escaped`:        `already \\n escaped`,
+	}
+	for value, want := range tests {
+		if got := escapeText(value); got != want {
+			t.Errorf("escapeText(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+// foldedLines writes a content line and returns the physical lines it was folded into
+func foldedLines(line string) []string {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeLine(w, line)
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+}
+
+func TestWriteLineFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int
+	}{
+		{name: "short", line: "SUMMARY:Morning ride", lines: 1},
+		{name: "exactly 75 octets", line: strings.Repeat("a", 75), lines: 1},
+		{name: "76 octets", line: strings.Repeat("a", 76), lines: 2},
+		{name: "ASCII", line: "DESCRIPTION:" + strings.Repeat("0123456789", 20), lines: 3},
+		// two octet characters, so the first cut falls in the middle of one
+		{name: "two octet characters", line: "SUMMARY:" + strings.Repeat("é", 60), lines: 2},
+		// four octet characters
+		{name: "emoji", line: "SUMMARY:" + strings.Repeat("🚲", 40), lines: 3},
+		{name: "CJK", line: "LOCATION:" + strings.Repeat("新加坡", 20), lines: 3},
+	}
+	for _, test := range tests {
+		lines := foldedLines(test.line)
+		if len(lines) != test.lines {
+			t.Errorf("%s: folded into %d lines, want %d: %q", test.name, len(lines), test.lines, lines)
+		}
+		unfolded := lines[0]
+		for i, line := range lines {
+			if len(line) > maxLineLength {
+				t.Errorf("%s: line %d is %d octets", test.name, i, len(line))
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("%s: line %d splits a UTF-8 character: %q", test.name, i, line)
+			}
+			if i > 0 {
+				if !strings.HasPrefix(line, " ") {
+					t.Errorf("%s: continuation line %d does not start with a space: %q", test.name, i, line)
+				}
+				unfolded += strings.TrimPrefix(line, " ")
+			}
+		}
+		if unfolded != test.line {
+			t.Errorf("%s: unfolds to %q, want %q", test.name, unfolded, test.line)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	start := time.Date(2024, 3, 2, 9, 0, 0, 0, time.FixedZone("SGT", 8*60*60))
+	cal := &Calendar{
+		Name: "Rides, Singapore",
+		Events: []Event{
+			{UID: "event-1@example.com", Summary: "Morning ride", Location: "Town hall", Start: start, End: start.Add(2 * time.Hour)},
+			{UID: "event-2@example.com", Summary: "Night ride", Description: "Bring lights;\nhelmets", Start: start.AddDate(0, 0, 7), Cancelled: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, cal); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("Encode output is not a VCALENDAR:\n%s", out)
+	}
+	if strings.Count(out, "BEGIN:VEVENT\r\n") != 2 || strings.Count(out, "END:VEVENT\r\n") != 2 {
+		t.Errorf("Encode output does not have 2 events:\n%s", out)
+	}
+	for _, line := range []string{
+		`X-WR-CALNAME:Rides\, Singapore`,
+		"UID:event-1@example.com",
+		// times are converted to UTC
+		"DTSTART:20240302T010000Z",
+		"DTEND:20240302T030000Z",
+		"LOCATION:Town hall",
+		"DTSTART:20240309T010000Z",
+		`DESCRIPTION:Bring lights\;\nhelmets`,
+		"STATUS:CANCELLED",
+	} {
+		if !strings.Contains(out, "\r\n"+line+"\r\n") {
+			t.Errorf("Encode output is missing %q:\n%s", line, out)
+		}
+	}
+	if strings.Count(out, "DTEND:") != 1 || strings.Count(out, "STATUS:CANCELLED") != 1 {
+		t.Errorf("Encode output has DTEND or STATUS on the wrong events:\n%s", out)
+	}
+}
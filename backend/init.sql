@@ -3,8 +3,8 @@ INSERT INTO roles (role_id, role_name) VALUES (2, 'Moderator');
 INSERT INTO roles (role_id, role_name) VALUES (3, 'User');
 INSERT INTO roles (role_id, role_name) VALUES (4, 'Guest');
 
-INSERT INTO users (username, email, password_hash, profile_picture, biography, role_id) 
-VALUES ('testUser', 'testUser@testUser@example.com', '$2a$10$sT4z5AHcw5CqATcCBIklqeSKNnW1XVnaQQ9KBCEdL0Q5DGbJoDnU2', 'https://example.com/profile.jpg', 'This is a test user', 1);
+INSERT INTO users (username, email, password_hash, profile_picture, biography, email_verified_at, role_id) 
+VALUES ('testUser', 'testUser@example.com', '$2a$10$sT4z5AHcw5CqATcCBIklqeSKNnW1XVnaQQ9KBCEdL0Q5DGbJoDnU2', 'https://example.com/profile.jpg', 'This is a test user', CURRENT_TIMESTAMP, 1);
 
 INSERT INTO categories (name, description) VALUES
 ('Technology', 'Posts about various technology topics'),
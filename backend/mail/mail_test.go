@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	from := &netmail.Address{Name: "Cycling Forum", Address: "forum@example.com"}
+	to := &netmail.Address{Address: "rider@example.com"}
+	msg := Message{
+		Subject: "Your weekly digest – 3 new posts",
+		Text:    "Hi rider, here is a line long enough that quoted-printable has to wrap it across more than one line of the body",
+		HTML:    `<p style="color: #222;">Hi rider</p>`,
+		Headers: map[string]string{
+			"list-unsubscribe": "<https://example.com/unsubscribe>\r\nBcc: someone@example.com",
+		},
+	}
+	body, err := msg.encode(from, to)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("encoded message does not parse: %v", err)
+	}
+	header := parsed.Header
+	if got := header.Get("From"); got != `"Cycling Forum" <forum@example.com>` {
+		t.Errorf("From = %q", got)
+	}
+	if got := header.Get("To"); got != "<rider@example.com>" {
+		t.Errorf("To = %q", got)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject")); err != nil || subject != msg.Subject {
+		t.Errorf("Subject decodes to %q, %v, want %q", subject, err, msg.Subject)
+	}
+	if got := header.Get("Message-Id"); !strings.HasPrefix(got, "<") || !strings.HasSuffix(got, "@example.com>") {
+		t.Errorf("Message-ID = %q, want one at the sender's domain", got)
+	}
+	if _, err := header.Date(); err != nil {
+		t.Errorf("Date does not parse: %v", err)
+	}
+	// line breaks in header values are stripped rather than starting a new header
+	if got := header.Get("Bcc"); got != "" {
+		t.Errorf("Bcc = %q, want no header added by a header value", got)
+	}
+	if got := header.Get("List-Unsubscribe"); got != "<https://example.com/unsubscribe>Bcc: someone@example.com" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v, want multipart/alternative", mediaType, err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("missing %s part: %v", want.contentType, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		got, err := io.ReadAll(part)
+		if err != nil || string(got) != want.body {
+			t.Errorf("%s part = %q, %v, want %q", want.contentType, got, err, want.body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("message has more than 2 parts: %v", err)
+	}
+}
+
+type testEmail struct {
+	SiteName       string
+	UnsubscribeURL string
+	Username       string
+	VerifyURL      string
+	ExpiresIn      string
+}
+
+func TestRender(t *testing.T) {
+	data := testEmail{
+		SiteName:  "Cycling Forum",
+		Username:  "<b>rider</b>",
+		VerifyURL: "https://example.com/verify?token=1.2.abc",
+		ExpiresIn: "24 hours",
+	}
+	msg, err := Render("verify_email", data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(msg.HTML, "Hi &lt;b&gt;rider&lt;/b&gt;") || strings.Contains(msg.HTML, "<b>rider") {
+		t.Errorf("HTML does not escape the username:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "Hi <b>rider</b>") {
+		t.Errorf("text escapes the username:\n%s", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, `href="https://example.com/verify?token=1.2.abc"`) || !strings.Contains(msg.Text, data.VerifyURL) {
+		t.Errorf("message is missing the verification link:\n%s\n%s", msg.HTML, msg.Text)
+	}
+	if strings.Contains(msg.Text, "Unsubscribe") || strings.Contains(msg.HTML, "Unsubscribe") {
+		t.Errorf("footer has an unsubscribe link without an unsubscribe URL:\n%s", msg.Text)
+	}
+
+	data.UnsubscribeURL = "https://example.com/unsubscribe?token=abc"
+	msg, err = Render("verify_email", data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(msg.Text, "Unsubscribe from these emails: "+data.UnsubscribeURL) || !strings.Contains(msg.HTML, `href="`+data.UnsubscribeURL+`"`) {
+		t.Errorf("footer is missing the unsubscribe link:\n%s\n%s", msg.HTML, msg.Text)
+	}
+
+	if _, err := Render("no_such_email", data); err == nil {
+		t.Error("Render of a missing template succeeded")
+	}
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"os"
 	"server/db"
 	"server/handlers"
+	"server/mail"
+	"server/scheduler"
+	"server/stream"
+	"strings"
+	"time"
+	_ "time/tzdata" // event time zones, since the runtime image has no zoneinfo
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -34,20 +41,55 @@ func main() {
 
 	r := gin.Default()
 
+	// the frontend's origins, which may call the API and open chat WebSockets
+	origins := []string{"http://localhost:8082", "http://localhost:4173", "https://cvwo-spa.onrender.com"}
+
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:8082", "http://localhost:4173", "https://cvwo-spa.onrender.com"}
+	config.AllowOrigins = origins
 	config.AllowCredentials = true
 	config.AllowHeaders = append(config.AllowHeaders, "session_id")
 	r.Use(cors.New(config))
 
 	queries := db.New(dbpool)
 
+	// emails are logged instead of sent when no SMTP server is configured
+	var mailer mail.Mailer = mail.LogMailer{Log: log}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		mailer = mail.NewSMTPMailer(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+	}
+	verificationSecret := []byte(os.Getenv("EMAIL_VERIFICATION_SECRET"))
+	if len(verificationSecret) == 0 {
+		// links only work on this instance until it restarts, so the secret must be set when running several
+		log.Warn("EMAIL_VERIFICATION_SECRET is not set, using a random secret")
+		verificationSecret = make([]byte, 32)
+		if _, err := rand.Read(verificationSecret); err != nil {
+			log.Fatalf("Unable to generate verification secret: %v\n", err)
+		}
+	}
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8080"
+	}
+
 	h := handlers.Handler{
-		Log:     log,
-		Queries: queries,
-		Dbpool:  dbpool,
+		Log:                log,
+		Queries:            queries,
+		Dbpool:             dbpool,
+		Stream:             stream.NewHub(dbpool, log),
+		Mailer:             mailer,
+		SiteURL:            strings.TrimSuffix(siteURL, "/"),
+		VerificationSecret: verificationSecret,
+		AllowedOrigins:     origins,
 	}
 
+	go h.ListenForStreamEvents(ctx)
+
+	// reminders are idempotent, so every server instance can run the job
+	go scheduler.Run(ctx, log, "event reminders", time.Minute, h.SendEventReminders)
+	go scheduler.Run(ctx, log, "render Markdown", time.Hour, h.RenderMissingContentHTML)
+	go scheduler.Run(ctx, log, "reinstate suspended users", time.Minute, h.ReinstateExpiredSuspensions)
+	go scheduler.Run(ctx, log, "email digests", 15*time.Minute, h.SendEmailDigests)
+
 	api := r.Group("/api", h.InjectRoleNameAndUserID())
 	{
 		api.GET("/ping", h.Ping)
@@ -59,8 +101,12 @@ func main() {
 			users.GET("", h.GetAllUsers)
 			users.GET("/:id", h.GetUser)
 			users.POST("", h.CreateUser)
+			users.GET("/verify_email", h.VerifyEmailHandler)
+			users.POST("/verify_email/resend", h.EnsureRole("User", "Moderator", "Admin"), h.ResendVerificationEmailHandler)
 			users.PATCH("/password", h.EnsureRole("User", "Moderator", "Admin"), h.UpdateUserPassword)
 			users.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.UpdateUserExcludingSensitive)
+			users.GET("/email_preferences", h.EnsureRole("User", "Moderator", "Admin"), h.GetEmailPreferencesHandler)
+			users.PUT("/email_preferences", h.EnsureRole("User", "Moderator", "Admin"), h.UpdateEmailPreferencesHandler)
 			users.DELETE("/:id", h.EnsureRole("User", "Moderator", "Admin"), h.DeleteUser)
 		}
 
@@ -70,9 +116,28 @@ func main() {
 			posts.GET("/:id", h.GetPostHandler)
 			posts.GET("/user/:userID", h.GetPostsByUserHandler)
 			posts.GET("/category/:postCategoryID", h.GetPostsByCategoryHandler)
-			posts.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.CreatePostHandler)
-			posts.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.UpdatePostHandler)
+			posts.GET("/sticky", h.GetStickyPostsHandler)
+			posts.GET("/locked", h.EnsureRole("Moderator", "Admin"), h.GetLockedPostsHandler)
+			posts.GET("/:id/revisions", h.EnsureRole("User", "Moderator", "Admin"), h.GetPostRevisionsHandler)
+			posts.GET("/:id/revisions/diff", h.EnsureRole("User", "Moderator", "Admin"), h.GetPostRevisionDiffHandler)
+			posts.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CreatePostHandler)
+			posts.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpdatePostHandler)
+			posts.PUT("/:id/vote", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.VotePostHandler)
+			posts.POST("/:id/lock", h.EnsureRole("Moderator", "Admin"), h.LockPostHandler)
+			posts.POST("/:id/unlock", h.EnsureRole("Moderator", "Admin"), h.UnlockPostHandler)
+			posts.POST("/:id/sticky", h.EnsureRole("Moderator", "Admin"), h.StickyPostHandler)
+			posts.POST("/:id/unsticky", h.EnsureRole("Moderator", "Admin"), h.UnstickyPostHandler)
 			posts.DELETE("/:id", h.EnsureRole("User", "Moderator", "Admin"), h.DeletePostHandler)
+			posts.GET("/:id/watch", h.EnsureRole("User", "Moderator", "Admin"), h.GetPostWatchHandler)
+			posts.PUT("/:id/watch", h.EnsureRole("User", "Moderator", "Admin"), h.WatchPostHandler)
+			posts.DELETE("/:id/watch", h.EnsureRole("User", "Moderator", "Admin"), h.UnwatchPostHandler)
+		}
+
+		categories := api.Group("/categories", h.EnsureRole("User", "Moderator", "Admin"))
+		{
+			categories.GET("/followed", h.GetFollowedCategoriesHandler)
+			categories.PUT("/:id/follow", h.FollowCategoryHandler)
+			categories.DELETE("/:id/follow", h.UnfollowCategoryHandler)
 		}
 
 		comments := api.Group("/comments")
@@ -80,11 +145,87 @@ func main() {
 			comments.GET("/:commentID", h.GetCommentHandler)
 			comments.GET("/post/:postID", h.GetCommentsByPostHandler)
 			comments.GET("/user/:userID", h.GetCommentsByUserHandler)
-			comments.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.CreateCommentHandler)
-			comments.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.UpdateCommentHandler)
+			comments.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CreateCommentHandler)
+			comments.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpdateCommentHandler)
+			comments.PUT("/:commentID/vote", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.VoteCommentHandler)
 			comments.DELETE("/:commentID", h.EnsureRole("User", "Moderator", "Admin"), h.DeleteCommentHandler)
 		}
 
+		routes := api.Group("/routes")
+		{
+			routes.GET("", h.GetRoutesHandler)
+			routes.GET("/:id", h.GetRouteHandler)
+			routes.GET("/user/:userID", h.GetRoutesByUserHandler)
+			routes.GET("/:id/gpx", h.ExportRouteGPXHandler)
+			routes.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CreateRouteHandler)
+			routes.POST("/gpx", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.ImportRouteGPXHandler)
+			routes.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpdateRouteHandler)
+			routes.PUT("/:id/gpx", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.ReplaceRouteGPXHandler)
+			routes.DELETE("/:id", h.EnsureRole("User", "Moderator", "Admin"), h.DeleteRouteHandler)
+		}
+
+		events := api.Group("/events")
+		{
+			events.GET("", h.GetEventsHandler)
+			events.GET("/:id", h.GetEventHandler)
+			events.GET("/:id/rsvps", h.GetEventRSVPsHandler)
+			events.GET("/rsvps/user/:userID", h.GetRSVPsByUserHandler)
+			events.GET("/:id/ics", h.ExportEventICSHandler)
+			events.GET("/:id/occurrences", h.GetEventOccurrencesHandler)
+			events.GET("/calendar", h.GetEventsCalendarHandler)
+			events.GET("/calendar/user/:token", h.GetUserCalendarHandler)
+			events.GET("/calendar/token", h.EnsureRole("User", "Moderator", "Admin"), h.GetCalendarTokenHandler)
+			events.POST("/calendar/token", h.EnsureRole("User", "Moderator", "Admin"), h.RegenerateCalendarTokenHandler)
+			events.POST("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CreateEventHandler)
+			events.POST("/:id/rsvps", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpsertRSVPHandler)
+			events.POST("/:id/rsvps/:userID/attended", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.MarkRSVPAttendedHandler)
+			events.PUT("", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpdateEventHandler)
+			events.PUT("/:id/rsvps", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpsertRSVPHandler)
+			events.DELETE("/:id", h.EnsureRole("User", "Moderator", "Admin"), h.DeleteEventHandler)
+			events.PUT("/:id/occurrences", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.UpdateEventOccurrenceHandler)
+			events.POST("/:id/occurrences/cancel", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CancelEventOccurrenceHandler)
+			events.DELETE("/:id/rsvps", h.EnsureRole("User", "Moderator", "Admin"), h.DeleteRSVPHandler)
+			events.DELETE("/:id/occurrences", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.RestoreEventOccurrenceHandler)
+		}
+
+		messages := api.Group("/messages", h.EnsureRole("User", "Moderator", "Admin"))
+		{
+			messages.GET("", h.GetConversationsHandler)
+			messages.GET("/unread_count", h.GetUnreadMessageCountHandler)
+			messages.GET("/ws", h.ChatHandler)
+			messages.GET("/:userID", h.GetConversationHandler)
+			messages.POST("", h.SendMessageHandler)
+			messages.POST("/:userID/read", h.MarkConversationReadHandler)
+		}
+
+		notifications := api.Group("/notifications", h.EnsureRole("User", "Moderator", "Admin"))
+		{
+			notifications.GET("", h.GetNotificationsHandler)
+			notifications.GET("/unread_count", h.GetUnreadNotificationCountHandler)
+			notifications.POST("/read_all", h.MarkAllNotificationsReadHandler)
+			notifications.POST("/:id/read", h.MarkNotificationReadHandler)
+			notifications.DELETE("/:id", h.DeleteNotificationHandler)
+		}
+
+		api.GET("/stream", h.EnsureRole("User", "Moderator", "Admin"), h.StreamHandler)
+		api.POST("/stream/ticket", h.EnsureRole("User", "Moderator", "Admin"), h.CreateStreamTicketHandler)
+		api.GET("/search", h.SearchHandler)
+		api.GET("/unsubscribe/:token", h.UnsubscribePageHandler)
+		api.POST("/unsubscribe/:token", h.UnsubscribeHandler)
+		api.POST("/reports", h.EnsureRole("User", "Moderator", "Admin"), h.EnsureEmailVerified(), h.CreateReportHandler)
+
+		moderation := api.Group("/moderation", h.EnsureRole("Moderator", "Admin"))
+		{
+			moderation.GET("/logs", h.GetModerationLogsHandler)
+			moderation.GET("/reports", h.GetReportsHandler)
+			moderation.GET("/reports/:id", h.GetReportHandler)
+			moderation.POST("/reports/:id/review", h.ReviewReportHandler)
+			moderation.POST("/reports/:id/resolve", h.ResolveReportHandler)
+			moderation.POST("/users/:id/suspend", h.SuspendUserHandler)
+			moderation.POST("/users/:id/ban", h.BanUserHandler)
+			moderation.POST("/users/:id/reinstate", h.ReinstateUserHandler)
+		}
+
 		log.Info("Server running on port 8081")
 		r.Run(":8081")
 	}
@@ -0,0 +1,38 @@
+// Package markdown renders user-written CommonMark to sanitised HTML
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// renderer parses CommonMark, including fenced code blocks, with GitHub style tables and autolinks.
+// Raw HTML in the source is dropped rather than passed through.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.Table, extension.Linkify),
+)
+
+// policy is the set of elements and attributes allowed in rendered HTML.
+// Links open with rel="nofollow noopener", and code blocks keep their language class for syntax highlighting.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// Render converts Markdown source to sanitised HTML that is safe to insert into a page
+func Render(source string) string {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		// goldmark only fails when writing the output fails, which a bytes.Buffer never does
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return policy.Sanitize(buf.String())
+}
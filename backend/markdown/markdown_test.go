@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderDropsUnsafeHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		banned []string
+	}{
+		{name: "script block", source: "Hello\n\n<script>alert(1)</script>", banned: []string{"<script", "alert(1)</script>"}},
+		{name: "inline script", source: "Hello <script>alert(1)</script> there", banned: []string{"<script"}},
+		{name: "javascript link", source: "[click](javascript:alert(1))", banned: []string{"javascript:"}},
+		{name: "mixed case javascript link", source: "[click](JavaScript:alert(1))", banned: []string{"javascript:", "JavaScript:"}},
+		{name: "javascript autolink", source: "<javascript:alert(1)>", banned: []string{`href="javascript:`}},
+		{name: "event handler", source: `<img src="x.png" onerror="alert(1)">`, banned: []string{"onerror"}},
+		{name: "iframe", source: `<iframe src="https://example.com"></iframe>`, banned: []string{"<iframe"}},
+		{name: "data image", source: "![x](data:text/html;base64,PHNjcmlwdD4=)", banned: []string{"data:text/html"}},
+	}
+	for _, test := range tests {
+		got := Render(test.source)
+		for _, banned := range test.banned {
+			if strings.Contains(got, banned) {
+				t.Errorf("%s: Render(%q) = %q, contains %q", test.name, test.source, got, banned)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{name: "emphasis", source: "**bold** and _italic_", want: []string{"<strong>bold</strong>", "<em>italic</em>"}},
+		{name: "escaped text", source: "1 < 2 & 3 > 2", want: []string{"1 &lt; 2 &amp; 3 &gt; 2"}},
+		{name: "link", source: "[route](https://example.com/route)", want: []string{`href="https://example.com/route"`, `rel="nofollow noopener"`, `target="_blank"`}},
+		{name: "autolink", source: "See https://example.com", want: []string{`<a href="https://example.com"`}},
+		{name: "fenced code", source: "```go\nfmt.Println(\"<hi>\")\n```", want: []string{`<code class="language-go">`, "&lt;hi&gt;"}},
+		{name: "table", source: "| a | b |\n| - | - |\n| 1 | 2 |", want: []string{"<table>", "<td>1</td>"}},
+	}
+	for _, test := range tests {
+		got := Render(test.source)
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: Render(%q) = %q, want it to contain %q", test.name, test.source, got, want)
+			}
+		}
+	}
+}
@@ -1,11 +1,12 @@
--- name: CreateUser :exec
--- Create a new user
+-- name: CreateUser :one
+-- Create a new user, whose email address is unverified
 INSERT INTO users (username, email, password_hash, profile_picture, biography, role_id)
-VALUES ($1, $2, $3, $4, $5, $6);
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING user_id;
 
 -- name: GetUser :one
 -- Get a user by id, no password_hash
-SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users WHERE user_id = $1;
+SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, email_verified_at, role_id FROM users WHERE user_id = $1;
 
 -- name: GetUserWithRoleName :one
 -- Get a user by id, no password_hash, with role_name
@@ -15,12 +16,18 @@ INNER JOIN roles ON users.role_id = roles.role_id
 WHERE users.user_id = $1;
 
 -- name: GetAllUsers :many
--- Get all users, no password_hash
-SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users;
+-- Get a page of users ordered by user_id, starting after the cursor, no password_hash
+SELECT user_id, username, email, registration_date, profile_picture, biography, last_login_date, is_active, role_id FROM users
+WHERE sqlc.narg(cursor_id)::int IS NULL OR user_id > sqlc.narg(cursor_id)::int
+ORDER BY user_id
+LIMIT sqlc.arg(page_limit);
 
 -- name: UpdateUserExcludingSensitive :exec
--- Update a user's details, no password_hash
-UPDATE users SET username = $2, email = $3, profile_picture = $4, biography = $5 WHERE user_id = $1;
+-- Update a user's details, no password_hash. Changing the email address makes it unverified again. The time the last
+-- verification email was sent is kept, so that changing the address does not get around the resend throttle.
+UPDATE users SET username = $2, email = $3, profile_picture = $4, biography = $5,
+email_verified_at = CASE WHEN email = $3 THEN email_verified_at END
+WHERE user_id = $1;
 
 -- name: UpdateUserPassword :exec
 -- Update a user's password_hash
@@ -51,8 +58,19 @@ UPDATE users SET last_login_date = CURRENT_TIMESTAMP WHERE user_id = $1;
 UPDATE users SET is_active = FALSE WHERE user_id = $1;
 
 -- name: ActivateUser :exec
--- Activate a user
-UPDATE users SET is_active = TRUE WHERE user_id = $1;
+-- Activate a user, lifting any suspension or ban
+UPDATE users SET is_active = TRUE, suspended_until = NULL, suspension_reason = NULL WHERE user_id = $1;
+
+-- name: SuspendUser :exec
+-- Suspend a user until suspended_until, or ban them permanently when it is NULL
+UPDATE users SET is_active = FALSE, suspended_until = sqlc.narg(suspended_until)::timestamptz, suspension_reason = sqlc.arg(suspension_reason)::text
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: ReinstateExpiredSuspensions :many
+-- Reactivate the users whose suspensions have ended, returning their IDs
+UPDATE users SET is_active = TRUE, suspended_until = NULL, suspension_reason = NULL
+WHERE is_active = FALSE AND suspended_until <= sqlc.arg(now)::timestamptz
+RETURNING user_id;
 
 ------------------------------------------------------------------------------------------------------------------------
 
@@ -102,26 +120,157 @@ DELETE FROM categories WHERE category_id = $1;
 ------------------------------------------------------------------------------------------------------------------------
 
 -- name: CreatePost :exec
-INSERT INTO posts (title, content, user_id, post_category_id, additional_notes)
-VALUES ($1, $2, $3, $4, $5);
+INSERT INTO posts (title, content, content_html, user_id, post_category_id, additional_notes)
+VALUES ($1, $2, $3, $4, $5, $6);
 
 -- name: GetPost :one
 SELECT * FROM posts WHERE post_id = $1;
 
--- name: GetAllPosts :many
-SELECT posts.*, users.username 
-FROM posts 
-INNER JOIN users ON posts.user_id = users.user_id 
-ORDER BY posts.creation_date DESC;
+-- name: GetAllPostsNew :many
+-- Get a page of posts, sticky posts first and then newest first, starting after the cursor.
+-- The new, top and active sorts each have an index on the sticky flag, their key and the post ID, see schema.sql.
+SELECT posts.*, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetAllPostsTop :many
+-- Get a page of posts, sticky posts first and then highest score first, starting after the cursor
+SELECT posts.*, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_score)::int, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetAllPostsActive :many
+-- Get a page of posts, sticky posts first and then latest comment first, starting after the cursor
+SELECT posts.*, users.username
+FROM posts
+INNER JOIN users ON posts.user_id = users.user_id
+WHERE (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetAllPostsHot :many
+-- Get a page of posts, sticky posts first and then by score decayed by age, starting after the cursor.
+-- The key changes with time, so it cannot be indexed. It is computed once in a subquery and shared by the cursor
+-- condition and the ordering.
+SELECT ranked.*, users.username
+FROM (
+  SELECT posts.*, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+) AS ranked
+INNER JOIN users ON ranked.user_id = users.user_id
+WHERE (sqlc.narg(cursor_key)::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_key)::float8, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByUserNew :many
+-- Get a page of posts by a specific user, sticky posts first and then newest first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByUserTop :many
+-- Get a page of posts by a specific user, sticky posts first and then highest score first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_score)::int, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByUserActive :many
+-- Get a page of posts by a specific user, sticky posts first and then latest comment first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByUserHot :many
+-- Get a page of posts by a specific user, sticky posts first and then by score decayed by age, starting after the cursor
+SELECT ranked.*
+FROM (
+  SELECT posts.*, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+  WHERE posts.user_id = sqlc.arg(user_id)
+) AS ranked
+WHERE (sqlc.narg(cursor_key)::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_key)::float8, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByCategoryNew :many
+-- Get a page of posts in a specific category, sticky posts first and then newest first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.post_category_id = sqlc.arg(post_category_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.creation_date, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.creation_date DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByCategoryTop :many
+-- Get a page of posts in a specific category, sticky posts first and then highest score first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.post_category_id = sqlc.arg(post_category_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), posts.score, posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_score)::int, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, posts.score DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByCategoryActive :many
+-- Get a page of posts in a specific category, sticky posts first and then latest comment first, starting after the cursor
+SELECT * FROM posts
+WHERE posts.post_category_id = sqlc.arg(post_category_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (COALESCE(posts.is_sticky, FALSE), COALESCE(posts.last_activity_date, posts.creation_date), posts.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(posts.is_sticky, FALSE) DESC, COALESCE(posts.last_activity_date, posts.creation_date) DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostsByCategoryHot :many
+-- Get a page of posts in a specific category, sticky posts first and then by score decayed by age, starting after the cursor
+SELECT ranked.*
+FROM (
+  SELECT posts.*, (sign(posts.score::float8) * log(greatest(abs(posts.score), 1)::float8) + extract(epoch FROM posts.creation_date)::float8 / 45000)::float8 AS sort_key
+  FROM posts
+  WHERE posts.post_category_id = sqlc.arg(post_category_id)
+) AS ranked
+WHERE (sqlc.narg(cursor_key)::float8 IS NULL OR (COALESCE(ranked.is_sticky, FALSE), ranked.sort_key, ranked.post_id) < (sqlc.narg(cursor_sticky)::boolean, sqlc.narg(cursor_key)::float8, sqlc.narg(cursor_id)::int))
+ORDER BY COALESCE(ranked.is_sticky, FALSE) DESC, ranked.sort_key DESC, ranked.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPostForUpdate :one
+-- Get a post by id, locking it until the end of the transaction
+SELECT * FROM posts WHERE post_id = $1 FOR UPDATE;
+
+-- name: UpdatePost :one
+-- Update a post's details and mark it as edited
+UPDATE posts SET title = $2, content = $3, content_html = $4, post_category_id = $5, additional_notes = $6, edited_at = CURRENT_TIMESTAMP
+WHERE post_id = $1
+RETURNING *;
+
+-- name: CreatePostRevision :one
+-- Record a version of a post
+INSERT INTO post_revisions (post_id, revision_number, title, content, post_category_id, additional_notes, editor_user_id, creation_date)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
 
--- name: GetPostsByUser :many
-SELECT * FROM posts WHERE user_id = $1 ORDER BY creation_date DESC;
+-- name: GetLatestPostRevisionNumber :one
+-- Get the number of the latest revision of a post, 0 if it has never been edited
+SELECT COALESCE(MAX(revision_number), 0)::int AS revision_number FROM post_revisions WHERE post_id = $1;
 
--- name: GetPostsByCategory :many
-SELECT * FROM posts WHERE post_category_id = $1 ORDER BY creation_date DESC;
+-- name: GetPostRevisionsByPostID :many
+-- Get all revisions of a post, oldest first
+SELECT post_revisions.*, users.username
+FROM post_revisions
+LEFT JOIN users ON post_revisions.editor_user_id = users.user_id
+WHERE post_revisions.post_id = $1
+ORDER BY post_revisions.revision_number;
 
--- name: UpdatePost :exec
-UPDATE posts SET title = $2, content = $3, user_id = $4, post_category_id = $5, additional_notes = $6 WHERE post_id = $1;
+-- name: GetPostRevision :one
+-- Get a single revision of a post by its number
+SELECT * FROM post_revisions WHERE post_id = $1 AND revision_number = $2;
 
 -- name: DeletePost :exec
 DELETE FROM posts WHERE post_id = $1;
@@ -129,11 +278,48 @@ DELETE FROM posts WHERE post_id = $1;
 -- name: DeletePostByPostIdAndUserId :exec
 DELETE FROM posts WHERE post_id = $1 AND user_id = $2;
 
+-- name: GetPostsWithoutContentHTML :many
+-- Get the content of posts whose Markdown has not been rendered yet
+SELECT post_id, content FROM posts WHERE content_html IS NULL ORDER BY post_id LIMIT $1;
+
+-- name: SetPostContentHTML :exec
+-- Store the rendered content of a post, unless it was rendered by an update in the meantime
+UPDATE posts SET content_html = $2 WHERE post_id = $1 AND content_html IS NULL;
+
+-- name: AddPostScore :one
+-- Add to a post's score, returning the new score
+UPDATE posts SET score = score + sqlc.arg(delta)::int WHERE post_id = sqlc.arg(post_id) RETURNING score;
+
+-- name: TouchPostActivity :exec
+-- Mark a post as active now, for the active sort order
+UPDATE posts SET last_activity_date = CURRENT_TIMESTAMP WHERE post_id = $1;
+
+-- name: GetPostVote :one
+-- Get a user's vote on a post
+SELECT * FROM post_votes WHERE post_id = $1 AND user_id = $2;
+
+-- name: UpsertPostVote :exec
+-- Create or change a user's vote on a post
+INSERT INTO post_votes (post_id, user_id, value) VALUES ($1, $2, $3)
+ON CONFLICT (post_id, user_id) DO UPDATE SET value = EXCLUDED.value, creation_date = CURRENT_TIMESTAMP;
+
+-- name: DeletePostVote :exec
+-- Remove a user's vote on a post
+DELETE FROM post_votes WHERE post_id = $1 AND user_id = $2;
+
 -- name: GetStickyPosts :many
-SELECT * FROM posts WHERE is_sticky = TRUE ORDER BY creation_date DESC;
+-- Get a page of sticky posts, newest first, starting after the cursor
+SELECT * FROM posts WHERE is_sticky = TRUE
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (posts.creation_date, posts.post_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY creation_date DESC, post_id DESC
+LIMIT sqlc.arg(page_limit);
 
 -- name: GetLockedPosts :many
-SELECT * FROM posts WHERE is_locked = TRUE ORDER BY creation_date DESC;
+-- Get a page of locked posts, newest first, starting after the cursor
+SELECT * FROM posts WHERE is_locked = TRUE
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (posts.creation_date, posts.post_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY creation_date DESC, post_id DESC
+LIMIT sqlc.arg(page_limit);
 
 -- name: GetUnlockedPosts :many
 SELECT * FROM posts WHERE is_locked = FALSE ORDER BY creation_date DESC;
@@ -150,6 +336,21 @@ UPDATE posts SET is_sticky = TRUE WHERE post_id = $1;
 -- name: UnstickyPost :exec
 UPDATE posts SET is_sticky = FALSE WHERE post_id = $1;
 
+-- name: SearchPosts :many
+-- Full-text search of post titles and content, best match first, starting after the cursor
+SELECT posts.post_id, posts.title, posts.creation_date, posts.user_id, posts.post_category_id, users.username,
+  ts_rank_cd(post_search_document(posts.title, posts.content), websearch_to_tsquery('english', sqlc.arg(query)::text))::float8 AS rank,
+  ts_headline('english', posts.title, websearch_to_tsquery('english', sqlc.arg(query)::text), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS title_highlight,
+  ts_headline('english', posts.content, websearch_to_tsquery('english', sqlc.arg(query)::text), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')::text AS snippet
+FROM posts
+LEFT JOIN users ON posts.user_id = users.user_id
+WHERE post_search_document(posts.title, posts.content) @@ websearch_to_tsquery('english', sqlc.arg(query)::text)
+AND (sqlc.narg(category_id)::int IS NULL OR posts.post_category_id = sqlc.narg(category_id)::int)
+AND (sqlc.narg(author_id)::int IS NULL OR posts.user_id = sqlc.narg(author_id)::int)
+AND (sqlc.narg(cursor_rank)::float8 IS NULL OR (ts_rank_cd(post_search_document(posts.title, posts.content), websearch_to_tsquery('english', sqlc.arg(query)::text))::float8, posts.post_id) < (sqlc.narg(cursor_rank)::float8, sqlc.narg(cursor_id)::int))
+ORDER BY rank DESC, posts.post_id DESC
+LIMIT sqlc.arg(page_limit);
+
 ------------------------------------------------------------------------------------------------------------------------
 
 -- name: GetComment :one
@@ -161,28 +362,108 @@ SELECT * FROM comments WHERE comment_id = $1;
 SELECT * FROM comments ORDER BY creation_date DESC;
 
 -- name: GetCommentsByPost :many
--- Get all comments for a specific post, ordered by creation date
-SELECT comments.*, users.username 
+-- Get a page of comments for a specific post, newest first, starting after the cursor, with their number of direct replies
+SELECT comments.*, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
 FROM comments 
-INNER JOIN users ON comments.user_id = users.user_id 
-WHERE post_id = $1 
-ORDER BY comments.creation_date DESC;
+LEFT JOIN users ON comments.user_id = users.user_id 
+WHERE comments.post_id = sqlc.arg(post_id) 
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY comments.creation_date DESC, comments.comment_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTopLevelCommentsByPost :many
+-- Get a page of the top level comments of a post, newest first, starting after the cursor, with their number of direct replies
+SELECT comments.*, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
+FROM comments 
+LEFT JOIN users ON comments.user_id = users.user_id 
+WHERE comments.post_id = sqlc.arg(post_id) AND comments.parent_comment_id IS NULL
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY comments.creation_date DESC, comments.comment_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetCommentsByRootIDs :many
+-- Get all replies in the threads of the given top level comments, oldest first, with their number of direct replies
+SELECT comments.*, users.username,
+  (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_comment_id = comments.comment_id)::int AS reply_count
+FROM comments
+LEFT JOIN users ON comments.user_id = users.user_id
+WHERE comments.root_comment_id = ANY(sqlc.arg(root_ids)::int[])
+ORDER BY comments.creation_date, comments.comment_id;
 
 -- name: GetCommentsByUser :many
--- Get all comments made by a specific user, ordered by creation date
-SELECT * FROM comments WHERE user_id = $1 ORDER BY creation_date DESC;
+-- Get a page of comments made by a specific user, newest first, starting after the cursor
+SELECT * FROM comments WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (comments.creation_date, comments.comment_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY creation_date DESC, comment_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SearchComments :many
+-- Full-text search of comment content, best match first, starting after the cursor
+SELECT comments.comment_id, comments.post_id, comments.creation_date, comments.user_id, users.username, posts.title AS post_title,
+  ts_rank_cd(comment_search_document(comments.content), websearch_to_tsquery('english', sqlc.arg(query)::text))::float8 AS rank,
+  ts_headline('english', comments.content, websearch_to_tsquery('english', sqlc.arg(query)::text), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "')::text AS snippet
+FROM comments
+INNER JOIN posts ON comments.post_id = posts.post_id
+LEFT JOIN users ON comments.user_id = users.user_id
+WHERE comment_search_document(comments.content) @@ websearch_to_tsquery('english', sqlc.arg(query)::text)
+AND (sqlc.narg(category_id)::int IS NULL OR posts.post_category_id = sqlc.narg(category_id)::int)
+AND (sqlc.narg(author_id)::int IS NULL OR comments.user_id = sqlc.narg(author_id)::int)
+AND (sqlc.narg(cursor_rank)::float8 IS NULL OR (ts_rank_cd(comment_search_document(comments.content), websearch_to_tsquery('english', sqlc.arg(query)::text))::float8, comments.comment_id) < (sqlc.narg(cursor_rank)::float8, sqlc.narg(cursor_id)::int))
+ORDER BY rank DESC, comments.comment_id DESC
+LIMIT sqlc.arg(page_limit);
 
 -- name: CreateComment :one
 -- Create a new comment
-INSERT INTO comments (content, post_id, user_id) VALUES ($1, $2, $3) RETURNING *;
+INSERT INTO comments (content, content_html, post_id, user_id, parent_comment_id, root_comment_id, depth)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
 
 -- name: UpdateComment :one
 -- Update a comment's content
-UPDATE comments SET content = $2 WHERE comment_id = $1 RETURNING *;
+UPDATE comments SET content = $2, content_html = $3 WHERE comment_id = $1 RETURNING *;
 
 -- name: UpdateCommentByCommentIdAndUserId :one
 -- Update a comment's content by its ID and the ID of the user who made it
-UPDATE comments SET content = $3 WHERE comment_id = $1 AND user_id = $2 RETURNING *;
+UPDATE comments SET content = $3, content_html = $4 WHERE comment_id = $1 AND user_id = $2 RETURNING *;
+
+-- name: GetCommentsWithoutContentHTML :many
+-- Get the content of comments whose Markdown has not been rendered yet
+SELECT comment_id, content FROM comments WHERE content_html IS NULL ORDER BY comment_id LIMIT $1;
+
+-- name: SetCommentContentHTML :exec
+-- Store the rendered content of a comment, unless it was rendered by an update in the meantime
+UPDATE comments SET content_html = $2 WHERE comment_id = $1 AND content_html IS NULL;
+
+-- name: GetCommentForUpdate :one
+-- Get a comment by its ID, locking it until the end of the transaction
+SELECT * FROM comments WHERE comment_id = $1 FOR UPDATE;
+
+-- name: AddCommentScore :one
+-- Add to a comment's score, returning the new score
+UPDATE comments SET score = score + sqlc.arg(delta)::int WHERE comment_id = sqlc.arg(comment_id) RETURNING score;
+
+-- name: GetCommentVote :one
+-- Get a user's vote on a comment
+SELECT * FROM comment_votes WHERE comment_id = $1 AND user_id = $2;
+
+-- name: UpsertCommentVote :exec
+-- Create or change a user's vote on a comment
+INSERT INTO comment_votes (comment_id, user_id, value) VALUES ($1, $2, $3)
+ON CONFLICT (comment_id, user_id) DO UPDATE SET value = EXCLUDED.value, creation_date = CURRENT_TIMESTAMP;
+
+-- name: DeleteCommentVote :exec
+-- Remove a user's vote on a comment
+DELETE FROM comment_votes WHERE comment_id = $1 AND user_id = $2;
+
+-- name: CountCommentReplies :one
+-- Count the direct replies to a comment
+SELECT COUNT(*) FROM comments WHERE parent_comment_id = $1;
+
+-- name: SoftDeleteComment :exec
+-- Replace a comment that has replies with a placeholder, removing its content and author
+UPDATE comments SET content = '', content_html = '', user_id = NULL, is_deleted = TRUE WHERE comment_id = $1;
 
 -- name: DeleteComment :exec
 -- Delete a comment by its ID
@@ -195,17 +476,24 @@ DELETE FROM comments WHERE comment_id = $1 AND user_id = $2;
 ------------------------------------------------------------------------------------------------------------------------
 
 -- name: GetRoutes :many
--- Get all routes, ordered by name
-SELECT * FROM routes ORDER BY name;
+-- Get a page of routes, ordered by name, starting after the cursor
+SELECT * FROM routes
+WHERE sqlc.narg(cursor_id)::int IS NULL OR (name, route_id) > (sqlc.narg(cursor_name)::text, sqlc.narg(cursor_id)::int)
+ORDER BY name, route_id
+LIMIT sqlc.arg(page_limit);
 
 -- name: GetRouteByID :one
 -- Get a specific route by its ID
 SELECT * FROM routes WHERE route_id = $1;
 
--- name: CreateRoute :exec
+-- name: GetRouteByIDForUpdate :one
+-- Get a specific route by its ID, locking it until the end of the transaction
+SELECT * FROM routes WHERE route_id = $1 FOR UPDATE;
+
+-- name: CreateRoute :one
 -- Create a new route
 INSERT INTO routes (name, description, start_location, end_location, distance, elevation_gain, route_map_link, user_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
 
 -- name: UpdateRoute :exec
 -- Update a specific route
@@ -217,12 +505,55 @@ WHERE route_id = $1;
 DELETE FROM routes WHERE route_id = $1;
 
 -- name: GetRoutesByUserID :many
--- Get all routes created by a specific user
-SELECT * FROM routes WHERE user_id = $1 ORDER BY name;
+-- Get a page of routes created by a specific user, ordered by name, starting after the cursor
+SELECT * FROM routes WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR (name, route_id) > (sqlc.narg(cursor_name)::text, sqlc.narg(cursor_id)::int))
+ORDER BY name, route_id
+LIMIT sqlc.arg(page_limit);
 
 -- name: GetRoutesByDistance :many
--- Get all routes, ordered by distance
-SELECT * FROM routes ORDER BY distance;
+-- Get a page of routes, shortest first and routes without a distance last, starting after the cursor.
+-- A cursor without a distance is on a route without one.
+SELECT * FROM routes
+WHERE sqlc.narg(cursor_id)::int IS NULL
+OR (sqlc.narg(cursor_distance)::float8 IS NULL AND distance IS NULL AND route_id > sqlc.narg(cursor_id)::int)
+OR (sqlc.narg(cursor_distance)::float8 IS NOT NULL AND (distance IS NULL OR (distance, route_id) > (sqlc.narg(cursor_distance)::float8, sqlc.narg(cursor_id)::int)))
+ORDER BY distance NULLS LAST, route_id
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateRouteByRouteIdAndUserId :one
+-- Update a specific route by its ID and the ID of the user who created it
+UPDATE routes SET name = $3, description = $4, start_location = $5, end_location = $6, distance = $7, elevation_gain = $8, route_map_link = $9
+WHERE route_id = $1 AND user_id = $2 RETURNING *;
+
+-- name: DeleteRouteByRouteIdAndUserId :execrows
+-- Delete a specific route by its ID and the ID of the user who created it
+DELETE FROM routes WHERE route_id = $1 AND user_id = $2;
+
+-- name: UpdateRouteTrackStats :exec
+-- Update the distance and elevation gain of a route derived from its GPX track
+UPDATE routes SET distance = $2, elevation_gain = $3 WHERE route_id = $1;
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: CreateRoutePoints :exec
+-- Bulk insert the track points of a route, NaN elevations are stored as NULL
+INSERT INTO route_points (route_id, point_index, latitude, longitude, elevation)
+SELECT sqlc.arg(route_id)::int, p.point_index, p.latitude, p.longitude, NULLIF(p.elevation, 'NaN')
+FROM unnest(sqlc.arg(point_indexes)::int[], sqlc.arg(latitudes)::float8[], sqlc.arg(longitudes)::float8[], sqlc.arg(elevations)::float8[])
+AS p(point_index, latitude, longitude, elevation);
+
+-- name: GetRoutePointsByRouteID :many
+-- Get the track points of a route in order
+SELECT * FROM route_points WHERE route_id = $1 ORDER BY point_index;
+
+-- name: CountRoutePointsByRouteID :one
+-- Count the track points of a route
+SELECT COUNT(*) FROM route_points WHERE route_id = $1;
+
+-- name: DeleteRoutePointsByRouteID :exec
+-- Delete the track points of a route
+DELETE FROM route_points WHERE route_id = $1;
 
 ------------------------------------------------------------------------------------------------------------------------
 
@@ -234,10 +565,14 @@ SELECT * FROM events ORDER BY event_date;
 -- Get a specific event by its ID
 SELECT * FROM events WHERE event_id = $1;
 
--- name: CreateEvent :exec
+-- name: GetEventByIDForUpdate :one
+-- Get a specific event by its ID and lock it until the end of the transaction
+SELECT * FROM events WHERE event_id = $1 FOR UPDATE;
+
+-- name: CreateEvent :one
 -- Create a new event
-INSERT INTO events (title, description, event_date, meeting_point, route_id, creator_user_id)
-VALUES ($1, $2, $3, $4, $5, $6);
+INSERT INTO events (title, description, event_date, meeting_point, route_id, creator_user_id, capacity, recurrence_rule, time_zone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
 
 -- name: UpdateEvent :exec
 -- Update a specific event
@@ -248,6 +583,15 @@ WHERE event_id = $1;
 -- Delete a specific event
 DELETE FROM events WHERE event_id = $1;
 
+-- name: UpdateEventByEventIdAndUserId :one
+-- Update a specific event by its ID and the ID of the user who created it
+UPDATE events SET title = $3, description = $4, event_date = $5, meeting_point = $6, route_id = $7, capacity = $8, recurrence_rule = $9, time_zone = $10
+WHERE event_id = $1 AND creator_user_id = $2 RETURNING *;
+
+-- name: DeleteEventByEventIdAndUserId :execrows
+-- Delete a specific event by its ID and the ID of the user who created it
+DELETE FROM events WHERE event_id = $1 AND creator_user_id = $2;
+
 -- name: GetEventsByUserID :many
 -- Get all events created by a specific user
 SELECT * FROM events WHERE creator_user_id = $1 ORDER BY event_date;
@@ -256,25 +600,44 @@ SELECT * FROM events WHERE creator_user_id = $1 ORDER BY event_date;
 -- Get all events for a specific route
 SELECT * FROM events WHERE route_id = $1 ORDER BY event_date;
 
--- name: GetEventsByDate :many
--- Get all events, ordered by event date
-SELECT * FROM events ORDER BY event_date;
-
 -- name: GetEventsByDateRange :many
 -- Get all events within a date range
 SELECT * FROM events WHERE event_date >= $1 AND event_date <= $2 ORDER BY event_date;
 
--- name: GetEventsByDateRangeAndRouteID :many
--- Get all events within a date range for a specific route
-SELECT * FROM events WHERE event_date >= $1 AND event_date <= $2 AND route_id = $3 ORDER BY event_date;
-
--- name: GetEventsByDateRangeAndUserID :many
--- Get all events within a date range for a specific user
-SELECT * FROM events WHERE event_date >= $1 AND event_date <= $2 AND creator_user_id = $3 ORDER BY event_date;
+-- name: GetOneOffEventsPage :many
+-- Get a page of one-off events starting within a date range, optionally for a specific route and creator,
+-- ordered by start time, starting after the cursor
+SELECT * FROM events
+WHERE recurrence_rule IS NULL AND event_date >= sqlc.arg(from_date)::timestamptz AND event_date <= sqlc.arg(to_date)::timestamptz
+AND (sqlc.narg(route_id)::int IS NULL OR route_id = sqlc.narg(route_id)::int)
+AND (sqlc.narg(creator_user_id)::int IS NULL OR creator_user_id = sqlc.narg(creator_user_id)::int)
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (event_date, event_id) > (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY event_date, event_id
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetRecurringEventsStartingBefore :many
+-- Get all recurring events whose first occurrence is before a specific date
+SELECT * FROM events WHERE recurrence_rule IS NOT NULL AND event_date <= $1 ORDER BY event_date;
+
+-- name: GetEventOccurrencesByEventID :many
+-- Get the changed and cancelled occurrences of a recurring event
+SELECT * FROM event_occurrences WHERE event_id = $1 ORDER BY occurrence_date;
+
+-- name: GetEventOccurrence :one
+-- Get the changes to a single occurrence of a recurring event
+SELECT * FROM event_occurrences WHERE event_id = $1 AND occurrence_date = $2;
+
+-- name: UpsertEventOccurrence :one
+-- Cancel or change a single occurrence of a recurring event
+INSERT INTO event_occurrences (event_id, occurrence_date, is_cancelled, title, description, event_date, meeting_point)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (event_id, occurrence_date) DO UPDATE SET is_cancelled = EXCLUDED.is_cancelled, title = EXCLUDED.title,
+description = EXCLUDED.description, event_date = EXCLUDED.event_date, meeting_point = EXCLUDED.meeting_point
+RETURNING *;
 
--- name: GetEventsByDateRangeAndRouteIDAndUserID :many
--- Get all events within a date range for a specific route and user
-SELECT * FROM events WHERE event_date >= $1 AND event_date <= $2 AND route_id = $3 AND creator_user_id = $4 ORDER BY event_date;
+-- name: DeleteEventOccurrence :execrows
+-- Restore a single occurrence of a recurring event to the series
+DELETE FROM event_occurrences WHERE event_id = $1 AND occurrence_date = $2;
 
 ------------------------------------------------------------------------------------------------------------------------
 
@@ -286,13 +649,19 @@ SELECT * FROM rsvps;
 -- Get a specific RSVP by its ID
 SELECT * FROM rsvps WHERE rsvp_id = $1;
 
--- name: CreateRSVP :exec
+-- name: CreateRSVP :one
 -- Create a new RSVP
-INSERT INTO rsvps (event_id, user_id) VALUES ($1, $2);
+INSERT INTO rsvps (event_id, user_id, rsvp_status) VALUES ($1, $2, $3) RETURNING *;
 
--- name: UpdateRSVP :exec
--- Update a specific RSVP
-UPDATE rsvps SET event_id = $2, user_id = $3 WHERE rsvp_id = $1;
+-- name: UpdateRSVP :one
+-- Update the status of a specific RSVP
+UPDATE rsvps SET rsvp_status = $2, rsvp_date = CURRENT_TIMESTAMP WHERE rsvp_id = $1 RETURNING *;
+
+-- name: UpsertRSVP :one
+-- Create a user's RSVP to an event occurrence, or change its status if the user has already RSVP'd
+INSERT INTO rsvps (event_id, user_id, rsvp_status, occurrence_date) VALUES ($1, $2, $3, $4)
+ON CONFLICT (event_id, user_id, occurrence_date) DO UPDATE SET rsvp_status = EXCLUDED.rsvp_status, rsvp_date = CURRENT_TIMESTAMP
+RETURNING *;
 
 -- name: DeleteRSVP :exec
 -- Delete a specific RSVP
@@ -306,16 +675,78 @@ SELECT * FROM rsvps WHERE event_id = $1;
 -- Get all RSVPs for a specific user
 SELECT * FROM rsvps WHERE user_id = $1;
 
+-- name: GetRSVPsByUserIDPage :many
+-- Get a page of the RSVPs of a specific user, newest first, starting after the cursor
+SELECT * FROM rsvps WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(cursor_id)::int IS NULL OR rsvp_id < sqlc.narg(cursor_id)::int)
+ORDER BY rsvp_id DESC
+LIMIT sqlc.arg(page_limit);
+
 -- name: GetRSVPsByEventIDAndUserID :many
 -- Get all RSVPs for a specific event and user
 SELECT * FROM rsvps WHERE event_id = $1 AND user_id = $2;
 
+-- name: GetRSVPsByEventOccurrence :many
+-- Get all RSVPs for a specific event occurrence. The occurrence date is NULL for one-off events.
+SELECT * FROM rsvps WHERE event_id = sqlc.arg(event_id) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz;
+
+-- name: GetRSVPsByEventOccurrencePage :many
+-- Get a page of the RSVPs for a specific event occurrence, in the order they were first made, starting after the cursor
+SELECT * FROM rsvps WHERE event_id = sqlc.arg(event_id) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz
+AND (sqlc.narg(cursor_id)::int IS NULL OR rsvp_id > sqlc.narg(cursor_id)::int)
+ORDER BY rsvp_id
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetRSVPByEventIDAndUserID :one
+-- Get the RSVP of a specific user to a specific event occurrence
+SELECT * FROM rsvps WHERE event_id = sqlc.arg(event_id) AND user_id = sqlc.arg(user_id) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz;
+
+-- name: DeleteRSVPByEventIDAndUserID :execrows
+-- Delete the RSVP of a specific user to a specific event occurrence
+DELETE FROM rsvps WHERE event_id = sqlc.arg(event_id) AND user_id = sqlc.arg(user_id) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz;
+
+-- name: CountRSVPsByEventIDAndStatus :one
+-- Count the RSVPs for a specific event occurrence with a specific status
+SELECT COUNT(*) FROM rsvps WHERE event_id = sqlc.arg(event_id) AND rsvp_status = sqlc.arg(rsvp_status) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz;
+
+-- name: GetWaitlistedRSVPsByEventID :many
+-- Get the waitlist of a specific event occurrence, in the order members joined it
+SELECT * FROM rsvps WHERE event_id = sqlc.arg(event_id) AND rsvp_status = 'waitlisted' AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz
+ORDER BY rsvp_date, rsvp_id;
+
+-- name: GetNextWaitlistedRSVPForUpdate :one
+-- Get and lock the first RSVP on the waitlist of a specific event occurrence
+SELECT * FROM rsvps WHERE event_id = sqlc.arg(event_id) AND rsvp_status = 'waitlisted' AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz
+ORDER BY rsvp_date, rsvp_id LIMIT 1 FOR UPDATE;
+
+-- name: GetWaitlistedOccurrencesByEventID :many
+-- Get the occurrences of a specific event that have a waitlist
+SELECT occurrence_date FROM rsvps WHERE event_id = $1 AND rsvp_status = 'waitlisted' GROUP BY occurrence_date ORDER BY occurrence_date;
+
+-- name: CreateEventReminder :execrows
+-- Record that a reminder is being sent, affecting no rows if it has already been sent
+INSERT INTO event_reminders (event_id, occurrence_date, user_id, reminder_type) VALUES ($1, $2, $3, $4)
+ON CONFLICT (event_id, occurrence_date, user_id, reminder_type) DO NOTHING;
+
+-- name: GetRSVPUserIDsByEventID :many
+-- Get the users who have RSVPed to an event, other than declining, either to any occurrence or, when
+-- single_occurrence is true, to the occurrence at occurrence_date
+SELECT user_id FROM rsvps
+WHERE event_id = sqlc.arg(event_id) AND rsvp_status <> 'declined' AND user_id IS NOT NULL
+AND (NOT sqlc.arg(single_occurrence)::boolean OR occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz)
+GROUP BY user_id;
+
+-- name: CountRSVPsByEventIDGroupedByStatus :many
+-- Count the RSVPs for a specific event occurrence by status
+SELECT rsvp_status, COUNT(*) FROM rsvps WHERE event_id = sqlc.arg(event_id) AND occurrence_date IS NOT DISTINCT FROM sqlc.narg(occurrence_date)::timestamptz GROUP BY rsvp_status;
+
 ------------------------------------------------------------------------------------------------------------------------
 
--- name: CreatePrivateMessage :exec
+-- name: CreatePrivateMessage :one
 -- Create a new private message
 INSERT INTO private_messages (content, sender_user_id, receiver_user_id)
-VALUES ($1, $2, $3);
+VALUES ($1, $2, $3)
+RETURNING *;
 
 -- name: GetAllPrivateMessages :many
 -- Get all private messages
@@ -345,6 +776,60 @@ UPDATE private_messages SET content = $2 WHERE message_id = $1;
 -- Mark a private message as read
 UPDATE private_messages SET is_read = TRUE WHERE message_id = $1;
 
+-- name: GetConversations :many
+-- Get a page of a user's conversations, with the latest message of each, the other user and the number of messages
+-- they have sent that the user has not read, most recently active first, starting after the cursor.
+-- A conversation is every message between the same two users, in either direction.
+SELECT private_messages.*, partners.user_id AS partner_user_id, partners.username AS partner_username,
+(SELECT COUNT(*) FROM private_messages AS unread
+  WHERE unread.receiver_user_id = sqlc.arg(user_id)::int
+  AND unread.sender_user_id IS NOT DISTINCT FROM partners.user_id
+  AND unread.is_read = FALSE)::int AS unread_count
+FROM private_messages
+LEFT JOIN users AS partners ON partners.user_id = CASE WHEN private_messages.sender_user_id = sqlc.arg(user_id)::int THEN private_messages.receiver_user_id ELSE private_messages.sender_user_id END
+WHERE private_messages.message_id IN (
+  SELECT MAX(latest.message_id) FROM private_messages AS latest
+  WHERE latest.sender_user_id = sqlc.arg(user_id)::int OR latest.receiver_user_id = sqlc.arg(user_id)::int
+  GROUP BY LEAST(COALESCE(latest.sender_user_id, 0), COALESCE(latest.receiver_user_id, 0)), GREATEST(COALESCE(latest.sender_user_id, 0), COALESCE(latest.receiver_user_id, 0))
+)
+AND (sqlc.narg(cursor_id)::int IS NULL OR private_messages.message_id < sqlc.narg(cursor_id)::int)
+ORDER BY private_messages.message_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetConversationMessages :many
+-- Get a page of the messages between two users, newest first, starting after the cursor
+SELECT * FROM private_messages
+WHERE ((sender_user_id = sqlc.arg(user_id)::int AND receiver_user_id = sqlc.arg(partner_user_id)::int)
+  OR (sender_user_id = sqlc.arg(partner_user_id)::int AND receiver_user_id = sqlc.arg(user_id)::int))
+AND (sqlc.narg(cursor_id)::int IS NULL OR message_id < sqlc.narg(cursor_id)::int)
+ORDER BY message_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: MarkPrivateMessageDelivered :execrows
+-- Record that a message reached its receiver, unless it already had, so that only the first of the receiver's
+-- connections to get it sends a delivery receipt
+UPDATE private_messages SET delivered_date = CURRENT_TIMESTAMP WHERE message_id = $1 AND delivered_date IS NULL;
+
+-- name: MarkConversationAsRead :many
+-- Mark the messages a user has received from another user as read, up to and including up_to_message_id
+-- when it is given, returning the IDs of the messages that were unread
+UPDATE private_messages SET is_read = TRUE
+WHERE receiver_user_id = sqlc.arg(user_id)::int AND sender_user_id = sqlc.arg(partner_user_id)::int AND is_read = FALSE
+AND (sqlc.narg(up_to_message_id)::int IS NULL OR message_id <= sqlc.narg(up_to_message_id)::int)
+RETURNING message_id;
+
+-- name: HasConversation :one
+-- Check whether two users have exchanged a private message in either direction
+SELECT EXISTS (
+  SELECT 1 FROM private_messages
+  WHERE (sender_user_id = sqlc.arg(user_id)::int AND receiver_user_id = sqlc.arg(partner_user_id)::int)
+  OR (sender_user_id = sqlc.arg(partner_user_id)::int AND receiver_user_id = sqlc.arg(user_id)::int)
+)::boolean AS has_conversation;
+
+-- name: CountUnreadPrivateMessages :one
+-- Count the messages a user has received and not read
+SELECT COUNT(*) FROM private_messages WHERE receiver_user_id = $1 AND is_read = FALSE;
+
 -- name: DeletePrivateMessage :exec
 -- Delete a private message by its ID
 DELETE FROM private_messages WHERE message_id = $1;
@@ -371,33 +856,79 @@ VALUES ($1, $2, $3, $4, $5, $6);
 SELECT * FROM forum_moderation_log WHERE log_id = $1;
 
 -- name: GetAllLogs :many
-SELECT * FROM forum_moderation_log;
-
--- name: UpdateLog :exec
-UPDATE forum_moderation_log
-SET action = $2, moderator_user_id = $3, affected_user_id = $4, post_id = $5, comment_id = $6, reason = $7
-WHERE log_id = $1;
-
--- name: DeleteLog :exec
-DELETE FROM forum_moderation_log WHERE log_id = $1;
-
--- name: GetLogsByAction :many
-SELECT * FROM forum_moderation_log WHERE action = $1;
-
--- name: GetLogsByModerator :many
-SELECT * FROM forum_moderation_log WHERE moderator_user_id = $1 ORDER BY action_date DESC;
+-- Get a page of moderation logs matching all of the given filters, newest first, starting after the cursor.
+-- Each filter is skipped when NULL, so this is the only query reading the log. The log is append-only, so there are
+-- no queries to update or delete entries.
+SELECT forum_moderation_log.*, moderators.username AS moderator_username, affected_users.username AS affected_username
+FROM forum_moderation_log
+LEFT JOIN users AS moderators ON forum_moderation_log.moderator_user_id = moderators.user_id
+LEFT JOIN users AS affected_users ON forum_moderation_log.affected_user_id = affected_users.user_id
+WHERE (sqlc.narg(action)::text IS NULL OR forum_moderation_log.action = sqlc.narg(action)::text)
+AND (sqlc.narg(moderator_user_id)::int IS NULL OR forum_moderation_log.moderator_user_id = sqlc.narg(moderator_user_id)::int)
+AND (sqlc.narg(affected_user_id)::int IS NULL OR forum_moderation_log.affected_user_id = sqlc.narg(affected_user_id)::int)
+AND (sqlc.narg(post_id)::int IS NULL OR forum_moderation_log.post_id = sqlc.narg(post_id)::int)
+AND (sqlc.narg(comment_id)::int IS NULL OR forum_moderation_log.comment_id = sqlc.narg(comment_id)::int)
+AND (sqlc.narg(reason)::text IS NULL OR forum_moderation_log.reason ILIKE '%' || sqlc.narg(reason)::text || '%')
+AND (sqlc.narg(from_date)::timestamptz IS NULL OR forum_moderation_log.action_date >= sqlc.narg(from_date)::timestamptz)
+AND (sqlc.narg(to_date)::timestamptz IS NULL OR forum_moderation_log.action_date < sqlc.narg(to_date)::timestamptz)
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (forum_moderation_log.action_date, forum_moderation_log.log_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY forum_moderation_log.action_date DESC, forum_moderation_log.log_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpsertReport :one
+-- Open a report on a target, or return the report that is already open or in review for it
+INSERT INTO reports (target_type, target_id, target_user_id) VALUES ($1, $2, $3)
+ON CONFLICT (target_type, target_id) WHERE status IN ('open', 'in_review')
+DO UPDATE SET last_reported_date = CURRENT_TIMESTAMP
+RETURNING *;
 
--- name: GetLogsByAffectedUser :many
-SELECT * FROM forum_moderation_log WHERE affected_user_id = $1 ORDER BY action_date DESC;
+-- name: CreateReportSubmission :execrows
+-- Add a user's report to a report, doing nothing if they have already reported it
+INSERT INTO report_submissions (report_id, reporter_user_id, reason_category, note) VALUES ($1, $2, $3, $4)
+ON CONFLICT (report_id, reporter_user_id) DO NOTHING;
 
--- name: GetLogsByPost :many
-SELECT * FROM forum_moderation_log WHERE post_id = $1;
+-- name: IncrementReportSubmissionCount :one
+UPDATE reports SET submission_count = submission_count + 1 WHERE report_id = $1
+RETURNING *;
 
--- name: GetLogsByComment :many
-SELECT * FROM forum_moderation_log WHERE comment_id = $1;
+-- name: GetReports :many
+-- Get a page of reports matching the given filters, most recently reported first, starting after the cursor.
+-- Each filter is skipped when NULL.
+SELECT reports.*, target_users.username AS target_username, reviewers.username AS reviewer_username
+FROM reports
+LEFT JOIN users AS target_users ON reports.target_user_id = target_users.user_id
+LEFT JOIN users AS reviewers ON reports.reviewer_user_id = reviewers.user_id
+WHERE (sqlc.narg(status)::text IS NULL OR reports.status = sqlc.narg(status)::text)
+AND (sqlc.narg(target_type)::text IS NULL OR reports.target_type = sqlc.narg(target_type)::text)
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (reports.last_reported_date, reports.report_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY reports.last_reported_date DESC, reports.report_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE report_id = $1;
+
+-- name: GetReportForUpdate :one
+-- Get a report and lock it until the end of the transaction
+SELECT * FROM reports WHERE report_id = $1 FOR UPDATE;
+
+-- name: GetReportSubmissions :many
+-- Get the submissions of a report with their reporters' usernames, oldest first
+SELECT report_submissions.*, users.username AS reporter_username
+FROM report_submissions
+LEFT JOIN users ON report_submissions.reporter_user_id = users.user_id
+WHERE report_submissions.report_id = $1
+ORDER BY report_submissions.creation_date, report_submissions.submission_id;
+
+-- name: ReviewReport :one
+-- Mark a report as in review by a moderator
+UPDATE reports SET status = 'in_review', reviewer_user_id = $2 WHERE report_id = $1
+RETURNING *;
 
--- name: GetLogsByReason :many
-SELECT * FROM forum_moderation_log WHERE reason = $1;
+-- name: ResolveReport :one
+-- Mark a report as actioned or dismissed
+UPDATE reports SET status = $2, reviewer_user_id = $3, resolution_action = $4, resolution_note = $5, resolution_date = CURRENT_TIMESTAMP
+WHERE report_id = $1
+RETURNING *;
 
 ------------------------------------------------------------------------------------------------------------------------
 
@@ -407,7 +938,8 @@ SELECT * FROM user_sessions WHERE session_id = $1;
 
 -- name: GetUserSessionAndRoleName :one
 -- Get a single user session by session_id, with role_name
-SELECT user_sessions.session_id, user_sessions.user_id, user_sessions.expiry_date, user_sessions.ip_address, user_sessions.user_agent, user_sessions.creation_date, users.role_id, roles.role_name
+SELECT user_sessions.session_id, user_sessions.user_id, user_sessions.expiry_date, user_sessions.ip_address, user_sessions.user_agent, user_sessions.creation_date, users.role_id, roles.role_name,
+users.is_active, users.suspended_until, users.suspension_reason, users.email_verified_at
 FROM user_sessions
 INNER JOIN users ON user_sessions.user_id = users.user_id
 INNER JOIN roles ON users.role_id = roles.role_id
@@ -446,45 +978,104 @@ SELECT * FROM user_sessions ORDER BY creation_date;
 -- Get all user sessions after a specific date
 SELECT * FROM user_sessions WHERE creation_date > $1;
 
+-- name: DeleteUserSessionsByUserId :exec
+-- Delete all sessions of a user, logging them out everywhere
+DELETE FROM user_sessions WHERE user_id = $1;
+
 -- name: InvalidateUserSession :exec
 -- Invalidate a user session by setting the expiry_date to a past date
 UPDATE user_sessions SET expiry_date = TIMESTAMP '1970-01-01 00:00:00' WHERE session_id = $1;
 
+-- name: CreateStreamTicket :exec
+-- Create a single-use ticket opening a stream for a session
+INSERT INTO stream_tickets (ticket, session_id, expiry_date) VALUES ($1, $2, $3);
+
+-- name: RedeemStreamTicket :one
+-- Use up a stream ticket that has not expired, returning the session it opens a stream for
+DELETE FROM stream_tickets WHERE ticket = sqlc.arg(ticket) AND expiry_date > sqlc.arg(now)::timestamptz
+RETURNING session_id;
+
+-- name: DeleteExpiredStreamTickets :exec
+-- Delete the stream tickets that expired without being used
+DELETE FROM stream_tickets WHERE expiry_date <= sqlc.arg(now)::timestamptz;
+
 ------------------------------------------------------------------------------------------------------------------------
 
--- @name GetNotifications :many
+-- name: GetCalendarTokenByUserID :one
+-- Get the private calendar feed token of a user
+SELECT * FROM calendar_tokens WHERE user_id = $1;
+
+-- name: GetCalendarTokenByToken :one
+-- Get a calendar feed token, used to find the user whose feed is requested
+SELECT * FROM calendar_tokens WHERE token = $1;
+
+-- name: UpsertCalendarToken :one
+-- Create or replace the private calendar feed token of a user
+INSERT INTO calendar_tokens (user_id, token) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, creation_date = CURRENT_TIMESTAMP
+RETURNING *;
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: GetNotifications :many
 -- Get all notifications, ordered by creation_date
 SELECT * FROM notifications ORDER BY creation_date DESC;
 
--- @name GetNotificationById :one
+-- name: GetNotificationById :one
 -- Get a single notification by its ID
 SELECT * FROM notifications WHERE notification_id = $1;
 
--- @name GetNotificationsByUserId :many
--- Get all notifications for a specific user, ordered by creation_date
-SELECT * FROM notifications WHERE user_id = $1 ORDER BY creation_date DESC;
-
--- @name GetUnreadNotificationsByUserId :many
+-- name: GetNotificationsByUserId :many
+-- Get a page of a user's notifications, newest first, starting after the cursor. Only unread notifications are
+-- included when unread_only is true.
+SELECT notifications.*, actors.username AS actor_username
+FROM notifications
+LEFT JOIN users AS actors ON notifications.actor_user_id = actors.user_id
+WHERE notifications.user_id = sqlc.arg(user_id)::int
+AND (NOT sqlc.arg(unread_only)::boolean OR notifications.is_read = FALSE)
+AND (sqlc.narg(cursor_date)::timestamptz IS NULL OR (notifications.creation_date, notifications.notification_id) < (sqlc.narg(cursor_date)::timestamptz, sqlc.narg(cursor_id)::int))
+ORDER BY notifications.creation_date DESC, notifications.notification_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetUnreadNotificationsByUserId :many
 -- Get all unread notifications for a specific user, ordered by creation_date
 SELECT * FROM notifications WHERE user_id = $1 AND is_read = FALSE ORDER BY creation_date DESC;
 
--- @name CreateNotification :exec
+-- name: CountUnreadNotificationsByUserId :one
+-- Count the unread notifications of a specific user
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND is_read = FALSE;
+
+-- name: CreateNotification :one
 -- Create a new notification
-INSERT INTO notifications (user_id, content) VALUES ($1, $2);
+INSERT INTO notifications (user_id, content, notification_type, actor_user_id, post_id, comment_id, message_id, event_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
 
--- @name UpdateNotification :exec
+-- name: UpdateNotification :exec
 -- Update a notification's content
 UPDATE notifications SET content = $2 WHERE notification_id = $1;
 
--- @name MarkNotificationAsRead :exec
+-- name: MarkNotificationAsRead :exec
 -- Mark a notification as read
 UPDATE notifications SET is_read = TRUE WHERE notification_id = $1;
 
--- @name DeleteNotification :exec
+-- name: MarkNotificationAsReadByNotificationIdAndUserId :execrows
+-- Mark a notification of a specific user as read
+UPDATE notifications SET is_read = TRUE WHERE notification_id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsAsReadByUserId :execrows
+-- Mark every notification of a specific user as read
+UPDATE notifications SET is_read = TRUE WHERE user_id = $1 AND is_read = FALSE;
+
+-- name: DeleteNotification :exec
 -- Delete a notification by its ID
 DELETE FROM notifications WHERE notification_id = $1;
 
--- @name DeleteNotificationsByUserId :exec
+-- name: DeleteNotificationByNotificationIdAndUserId :execrows
+-- Delete a notification of a specific user
+DELETE FROM notifications WHERE notification_id = $1 AND user_id = $2;
+
+-- name: DeleteNotificationsByUserId :exec
 -- Delete all notifications for a specific user
 DELETE FROM notifications WHERE user_id = $1;
 
@@ -517,3 +1108,157 @@ DELETE FROM bookmarks WHERE bookmark_id = $1;
 -- name: DeleteBookmarksByUser :exec
 -- Delete all bookmarks of a user
 DELETE FROM bookmarks WHERE user_id = $1;
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: WatchPost :exec
+-- Watch a post, doing nothing if the user already watches it
+INSERT INTO post_watches (user_id, post_id) VALUES ($1, $2)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnwatchPost :execrows
+DELETE FROM post_watches WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetPostWatch :one
+SELECT * FROM post_watches WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetPostWatcherIDs :many
+-- Get the users watching a post
+SELECT user_id FROM post_watches WHERE post_id = $1;
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: GetLatestNotificationID :one
+-- Get the ID of the newest notification, where the real-time stream starts for a new connection
+SELECT COALESCE(MAX(notification_id), 0)::int AS latest_id FROM notifications;
+
+-- name: GetLatestPrivateMessageID :one
+SELECT COALESCE(MAX(message_id), 0)::int AS latest_id FROM private_messages;
+
+-- name: GetLatestCommentID :one
+SELECT COALESCE(MAX(comment_id), 0)::int AS latest_id FROM comments;
+
+-- name: GetNotificationsByUserIdAfter :many
+-- Get a user's notifications created after after_id or since a time, oldest first, to resume the real-time stream.
+-- The time catches notifications committed after ones with higher IDs. Pages start after page_after_id.
+SELECT * FROM notifications WHERE user_id = sqlc.arg(user_id)::int
+AND (notification_id > sqlc.arg(after_id)::int OR creation_date > sqlc.arg(since)::timestamptz)
+AND notification_id > sqlc.arg(page_after_id)::int
+ORDER BY notification_id
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetPrivateMessagesByUserIdAfter :many
+-- Get the messages a user has sent or received after after_id or since a time, oldest first, to resume the real-time
+-- stream. Pages start after page_after_id.
+SELECT * FROM private_messages
+WHERE (sender_user_id = sqlc.arg(user_id)::int OR receiver_user_id = sqlc.arg(user_id)::int)
+AND (message_id > sqlc.arg(after_id)::int OR sent_date > sqlc.arg(since)::timestamptz)
+AND message_id > sqlc.arg(page_after_id)::int
+ORDER BY message_id
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetWatchedCommentsByUserIdAfter :many
+-- Get the comments made after after_id or since a time on the posts a user watches, oldest first, to resume the
+-- real-time stream. Pages start after page_after_id.
+SELECT comments.* FROM comments
+INNER JOIN post_watches ON comments.post_id = post_watches.post_id
+WHERE post_watches.user_id = sqlc.arg(user_id)::int AND comments.is_deleted = FALSE
+AND (comments.comment_id > sqlc.arg(after_id)::int OR comments.creation_date > sqlc.arg(since)::timestamptz)
+AND comments.comment_id > sqlc.arg(page_after_id)::int
+ORDER BY comments.comment_id
+LIMIT sqlc.arg(page_limit);
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: FollowCategory :exec
+-- Follow a category, doing nothing if the user already follows it
+INSERT INTO category_follows (user_id, category_id) VALUES ($1, $2)
+ON CONFLICT (user_id, category_id) DO NOTHING;
+
+-- name: UnfollowCategory :execrows
+DELETE FROM category_follows WHERE user_id = $1 AND category_id = $2;
+
+-- name: GetFollowedCategories :many
+-- Get the categories a user follows, by name
+SELECT categories.* FROM categories
+INNER JOIN category_follows ON categories.category_id = category_follows.category_id
+WHERE category_follows.user_id = $1
+ORDER BY categories.name;
+
+-- name: GetFollowedCategoryPostsSince :many
+-- Get the posts created since a date in the categories a user follows, newest first, for their digest
+SELECT posts.post_id, posts.title, posts.creation_date, categories.name AS category_name, authors.username AS author_username
+FROM posts
+INNER JOIN category_follows ON posts.post_category_id = category_follows.category_id
+INNER JOIN categories ON posts.post_category_id = categories.category_id
+LEFT JOIN users AS authors ON posts.user_id = authors.user_id
+WHERE category_follows.user_id = sqlc.arg(user_id)::int AND posts.creation_date > sqlc.arg(since)::timestamptz
+AND (posts.user_id IS NULL OR posts.user_id <> sqlc.arg(user_id)::int)
+ORDER BY posts.creation_date DESC
+LIMIT sqlc.arg(page_limit);
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: GetEmailPreferencesByUserID :one
+SELECT * FROM email_preferences WHERE user_id = $1;
+
+-- name: GetEmailPreferencesByToken :one
+-- Get email preferences by unsubscribe token, used to find the user who follows an unsubscribe link
+SELECT * FROM email_preferences WHERE unsubscribe_token = $1;
+
+-- name: CreateEmailPreferences :one
+-- Create a user's email preferences with the defaults, returning the existing ones if they already have them
+INSERT INTO email_preferences (user_id, unsubscribe_token) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING *;
+
+-- name: UpdateDigestFrequency :one
+UPDATE email_preferences SET digest_frequency = $2 WHERE user_id = $1
+RETURNING *;
+
+-- name: UnsubscribeFromDigests :execrows
+-- Stop sending digests to the user an unsubscribe token belongs to
+UPDATE email_preferences SET digest_frequency = 'off' WHERE unsubscribe_token = $1;
+
+-- name: GetUsersDueForDigest :many
+-- Get the active, verified users whose next digest is due, which is when they have never had one or their latest is
+-- older than their digest frequency
+SELECT users.user_id, users.username, users.email,
+  COALESCE(email_preferences.digest_frequency, 'weekly')::text AS digest_frequency, email_preferences.last_digest_date
+FROM users
+LEFT JOIN email_preferences ON users.user_id = email_preferences.user_id
+WHERE users.is_active = TRUE AND users.email_verified_at IS NOT NULL
+AND COALESCE(email_preferences.digest_frequency, 'weekly') <> 'off'
+AND (
+  email_preferences.last_digest_date IS NULL
+  OR (email_preferences.digest_frequency = 'daily' AND email_preferences.last_digest_date <= sqlc.arg(daily_before)::timestamptz)
+  OR (email_preferences.digest_frequency = 'weekly' AND email_preferences.last_digest_date <= sqlc.arg(weekly_before)::timestamptz)
+)
+ORDER BY users.user_id;
+
+-- name: ClaimDigest :one
+-- Record that a user's digest is being sent, unless another server instance already did since it was found due.
+-- Returns no rows when the digest was claimed elsewhere.
+INSERT INTO email_preferences (user_id, unsubscribe_token, last_digest_date)
+VALUES (sqlc.arg(user_id)::int, sqlc.arg(unsubscribe_token)::uuid, sqlc.arg(now)::timestamptz)
+ON CONFLICT (user_id) DO UPDATE SET last_digest_date = EXCLUDED.last_digest_date
+WHERE email_preferences.last_digest_date IS NOT DISTINCT FROM sqlc.narg(previous_digest_date)::timestamptz
+RETURNING *;
+
+-- name: ReleaseDigest :exec
+-- Undo the claim of a digest that could not be sent, so that the next run retries it
+UPDATE email_preferences SET last_digest_date = sqlc.narg(previous_digest_date)::timestamptz
+WHERE user_id = sqlc.arg(user_id)::int AND last_digest_date = sqlc.arg(claimed_digest_date)::timestamptz;
+
+------------------------------------------------------------------------------------------------------------------------
+
+-- name: ClaimVerificationEmail :execrows
+-- Record that a verification email is being sent to an unverified user, unless one was sent since throttle_before
+UPDATE users SET verification_sent_date = sqlc.arg(now)::timestamptz
+WHERE user_id = sqlc.arg(user_id)::int AND email_verified_at IS NULL
+AND (verification_sent_date IS NULL OR verification_sent_date <= sqlc.arg(throttle_before)::timestamptz);
+
+-- name: VerifyUserEmail :execrows
+-- Mark a user's email address as verified, as long as it is still the address the verification link was sent to
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND email = $2 AND email_verified_at IS NULL;
@@ -0,0 +1,426 @@
+// Package rrule parses and expands the subset of RFC 5545 recurrence rules used for recurring events:
+// FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds expansion so that a rule which never produces an occurrence cannot loop forever
+const maxPeriods = 100000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayNum is a BYDAY entry such as SA, 1SA (first Saturday) or -1SU (last Sunday)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 matches every such weekday in the period
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 when the rule is not limited by a count
+	Until      time.Time // zero when the rule is not limited by an end time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Parse parses an RRULE value, with or without the "RRULE:" prefix
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		key = strings.ToUpper(key)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rule part %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly && r.Freq != Yearly {
+				err = fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parsePositive(value)
+		case "COUNT":
+			r.Count, err = parsePositive(value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseIntList(value, 12)
+			for _, m := range months {
+				if m < 0 {
+					err = fmt.Errorf("invalid BYMONTH %d", m)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				err = errors.New("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, errors.New("numbered BYDAY is only supported with FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	if r.Freq == Yearly && (len(r.ByDay) > 0 || len(r.ByMonthDay) > 0) && len(r.ByMonth) == 0 {
+		return nil, errors.New("BYDAY and BYMONTHDAY with FREQ=YEARLY require BYMONTH")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY is not supported with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+func parsePositive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+// parseUntil accepts a UTC date-time or a date, which includes the whole day
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(strings.ToUpper(value), ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+		}
+		days = append(days, WeekdayNum{Weekday: weekday, N: n})
+	}
+	return days, nil
+}
+
+func parseIntList(value string, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -max || n > max {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// String formats the rule in a canonical form
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = weekdayNames[d.Weekday]
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the start times of the occurrences of a series starting at dtstart that fall within [from, to], in order.
+// Occurrences keep the wall clock time of dtstart in its location, so a series follows daylight saving changes.
+// As in RFC 5545, dtstart itself is always the first occurrence.
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	r.Each(dtstart, from, to, func(t time.Time) bool {
+		occurrences = append(occurrences, t)
+		return true
+	})
+	return occurrences
+}
+
+// Each calls fn with the start time of each occurrence that Between would return, in order, and stops expanding the
+// series when fn returns false
+func (r *Rule) Each(dtstart, from, to time.Time, fn func(time.Time) bool) {
+	if !dtstart.Before(from) && !dtstart.After(to) && !fn(dtstart) {
+		return
+	}
+	count := 1
+	if r.Count == 1 {
+		return
+	}
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.candidates(dtstart, period)
+		start := r.periodStart(dtstart, period)
+		if len(candidates) == 0 && (start.After(to) || (!r.Until.IsZero() && start.After(r.Until))) {
+			return
+		}
+		for _, t := range candidates {
+			if !t.After(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return
+			}
+			if t.After(to) {
+				return
+			}
+			count++
+			if !t.Before(from) && !fn(t) {
+				return
+			}
+			if r.Count > 0 && count >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// IsOccurrence reports whether t is the start time of an occurrence of a series starting at dtstart
+func (r *Rule) IsOccurrence(dtstart, t time.Time) bool {
+	occurrences := r.Between(dtstart, t, t)
+	return len(occurrences) == 1 && occurrences[0].Equal(t)
+}
+
+// periodStart returns the first day of the nth period of the series
+func (r *Rule) periodStart(dtstart time.Time, n int) time.Time {
+	y, m, d := dtstart.Date()
+	step := n * r.Interval
+	switch r.Freq {
+	case Daily:
+		return time.Date(y, m, d+step, 0, 0, 0, 0, dtstart.Location())
+	case Weekly:
+		// weeks start on Monday
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset+7*step, 0, 0, 0, 0, dtstart.Location())
+	case Monthly:
+		return time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, dtstart.Location())
+	default:
+		return time.Date(y+step, 1, 1, 0, 0, 0, 0, dtstart.Location())
+	}
+}
+
+// candidates returns the sorted occurrence times within the nth period, before COUNT and UNTIL are applied
+func (r *Rule) candidates(dtstart time.Time, n int) []time.Time {
+	start := r.periodStart(dtstart, n)
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		days = []time.Time{start}
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			days = []time.Time{start.AddDate(0, 0, (int(dtstart.Weekday())+6)%7)}
+		}
+		for _, d := range r.ByDay {
+			days = append(days, start.AddDate(0, 0, (int(d.Weekday)+6)%7))
+		}
+	case Monthly:
+		days = r.daysInMonth(dtstart, start.Year(), start.Month())
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, m := range months {
+			days = append(days, r.daysInMonth(dtstart, start.Year(), m)...)
+		}
+	}
+
+	hour, min, sec := dtstart.Clock()
+	var times []time.Time
+	for _, day := range days {
+		if !r.matchesFilters(day) {
+			continue
+		}
+		times = append(times, time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, dtstart.Nanosecond(), dtstart.Location()))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return dedupe(times)
+}
+
+// daysInMonth expands BYMONTHDAY and BYDAY within a month, defaulting to the day of month of dtstart.
+// Days that do not exist in the month, such as the 31st of April, are skipped.
+func (r *Rule) daysInMonth(dtstart time.Time, year int, month time.Month) []time.Time {
+	loc := dtstart.Location()
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	length := first.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	addDay := func(d int) {
+		if d >= 1 && d <= length {
+			days = append(days, time.Date(year, month, d, 0, 0, 0, 0, loc))
+		}
+	}
+
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = length + d + 1
+		}
+		addDay(d)
+	}
+	if len(r.ByMonthDay) == 0 {
+		for _, wd := range r.ByDay {
+			firstOfWeekday := 1 + (int(wd.Weekday)-int(first.Weekday())+7)%7
+			switch {
+			case wd.N > 0:
+				addDay(firstOfWeekday + 7*(wd.N-1))
+			case wd.N < 0:
+				last := firstOfWeekday + 7*((length-firstOfWeekday)/7)
+				addDay(last + 7*(wd.N+1))
+			default:
+				for d := firstOfWeekday; d <= length; d += 7 {
+					addDay(d)
+				}
+			}
+		}
+		if len(r.ByDay) == 0 {
+			addDay(dtstart.Day())
+		}
+	}
+	return days
+}
+
+// matchesFilters applies BYMONTH to every frequency, and BYDAY and BYMONTHDAY as filters where they do not expand the
+// period. A day must match every filter that applies.
+func (r *Rule) matchesFilters(day time.Time) bool {
+	if len(r.ByMonth) > 0 && r.Freq != Yearly && !containsMonth(r.ByMonth, day.Month()) {
+		return false
+	}
+	if len(r.ByDay) > 0 && (r.Freq == Daily || len(r.ByMonthDay) > 0) && !containsWeekday(r.ByDay, day.Weekday()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Daily && !containsMonthDay(r.ByMonthDay, day) {
+		return false
+	}
+	return true
+}
+
+func containsWeekday(days []WeekdayNum, weekday time.Weekday) bool {
+	for _, d := range days {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// containsMonthDay reports whether day is one of the days of its month, counting negative days from the end of the month
+func containsMonthDay(monthDays []int, day time.Time) bool {
+	length := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, d := range monthDays {
+		if d == day.Day() || (d < 0 && length+d+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMonth(months []time.Month, m time.Month) bool {
+	for _, month := range months {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(times []time.Time) []time.Time {
+	out := times[:0]
+	for i, t := range times {
+		if i == 0 || !t.Equal(times[i-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package rrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(date string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func formatTimes(times []time.Time) string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format("2006-01-02 15:04")
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=DAILY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=MONTHLY;BYDAY=6FR",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if r, err := Parse(rule); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", rule, r)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[string]string{
+		"RRULE:freq=monthly;bymonthday=-1;count=4":     "FREQ=MONTHLY;COUNT=4;BYMONTHDAY=-1",
+		"FREQ=WEEKLY;INTERVAL=1;BYDAY=TU,TH;WKST=MO":   "FREQ=WEEKLY;BYDAY=TU,TH",
+		"FREQ=YEARLY;BYDAY=4TH;BYMONTH=11":             "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+		"FREQ=DAILY;INTERVAL=2;UNTIL=20240205T120000Z": "FREQ=DAILY;INTERVAL=2;UNTIL=20240205T120000Z",
+	}
+	for rule, want := range tests {
+		r, err := Parse(rule)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", rule, err)
+			continue
+		}
+		if got := r.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", rule, got, want)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		rule    string
+		dtstart string
+		want    string
+	}{
+		{
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2024-01-30 10:00",
+			want:    "2024-01-30 10:00, 2024-01-31 10:00, 2024-02-01 10:00",
+		},
+		{
+			// a date UNTIL includes the whole day
+			rule:    "FREQ=DAILY;INTERVAL=2;UNTIL=20240205",
+			dtstart: "2024-02-01 18:00",
+			want:    "2024-02-01 18:00, 2024-02-03 18:00, 2024-02-05 18:00",
+		},
+		{
+			rule:    "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4",
+			dtstart: "2024-03-05 10:00",
+			want:    "2024-03-05 10:00, 2024-03-07 10:00, 2024-03-12 10:00, 2024-03-14 10:00",
+		},
+		{
+			rule:    "FREQ=WEEKLY;INTERVAL=2;UNTIL=20240402T100000Z",
+			dtstart: "2024-03-05 10:00",
+			want:    "2024-03-05 10:00, 2024-03-19 10:00, 2024-04-02 10:00",
+		},
+		{
+			// months without a 31st are skipped
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: "2024-01-31 19:30",
+			want:    "2024-01-31 19:30, 2024-03-31 19:30, 2024-05-31 19:30",
+		},
+		{
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4",
+			dtstart: "2024-01-31 19:30",
+			want:    "2024-01-31 19:30, 2024-02-29 19:30, 2024-03-31 19:30, 2024-04-30 19:30",
+		},
+		{
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: "2024-01-26 09:00",
+			want:    "2024-01-26 09:00, 2024-02-23 09:00, 2024-03-29 09:00",
+		},
+		{
+			rule:    "FREQ=YEARLY;COUNT=3",
+			dtstart: "2024-02-29 08:00",
+			want:    "2024-02-29 08:00, 2028-02-29 08:00, 2032-02-29 08:00",
+		},
+		{
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3",
+			dtstart: "2024-11-28 08:00",
+			want:    "2024-11-28 08:00, 2025-11-27 08:00, 2026-11-26 08:00",
+		},
+		{
+			rule:    "FREQ=DAILY;BYDAY=FR;BYMONTHDAY=13;COUNT=3",
+			dtstart: "2024-09-13 20:00",
+			want:    "2024-09-13 20:00, 2024-12-13 20:00, 2025-06-13 20:00",
+		},
+		{
+			rule:    "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3",
+			dtstart: "2024-09-13 20:00",
+			want:    "2024-09-13 20:00, 2024-12-13 20:00, 2025-06-13 20:00",
+		},
+		{
+			rule:    "FREQ=DAILY;BYMONTH=6;COUNT=3",
+			dtstart: "2024-05-30 07:00",
+			want:    "2024-05-30 07:00, 2024-06-01 07:00, 2024-06-02 07:00",
+		},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.rule)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.rule, err)
+			continue
+		}
+		dtstart := at(test.dtstart)
+		if got := formatTimes(r.Between(dtstart, dtstart, dtstart.AddDate(10, 0, 0))); got != test.want {
+			t.Errorf("%s from %s = %s, want %s", test.rule, test.dtstart, got, test.want)
+		}
+	}
+}
+
+func TestBetweenBounds(t *testing.T) {
+	dtstart := at("2024-03-05 10:00")
+	tests := []struct {
+		rule     string
+		from, to string
+		want     string
+	}{
+		// both ends are inclusive
+		{rule: "FREQ=WEEKLY", from: "2024-03-12 10:00", to: "2024-03-26 10:00", want: "2024-03-12 10:00, 2024-03-19 10:00, 2024-03-26 10:00"},
+		{rule: "FREQ=WEEKLY", from: "2024-03-12 10:01", to: "2024-03-26 09:59", want: "2024-03-19 10:00"},
+		{rule: "FREQ=WEEKLY", from: "2024-03-13 00:00", to: "2024-03-18 00:00", want: ""},
+		{rule: "FREQ=WEEKLY", from: "2024-01-01 00:00", to: "2024-03-05 10:00", want: "2024-03-05 10:00"},
+		// occurrences before the range still count towards COUNT
+		{rule: "FREQ=WEEKLY;COUNT=3", from: "2024-03-13 00:00", to: "2024-12-31 00:00", want: "2024-03-19 10:00"},
+		{rule: "FREQ=WEEKLY;COUNT=1", from: "2024-01-01 00:00", to: "2024-12-31 00:00", want: "2024-03-05 10:00"},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", test.rule, err)
+		}
+		if got := formatTimes(r.Between(dtstart, at(test.from), at(test.to))); got != test.want {
+			t.Errorf("%s between %s and %s = %s, want %s", test.rule, test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestBetweenKeepsLocalTime(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	r, err := Parse("FREQ=DAILY;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the clocks go forward on 31 March 2024
+	dtstart := time.Date(2024, 3, 30, 9, 0, 0, 0, london)
+	got := formatTimes(r.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0)))
+	if want := "2024-03-30 09:00, 2024-03-31 09:00, 2024-04-01 09:00"; got != want {
+		t.Errorf("daily from %v = %s, want %s", dtstart, got, want)
+	}
+}
+
+func TestIsOccurrence(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := at("2024-03-05 10:00")
+	for date, want := range map[string]bool{
+		"2024-03-05 10:00": true,
+		"2024-03-07 10:00": true,
+		"2024-03-14 10:00": true,
+		"2024-03-07 11:00": false, // not the time of the series
+		"2024-03-06 10:00": false, // not a listed weekday
+		"2024-03-19 10:00": false, // after COUNT
+		"2024-02-29 10:00": false, // before dtstart
+	} {
+		if got := r.IsOccurrence(dtstart, at(date)); got != want {
+			t.Errorf("IsOccurrence(%s) = %v, want %v", date, got, want)
+		}
+	}
+}
+
+func TestEachStops(t *testing.T) {
+	r, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := at("2024-01-01 10:00")
+	var got []time.Time
+	r.Each(dtstart, at("2024-01-03 00:00"), dtstart.AddDate(1, 0, 0), func(t time.Time) bool {
+		got = append(got, t)
+		return len(got) < 2
+	})
+	if want := "2024-01-03 10:00, 2024-01-04 10:00"; formatTimes(got) != want {
+		t.Errorf("Each visited %s, want %s", formatTimes(got), want)
+	}
+}
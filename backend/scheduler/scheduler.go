@@ -0,0 +1,40 @@
+// Package scheduler runs background jobs at a fixed interval inside the server process
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is a unit of background work. Jobs must be idempotent, since several server instances may run them at once.
+type Job func(ctx context.Context, now time.Time) error
+
+// Run runs a job immediately and then every interval until ctx is cancelled.
+// Errors and panics are logged so that one failed run does not stop later runs.
+func Run(ctx context.Context, log *logrus.Logger, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runOnce(ctx, log, name, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runOnce(ctx context.Context, log *logrus.Logger, name string, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Job %s panicked: %v\n", name, r)
+		}
+	}()
+
+	if err := job(ctx, time.Now()); err != nil {
+		log.Errorf("Job %s failed: %v\n", name, err)
+	}
+}
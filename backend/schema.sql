@@ -1,5 +1,5 @@
 -- Drop all tables
--- DROP TABLE IF EXISTS bookmarks, notifications, user_sessions, forum_moderation_log, private_messages, rsvps, events, routes, comments, posts, categories, users, roles CASCADE;
+-- DROP TABLE IF EXISTS email_preferences, category_follows, post_watches, bookmarks, notifications, calendar_tokens, stream_tickets, user_sessions, report_submissions, reports, forum_moderation_log, private_messages, event_reminders, rsvps, event_occurrences, events, route_points, routes, comment_votes, comments, post_votes, post_revisions, posts, categories, users, roles CASCADE;
 
 -- User Roles
 CREATE TABLE roles (
@@ -17,7 +17,11 @@ CREATE TABLE users (
   profile_picture TEXT,
   biography TEXT,
   last_login_date TIMESTAMP WITH TIME ZONE,
-  is_active BOOLEAN DEFAULT TRUE,
+  is_active BOOLEAN DEFAULT TRUE, -- FALSE while the user is suspended or banned
+  suspended_until TIMESTAMP WITH TIME ZONE, -- end of the user's suspension, NULL when inactive for a permanent ban
+  suspension_reason TEXT,
+  email_verified_at TIMESTAMP WITH TIME ZONE, -- NULL until the user follows the link emailed to their current address
+  verification_sent_date TIMESTAMP WITH TIME ZONE, -- when the latest verification email was sent, to throttle resends
   role_id INT REFERENCES roles(role_id) ON DELETE SET NULL
 );
 
@@ -33,21 +37,89 @@ CREATE TABLE posts (
   post_id SERIAL PRIMARY KEY,
   title VARCHAR(255) NOT NULL,
   content TEXT NOT NULL,
+  content_html TEXT, -- content rendered from Markdown, NULL until rendered
   creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
   user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
   is_sticky BOOLEAN DEFAULT FALSE,
   is_locked BOOLEAN DEFAULT FALSE,
   post_category_id INT REFERENCES categories(category_id),
-  additional_notes TEXT
+  additional_notes TEXT,
+  edited_at TIMESTAMP WITH TIME ZONE,
+  score INT NOT NULL DEFAULT 0, -- sum of post_votes
+  last_activity_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP -- creation of the post or its latest comment
+);
+
+-- Full-text search documents. They are indexed as expressions rather than stored in columns, which would be returned
+-- with every post and comment. Search queries call the same functions so that the indexes are used.
+CREATE OR REPLACE FUNCTION post_search_document(title TEXT, content TEXT) RETURNS TSVECTOR AS $$
+  SELECT setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION comment_search_document(content TEXT) RETURNS TSVECTOR AS $$
+  SELECT to_tsvector('english', content)
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE INDEX posts_search_document_idx ON posts USING GIN (post_search_document(title, content));
+
+-- Post listings put sticky posts first and then sort by creation date, score or latest activity, see GetAllPostsNew in
+-- query.sql. Category listings have their own indexes, while a user's posts are few enough to sort after filtering.
+CREATE INDEX posts_new_idx ON posts ((COALESCE(is_sticky, FALSE)), creation_date, post_id);
+CREATE INDEX posts_top_idx ON posts ((COALESCE(is_sticky, FALSE)), score, post_id);
+CREATE INDEX posts_active_idx ON posts ((COALESCE(is_sticky, FALSE)), (COALESCE(last_activity_date, creation_date)), post_id);
+CREATE INDEX posts_category_new_idx ON posts (post_category_id, (COALESCE(is_sticky, FALSE)), creation_date, post_id);
+CREATE INDEX posts_category_top_idx ON posts (post_category_id, (COALESCE(is_sticky, FALSE)), score, post_id);
+CREATE INDEX posts_category_active_idx ON posts (post_category_id, (COALESCE(is_sticky, FALSE)), (COALESCE(last_activity_date, creation_date)), post_id);
+CREATE INDEX posts_user_id_idx ON posts (user_id);
+
+-- Post revisions, numbered from 1 for each post. Revision 1 is the original post.
+CREATE TABLE post_revisions (
+  revision_id SERIAL PRIMARY KEY,
+  post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+  revision_number INT NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  content TEXT NOT NULL,
+  post_category_id INT REFERENCES categories(category_id),
+  additional_notes TEXT,
+  editor_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (post_id, revision_number)
 );
 
 -- Comments
 CREATE TABLE comments (
   comment_id SERIAL PRIMARY KEY,
   content TEXT NOT NULL,
+  content_html TEXT, -- content rendered from Markdown, NULL until rendered
   creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
   post_id INT REFERENCES posts(post_id) ON DELETE CASCADE,
-  user_id INT REFERENCES users(user_id) ON DELETE SET NULL
+  user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+  score INT NOT NULL DEFAULT 0, -- sum of comment_votes
+  parent_comment_id INT REFERENCES comments(comment_id) ON DELETE CASCADE, -- NULL for top level comments
+  root_comment_id INT REFERENCES comments(comment_id) ON DELETE CASCADE, -- top level comment of the thread, NULL for top level comments
+  depth INT NOT NULL DEFAULT 0, -- 0 for top level comments
+  is_deleted BOOLEAN NOT NULL DEFAULT FALSE -- deleted comments with replies are kept as placeholders
+);
+
+CREATE INDEX comments_search_document_idx ON comments USING GIN (comment_search_document(content));
+CREATE INDEX comments_parent_comment_id_idx ON comments (parent_comment_id);
+CREATE INDEX comments_root_comment_id_idx ON comments (root_comment_id);
+
+-- Post votes, one per user. value is 1 for an upvote and -1 for a downvote.
+CREATE TABLE post_votes (
+  post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+  user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (post_id, user_id)
+);
+
+-- Comment votes, one per user. value is 1 for an upvote and -1 for a downvote.
+CREATE TABLE comment_votes (
+  comment_id INT NOT NULL REFERENCES comments(comment_id) ON DELETE CASCADE,
+  user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (comment_id, user_id)
 );
 
 -- Routes
@@ -63,6 +135,16 @@ CREATE TABLE routes (
   user_id INT REFERENCES users(user_id) ON DELETE CASCADE
 );
 
+-- Route Points, the GPX track of a route
+CREATE TABLE route_points (
+  route_id INT NOT NULL REFERENCES routes(route_id) ON DELETE CASCADE,
+  point_index INT NOT NULL,
+  latitude FLOAT NOT NULL,
+  longitude FLOAT NOT NULL,
+  elevation FLOAT,
+  PRIMARY KEY (route_id, point_index)
+);
+
 -- Events
 CREATE TABLE events (
   event_id SERIAL PRIMARY KEY,
@@ -71,7 +153,22 @@ CREATE TABLE events (
   event_date TIMESTAMP WITH TIME ZONE NOT NULL,
   meeting_point TEXT NOT NULL,
   route_id INT REFERENCES routes(route_id) ON DELETE SET NULL,
-  creator_user_id INT REFERENCES users(user_id) ON DELETE SET NULL
+  creator_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+  capacity INT CHECK (capacity > 0),
+  recurrence_rule TEXT, -- RFC 5545 RRULE with event_date as the first occurrence, NULL for one-off events
+  time_zone TEXT NOT NULL DEFAULT 'UTC' -- IANA time zone the recurrence rule is expanded in, so occurrences keep their local start time
+);
+
+-- Changes to single occurrences of recurring events, keyed by the occurrence's original start time
+CREATE TABLE event_occurrences (
+  event_id INT NOT NULL REFERENCES events(event_id) ON DELETE CASCADE,
+  occurrence_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  is_cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+  title VARCHAR(255),
+  description TEXT,
+  event_date TIMESTAMP WITH TIME ZONE,
+  meeting_point TEXT,
+  PRIMARY KEY (event_id, occurrence_date)
 );
 
 -- RSVPs
@@ -79,8 +176,21 @@ CREATE TABLE rsvps (
   rsvp_id SERIAL PRIMARY KEY,
   event_id INT REFERENCES events(event_id) ON DELETE CASCADE,
   user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
-  rsvp_status VARCHAR(255) NOT NULL,
-  rsvp_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+  rsvp_status VARCHAR(255) NOT NULL CHECK (rsvp_status IN ('going', 'maybe', 'declined', 'waitlisted', 'attended')),
+  rsvp_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  occurrence_date TIMESTAMP WITH TIME ZONE, -- original start of the occurrence of a recurring event, NULL for one-off events
+  UNIQUE NULLS NOT DISTINCT (event_id, user_id, occurrence_date)
+);
+
+-- Event reminders already sent, so that each reminder is only sent once
+CREATE TABLE event_reminders (
+  reminder_id SERIAL PRIMARY KEY,
+  event_id INT NOT NULL REFERENCES events(event_id) ON DELETE CASCADE,
+  occurrence_date TIMESTAMP WITH TIME ZONE, -- NULL for one-off events
+  user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  reminder_type VARCHAR(255) NOT NULL,
+  sent_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE NULLS NOT DISTINCT (event_id, occurrence_date, user_id, reminder_type)
 );
 
 -- Private Messages
@@ -90,9 +200,13 @@ CREATE TABLE private_messages (
   sender_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
   receiver_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
   sent_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-  is_read BOOLEAN DEFAULT FALSE
+  is_read BOOLEAN DEFAULT FALSE,
+  delivered_date TIMESTAMP WITH TIME ZONE -- when the message first reached a chat connection of its receiver
 );
 
+CREATE INDEX private_messages_sender_receiver_idx ON private_messages (sender_user_id, receiver_user_id, message_id);
+CREATE INDEX private_messages_receiver_sender_idx ON private_messages (receiver_user_id, sender_user_id, message_id);
+
 -- Forum Moderation Log
 CREATE TABLE forum_moderation_log (
   log_id SERIAL PRIMARY KEY,
@@ -100,11 +214,42 @@ CREATE TABLE forum_moderation_log (
   action_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
   moderator_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
   affected_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
-  post_id INT REFERENCES posts(post_id) ON DELETE SET NULL,
-  comment_id INT REFERENCES comments(comment_id) ON DELETE SET NULL,
+  post_id INT, -- not a foreign key, so that entries keep referring to deleted posts
+  comment_id INT, -- not a foreign key, so that entries keep referring to deleted comments
   reason TEXT
 );
 
+-- Reports, one per reported target while it is open or in review. Further reports of the same target are
+-- aggregated onto it as submissions until it is resolved.
+CREATE TABLE reports (
+  report_id SERIAL PRIMARY KEY,
+  target_type VARCHAR(255) NOT NULL CHECK (target_type IN ('post', 'comment', 'message', 'user')),
+  target_id INT NOT NULL, -- not a foreign key, so that reports outlive the content they are about
+  target_user_id INT REFERENCES users(user_id) ON DELETE SET NULL, -- author of the reported content, or the reported user
+  status VARCHAR(255) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_review', 'actioned', 'dismissed')),
+  submission_count INT NOT NULL DEFAULT 0,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  last_reported_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  reviewer_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+  resolution_action VARCHAR(255), -- moderation log action taken when the report was actioned, if any
+  resolution_note TEXT,
+  resolution_date TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX reports_unresolved_target_idx ON reports (target_type, target_id) WHERE status IN ('open', 'in_review');
+CREATE INDEX reports_status_idx ON reports (status, last_reported_date);
+
+-- Report submissions, one per user per report
+CREATE TABLE report_submissions (
+  submission_id SERIAL PRIMARY KEY,
+  report_id INT NOT NULL REFERENCES reports(report_id) ON DELETE CASCADE,
+  reporter_user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+  reason_category VARCHAR(255) NOT NULL CHECK (reason_category IN ('spam', 'harassment', 'hate', 'sexual', 'violence', 'misinformation', 'other')),
+  note TEXT,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (report_id, reporter_user_id)
+);
+
 CREATE TABLE user_sessions (
   session_id UUID PRIMARY KEY,
   user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
@@ -114,18 +259,92 @@ CREATE TABLE user_sessions (
   user_agent TEXT
 );
 
+-- Single-use tickets that open a stream or chat WebSocket for a session. Browsers cannot send the session header on
+-- these connections, and a short-lived ticket in their URL does not leak the session into logs and browser history.
+CREATE TABLE stream_tickets (
+  ticket UUID PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES user_sessions(session_id) ON DELETE CASCADE,
+  expiry_date TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Calendar feed tokens
+CREATE TABLE calendar_tokens (
+  user_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+  token UUID UNIQUE NOT NULL,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Notifications
 CREATE TABLE notifications (
   notification_id SERIAL PRIMARY KEY,
   user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
   content TEXT NOT NULL,
   creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-  is_read BOOLEAN DEFAULT FALSE
+  is_read BOOLEAN DEFAULT FALSE,
+  notification_type VARCHAR(255) NOT NULL DEFAULT 'general',
+  actor_user_id INT REFERENCES users(user_id) ON DELETE SET NULL, -- the user whose action caused the notification
+  -- what the notification is about, not foreign keys so that notifications can refer to deleted content
+  post_id INT,
+  comment_id INT,
+  message_id INT,
+  event_id INT
 );
 
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, creation_date);
+
 -- Bookmarks
 CREATE TABLE bookmarks (
   bookmark_id SERIAL PRIMARY KEY,
   user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
   post_id INT REFERENCES posts(post_id) ON DELETE SET NULL
 );
+
+-- Post watches. Watchers get new comments on the post through the real-time stream.
+CREATE TABLE post_watches (
+  user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  post_id INT NOT NULL REFERENCES posts(post_id) ON DELETE CASCADE,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX post_watches_post_id_idx ON post_watches (post_id);
+
+-- Category follows. New posts in followed categories are summarised in digest emails.
+CREATE TABLE category_follows (
+  user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  category_id INT NOT NULL REFERENCES categories(category_id) ON DELETE CASCADE,
+  creation_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, category_id)
+);
+
+-- Email preferences, created the first time a user's preferences are read or a digest is sent to them. Users without
+-- a row get weekly digests. The token identifies the user in unsubscribe links, which work without logging in.
+CREATE TABLE email_preferences (
+  user_id INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+  digest_frequency VARCHAR(16) NOT NULL DEFAULT 'weekly' CHECK (digest_frequency IN ('off', 'daily', 'weekly')),
+  unsubscribe_token UUID UNIQUE NOT NULL,
+  last_digest_date TIMESTAMP WITH TIME ZONE -- when the latest digest was sent, NULL if none has been
+);
+
+-- Real-time stream. New notifications, private messages and comments are announced on the stream channel with
+-- NOTIFY, so that every server instance can push them to its connected users. Suspending or banning a user announces
+-- a revoke event, which closes their open streams and chat connections. See the stream package.
+CREATE OR REPLACE FUNCTION notify_stream() RETURNS trigger AS $$
+BEGIN
+  IF TG_TABLE_NAME = 'notifications' THEN
+    PERFORM pg_notify('stream', json_build_object('type', 'notification', 'id', NEW.notification_id, 'user_ids', json_build_array(NEW.user_id))::text);
+  ELSIF TG_TABLE_NAME = 'private_messages' THEN
+    PERFORM pg_notify('stream', json_build_object('type', 'message', 'id', NEW.message_id, 'user_ids', json_build_array(NEW.sender_user_id, NEW.receiver_user_id))::text);
+  ELSIF TG_TABLE_NAME = 'comments' THEN
+    PERFORM pg_notify('stream', json_build_object('type', 'comment', 'id', NEW.comment_id, 'post_id', NEW.post_id)::text);
+  ELSIF TG_TABLE_NAME = 'users' THEN
+    PERFORM pg_notify('stream', json_build_object('type', 'revoke', 'user_ids', json_build_array(NEW.user_id))::text);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notifications_notify_stream AFTER INSERT ON notifications FOR EACH ROW EXECUTE FUNCTION notify_stream();
+CREATE TRIGGER private_messages_notify_stream AFTER INSERT ON private_messages FOR EACH ROW EXECUTE FUNCTION notify_stream();
+CREATE TRIGGER comments_notify_stream AFTER INSERT ON comments FOR EACH ROW EXECUTE FUNCTION notify_stream();
+CREATE TRIGGER users_notify_stream AFTER UPDATE OF is_active ON users FOR EACH ROW WHEN (NEW.is_active = FALSE) EXECUTE FUNCTION notify_stream();
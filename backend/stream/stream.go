@@ -0,0 +1,191 @@
+// Package stream fans out real-time events to the users connected to every server instance.
+// Events are announced with PostgreSQL NOTIFY on the stream channel, and each instance LISTENs on one pooled connection.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Channel is the PostgreSQL notification channel events are announced on
+const Channel = "stream"
+
+// Event types. The stored ones are announced by the notify_stream trigger in schema.sql.
+const (
+	EventNotification = "notification"
+	EventMessage      = "message"
+	EventComment      = "comment"
+	// EventRevoke is announced by the trigger when users are suspended or banned, and closes their subscriptions
+	EventRevoke = "revoke"
+)
+
+// subscriptionBuffer is the number of events a subscription can fall behind by before it is closed
+const subscriptionBuffer = 64
+
+// retryInterval is how long Listen waits before listening again after losing its connection
+const retryInterval = 5 * time.Second
+
+// Event announces that something a user may want to see has happened.
+// Events only carry IDs, so that payloads stay within the NOTIFY size limit and receivers fetch what they are allowed to see.
+type Event struct {
+	Type    string          `json:"type"`
+	ID      int32           `json:"id"`
+	UserIDs []int32         `json:"user_ids,omitempty"` // the users the event is for
+	PostID  int32           `json:"post_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"` // for events that are not stored, such as typing indicators
+}
+
+// Resolver fills in the users an event is for when they are not part of its payload
+type Resolver func(ctx context.Context, event *Event) error
+
+// Subscription receives the events for one connected user
+type Subscription struct {
+	UserID int32
+	Events <-chan Event
+	// Lagged is closed when the subscription fell too far behind and stopped receiving events.
+	// Clients should reconnect and resume from the last event they received.
+	Lagged <-chan struct{}
+	// Revoked is closed when the user was suspended or banned and the subscription stopped receiving events.
+	// Connections should be closed, and the user is refused if they reconnect.
+	Revoked <-chan struct{}
+
+	events  chan Event
+	lagged  chan struct{}
+	revoked chan struct{}
+}
+
+// Hub delivers events received from PostgreSQL to the subscriptions of this server instance
+type Hub struct {
+	pool *pgxpool.Pool
+	log  *logrus.Logger
+
+	mu   sync.Mutex
+	subs map[int32]map[*Subscription]struct{}
+}
+
+func NewHub(pool *pgxpool.Pool, log *logrus.Logger) *Hub {
+	return &Hub{pool: pool, log: log, subs: make(map[int32]map[*Subscription]struct{})}
+}
+
+// Subscribe starts receiving the events for a user. Subscriptions must be closed with Unsubscribe.
+func (h *Hub) Subscribe(userID int32) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	lagged := make(chan struct{})
+	revoked := make(chan struct{})
+	sub := &Subscription{
+		UserID:  userID,
+		Events:  events,
+		Lagged:  lagged,
+		Revoked: revoked,
+		events:  events,
+		lagged:  lagged,
+		revoked: revoked,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[sub.UserID], sub)
+	if len(h.subs[sub.UserID]) == 0 {
+		delete(h.subs, sub.UserID)
+	}
+}
+
+// Publish announces an event to every server instance
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = h.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload))
+	return err
+}
+
+// Listen receives events until ctx is cancelled, delivering each to the subscriptions of the users it is for.
+// A lost connection is retried, and clients resume from their last event when the events missed meanwhile are stored.
+func (h *Hub) Listen(ctx context.Context, resolve Resolver) {
+	for {
+		if err := h.listen(ctx, resolve); err != nil && ctx.Err() == nil {
+			h.log.Errorf("Unable to listen for stream events: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context, resolve Resolver) error {
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the connection is left in LISTEN mode, so it is closed rather than returned to the pool
+	defer conn.Hijack().Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			h.log.Errorf("Invalid stream event %q: %v\n", notification.Payload, err)
+			continue
+		}
+		if len(event.UserIDs) == 0 && resolve != nil {
+			if err := resolve(ctx, &event); err != nil {
+				h.log.Errorf("Unable to resolve stream event: %v\n", err)
+				continue
+			}
+		}
+		h.deliver(event)
+	}
+}
+
+// deliver sends an event to the subscriptions of its users, closing the ones that have fallen behind.
+// Revoke events close every subscription of their users instead.
+func (h *Hub) deliver(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range event.UserIDs {
+		if event.Type == EventRevoke {
+			for sub := range h.subs[userID] {
+				close(sub.revoked)
+			}
+			delete(h.subs, userID)
+			continue
+		}
+		for sub := range h.subs[userID] {
+			select {
+			case sub.events <- event:
+			default:
+				close(sub.lagged)
+				delete(h.subs[userID], sub)
+			}
+		}
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+	}
+}
@@ -1,5 +1,6 @@
 import { instance } from "../lib/axiosinstance";
-import { useMutation, useQuery } from "@tanstack/react-query";
+import { useInfiniteQuery, useMutation } from "@tanstack/react-query";
+import { Page } from "../lib/page";
 import {
   Card,
   CardContent,
@@ -8,6 +9,7 @@ import {
   IconButton,
   Divider,
   Box,
+  Button,
 } from "@mui/material";
 import { queryClient } from "../main";
 import DeleteIcon from "@mui/icons-material/Delete";
@@ -16,23 +18,38 @@ import { useStore } from "../lib/store";
 interface Comment {
   CommentID: number;
   Content: string;
+  ContentHtml: string | null;
   CreationDate: string;
   PostID: number;
-  UserID: number;
-  Username: string;
+  UserID: number | null;
+  Username: string | null;
+  ParentCommentID: number | null;
+  IsDeleted: boolean;
+  ReplyCount: number;
 }
 
 export default function CommentCards({ postId }: { postId: string }) {
   const { userId, isLoggedIn } = useStore();
 
-  const { isLoading, data, isError, error } = useQuery({
+  const {
+    isLoading,
+    data,
+    isError,
+    error,
+    fetchNextPage,
+    hasNextPage,
+    isFetchingNextPage,
+  } = useInfiniteQuery({
     queryKey: ["post", postId, "comments"],
-    queryFn: async () => {
-      const response = await instance.get<Comment[]>(
-        `/comments/post/${postId}`
+    queryFn: async ({ pageParam }) => {
+      const response = await instance.get<Page<Comment>>(
+        `/comments/post/${postId}`,
+        { params: pageParam ? { cursor: pageParam } : undefined }
       );
       return response.data;
     },
+    initialPageParam: null as string | null,
+    getNextPageParam: (lastPage) => lastPage.next_cursor,
   });
 
   const deleteCommentMutation = useMutation({
@@ -61,7 +78,7 @@ export default function CommentCards({ postId }: { postId: string }) {
 
   return (
     <Stack spacing={2}>
-      {data.map((comment) => (
+      {data.pages.flatMap((page) => page.items).map((comment) => (
         <Card key={comment.CommentID} sx={{}}>
           <CardContent
             sx={{
@@ -71,9 +88,22 @@ export default function CommentCards({ postId }: { postId: string }) {
               alignItems: "center",
             }}
           >
-            <Typography variant="body1" color="textSecondary" component="p">
-              {comment.Content}
-            </Typography>
+            {comment.IsDeleted ? (
+              <Typography variant="body1" color="textSecondary" component="p">
+                <i>[deleted]</i>
+              </Typography>
+            ) : comment.ContentHtml ? (
+              <Typography
+                variant="body1"
+                color="textSecondary"
+                component="div"
+                dangerouslySetInnerHTML={{ __html: comment.ContentHtml }}
+              />
+            ) : (
+              <Typography variant="body1" color="textSecondary" component="p">
+                {comment.Content}
+              </Typography>
+            )}
             {isLoggedIn && userId === comment.UserID ? (
               <IconButton
                 color="secondary"
@@ -103,6 +133,14 @@ export default function CommentCards({ postId }: { postId: string }) {
           </Box>
         </Card>
       ))}
+      {hasNextPage ? (
+        <Button
+          onClick={() => fetchNextPage()}
+          disabled={isFetchingNextPage}
+        >
+          {isFetchingNextPage ? "Loading..." : "Load more"}
+        </Button>
+      ) : null}
     </Stack>
   );
 }
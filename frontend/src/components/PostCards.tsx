@@ -1,5 +1,6 @@
 import {
   Box,
+  Button,
   Card,
   CardActionArea,
   CardContent,
@@ -9,8 +10,9 @@ import {
   Typography,
 } from "@mui/material";
 import { Link } from "react-router-dom";
-import { useMutation, useQuery } from "@tanstack/react-query";
+import { useInfiniteQuery, useMutation } from "@tanstack/react-query";
 import { instance } from "../lib/axiosinstance";
+import { Page } from "../lib/page";
 import { queryClient } from "../main";
 import DeleteIcon from "@mui/icons-material/Delete";
 import { useStore } from "../lib/store";
@@ -19,26 +21,40 @@ export type Post = {
   PostID: number;
   Title: string;
   Content: string;
+  ContentHtml: string | null;
   CreationDate: string;
   UserID: number;
   IsSticky: boolean;
   IsLocked: boolean;
   PostCategoryID: number;
   AdditionalNotes: string | null;
+  EditedAt: string | null;
   Username: string;
 };
 
-async function getAllPosts() {
-  const response = await instance.get<Post[]>("/posts");
+async function getPosts(cursor: string | null) {
+  const response = await instance.get<Page<Post>>("/posts", {
+    params: cursor ? { cursor } : undefined,
+  });
   return response.data;
 }
 
 export default function PostCards() {
   const { userId, isLoggedIn } = useStore();
 
-  const { isLoading, data, isError, error } = useQuery({
+  const {
+    isLoading,
+    data,
+    isError,
+    error,
+    fetchNextPage,
+    hasNextPage,
+    isFetchingNextPage,
+  } = useInfiniteQuery({
     queryKey: ["posts"],
-    queryFn: getAllPosts,
+    queryFn: ({ pageParam }) => getPosts(pageParam),
+    initialPageParam: null as string | null,
+    getNextPageParam: (lastPage) => lastPage.next_cursor,
   });
 
   const deletePostMutation = useMutation({
@@ -67,7 +83,7 @@ export default function PostCards() {
 
   return (
     <Stack spacing="1rem">
-      {data.map((post) => (
+      {data.pages.flatMap((page) => page.items).map((post) => (
         <Card key={post.PostID}>
           <CardActionArea component={Link} to={`/posts/${post.PostID}`}>
             <CardContent>
@@ -113,6 +129,14 @@ export default function PostCards() {
           </Box>
         </Card>
       ))}
+      {hasNextPage ? (
+        <Button
+          onClick={() => fetchNextPage()}
+          disabled={isFetchingNextPage}
+        >
+          {isFetchingNextPage ? "Loading..." : "Load more"}
+        </Button>
+      ) : null}
     </Stack>
   );
 }
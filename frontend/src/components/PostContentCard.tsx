@@ -37,9 +37,23 @@ export default function PostContentCard({ postId }: { postId: string }) {
         <Typography variant="h4" component="h2">
           {data.Title}
         </Typography>
-        <Typography variant="body2" color="textSecondary" component="p">
-          {data.Content}
-        </Typography>
+        {data.ContentHtml ? (
+          <Typography
+            variant="body2"
+            color="textSecondary"
+            component="div"
+            dangerouslySetInnerHTML={{ __html: data.ContentHtml }}
+          />
+        ) : (
+          <Typography variant="body2" color="textSecondary" component="p">
+            {data.Content}
+          </Typography>
+        )}
+        {data.EditedAt ? (
+          <Typography variant="caption" color="textSecondary">
+            Edited {new Date(data.EditedAt).toLocaleString()}
+          </Typography>
+        ) : null}
       </CardContent>
     </Card>
   );
@@ -0,0 +1,5 @@
+// Page is the envelope returned by paginated list endpoints
+export type Page<T> = {
+  items: T[];
+  next_cursor: string | null;
+};